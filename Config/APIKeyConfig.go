@@ -0,0 +1,44 @@
+package Config
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+)
+
+const (
+	// APIKeyHeader carries an API key; "Authorization: Bearer" works too.
+	APIKeyHeader = "X-API-Key"
+	// apiKeyMarker starts every API key, telling it apart from JWTs and
+	// making leaked keys easy to search for.
+	apiKeyMarker = "lib_"
+	// apiKeyPrefixLength covers the marker and the random key ID after it.
+	apiKeyPrefixLength = len(apiKeyMarker) + 8
+)
+
+var apiKeyIDEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewAPIKey returns a random key of the form lib_<id>_<secret> together with
+// its prefix lib_<id>, which is stored in clear to find the key again.
+func NewAPIKey() (key, prefix string, err error) {
+	id := make([]byte, 5)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret, err := NewSecretToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix = apiKeyMarker + strings.ToLower(apiKeyIDEncoding.EncodeToString(id))
+	return prefix + "_" + secret, prefix, nil
+}
+
+// APIKeyPrefix returns the prefix of key, and false when key does not look
+// like an API key at all.
+func APIKeyPrefix(key string) (string, bool) {
+	if !strings.HasPrefix(key, apiKeyMarker) || len(key) <= apiKeyPrefixLength+1 || key[apiKeyPrefixLength] != '_' {
+		return "", false
+	}
+	return key[:apiKeyPrefixLength], true
+}
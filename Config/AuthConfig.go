@@ -1,18 +1,178 @@
 package Config
 
 import (
+	"awesomeProject/Model"
+	"errors"
 	"github.com/golang-jwt/jwt/v5"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 )
 
-func GenerateJWT() (string, error) {
+const (
+	// TokenTTL is how long an access token is valid.
+	TokenTTL = time.Hour
+	// EmailVerificationTTL is how long a verification link stays valid.
+	EmailVerificationTTL = 24 * time.Hour
+	// LoginChallengeTTL is how long a user has to enter their second factor
+	// after the password was accepted.
+	LoginChallengeTTL = 5 * time.Minute
+	// OIDCFlowTTL is how long a user has to log in at the identity
+	// provider.
+	OIDCFlowTTL = 10 * time.Minute
+)
+
+// Each purpose signs with a key of its own, so no token can be replayed as
+// another kind, least of all as a Bearer token.
+var (
+	emailVerificationKey = []byte("SUPER_SECRET_KEY/email-verification")
+	loginChallengeKey    = []byte("SUPER_SECRET_KEY/login-challenge")
+	oidcFlowKey          = []byte("SUPER_SECRET_KEY/oidc-flow")
+)
+
+// GenerateJWT issues an access token for user. tokenID becomes its jti
+// claim, which names the session the token belongs to. The role claim is
+// not checked against the database, so a new role only applies to tokens
+// issued after the TokenVersion bump that revokes the old ones.
+func GenerateJWT(clock Clock, user Model.UserModel, tokenID string) (string, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 
-	expirationTime := time.Now().Add(1 * time.Hour).Unix()
+	expirationTime := clock.Now().Add(TokenTTL).Unix()
 	token.Claims = jwt.MapClaims{
-		"exp": expirationTime,
+		"sub":  user.UserId,
+		"role": user.Role,
+		"ver":  user.TokenVersion,
+		"jti":  tokenID,
+		"exp":  expirationTime,
 	}
 
 	secretKey := []byte("SUPER_SECRET_KEY")
 	return token.SignedString(secretKey)
 }
+
+// GenerateEmailVerificationToken signs the address being verified, so the
+// token stops working once the user no longer has or asks for it.
+func GenerateEmailVerificationToken(clock Clock, userID int, email string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   userID,
+		"email": email,
+		"exp":   clock.Now().Add(EmailVerificationTTL).Unix(),
+	})
+	return token.SignedString(emailVerificationKey)
+}
+
+// ParseEmailVerificationToken returns the user ID and address signed into
+// tokenString, failing when it is malformed, forged or expired.
+func ParseEmailVerificationToken(clock Clock, tokenString string) (int, string, error) {
+	claims, err := parseSignedClaims(clock, emailVerificationKey, tokenString)
+	if err != nil {
+		return 0, "", err
+	}
+
+	userID, _ := claims["sub"].(float64)
+	email, _ := claims["email"].(string)
+	if userID == 0 || email == "" {
+		return 0, "", errors.New("verification token lacks subject or email")
+	}
+	return int(userID), email, nil
+}
+
+// GenerateLoginChallenge vouches that user passed the password step. It
+// carries the token version, so a password change voids pending challenges.
+func GenerateLoginChallenge(clock Clock, user Model.UserModel) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": user.UserId,
+		"ver": user.TokenVersion,
+		"exp": clock.Now().Add(LoginChallengeTTL).Unix(),
+	})
+	return token.SignedString(loginChallengeKey)
+}
+
+// ParseLoginChallenge returns the user ID and token version signed into a
+// challenge, failing when it is malformed, forged or expired.
+func ParseLoginChallenge(clock Clock, tokenString string) (int, int, error) {
+	claims, err := parseSignedClaims(clock, loginChallengeKey, tokenString)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	userID, _ := claims["sub"].(float64)
+	version, _ := claims["ver"].(float64)
+	if userID == 0 {
+		return 0, 0, errors.New("login challenge lacks subject")
+	}
+	return int(userID), int(version), nil
+}
+
+// GenerateOIDCFlowToken signs flow so it can be parked in a cookie until
+// the provider redirects back.
+func GenerateOIDCFlowToken(clock Clock, flow OIDCFlow) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"state":    flow.State,
+		"nonce":    flow.Nonce,
+		"verifier": flow.Verifier,
+		"exp":      clock.Now().Add(OIDCFlowTTL).Unix(),
+	})
+	return token.SignedString(oidcFlowKey)
+}
+
+// ParseOIDCFlowToken returns the flow signed into tokenString, failing when
+// it is malformed, forged or expired.
+func ParseOIDCFlowToken(clock Clock, tokenString string) (OIDCFlow, error) {
+	claims, err := parseSignedClaims(clock, oidcFlowKey, tokenString)
+	if err != nil {
+		return OIDCFlow{}, err
+	}
+
+	var flow OIDCFlow
+	flow.State, _ = claims["state"].(string)
+	flow.Nonce, _ = claims["nonce"].(string)
+	flow.Verifier, _ = claims["verifier"].(string)
+	if flow.State == "" || flow.Nonce == "" || flow.Verifier == "" {
+		return OIDCFlow{}, errors.New("incomplete login flow")
+	}
+	return flow, nil
+}
+
+// parseSignedClaims verifies an HS256 token signed with key that must carry
+// an expiry.
+func parseSignedClaims(clock Clock, key []byte, tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithTimeFunc(clock.Now), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// AppBaseURL is the externally visible root of the API, used to build links
+// sent by mail. It reads APP_BASE_URL and defaults to the local server.
+func AppBaseURL() string {
+	base := os.Getenv("APP_BASE_URL")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return strings.TrimSuffix(base, "/")
+}
+
+// PasswordResetPageURL is where a frontend of its own lets users choose a new
+// password, read from PASSWORD_RESET_URL. It is empty when the API serves its
+// own form.
+func PasswordResetPageURL() string {
+	return os.Getenv("PASSWORD_RESET_URL")
+}
+
+// AppURL joins path and query onto AppBaseURL.
+func AppURL(path string, query url.Values) string {
+	link := AppBaseURL() + path
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	return link
+}
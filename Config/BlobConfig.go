@@ -0,0 +1,158 @@
+package Config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrBlobNotFound is returned by BlobStore.Get for keys nothing was stored
+// under.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps binary objects, such as cover images, under slash-separated
+// keys. Implementations must be safe for concurrent use.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStore builds the store selected by BLOB_STORE:
+//
+//   - "file" (default) keeps each blob as a file below BLOB_STORE_DIR
+//     (default "blobs");
+//   - "memory" keeps blobs in the process, for tests.
+func NewBlobStore() (BlobStore, error) {
+	switch kind := os.Getenv("BLOB_STORE"); kind {
+	case "", "file":
+		dir := os.Getenv("BLOB_STORE_DIR")
+		if dir == "" {
+			dir = "blobs"
+		}
+		slog.Info("Blobs are stored in files", "dir", dir)
+		return NewFileBlobStore(dir), nil
+	case "memory":
+		return NewMemoryBlobStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown BLOB_STORE %q", kind)
+	}
+}
+
+// FileBlobStore keeps every blob in its own file below a directory.
+type FileBlobStore struct {
+	dir string
+}
+
+func NewFileBlobStore(dir string) *FileBlobStore {
+	return &FileBlobStore{dir: dir}
+}
+
+func (s *FileBlobStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Write next to the target and rename, so readers never see half a
+	// blob.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return data, err
+}
+
+func (s *FileBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path maps key to a file below the store's directory, refusing keys that
+// would escape it.
+func (s *FileBlobStore) path(key string) (string, error) {
+	name := filepath.FromSlash(key)
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.dir, name), nil
+}
+
+// MemoryBlobStore keeps blobs in memory so tests can inspect them.
+type MemoryBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func NewMemoryBlobStore() *MemoryBlobStore {
+	return &MemoryBlobStore{blobs: map[string][]byte{}}
+}
+
+func (s *MemoryBlobStore) Put(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *MemoryBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, ErrBlobNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *MemoryBlobStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blobs, key)
+	return nil
+}
+
+// Keys returns every key holding a blob, in no particular order.
+func (s *MemoryBlobStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.blobs))
+	for key := range s.blobs {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -2,45 +2,210 @@ package Config
 
 import (
 	"awesomeProject/Model"
+	"fmt"
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
 )
 
-func InitializeDatabase() *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+// InitializeDatabase opens the database file at DATABASE_PATH, or an
+// in-memory database that is lost on exit when it is unset, and seeds it
+// if it is new, dating the seeded records by clock.
+func InitializeDatabase(clock Clock) *gorm.DB {
+	dsn := os.Getenv("DATABASE_PATH")
+	if dsn == "" {
+		dsn = ":memory:"
+	}
+	db, err := OpenDatabase(dsn)
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("Failed to initialize database", "error", err)
+		os.Exit(1)
 	}
 
-	db.AutoMigrate(&Model.UserModel{})
-	db.AutoMigrate(&Model.BookModel{})
+	slog.Info("Database successfully initialized")
+	insertBooks(db, clock)
+	insertAdmin(db, clock)
 
-	log.Println("Database successfully initialized")
-	insertBooks(db)
+	return db
+}
 
+// OpenImportDatabase opens the database file at DATABASE_PATH for the import
+// command, without seeding it. It exits when DATABASE_PATH is unset, since an
+// import into an in-memory database would be lost on exit.
+func OpenImportDatabase() *gorm.DB {
+	dsn := os.Getenv("DATABASE_PATH")
+	if dsn == "" {
+		slog.Error("Set DATABASE_PATH to the database to import into")
+		os.Exit(1)
+	}
+	db, err := OpenDatabase(dsn)
+	if err != nil {
+		slog.Error("Failed to open database", "path", dsn, "error", err)
+		os.Exit(1)
+	}
 	return db
 }
 
-func insertBooks(db *gorm.DB) {
+// OpenDatabase opens the SQLite database at dsn, instruments it and migrates
+// the schema, without seeding any data.
+func OpenDatabase(dsn string) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(withPragmas(dsn)), &gorm.Config{
+		TranslateError: true,
+		Logger: logger.New(log.New(os.Stderr, "", log.LstdFlags), logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  logger.Warn,
+			IgnoreRecordNotFoundError: true,
+		}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if dsn == ":memory:" {
+		// Every connection to :memory: gets its own empty database, so keep
+		// the pool to the one connection that holds the schema.
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	if err := RegisterQueryMetrics(db); err != nil {
+		return nil, err
+	}
+	if err := RegisterQueryTracing(db); err != nil {
+		return nil, err
+	}
+
+	// Author name keys used to be unique, which kept two authors from
+	// sharing a name.
+	if db.Migrator().HasIndex(&Model.AuthorNameModel{}, "idx_author_name_models_key") {
+		if err := db.Migrator().DropIndex(&Model.AuthorNameModel{}, "idx_author_name_models_key"); err != nil {
+			return nil, err
+		}
+	}
+
+	err = db.AutoMigrate(
+		&Model.UserModel{},
+		&Model.BookModel{},
+		&Model.BookSlugModel{},
+		&Model.BookCopyModel{},
+		&Model.AuthorModel{},
+		&Model.AuthorNameModel{},
+		&Model.BookAuthorModel{},
+		&Model.SubjectModel{},
+		&Model.BookSubjectModel{},
+		&Model.BookTagModel{},
+		&Model.LoanModel{},
+		&Model.PasswordResetModel{},
+		&Model.RecoveryCodeModel{},
+		&Model.ExternalIdentityModel{},
+		&Model.APIKeyModel{},
+		&Model.SessionModel{},
+		&Model.ImportJobModel{},
+		&Model.ImportErrorModel{},
+	)
+	return db, err
+}
+
+// sqlitePragmas are set on every connection to a database file. WAL lets
+// readers go on while an import writes, and the busy timeout makes a writer
+// wait for the lock instead of failing with "database is locked".
+var sqlitePragmas = []string{"busy_timeout(5000)", "journal_mode(WAL)"}
+
+// withPragmas adds sqlitePragmas to the connection parameters of dsn, and
+// makes transactions take the write lock when they begin. An in-memory
+// database has a single connection, so it never waits for a lock.
+func withPragmas(dsn string) string {
+	if dsn == ":memory:" {
+		return dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	for _, pragma := range sqlitePragmas {
+		dsn += separator + "_pragma=" + pragma
+		separator = "&"
+	}
+	// A transaction that read before writing cannot wait for the write
+	// lock, so SQLite fails it at once; taking the lock at BEGIN lets the
+	// busy timeout queue it behind the other writers instead.
+	return dsn + "&_txlock=immediate"
+}
+
+func insertBooks(db *gorm.DB, clock Clock) {
+	var count int64
+	if err := db.Model(&Model.BookModel{}).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+
 	books := []Model.BookModel{
-		{Title: "The Great Gatsby", Author: "F. Scott Fitzgerald", Description: "A novel about the American Dream.", Available: true},
-		{Title: "1984", Author: "George Orwell", Description: "A dystopian novel about totalitarianism.", Available: true},
-		{Title: "To Kill a Mockingbird", Author: "Harper Lee", Description: "A novel about racial injustice in the South.", Available: true},
-		{Title: "Pride and Prejudice", Author: "Jane Austen", Description: "A story about love, reputation, and class.", Available: true},
-		{Title: "The Catcher in the Rye", Author: "J.D. Salinger", Description: "A novel about teenage rebellion and alienation.", Available: true},
-		{Title: "Moby-Dick", Author: "Herman Melville", Description: "A tale of obsession and the quest for revenge.", Available: true},
-		{Title: "War and Peace", Author: "Leo Tolstoy", Description: "A novel about the Napoleonic wars and Russian society.", Available: true},
-		{Title: "The Hobbit", Author: "J.R.R. Tolkien", Description: "A fantasy novel about the journey of Bilbo Baggins.", Available: true},
-		{Title: "The Odyssey", Author: "Homer", Description: "An epic poem about Odysseus's journey home.", Available: true},
-		{Title: "Crime and Punishment", Author: "Fyodor Dostoevsky", Description: "A psychological drama about guilt and redemption.", Available: true},
+		{Title: "The Great Gatsby", Author: "F. Scott Fitzgerald", Description: "A novel about the American Dream.", ISBN13: "9780743273565"},
+		{Title: "1984", Author: "George Orwell", Description: "A dystopian novel about totalitarianism.", ISBN13: "9780451524935"},
+		{Title: "To Kill a Mockingbird", Author: "Harper Lee", Description: "A novel about racial injustice in the South.", ISBN13: "9780061120084"},
+		{Title: "Pride and Prejudice", Author: "Jane Austen", Description: "A story about love, reputation, and class.", ISBN13: "9780141439518"},
+		{Title: "The Catcher in the Rye", Author: "J.D. Salinger", Description: "A novel about teenage rebellion and alienation.", ISBN13: "9780316769488"},
+		{Title: "Moby-Dick", Author: "Herman Melville", Description: "A tale of obsession and the quest for revenge.", ISBN13: "9780142437247"},
+		{Title: "War and Peace", Author: "Leo Tolstoy", Description: "A novel about the Napoleonic wars and Russian society.", ISBN13: "9781400079988"},
+		{Title: "The Hobbit", Author: "J.R.R. Tolkien", Description: "A fantasy novel about the journey of Bilbo Baggins.", ISBN13: "9780547928227"},
+		{Title: "The Odyssey", Author: "Homer", Description: "An epic poem about Odysseus's journey home.", ISBN13: "9780140268867"},
+		{Title: "Crime and Punishment", Author: "Fyodor Dostoevsky", Description: "A psychological drama about guilt and redemption.", ISBN13: "9780143107637"},
 	}
 
+	// Popular titles get a few copies, the rest one.
+	copies := map[string]int{"1984": 3, "The Hobbit": 2, "The Great Gatsby": 2}
+	barcode := 0
 	for _, book := range books {
+		book.ISBN10, _ = Model.ISBN13To10(book.ISBN13)
 		if err := db.Create(&book).Error; err != nil {
-			log.Println("Error inserting book:", err)
+			slog.Error("Error inserting book", "title", book.Title, "error", err)
+			continue
 		}
+		for range max(copies[book.Title], 1) {
+			barcode++
+			item := Model.BookCopyModel{BookID: book.ID, Barcode: fmt.Sprintf("LIB-%05d", barcode), Status: Model.CopyAvailable, AcquiredAt: clock.Now()}
+			if err := db.Create(&item).Error; err != nil {
+				slog.Error("Error inserting copy", "title", book.Title, "error", err)
+			}
+		}
+	}
+
+	slog.Info("Books have been inserted successfully!")
+}
+
+// insertAdmin creates the administrator described by ADMIN_EMAIL and
+// ADMIN_PASSWORD, if both are set, since nobody can be promoted otherwise.
+func insertAdmin(db *gorm.DB, clock Clock) {
+	email, password := os.Getenv("ADMIN_EMAIL"), os.Getenv("ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		return
+	}
+
+	var count int64
+	if err := db.Model(&Model.UserModel{}).Where("email = ?", strings.ToLower(email)).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		slog.Error("Error hashing admin password", "error", err)
+		return
+	}
+
+	// The operator chose this address, so there is nobody to verify it.
+	verifiedAt := clock.Now()
+	admin := Model.UserModel{UserName: "admin", Email: strings.ToLower(email), Password: hash, Role: Model.RoleAdmin, EmailVerifiedAt: &verifiedAt}
+	if err := db.Create(&admin).Error; err != nil {
+		slog.Error("Error inserting admin", "email", email, "error", err)
+		return
 	}
 
-	log.Println("Books have been inserted successfully!")
+	slog.Info("Admin account created", "email", email)
 }
@@ -0,0 +1,165 @@
+package Config
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// RequestIDHeader is accepted from clients and echoed on every response.
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey is the echo.Context key holding the current request ID.
+	RequestIDKey = "requestId"
+
+	maxRequestIDLength = 128
+)
+
+// NewLogger builds the application logger from the environment. LOG_FORMAT
+// selects "json" or "text" (default) output and LOG_LEVEL one of "debug",
+// "info" (default), "warn" or "error".
+func NewLogger() *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		level = slog.LevelInfo
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), "json") {
+		return slog.New(slog.NewJSONHandler(os.Stdout, options))
+	}
+	return slog.New(slog.NewTextHandler(os.Stdout, options))
+}
+
+// RequestIDMiddleware reuses the client's X-Request-ID when it looks sane and
+// otherwise generates a new one, then exposes it on the context and response.
+func RequestIDMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		requestID := c.Request().Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(RequestIDKey, requestID)
+		c.Response().Header().Set(RequestIDHeader, requestID)
+
+		return next(c)
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID returns the ID assigned to the current request by
+// RequestIDMiddleware, or an empty string outside of it.
+func RequestID(c echo.Context) string {
+	requestID, _ := c.Get(RequestIDKey).(string)
+	return requestID
+}
+
+// AccessLogMiddleware writes one structured log line per request once the
+// handler chain has finished.
+func AccessLogMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+
+		status := responseStatus(c, err)
+		attrs := []slog.Attr{
+			slog.String("request_id", RequestID(c)),
+			slog.String("method", c.Request().Method),
+			slog.String("path", c.Request().URL.Path),
+			slog.String("route", c.Path()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("remote_ip", c.RealIP()),
+			slog.String("user_agent", c.Request().UserAgent()),
+		}
+		if userID, ok := c.Get(UserIDKey).(int); ok {
+			attrs = append(attrs, slog.Int("user_id", userID))
+		}
+		attrs = append(attrs, traceAttrs(c)...)
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+			if cause := internalError(err); cause != nil {
+				attrs = append(attrs, slog.String("error", cause.Error()))
+			}
+		}
+
+		slog.LogAttrs(c.Request().Context(), level, "request", attrs...)
+		return err
+	}
+}
+
+// HTTPErrorHandler renders every error returned from a handler as a JSON body
+// carrying the message and the request ID, so clients can quote it in bug
+// reports. Validation errors additionally carry an "errors" object mapping
+// each invalid field to its reason.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status := responseStatus(c, err)
+	body := map[string]interface{}{
+		"message":   "Internal server error",
+		"requestId": RequestID(c),
+	}
+	var httpErr *echo.HTTPError
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		body["message"] = "Validation failed"
+		body["errors"] = validationErr.Fields
+	case errors.As(err, &httpErr):
+		body["message"] = httpErr.Message
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = c.JSON(status, body)
+	}
+	if err != nil {
+		slog.Error("Failed to write error response", "request_id", RequestID(c), "error", err)
+	}
+}
+
+func responseStatus(c echo.Context, err error) int {
+	if err == nil {
+		return c.Response().Status
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusUnprocessableEntity
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return http.StatusInternalServerError
+}
+
+func internalError(err error) error {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Internal
+	}
+	return err
+}
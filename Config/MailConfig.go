@@ -0,0 +1,148 @@
+package Config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mail is a plain-text message to a single recipient.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MailSender delivers mail. Implementations must be safe for concurrent use.
+type MailSender interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+// NewMailSender builds the sender selected by MAIL_SENDER:
+//
+//   - "file" (default) writes each message as an .eml file into
+//     MAIL_OUTBOX_DIR (default "outbox"), for development;
+//   - "smtp" relays through SMTP_ADDR (host:port), authenticating with
+//     SMTP_USERNAME and SMTP_PASSWORD when set;
+//   - "memory" keeps messages in the process, for tests.
+//
+// MAIL_FROM sets the sender address of every message.
+func NewMailSender() (MailSender, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "library@localhost"
+	}
+
+	switch kind := os.Getenv("MAIL_SENDER"); kind {
+	case "", "file":
+		dir := os.Getenv("MAIL_OUTBOX_DIR")
+		if dir == "" {
+			dir = "outbox"
+		}
+		slog.Info("Mail is written to files", "dir", dir)
+		return NewFileOutbox(dir, from), nil
+	case "smtp":
+		addr := os.Getenv("SMTP_ADDR")
+		if addr == "" {
+			return nil, fmt.Errorf("MAIL_SENDER=smtp requires SMTP_ADDR")
+		}
+		return NewSMTPSender(addr, from, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD")), nil
+	case "memory":
+		return NewMemoryOutbox(), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_SENDER %q", kind)
+	}
+}
+
+// format renders mail as an RFC 5322 message.
+func (m Mail) format(from string, date time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// SMTPSender relays mail through an SMTP server.
+type SMTPSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPSender(addr, from, username, password string) *SMTPSender {
+	sender := &SMTPSender{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		sender.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return sender
+}
+
+func (s *SMTPSender) Send(ctx context.Context, mail Mail) error {
+	_, span := otel.Tracer(tracerName).Start(ctx, "mail.smtp")
+	defer span.End()
+
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{mail.To}, mail.format(s.from, time.Now()))
+}
+
+// FileOutbox writes every message to its own file instead of sending it.
+type FileOutbox struct {
+	dir  string
+	from string
+}
+
+func NewFileOutbox(dir, from string) *FileOutbox {
+	return &FileOutbox{dir: dir, from: from}
+}
+
+func (o *FileOutbox) Send(ctx context.Context, mail Mail) error {
+	if err := os.MkdirAll(o.dir, 0o755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	// The timestamp prefix keeps the directory listing in sending order.
+	name := now.UTC().Format("20060102T150405.000000000") + "-" + uuid.NewString() + ".eml"
+	return os.WriteFile(filepath.Join(o.dir, name), mail.format(o.from, now), 0o644)
+}
+
+// MemoryOutbox keeps sent messages in memory so tests can read them back.
+type MemoryOutbox struct {
+	mu       sync.Mutex
+	messages []Mail
+}
+
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{}
+}
+
+func (o *MemoryOutbox) Send(ctx context.Context, mail Mail) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, mail)
+	return nil
+}
+
+// Messages returns every message sent so far, oldest first.
+func (o *MemoryOutbox) Messages() []Mail {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Mail(nil), o.messages...)
+}
@@ -0,0 +1,153 @@
+package Config
+
+import (
+	"context"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
+)
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "library_db_query_duration_seconds",
+		Help:    "Database query latency by GORM operation and table.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation", "table"})
+
+	// FailedLogins counts login attempts rejected because of unknown users or
+	// wrong passwords.
+	FailedLogins = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "library_failed_logins_total",
+		Help: "Number of rejected login attempts.",
+	})
+
+	// ThrottledLogins counts login attempts refused unchecked because their
+	// account or client is backing off after repeated failures.
+	ThrottledLogins = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "library_throttled_logins_total",
+		Help: "Number of login attempts refused during backoff or lockout.",
+	})
+)
+
+const queryStartKey = "metrics:query_start"
+
+// MetricsMiddleware records the request count and latency of every request,
+// labelled by the route template rather than the raw path so that path
+// parameters do not explode the label cardinality.
+func MetricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+
+		status := responseStatus(c, err)
+		route := c.Path()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		labels := prometheus.Labels{
+			"method": c.Request().Method,
+			"route":  route,
+			"status": strconv.Itoa(status),
+		}
+		httpRequestsTotal.With(labels).Inc()
+		httpRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+// LoanStats reports the loan counts exported as gauges.
+type LoanStats interface {
+	ActiveLoans(ctx context.Context) (int64, error)
+	OverdueLoans(ctx context.Context) (int64, error)
+}
+
+// MetricsHandler serves all collected metrics in the Prometheus text format,
+// including the loan gauges computed from loans at scrape time.
+func MetricsHandler(loans LoanStats) echo.HandlerFunc {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestsTotal,
+		httpRequestDuration,
+		dbQueryDuration,
+		FailedLogins,
+		ThrottledLogins,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "library_active_loans",
+			Help: "Number of books currently on loan.",
+		}, loanGauge(loans.ActiveLoans)),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "library_overdue_loans",
+			Help: "Number of books on loan past their due date.",
+		}, loanGauge(loans.OverdueLoans)),
+	)
+
+	return echo.WrapHandler(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
+
+func loanGauge(count func(context.Context) (int64, error)) func() float64 {
+	return func() float64 {
+		value, err := count(context.Background())
+		if err != nil {
+			return 0
+		}
+		return float64(value)
+	}
+}
+
+// RegisterQueryMetrics installs GORM callbacks that time every database
+// operation executed through db.
+func RegisterQueryMetrics(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:before_create", startQueryTimer),
+		cb.Create().After("gorm:create").Register("metrics:after_create", observeQuery("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", startQueryTimer),
+		cb.Query().After("gorm:query").Register("metrics:after_query", observeQuery("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", startQueryTimer),
+		cb.Update().After("gorm:update").Register("metrics:after_update", observeQuery("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", startQueryTimer),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", observeQuery("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", startQueryTimer),
+		cb.Row().After("gorm:row").Register("metrics:after_row", observeQuery("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", startQueryTimer),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", observeQuery("raw")),
+	)
+}
+
+func startQueryTimer(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+func observeQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+		dbQueryDuration.WithLabelValues(operation, db.Statement.Table).Observe(time.Since(start).Seconds())
+	}
+}
@@ -1,36 +1,189 @@
 package Config
 
 import (
+	"context"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"net/http"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
 )
 
-func Middleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		authHeader := c.Request().Header.Get("Authorization")
-		if authHeader == "" {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Missing Authorization Header")
+const (
+	// UserIDKey is the echo.Context key under which Middleware stores the
+	// authenticated user's ID.
+	UserIDKey = "userId"
+	// RoleKey is the echo.Context key holding the authenticated user's role.
+	RoleKey = "role"
+	// TokenIDKey is the echo.Context key holding the jti of the user's
+	// token, which identifies their session.
+	TokenIDKey = "tokenId"
+	// APIKeyIDKey is the echo.Context key holding the ID of the API key a
+	// request authenticated with. Such requests have no user ID or role.
+	APIKeyIDKey = "apiKeyId"
+)
+
+// IPExtractor decides where c.RealIP finds the client address. Proxy headers
+// such as X-Forwarded-For are only honoured when TRUST_PROXY_HEADERS is true,
+// since any client could otherwise forge them to dodge per-client limits.
+func IPExtractor() echo.IPExtractor {
+	if trusted, _ := strconv.ParseBool(os.Getenv("TRUST_PROXY_HEADERS")); trusted {
+		return echo.ExtractIPFromXFFHeader()
+	}
+	return echo.ExtractIPDirect()
+}
+
+// TokenChecker is consulted after a token's signature and expiry check out,
+// so tokens can be revoked before they expire.
+type TokenChecker interface {
+	// TokenCurrent reports whether the token tokenID of userID carrying
+	// version is still honoured; it is false once the account is gone or
+	// the token's session was revoked.
+	TokenCurrent(ctx context.Context, userID, version int, tokenID string) (bool, error)
+}
+
+// APIKeyChecker authenticates API keys.
+type APIKeyChecker interface {
+	// CheckAPIKey returns the ID and scopes of key, with ok false when the
+	// key is unknown, revoked or expired.
+	CheckAPIKey(ctx context.Context, key string) (id int, scopes []string, ok bool, err error)
+}
+
+// Middleware returns the authentication check for secured routes. It takes
+// a Bearer JWT, validating expiry against clock and revocation against
+// tokens, or an API key in the X-API-Key header or as Bearer token. API
+// keys are only let through when they hold one of scopes; routes without
+// scopes are for users only.
+func Middleware(clock Clock, tokens TokenChecker, keys APIKeyChecker, scopes ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if key := c.Request().Header.Get(APIKeyHeader); key != "" {
+				return authenticateAPIKey(c, keys, key, scopes, next)
+			}
+
+			authHeader := c.Request().Header.Get("Authorization")
+			if authHeader == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing Authorization Header")
+			}
+
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+			if tokenString == authHeader {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid Authorization Header")
+			}
+			if _, ok := APIKeyPrefix(tokenString); ok {
+				return authenticateAPIKey(c, keys, tokenString, scopes, next)
+			}
+
+			claims, err := verifyToken(c, clock, tokenString)
+			if err != nil {
+				return err
+			}
+
+			current, err := tokens.TokenCurrent(c.Request().Context(), claims.userID, claims.version, claims.tokenID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify token").SetInternal(err)
+			}
+			if !current {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Token has been revoked")
+			}
+			c.Set(UserIDKey, claims.userID)
+			c.Set(RoleKey, claims.role)
+			c.Set(TokenIDKey, claims.tokenID)
+
+			return next(c)
 		}
+	}
+}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid Authorization Header")
+func authenticateAPIKey(c echo.Context, keys APIKeyChecker, key string, scopes []string, next echo.HandlerFunc) error {
+	ctx, span := StartSpan(c, "auth.apikey")
+	id, granted, ok, err := keys.CheckAPIKey(ctx, key)
+	span.End()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify API key").SetInternal(err)
+	}
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or revoked API key")
+	}
+	if len(scopes) == 0 {
+		return echo.NewHTTPError(http.StatusForbidden, "API keys cannot access this endpoint")
+	}
+	if !slices.ContainsFunc(scopes, func(scope string) bool { return slices.Contains(granted, scope) }) {
+		return echo.NewHTTPError(http.StatusForbidden, "API key lacks the "+strings.Join(scopes, " or ")+" scope")
+	}
+
+	c.Set(APIKeyIDKey, id)
+	return next(c)
+}
+
+// RequireRole rejects requests whose token does not carry role. It must run
+// after Middleware.
+func RequireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Get(RoleKey) != role {
+				return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+			}
+			return next(c)
 		}
+	}
+}
 
-		secretKey := []byte("SUPER_SECRET_KEY")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, echo.NewHTTPError(http.StatusUnauthorized, "Invalid Signing Method")
+// RequireUserRole rejects user tokens that do not carry role while letting
+// API keys through, for routes whose Middleware already checked the key's
+// scopes. It must run after Middleware.
+func RequireUserRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Get(APIKeyIDKey) == nil && c.Get(RoleKey) != role {
+				return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
 			}
-			return secretKey, nil
-		})
+			return next(c)
+		}
+	}
+}
+
+type tokenClaims struct {
+	userID  int
+	role    string
+	version int
+	tokenID string
+}
+
+// verifyToken checks the token's signature and expiry inside its own span so
+// traces show how long authentication took, separately from the handler.
+func verifyToken(c echo.Context, clock Clock, tokenString string) (tokenClaims, error) {
+	_, span := StartSpan(c, "auth.jwt")
+	defer span.End()
 
-		if err != nil || !token.Valid {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or Expired Token")
+	secretKey := []byte("SUPER_SECRET_KEY")
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, echo.NewHTTPError(http.StatusUnauthorized, "Invalid Signing Method")
 		}
+		return secretKey, nil
+	}, jwt.WithTimeFunc(clock.Now))
 
-		return next(c)
+	if err != nil || !token.Valid {
+		span.SetStatus(codes.Error, "invalid token")
+		return tokenClaims{}, echo.NewHTTPError(http.StatusUnauthorized, "Invalid or Expired Token")
 	}
+
+	userID, ok := claims["sub"].(float64)
+	if !ok {
+		span.SetStatus(codes.Error, "missing subject")
+		return tokenClaims{}, echo.NewHTTPError(http.StatusUnauthorized, "Invalid Token Subject")
+	}
+	role, _ := claims["role"].(string)
+	// Tokens issued before versions existed carry none and count as version 0.
+	version, _ := claims["ver"].(float64)
+	tokenID, _ := claims["jti"].(string)
+	span.SetAttributes(attribute.Int("enduser.id", int(userID)), attribute.String("enduser.role", role))
+
+	return tokenClaims{userID: int(userID), role: role, version: int(version), tokenID: tokenID}, nil
 }
@@ -0,0 +1,436 @@
+package Config
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCSettings configure login through an OpenID Connect provider.
+type OIDCSettings struct {
+	// Issuer is the provider's issuer URL; metadata is discovered below it.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL must be registered with the provider.
+	RedirectURL string
+	// GroupsClaim names the ID token claim listing the user's groups.
+	GroupsClaim string
+	// RoleMapping maps provider groups to local roles. When set, the
+	// provider decides the role on every login; when empty, roles are
+	// managed locally.
+	RoleMapping map[string]string
+}
+
+// OIDCSettingsFromEnv reads OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET,
+// OIDC_REDIRECT_URL (default APP_BASE_URL/login/oidc/callback),
+// OIDC_GROUPS_CLAIM (default "groups") and OIDC_ROLE_MAPPING, a comma
+// separated list of group=role pairs. Single sign-on is off unless
+// OIDC_ISSUER is set.
+func OIDCSettingsFromEnv() (OIDCSettings, bool, error) {
+	settings := OIDCSettings{
+		Issuer:       os.Getenv("OIDC_ISSUER"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		GroupsClaim:  os.Getenv("OIDC_GROUPS_CLAIM"),
+	}
+	if settings.Issuer == "" {
+		return settings, false, nil
+	}
+	if settings.ClientID == "" {
+		return settings, false, errors.New("OIDC_ISSUER requires OIDC_CLIENT_ID")
+	}
+	if settings.RedirectURL == "" {
+		settings.RedirectURL = AppURL("/login/oidc/callback", nil)
+	}
+	if settings.GroupsClaim == "" {
+		settings.GroupsClaim = "groups"
+	}
+
+	mapping, err := ParseRoleMapping(os.Getenv("OIDC_ROLE_MAPPING"))
+	if err != nil {
+		return settings, false, fmt.Errorf("OIDC_ROLE_MAPPING: %w", err)
+	}
+	settings.RoleMapping = mapping
+	return settings, true, nil
+}
+
+// ParseRoleMapping parses "group=role,group=role" into a map, rejecting
+// unknown roles.
+func ParseRoleMapping(value string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(pair, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" {
+			return nil, fmt.Errorf("%q is not a group=role pair", pair)
+		}
+		if role != Model.RoleMember && role != Model.RoleAdmin {
+			return nil, fmt.Errorf("unknown role %q for group %q", role, group)
+		}
+		mapping[group] = role
+	}
+	return mapping, nil
+}
+
+// OIDCFlow holds the secrets of one authorization request, which the
+// callback must present again.
+type OIDCFlow struct {
+	// State ties the callback to the browser that started the login.
+	State string
+	// Nonce ties the ID token to this request.
+	Nonce string
+	// Verifier is the PKCE code verifier; only its hash is sent up front.
+	Verifier string
+}
+
+func NewOIDCFlow() (OIDCFlow, error) {
+	var flow OIDCFlow
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		token, err := NewSecretToken()
+		if err != nil {
+			return flow, err
+		}
+		*value = token
+	}
+	return flow, nil
+}
+
+// CodeChallenge is the S256 PKCE challenge of the verifier.
+func (f OIDCFlow) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(f.Verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// OIDCIdentity is what a validated ID token says about the user.
+type OIDCIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+// ErrInvalidIDToken covers ID tokens that fail signature, issuer, audience,
+// expiry or nonce checks.
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider speaks the authorization code flow with PKCE to one
+// provider. Metadata and signing keys are fetched on first use and cached,
+// so the application starts even while the provider is unreachable.
+type OIDCProvider struct {
+	settings OIDCSettings
+	client   *http.Client
+
+	mu       sync.Mutex
+	metadata *oidcMetadata
+	keys     map[string]*rsa.PublicKey
+	// keysFetchedAt is when keys were last fetched, so that tokens naming
+	// unknown keys cannot make us hammer the provider.
+	keysFetchedAt time.Time
+	// missingKeys maps key IDs absent from a fetch to when they were found
+	// missing; tokens naming them are refused without asking again.
+	missingKeys map[string]time.Time
+}
+
+const (
+	// jwksRefetchInterval is the least time between two fetches of the
+	// signing keys.
+	jwksRefetchInterval = time.Minute
+	// jwksMissingKeyTTL is how long a key ID absent from the key set is
+	// believed not to exist.
+	jwksMissingKeyTTL = 5 * time.Minute
+)
+
+func NewOIDCProvider(settings OIDCSettings, client *http.Client) *OIDCProvider {
+	return &OIDCProvider{settings: settings, client: client}
+}
+
+func (p *OIDCProvider) Settings() OIDCSettings {
+	return p.settings
+}
+
+// AuthorizationURL is where the browser is sent to log in.
+func (p *OIDCProvider) AuthorizationURL(ctx context.Context, flow OIDCFlow) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.settings.ClientID},
+		"redirect_uri":          {p.settings.RedirectURL},
+		"scope":                 {"openid email profile"},
+		"state":                 {flow.State},
+		"nonce":                 {flow.Nonce},
+		"code_challenge":        {flow.CodeChallenge()},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the raw ID token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "oidc.token")
+	defer span.End()
+
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.settings.RedirectURL},
+		"client_id":     {p.settings.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.settings.ClientSecret != "" {
+		form.Set("client_secret", p.settings.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := p.doJSON(req, &body); err != nil {
+		return "", fmt.Errorf("token request: %w", err)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response lacks id_token")
+	}
+	return body.IDToken, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience and expiry of raw at
+// now, and that it carries nonce.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, raw, nonce string, now time.Time) (OIDCIdentity, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return OIDCIdentity{}, err
+	}
+
+	claims := jwt.MapClaims{}
+	var keyErr error
+	_, err = jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := p.signingKey(ctx, kid, now)
+		keyErr = err
+		return key, err
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.settings.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+		jwt.WithLeeway(time.Minute),
+	)
+	if keyErr != nil && !errors.Is(keyErr, ErrInvalidIDToken) {
+		// The provider could not be asked for its keys; that says nothing
+		// about the token.
+		return OIDCIdentity{}, keyErr
+	}
+	if err != nil {
+		return OIDCIdentity{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return OIDCIdentity{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	identity := OIDCIdentity{Issuer: metadata.Issuer}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	if identity.Name == "" {
+		identity.Name, _ = claims["preferred_username"].(string)
+	}
+	// Some providers send email_verified as a string.
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	switch groups := claims[p.settings.GroupsClaim].(type) {
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	case string:
+		identity.Groups = []string{groups}
+	}
+	if identity.Subject == "" {
+		return OIDCIdentity{}, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+	return identity, nil
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.settings.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var metadata oidcMetadata
+	if err := p.doJSON(req, &metadata); err != nil {
+		return nil, fmt.Errorf("discover provider: %w", err)
+	}
+	// OpenID Connect Discovery 1.0, section 4.3.
+	if metadata.Issuer != p.settings.Issuer {
+		return nil, fmt.Errorf("discover provider: issuer %q does not match %q", metadata.Issuer, p.settings.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("discover provider: metadata lacks endpoints")
+	}
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// signingKey returns the provider key with kid, refetching the key set when
+// kid is unknown, since providers rotate keys. Refetches happen at most once
+// per jwksRefetchInterval, and a kid found missing is not asked about again
+// for jwksMissingKeyTTL.
+func (p *OIDCProvider) signingKey(ctx context.Context, kid string, now time.Time) (*rsa.PublicKey, error) {
+	unknown := fmt.Errorf("%w: unknown signing key %q", ErrInvalidIDToken, kid)
+
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	missingSince, missing := p.missingKeys[kid]
+	lastFetch := p.keysFetchedAt
+	switch {
+	case ok:
+		p.mu.Unlock()
+		return key, nil
+	case missing && now.Sub(missingSince) < jwksMissingKeyTTL,
+		!lastFetch.IsZero() && now.Sub(lastFetch) < jwksRefetchInterval:
+		p.mu.Unlock()
+		return nil, unknown
+	}
+	// Claim the fetch, so concurrent logins do not all go to the provider.
+	p.keysFetchedAt = now
+	p.mu.Unlock()
+
+	keys, err := p.fetchKeys(ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		// A failed fetch does not count, so the outage is reported rather
+		// than blamed on the token.
+		p.keysFetchedAt = lastFetch
+		return nil, err
+	}
+	p.keys = keys
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	if p.missingKeys == nil {
+		p.missingKeys = map[string]time.Time{}
+	}
+	for id, since := range p.missingKeys {
+		if now.Sub(since) >= jwksMissingKeyTTL {
+			delete(p.missingKeys, id)
+		}
+	}
+	p.missingKeys[kid] = now
+	return nil, unknown
+}
+
+// fetchKeys downloads the provider's RSA signing keys by key ID.
+func (p *OIDCProvider) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("fetch signing keys: %w", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+func (p *OIDCProvider) doJSON(req *http.Request, target interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: status %d: %.200s", req.Method, req.URL.Redacted(), resp.StatusCode, body)
+	}
+	return json.Unmarshal(body, target)
+}
@@ -0,0 +1,42 @@
+package Config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHashCost is the bcrypt cost used for new hashes. Tests lower it to
+// keep suites fast.
+var PasswordHashCost = bcrypt.DefaultCost
+
+// HashPassword returns the bcrypt hash stored in place of password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordHashCost)
+	return string(hash), err
+}
+
+// CheckPassword reports whether password matches the stored hash.
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// NewSecretToken returns a random URL-safe token with 256 bits of entropy,
+// for credentials handed out once and looked up by HashSecretToken later.
+func NewSecretToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashSecretToken returns the digest stored in place of a NewSecretToken
+// token. The token's entropy makes a fast hash sufficient, and a
+// deterministic one lets the digest be looked up directly.
+func HashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,80 @@
+package Config
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// StatusClientClosedRequest is the non-standard status (popularised by nginx)
+// recorded when the client went away before the response was ready.
+const StatusClientClosedRequest = 499
+
+const defaultRequestTimeout = 10 * time.Second
+
+// RequestTimeout reads the per-request deadline from REQUEST_TIMEOUT, a Go
+// duration such as "5s". "0" disables the deadline; anything unparsable falls
+// back to the default of 10s.
+func RequestTimeout() time.Duration {
+	value := os.Getenv("REQUEST_TIMEOUT")
+	if value == "" {
+		return defaultRequestTimeout
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		slog.Warn("Invalid REQUEST_TIMEOUT, using default", "value", value, "default", defaultRequestTimeout)
+		return defaultRequestTimeout
+	}
+	return timeout
+}
+
+// TimeoutMiddleware bounds every request's context by timeout, so database
+// calls made with c.Request().Context() are cancelled when the deadline passes
+// or the client disconnects, and turns the resulting context errors into
+// proper status codes.
+func TimeoutMiddleware(timeout time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if timeout > 0 {
+				ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+				defer cancel()
+				c.SetRequest(c.Request().WithContext(ctx))
+			}
+
+			return contextError(next(c))
+		}
+	}
+}
+
+// contextError replaces errors caused by an ended request context or an
+// unavailable database with a response describing that condition instead of a
+// generic 500. Only the error itself is inspected: a handler that finished
+// before noticing the deadline keeps its response, and so does any
+// *echo.HTTPError a handler chose deliberately. Only a 500, which carries the
+// cause as its internal error, is reclassified.
+func contextError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code != http.StatusInternalServerError {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return echo.NewHTTPError(http.StatusGatewayTimeout, "Request timed out").SetInternal(err)
+	case errors.Is(err, context.Canceled):
+		return echo.NewHTTPError(StatusClientClosedRequest, "Client closed request").SetInternal(err)
+	case errors.Is(err, sql.ErrConnDone) || errors.Is(err, driver.ErrBadConn):
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Database unavailable").SetInternal(err)
+	}
+	return err
+}
@@ -0,0 +1,187 @@
+package Config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	serviceName = "library"
+	tracerName  = "awesomeProject"
+
+	spanKey = "tracing:span"
+)
+
+// InitTracing installs the global tracer provider and W3C trace-context
+// propagator. OTEL_TRACES_EXPORTER selects where spans go:
+//
+//   - "none" (default) keeps propagation but records nothing,
+//   - "console" pretty-prints spans to stdout,
+//   - "file" appends spans as JSON to OTEL_TRACES_FILE (default "traces.json"),
+//   - "otlp" ships spans over OTLP/HTTP, configured through the standard
+//     OTEL_EXPORTER_OTLP_* variables.
+//
+// The returned function flushes pending spans and must be called on shutdown.
+func InitTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, closer, err := newSpanExporter(ctx, strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")))
+	if err != nil || exporter == nil {
+		return func(context.Context) error { return nil }, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+func newSpanExporter(ctx context.Context, kind string) (sdktrace.SpanExporter, io.Closer, error) {
+	switch kind {
+	case "", "none":
+		return nil, nil, nil
+	case "console", "stdout":
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		return exporter, nil, err
+	case "file":
+		path := os.Getenv("OTEL_TRACES_FILE")
+		if path == "" {
+			path = "traces.json"
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	case "otlp":
+		exporter, err := otlptracehttp.New(ctx)
+		return exporter, nil, err
+	default:
+		return nil, nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", kind)
+	}
+}
+
+// TracingMiddleware starts a server span per request named after the route,
+// continuing any trace passed in the traceparent header.
+func TracingMiddleware() echo.MiddlewareFunc {
+	return otelecho.Middleware(serviceName, otelecho.WithSkipper(func(c echo.Context) bool {
+		return c.Path() == "/metrics"
+	}))
+}
+
+// StartSpan starts a child span of the request's current span.
+func StartSpan(c echo.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(c.Request().Context(), name)
+}
+
+// RegisterQueryTracing installs GORM callbacks that wrap every database
+// operation in a client span. Spans are only parented to the request when the
+// query runs with db.WithContext.
+func RegisterQueryTracing(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("tracing:before_create", startQuerySpan("create")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", endQuerySpan),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", startQuerySpan("query")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", endQuerySpan),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", startQuerySpan("update")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", endQuerySpan),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", startQuerySpan("delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", endQuerySpan),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", startQuerySpan("row")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", endQuerySpan),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", startQuerySpan("raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", endQuerySpan),
+	)
+}
+
+func startQuerySpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx, span := otel.Tracer(tracerName).Start(db.Statement.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemSqlite,
+				semconv.DBOperationName(operation),
+			),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func endQuerySpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBCollectionName(db.Statement.Table),
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
+
+// traceAttrs returns the trace and span IDs of the request's span for log
+// correlation, or nothing when the request is not being traced.
+func traceAttrs(c echo.Context) []slog.Attr {
+	spanContext := trace.SpanContextFromContext(c.Request().Context())
+	if !spanContext.IsValid() {
+		return nil
+	}
+	return []slog.Attr{
+		slog.String("trace_id", spanContext.TraceID().String()),
+		slog.String("span_id", spanContext.SpanID().String()),
+	}
+}
@@ -0,0 +1,154 @@
+package Config
+
+import (
+	"awesomeProject/Model"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// MinPasswordLength and MaxPasswordLength bound the password policy; the
+	// upper bound is bcrypt's input limit.
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// ValidationError lists the invalid fields of a request, keyed by their JSON
+// names, with a human-readable reason for each.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func NewValidationError(field, reason string) *ValidationError {
+	return &ValidationError{Fields: map[string]string{field: reason}}
+}
+
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+" "+e.Fields[name])
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Validator implements echo.Validator on top of go-playground/validator, so
+// request types declare their rules with `validate` struct tags.
+type Validator struct {
+	validate *validator.Validate
+}
+
+func NewValidator() *Validator {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "param", "query"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+
+	// Rules are static, so a registration failure is a programming error.
+	must(validate.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		return PasswordPolicyError(fl.Field().String()) == ""
+	}))
+	must(validate.RegisterValidation("duration", func(fl validator.FieldLevel) bool {
+		_, err := time.ParseDuration(fl.Field().String())
+		return err == nil
+	}))
+	must(validate.RegisterValidation("isbn", func(fl validator.FieldLevel) bool {
+		return Model.ValidISBN(fl.Field().String())
+	}))
+	must(validate.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	}))
+
+	return &Validator{validate: validate}
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Validate checks i against its `validate` tags and returns a
+// *ValidationError describing every failing field.
+func (v *Validator) Validate(i interface{}) error {
+	err := v.validate.Struct(i)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	result := &ValidationError{Fields: map[string]string{}}
+	for _, fieldError := range fieldErrors {
+		if _, seen := result.Fields[fieldError.Field()]; !seen {
+			result.Fields[fieldError.Field()] = describe(fieldError)
+		}
+	}
+	return result
+}
+
+// PasswordPolicyError explains why password breaks the password policy, or
+// returns "" when it complies.
+func PasswordPolicyError(password string) string {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return fmt.Sprintf("must be between %d and %d characters", MinPasswordLength, MaxPasswordLength)
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+	if !hasLetter || !hasDigit {
+		return "must contain at least one letter and one digit"
+	}
+	return ""
+}
+
+func describe(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required", "notblank":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		if fieldError.Kind() == reflect.String {
+			return "must be at least " + fieldError.Param() + " characters"
+		}
+		return "must be at least " + fieldError.Param()
+	case "max":
+		if fieldError.Kind() == reflect.String {
+			return "must be at most " + fieldError.Param() + " characters"
+		}
+		return "must be at most " + fieldError.Param()
+	case "password":
+		return PasswordPolicyError(fieldError.Value().(string))
+	case "duration":
+		return `must be a duration such as "90m" or "72h"`
+	case "isbn":
+		return "must be a valid ISBN-10 or ISBN-13"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldError.Param(), " ", ", ")
+	}
+	return "is invalid"
+}
@@ -0,0 +1,369 @@
+package Controller
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Service"
+	"bytes"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+// @Summary Verify an email address
+// @Description Target of the link mailed after registration or an email change. Following the link for a pending address moves the account to it.
+// @Tags account
+// @Produce json
+// @Param token query string true "Signed verification token"
+// @Success 200 {object} Model.UserResponse "Verified user"
+// @Failure 400 {object} map[string]string "Invalid or expired verification link"
+// @Failure 409 {object} map[string]string "Email already registered"
+// @Failure 500 {object} map[string]string "Failed to verify email"
+// @Router /verify-email [get]
+func verifyEmailHandler(users Service.UserService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, err := users.VerifyEmail(c.Request().Context(), c.QueryParam("token"))
+		if err != nil {
+			if errors.Is(err, Service.ErrInvalidVerificationToken) {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired verification link")
+			}
+			if errors.Is(err, Service.ErrEmailTaken) {
+				return echo.NewHTTPError(http.StatusConflict, "Email already registered")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify email").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusOK, Model.NewUserResponse(user))
+	}
+}
+
+// @Summary Resend the verification link
+// @Description Mail a new verification link. The response is the same whether or not the address belongs to an unverified account.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param request body Model.ResendVerificationRequest true "Address to verify"
+// @Success 202 {object} map[string]string "Link sent if applicable"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 500 {object} map[string]string "Failed to resend verification"
+// @Router /verify-email/resend [post]
+func resendVerificationHandler(users Service.UserService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.ResendVerificationRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		if err := users.ResendVerification(c.Request().Context(), request.Email); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to resend verification").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusAccepted, map[string]string{
+			"message": "If this address belongs to an unverified account, a new link is on its way",
+		})
+	}
+}
+
+// @Summary Request a password reset
+// @Description Mail a single-use link for choosing a new password. The response is the same whether or not the address has an account.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param request body Model.ForgotPasswordRequest true "Account address"
+// @Success 202 {object} map[string]string "Link sent if applicable"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 500 {object} map[string]string "Failed to request password reset"
+// @Router /password/forgot [post]
+func forgotPasswordHandler(users Service.UserService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.ForgotPasswordRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		if err := users.RequestPasswordReset(c.Request().Context(), request.Email); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to request password reset").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusAccepted, map[string]string{
+			"message": "If this address belongs to an account, a reset link is on its way",
+		})
+	}
+}
+
+// resetPasswordForm is the page the reset link opens unless a frontend
+// takes it over. It posts the new password to POST /password/reset.
+var resetPasswordForm = template.Must(template.New("reset").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Reset your password</title>
+</head>
+<body>
+<h1>Reset your password</h1>
+<form id="reset">
+<input type="hidden" name="token" value="{{.}}">
+<label>New password <input type="password" name="newPassword" autocomplete="new-password" required></label>
+<button type="submit">Reset password</button>
+</form>
+<p id="result" role="status"></p>
+<script>
+document.getElementById("reset").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  const response = await fetch(location.pathname, {
+    method: "POST",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify(Object.fromEntries(new FormData(event.target))),
+  });
+  const body = await response.json();
+  const reasons = Object.entries(body.errors || {}).map(([field, reason]) => field + " " + reason);
+  document.getElementById("result").textContent = [body.message, ...reasons].join(": ");
+});
+</script>
+</body>
+</html>
+`))
+
+// @Summary Open a password reset link
+// @Description Target of the link mailed by /password/forgot. Redirects to PASSWORD_RESET_URL with the token when a
+// @Description frontend is configured, and otherwise shows a form that submits to POST /password/reset.
+// @Tags account
+// @Produce html
+// @Param token query string true "Reset token"
+// @Success 200 {string} string "Reset form"
+// @Success 302 {string} string "Redirect to the frontend"
+// @Failure 400 {object} map[string]string "Invalid or expired reset link"
+// @Failure 500 {object} map[string]string "Failed to show reset form"
+// @Router /password/reset [get]
+func resetPasswordFormHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token := c.QueryParam("token")
+		if token == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired reset link")
+		}
+
+		if page := Config.PasswordResetPageURL(); page != "" {
+			target, err := url.Parse(page)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to show reset form").SetInternal(err)
+			}
+			query := target.Query()
+			query.Set("token", token)
+			target.RawQuery = query.Encode()
+			return c.Redirect(http.StatusFound, target.String())
+		}
+
+		var page bytes.Buffer
+		if err := resetPasswordForm.Execute(&page, token); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to show reset form").SetInternal(err)
+		}
+		c.Response().Header().Set("Referrer-Policy", "no-referrer")
+		return c.HTMLBlob(http.StatusOK, page.Bytes())
+	}
+}
+
+// @Summary Reset a forgotten password
+// @Description Set a new password with the token from a reset link. The token works once; every existing session is signed out.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param request body Model.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string "Password reset"
+// @Failure 400 {object} map[string]string "Invalid or expired reset link"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 500 {object} map[string]string "Failed to reset password"
+// @Router /password/reset [post]
+func resetPasswordHandler(users Service.UserService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.ResetPasswordRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		if err := users.ResetPassword(c.Request().Context(), request.Token, request.NewPassword); err != nil {
+			if errors.Is(err, Service.ErrInvalidResetToken) {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired reset link")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reset password").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusOK, map[string]string{"message": "Password has been reset; please log in"})
+	}
+}
+
+// @Summary View own profile
+// @Description Show the signed-in user's account and the books they currently have on loan
+// @Tags account
+// @Produce json
+// @Success 200 {object} Model.ProfileResponse "Profile"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Failed to load profile"
+// @Router /me [get]
+func viewProfileHandler(users Service.UserService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, loans, err := users.Profile(c.Request().Context(), c.Get(Config.UserIDKey).(int))
+		if err != nil {
+			return accountError(err, "Failed to load profile")
+		}
+
+		return c.JSON(http.StatusOK, Model.NewProfileResponse(user, loans))
+	}
+}
+
+// @Summary Update own profile
+// @Description Change the signed-in user's profile; fields left out are kept. Email and password have their own endpoints.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param profile body Model.UpdateProfileRequest true "Fields to change"
+// @Success 200 {object} Model.UserResponse "Updated user"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Failed to update profile"
+// @Router /me [patch]
+func updateProfileHandler(users Service.UserService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.UpdateProfileRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		user, err := users.UpdateProfile(c.Request().Context(), c.Get(Config.UserIDKey).(int), request.UserName)
+		if err != nil {
+			return accountError(err, "Failed to update profile")
+		}
+
+		return c.JSON(http.StatusOK, Model.NewUserResponse(user))
+	}
+}
+
+// @Summary Change own password
+// @Description Replace the password after confirming the current one. Accounts without a password confirm with a recent login or a two-factor code instead, and so set their first password. Every other session is signed out; the response carries a new token for this one.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param passwords body Model.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} Model.TokenResponse "New JWT token"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 422 {object} map[string]interface{} "Validation failed or current password incorrect"
+// @Failure 401 {object} map[string]string "Recent login required"
+// @Failure 500 {object} map[string]string "Failed to change password"
+// @Router /me/password [post]
+func changePasswordHandler(users Service.UserService, sessions Service.SessionService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.ChangePasswordRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		user, err := users.ChangePassword(c.Request().Context(), c.Get(Config.UserIDKey).(int), reauthentication(c, request.CurrentPassword, request.Code), request.NewPassword)
+		if errors.Is(err, Service.ErrInvalidCredentials) {
+			return Config.NewValidationError("currentPassword", "is incorrect")
+		}
+		if err != nil {
+			return accountError(err, "Failed to change password")
+		}
+
+		token, err := sessions.Start(c.Request().Context(), user, c.Request().UserAgent(), c.RealIP())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate JWT").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusOK, Model.TokenResponse{Token: token})
+	}
+}
+
+// @Summary Change own email
+// @Description Ask to move the account to a new email address after confirming the password, or for accounts without one a recent login or a two-factor code. The account keeps its current address, shown as pending the new one, until the link mailed to the new address is followed. Asking for the current address cancels a pending change.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param email body Model.ChangeEmailRequest true "New email and current password"
+// @Success 200 {object} Model.UserResponse "Updated user"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 422 {object} map[string]interface{} "Validation failed or password incorrect"
+// @Failure 409 {object} map[string]string "Email already registered"
+// @Failure 401 {object} map[string]string "Recent login required"
+// @Failure 500 {object} map[string]string "Failed to change email"
+// @Router /me/email [post]
+func changeEmailHandler(users Service.UserService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.ChangeEmailRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		user, err := users.ChangeEmail(c.Request().Context(), c.Get(Config.UserIDKey).(int), reauthentication(c, request.Password, request.Code), request.NewEmail)
+		switch {
+		case errors.Is(err, Service.ErrInvalidCredentials):
+			return Config.NewValidationError("password", "is incorrect")
+		case errors.Is(err, Service.ErrEmailTaken):
+			return echo.NewHTTPError(http.StatusConflict, "Email already registered")
+		case err != nil:
+			return accountError(err, "Failed to change email")
+		}
+
+		return c.JSON(http.StatusOK, Model.NewUserResponse(user))
+	}
+}
+
+// @Summary Delete own account
+// @Description Permanently delete the signed-in user's account after confirming the password, or for accounts without one a recent login or a two-factor code. Borrowed books must be returned first.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param confirmation body Model.DeleteAccountRequest true "Current password"
+// @Success 204 "Account deleted"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 422 {object} map[string]interface{} "Validation failed or password incorrect"
+// @Failure 409 {object} map[string]string "Books still on loan"
+// @Failure 401 {object} map[string]string "Recent login required"
+// @Failure 500 {object} map[string]string "Failed to delete account"
+// @Router /me [delete]
+func deleteAccountHandler(users Service.UserService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.DeleteAccountRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		err := users.DeleteAccount(c.Request().Context(), c.Get(Config.UserIDKey).(int), reauthentication(c, request.Password, request.Code))
+		switch {
+		case errors.Is(err, Service.ErrInvalidCredentials):
+			return Config.NewValidationError("password", "is incorrect")
+		case errors.Is(err, Service.ErrHasOpenLoans):
+			return echo.NewHTTPError(http.StatusConflict, "Return your borrowed books before deleting your account; GET /me lists them")
+		case err != nil:
+			return accountError(err, "Failed to delete account")
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// accountError maps the errors every account endpoint shares, falling back
+// to a 500 with message.
+func accountError(err error, message string) error {
+	switch {
+	case errors.Is(err, Service.ErrUserNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	case errors.Is(err, Service.ErrReauthenticationRequired):
+		return echo.NewHTTPError(http.StatusUnauthorized, "Log in again, or send a two-factor code, to confirm this change")
+	case errors.Is(err, Service.ErrInvalidTwoFactorCode):
+		return Config.NewValidationError("code", "is incorrect or was already used")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, message).SetInternal(err)
+}
+
+// reauthentication collects what a request offers to confirm a sensitive
+// change: the password or code in its body and the session of its token.
+func reauthentication(c echo.Context, password, code string) Service.Reauthentication {
+	tokenID, _ := c.Get(Config.TokenIDKey).(string)
+	return Service.Reauthentication{Password: password, TokenID: tokenID, Code: code}
+}
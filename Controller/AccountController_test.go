@@ -0,0 +1,514 @@
+package Controller_test
+
+import (
+	"awesomeProject/Model"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestViewProfile(t *testing.T) {
+	s := newTestServer(t)
+	user := aUser().withEmail("ada@example.com").create(s)
+	book := aBook().create(s)
+	token := s.loginAs(user.Email, user.Password)
+	expectStatus(t, s.do(http.MethodGet, "/view/borrow/1", nil, token), http.StatusOK)
+
+	rec := s.do(http.MethodGet, "/me", nil, token)
+
+	expectStatus(t, rec, http.StatusOK)
+	var got Model.ProfileResponse
+	decode(t, rec, &got)
+	if got.UserId != user.UserId || got.Email != "ada@example.com" || got.Role != Model.RoleMember {
+		t.Errorf("profile = %+v", got)
+	}
+	if len(got.Loans) != 1 || got.Loans[0].BookID != book.ID {
+		t.Errorf("loans = %+v, want the loan of book %d", got.Loans, book.ID)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	t.Run("renames the user", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		token := s.loginAs(user.Email, user.Password)
+
+		rec := s.do(http.MethodPatch, "/me", map[string]string{"userName": "  Ada  "}, token)
+
+		expectStatus(t, rec, http.StatusOK)
+		var got Model.UserResponse
+		decode(t, rec, &got)
+		if got.UserName != "Ada" || got.Email != user.Email {
+			t.Errorf("got %+v", got)
+		}
+	})
+
+	t.Run("keeps absent fields", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+
+		rec := s.do(http.MethodPatch, "/me", map[string]string{}, s.loginAs(user.Email, user.Password))
+
+		expectStatus(t, rec, http.StatusOK)
+		var got Model.UserResponse
+		decode(t, rec, &got)
+		if got.UserName != user.UserName {
+			t.Errorf("userName = %q, want %q", got.UserName, user.UserName)
+		}
+	})
+
+	t.Run("rejects a blank name", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPatch, "/me", map[string]string{"userName": " "}, s.login())
+
+		expectValidationError(t, rec, "userName")
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Run("revokes other sessions", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		other := s.loginAs(user.Email, user.Password)
+		current := s.loginAs(user.Email, user.Password)
+
+		rec := s.do(http.MethodPost, "/me/password", map[string]string{
+			"currentPassword": user.Password,
+			"newPassword":     "brand-new-1",
+		}, current)
+
+		expectStatus(t, rec, http.StatusOK)
+		var body Model.TokenResponse
+		decode(t, rec, &body)
+		expectStatus(t, s.do(http.MethodGet, "/me", nil, body.Token), http.StatusOK)
+		expectError(t, s.do(http.MethodGet, "/me", nil, other), http.StatusUnauthorized, "Token has been revoked")
+		expectError(t, s.do(http.MethodGet, "/me", nil, current), http.StatusUnauthorized, "Token has been revoked")
+
+		s.loginAs(user.Email, "brand-new-1")
+		rec = s.do(http.MethodPost, "/login", map[string]string{"email": user.Email, "password": user.Password}, "")
+		expectError(t, rec, http.StatusUnauthorized, "Invalid email or password")
+	})
+
+	t.Run("requires the current password", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/me/password", map[string]string{
+			"currentPassword": "not-it-1",
+			"newPassword":     "brand-new-1",
+		}, s.login())
+
+		expectValidationError(t, rec, "currentPassword")
+	})
+
+	t.Run("enforces the password policy", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+
+		rec := s.do(http.MethodPost, "/me/password", map[string]string{
+			"currentPassword": user.Password,
+			"newPassword":     "short",
+		}, s.loginAs(user.Email, user.Password))
+
+		expectValidationError(t, rec, "newPassword")
+	})
+}
+
+func TestChangeEmail(t *testing.T) {
+	t.Run("moves the account once the new address is verified", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+
+		rec := s.do(http.MethodPost, "/me/email", map[string]string{
+			"newEmail": "New.Address@Example.com",
+			"password": user.Password,
+		}, s.loginAs(user.Email, user.Password))
+
+		expectStatus(t, rec, http.StatusOK)
+		var got Model.UserResponse
+		decode(t, rec, &got)
+		if got.Email != user.Email || !got.EmailVerified || got.PendingEmail != "new.address@example.com" {
+			t.Errorf("got %+v, want the verified old address pending the new one", got)
+		}
+		s.loginAs(user.Email, user.Password)
+
+		s.verifyEmail("new.address@example.com")
+
+		s.loginAs("new.address@example.com", user.Password)
+		rec = s.do(http.MethodPost, "/login", map[string]string{"email": user.Email, "password": user.Password}, "")
+		expectStatus(t, rec, http.StatusUnauthorized)
+	})
+
+	t.Run("tells the old address about the move", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		s.do(http.MethodPost, "/me/email", map[string]string{"newEmail": "new@example.com", "password": user.Password}, s.loginAs(user.Email, user.Password))
+
+		s.verifyEmail("new@example.com")
+
+		messages := s.outbox.Messages()
+		notice := messages[len(messages)-1]
+		if notice.To != user.Email || !strings.Contains(notice.Body, "new@example.com") {
+			t.Errorf("last mail = %+v, want a notice to %s naming the new address", notice, user.Email)
+		}
+	})
+
+	t.Run("voids reset links sent to the old address", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		s.do(http.MethodPost, "/me/email", map[string]string{"newEmail": "new@example.com", "password": user.Password}, s.loginAs(user.Email, user.Password))
+		expectStatus(t, s.do(http.MethodPost, "/password/forgot", map[string]string{"email": user.Email}, ""), http.StatusAccepted)
+		link, err := url.Parse(s.lastLink(user.Email, "Reset"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.verifyEmail("new@example.com")
+
+		rec := s.do(http.MethodPost, "/password/reset", map[string]string{"token": link.Query().Get("token"), "newPassword": "brand-new-1"}, "")
+		expectError(t, rec, http.StatusBadRequest, "Invalid or expired reset link")
+	})
+
+	t.Run("cancels a pending change when asked for the current address", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		token := s.loginAs(user.Email, user.Password)
+		s.do(http.MethodPost, "/me/email", map[string]string{"newEmail": "new@example.com", "password": user.Password}, token)
+		link := s.lastLink("new@example.com", "Verify")
+
+		rec := s.do(http.MethodPost, "/me/email", map[string]string{"newEmail": user.Email, "password": user.Password}, token)
+
+		expectStatus(t, rec, http.StatusOK)
+		var got Model.UserResponse
+		decode(t, rec, &got)
+		if got.PendingEmail != "" {
+			t.Errorf("pendingEmail = %q, want none", got.PendingEmail)
+		}
+		expectError(t, s.do(http.MethodGet, link, nil, ""), http.StatusBadRequest, "Invalid or expired verification link")
+	})
+
+	t.Run("refuses an address taken before the link is followed", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		s.do(http.MethodPost, "/me/email", map[string]string{"newEmail": "new@example.com", "password": user.Password}, s.loginAs(user.Email, user.Password))
+		link := s.lastLink("new@example.com", "Verify")
+		aUser().withEmail("new@example.com").create(s)
+
+		expectError(t, s.do(http.MethodGet, link, nil, ""), http.StatusConflict, "Email already registered")
+		s.loginAs(user.Email, user.Password)
+	})
+
+	t.Run("refuses a taken address", func(t *testing.T) {
+		s := newTestServer(t)
+		aUser().withEmail("taken@example.com").create(s)
+		user := aUser().create(s)
+
+		rec := s.do(http.MethodPost, "/me/email", map[string]string{
+			"newEmail": "taken@example.com",
+			"password": user.Password,
+		}, s.loginAs(user.Email, user.Password))
+
+		expectError(t, rec, http.StatusConflict, "Email already registered")
+	})
+
+	t.Run("requires the password", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/me/email", map[string]string{
+			"newEmail": "new@example.com",
+			"password": "not-it-1",
+		}, s.login())
+
+		expectValidationError(t, rec, "password")
+	})
+}
+
+func TestDeleteAccount(t *testing.T) {
+	t.Run("deletes the account and its tokens", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		token := s.loginAs(user.Email, user.Password)
+
+		rec := s.do(http.MethodDelete, "/me", map[string]string{"password": user.Password}, token)
+
+		expectStatus(t, rec, http.StatusNoContent)
+		expectError(t, s.do(http.MethodGet, "/me", nil, token), http.StatusUnauthorized, "Token has been revoked")
+		var count int64
+		s.db.Model(&Model.UserModel{}).Where("user_id = ?", user.UserId).Count(&count)
+		if count != 0 {
+			t.Error("user still stored")
+		}
+	})
+
+	t.Run("waits for open loans to be returned", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		aBook().create(s)
+		token := s.loginAs(user.Email, user.Password)
+		expectStatus(t, s.do(http.MethodGet, "/view/borrow/1", nil, token), http.StatusOK)
+
+		rec := s.do(http.MethodDelete, "/me", map[string]string{"password": user.Password}, token)
+
+		expectError(t, rec, http.StatusConflict, "Return your borrowed books before deleting your account; GET /me lists them")
+
+		expectStatus(t, s.do(http.MethodGet, "/view/return/1", nil, token), http.StatusOK)
+		rec = s.do(http.MethodDelete, "/me", map[string]string{"password": user.Password}, token)
+		expectStatus(t, rec, http.StatusNoContent)
+		var loans int64
+		s.db.Model(&Model.LoanModel{}).Where("user_id = ?", user.UserId).Count(&loans)
+		if loans != 1 {
+			t.Errorf("loan history has %d loans, want 1", loans)
+		}
+	})
+
+	t.Run("requires the password", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodDelete, "/me", map[string]string{"password": "not-it-1"}, s.login())
+
+		expectValidationError(t, rec, "password")
+	})
+}
+
+func TestEmailVerification(t *testing.T) {
+	register := func(s *testServer, email string) {
+		s.t.Helper()
+		rec := s.do(http.MethodPost, "/register", map[string]string{"userName": "ada", "email": email, "password": "secret-123"}, "")
+		expectStatus(s.t, rec, http.StatusOK)
+		var got Model.UserResponse
+		decode(s.t, rec, &got)
+		if got.EmailVerified {
+			s.t.Error("new account is already verified")
+		}
+	}
+	login := func(s *testServer, email string) *httptest.ResponseRecorder {
+		return s.do(http.MethodPost, "/login", map[string]string{"email": email, "password": "secret-123"}, "")
+	}
+
+	t.Run("blocks login until the link is followed", func(t *testing.T) {
+		s := newTestServer(t)
+		register(s, "ada@example.com")
+
+		expectError(t, login(s, "ada@example.com"), http.StatusForbidden,
+			"Email address not verified; follow the link we mailed you or request a new one")
+
+		rec := s.do(http.MethodGet, s.lastLink("ada@example.com", "Verify"), nil, "")
+		expectStatus(t, rec, http.StatusOK)
+		var got Model.UserResponse
+		decode(t, rec, &got)
+		if !got.EmailVerified {
+			t.Error("account not verified after following the link")
+		}
+		expectStatus(t, login(s, "ada@example.com"), http.StatusOK)
+	})
+
+	t.Run("sends an absolute link", func(t *testing.T) {
+		t.Setenv("APP_BASE_URL", "https://library.example.org/")
+		s := newTestServer(t)
+		register(s, "ada@example.com")
+
+		mail := s.outbox.Messages()[0]
+		if mail.To != "ada@example.com" || !strings.Contains(mail.Body, "https://library.example.org/verify-email?token=") {
+			t.Errorf("mail = %+v", mail)
+		}
+	})
+
+	t.Run("rejects expired links", func(t *testing.T) {
+		s := newTestServer(t)
+		register(s, "ada@example.com")
+		link := s.lastLink("ada@example.com", "Verify")
+		s.clock.Advance(25 * time.Hour)
+
+		expectError(t, s.do(http.MethodGet, link, nil, ""), http.StatusBadRequest, "Invalid or expired verification link")
+
+		rec := s.do(http.MethodPost, "/verify-email/resend", map[string]string{"email": "ADA@example.com"}, "")
+		expectStatus(t, rec, http.StatusAccepted)
+		s.verifyEmail("ada@example.com")
+		expectStatus(t, login(s, "ada@example.com"), http.StatusOK)
+	})
+
+	t.Run("rejects tampered links", func(t *testing.T) {
+		s := newTestServer(t)
+		register(s, "ada@example.com")
+
+		link := s.lastLink("ada@example.com", "Verify")
+		expectError(t, s.do(http.MethodGet, link+"x", nil, ""), http.StatusBadRequest, "Invalid or expired verification link")
+		expectError(t, s.do(http.MethodGet, "/verify-email", nil, ""), http.StatusBadRequest, "Invalid or expired verification link")
+	})
+
+	t.Run("rejects links for a previous address", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().withEmail("old@example.com").create(s)
+		token := s.loginAs(user.Email, user.Password)
+		rec := s.do(http.MethodPost, "/me/email", map[string]string{"newEmail": "typo@example.com", "password": user.Password}, token)
+		expectStatus(t, rec, http.StatusOK)
+		typoLink := s.lastLink("typo@example.com", "Verify")
+		rec = s.do(http.MethodPost, "/me/email", map[string]string{"newEmail": "new@example.com", "password": user.Password}, token)
+		expectStatus(t, rec, http.StatusOK)
+
+		expectError(t, s.do(http.MethodGet, typoLink, nil, ""), http.StatusBadRequest, "Invalid or expired verification link")
+	})
+
+	t.Run("resend reveals nothing", func(t *testing.T) {
+		s := newTestServer(t)
+		verified := aUser().create(s)
+
+		for _, email := range []string{"nobody@example.com", verified.Email} {
+			rec := s.do(http.MethodPost, "/verify-email/resend", map[string]string{"email": email}, "")
+			expectStatus(t, rec, http.StatusAccepted)
+		}
+		if sent := s.outbox.Messages(); len(sent) != 0 {
+			t.Errorf("sent %d mails, want none", len(sent))
+		}
+	})
+
+	t.Run("blocks borrowing for unverified addresses", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().create(s)
+		user := aUser().create(s)
+		token := s.loginAs(user.Email, user.Password)
+		s.db.Model(&Model.UserModel{}).Where("user_id = ?", user.UserId).Update("email_verified_at", nil)
+
+		rec := s.do(http.MethodGet, "/view/borrow/1", nil, token)
+
+		expectError(t, rec, http.StatusForbidden, "Verify your email address before borrowing books")
+		expectStatus(t, s.do(http.MethodPost, "/verify-email/resend", map[string]string{"email": user.Email}, ""), http.StatusAccepted)
+		s.verifyEmail(user.Email)
+		expectStatus(t, s.do(http.MethodGet, "/view/borrow/1", nil, token), http.StatusOK)
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	requestReset := func(s *testServer, email string) *httptest.ResponseRecorder {
+		s.t.Helper()
+		return s.do(http.MethodPost, "/password/forgot", map[string]string{"email": email}, "")
+	}
+	resetToken := func(s *testServer, email string) string {
+		s.t.Helper()
+		link, err := url.Parse(s.lastLink(email, "Reset"))
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		return link.Query().Get("token")
+	}
+	reset := func(s *testServer, token, password string) *httptest.ResponseRecorder {
+		s.t.Helper()
+		return s.do(http.MethodPost, "/password/reset", map[string]string{"token": token, "newPassword": password}, "")
+	}
+
+	t.Run("sets a new password and signs out every session", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		session := s.loginAs(user.Email, user.Password)
+		expectStatus(t, requestReset(s, user.Email), http.StatusAccepted)
+		token := resetToken(s, user.Email)
+
+		expectStatus(t, reset(s, token, "brand-new-1"), http.StatusOK)
+
+		expectError(t, s.do(http.MethodGet, "/me", nil, session), http.StatusUnauthorized, "Token has been revoked")
+		s.loginAs(user.Email, "brand-new-1")
+		var stored Model.PasswordResetModel
+		s.db.Where("user_id = ?", user.UserId).First(&stored)
+		if stored.TokenHash == token || stored.UsedAt == nil {
+			t.Errorf("stored reset = %+v, want a hashed, used token", stored)
+		}
+	})
+
+	t.Run("opens the mailed link", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		expectStatus(t, requestReset(s, user.Email), http.StatusAccepted)
+		link, err := url.Parse(s.lastLink(user.Email, "Reset"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rec := s.do(http.MethodGet, link.RequestURI(), nil, "")
+		expectStatus(t, rec, http.StatusOK)
+		if got := rec.Header().Get(echo.HeaderContentType); !strings.HasPrefix(got, echo.MIMETextHTML) {
+			t.Errorf("content type = %q", got)
+		}
+		if want := `name="token" value="` + link.Query().Get("token") + `"`; !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("form lacks %s:\n%s", want, rec.Body)
+		}
+		expectError(t, s.do(http.MethodGet, "/password/reset", nil, ""), http.StatusBadRequest, "Invalid or expired reset link")
+
+		t.Setenv("PASSWORD_RESET_URL", "https://app.example.org/reset?lang=en")
+		rec = s.do(http.MethodGet, link.RequestURI(), nil, "")
+		expectStatus(t, rec, http.StatusFound)
+		if want := "https://app.example.org/reset?lang=en&token=" + link.Query().Get("token"); rec.Header().Get(echo.HeaderLocation) != want {
+			t.Errorf("Location = %q, want %q", rec.Header().Get(echo.HeaderLocation), want)
+		}
+	})
+
+	t.Run("works only once", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		requestReset(s, user.Email)
+		token := resetToken(s, user.Email)
+		expectStatus(t, reset(s, token, "brand-new-1"), http.StatusOK)
+
+		expectError(t, reset(s, token, "brand-new-2"), http.StatusBadRequest, "Invalid or expired reset link")
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		requestReset(s, user.Email)
+		s.clock.Advance(31 * time.Minute)
+
+		expectError(t, reset(s, resetToken(s, user.Email), "brand-new-1"), http.StatusBadRequest, "Invalid or expired reset link")
+	})
+
+	t.Run("supersedes earlier links", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		requestReset(s, user.Email)
+		first := resetToken(s, user.Email)
+		requestReset(s, user.Email)
+
+		expectError(t, reset(s, first, "brand-new-1"), http.StatusBadRequest, "Invalid or expired reset link")
+		expectStatus(t, reset(s, resetToken(s, user.Email), "brand-new-1"), http.StatusOK)
+	})
+
+	t.Run("does not reveal unknown addresses", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+
+		known := requestReset(s, user.Email)
+		unknown := requestReset(s, "nobody@example.com")
+
+		expectStatus(t, unknown, known.Code)
+		var knownBody, unknownBody map[string]string
+		decode(t, known, &knownBody)
+		decode(t, unknown, &unknownBody)
+		if knownBody["message"] != unknownBody["message"] {
+			t.Errorf("messages differ: %q vs %q", knownBody["message"], unknownBody["message"])
+		}
+		if sent := len(s.outbox.Messages()); sent != 1 {
+			t.Errorf("sent %d mails, want 1", sent)
+		}
+	})
+
+	t.Run("enforces the password policy", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		requestReset(s, user.Email)
+
+		expectValidationError(t, reset(s, resetToken(s, user.Email), "short"), "newPassword")
+	})
+
+	t.Run("verifies the address", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().unverified().create(s)
+		requestReset(s, user.Email)
+
+		expectStatus(t, reset(s, resetToken(s, user.Email), "brand-new-1"), http.StatusOK)
+
+		s.loginAs(user.Email, "brand-new-1")
+	})
+}
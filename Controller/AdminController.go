@@ -0,0 +1,286 @@
+package Controller
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Service"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// @Summary Unlock a user account
+// @Description Clear the failed login attempts of a user's address, lifting any backoff or lockout. Per-client limits are left in place.
+// @Tags admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]string "Account unlocked"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 422 {object} map[string]interface{} "Invalid user ID"
+// @Failure 500 {object} map[string]string "Failed to unlock account"
+// @Router /admin/users/{id}/unlock [post]
+func unlockUserHandler(users Service.UserService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+
+		if err := users.UnlockAccount(c.Request().Context(), userID); err != nil {
+			if errors.Is(err, Service.ErrUserNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "User not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to unlock account").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusOK, map[string]string{"message": "Account unlocked"})
+	}
+}
+
+// @Summary Create an API key
+// @Description Issue a long-lived key for a client that cannot log in, limited to the given scopes. Send it in the X-API-Key header or as Bearer token. The key is shown only in this response.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param key body Model.CreateAPIKeyRequest true "Name, scopes and optional expiry"
+// @Success 201 {object} Model.CreatedAPIKeyResponse "Created key"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 500 {object} map[string]string "Failed to create API key"
+// @Router /admin/api-keys [post]
+func createAPIKeyHandler(keys Service.APIKeyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.CreateAPIKeyRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		key, secret, err := keys.Create(c.Request().Context(), c.Get(Config.UserIDKey).(int), request.Name, request.Scopes, request.ExpiresAt)
+		if err != nil {
+			if errors.Is(err, Service.ErrAPIKeyExpiry) {
+				return Config.NewValidationError("expiresAt", "must be in the future")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create API key").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusCreated, Model.CreatedAPIKeyResponse{APIKeyResponse: Model.NewAPIKeyResponse(key), Key: secret})
+	}
+}
+
+// @Summary List API keys
+// @Description List every API key, including revoked and expired ones, without the keys themselves
+// @Tags admin
+// @Produce json
+// @Success 200 {array} Model.APIKeyResponse "API keys"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 500 {object} map[string]string "Failed to list API keys"
+// @Router /admin/api-keys [get]
+func listAPIKeysHandler(keys Service.APIKeyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		models, err := keys.List(c.Request().Context())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list API keys").SetInternal(err)
+		}
+
+		response := make([]Model.APIKeyResponse, 0, len(models))
+		for _, key := range models {
+			response = append(response, Model.NewAPIKeyResponse(key))
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// @Summary Revoke an API key
+// @Description Stop an API key from working. The key stays listed as revoked.
+// @Tags admin
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 200 {object} Model.APIKeyResponse "Revoked key"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "API key not found"
+// @Failure 422 {object} map[string]interface{} "Invalid API key ID"
+// @Failure 500 {object} map[string]string "Failed to revoke API key"
+// @Router /admin/api-keys/{id} [delete]
+func revokeAPIKeyHandler(keys Service.APIKeyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+
+		key, err := keys.Revoke(c.Request().Context(), id)
+		if err != nil {
+			if errors.Is(err, Service.ErrAPIKeyNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke API key").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusOK, Model.NewAPIKeyResponse(key))
+	}
+}
+
+// @Summary List the copies of a book
+// @Description List every physical copy of a title with its barcode and status, withdrawn ones included
+// @Tags admin
+// @Produce json
+// @Param id path string true "Book ID"
+// @Success 200 {array} Model.BookCopyResponse "Copies"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Book not found"
+// @Failure 422 {object} map[string]interface{} "Invalid book ID"
+// @Failure 500 {object} map[string]string "Failed to list copies"
+// @Router /admin/books/{id}/copies [get]
+func listCopiesHandler(catalog Service.CatalogService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bookID, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+
+		items, err := catalog.Copies(c.Request().Context(), bookID)
+		if err != nil {
+			if errors.Is(err, Service.ErrBookNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "Book not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list copies").SetInternal(err)
+		}
+
+		response := make([]Model.BookCopyResponse, 0, len(items))
+		for _, item := range items {
+			response = append(response, Model.NewBookCopyResponse(item))
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// @Summary Add a copy of a book
+// @Description Register a newly acquired copy of a title. It is available for borrowing straight away.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Book ID"
+// @Param copy body Model.AddBookCopyRequest true "Barcode and optional acquisition date"
+// @Success 201 {object} Model.BookCopyResponse "Created copy"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Book not found"
+// @Failure 409 {object} map[string]string "Barcode already in use"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 500 {object} map[string]string "Failed to add copy"
+// @Router /admin/books/{id}/copies [post]
+func addCopyHandler(catalog Service.CatalogService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bookID, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+		var request Model.AddBookCopyRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		item, err := catalog.AddCopy(c.Request().Context(), bookID, request.Barcode, request.AcquiredAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, Service.ErrBookNotFound):
+				return echo.NewHTTPError(http.StatusNotFound, "Book not found")
+			case errors.Is(err, Service.ErrDuplicateBarcode):
+				return echo.NewHTTPError(http.StatusConflict, "Barcode already in use")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to add copy").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusCreated, Model.NewBookCopyResponse(item))
+	}
+}
+
+// @Summary Set the ISBN of a book
+// @Description Give a book its ISBN-10 or ISBN-13, with or without hyphens. Both forms are stored and either finds the book. An empty ISBN clears it.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Book ID"
+// @Param isbn body Model.SetBookISBNRequest true "ISBN"
+// @Success 200 {object} Model.BookResponse "Updated book"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Book not found"
+// @Failure 409 {object} map[string]string "Another book has this ISBN"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 500 {object} map[string]string "Failed to save ISBN"
+// @Router /admin/books/{id}/isbn [put]
+func setBookISBNHandler(catalog Service.CatalogService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bookID, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+		var request Model.SetBookISBNRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		book, err := catalog.SetISBN(c.Request().Context(), bookID, request.ISBN)
+		if err != nil {
+			switch {
+			case errors.Is(err, Service.ErrBookNotFound):
+				return echo.NewHTTPError(http.StatusNotFound, "Book not found")
+			case errors.Is(err, Service.ErrDuplicateISBN):
+				return echo.NewHTTPError(http.StatusConflict, "Another book has this ISBN")
+			case errors.Is(err, Model.ErrInvalidISBN):
+				return Config.NewValidationError("isbn", "must be a valid ISBN-10 or ISBN-13")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save ISBN").SetInternal(err)
+		}
+
+		availability, err := catalog.Availability(c.Request().Context(), book.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save ISBN").SetInternal(err)
+		}
+		return c.JSON(http.StatusOK, Model.NewBookResponse(book, availability[book.ID]))
+	}
+}
+
+// @Summary Change the status of a copy
+// @Description Mark a copy as lost, in repair, withdrawn or available again. Copies on loan must be returned first.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Copy ID"
+// @Param status body Model.UpdateBookCopyRequest true "New status"
+// @Success 200 {object} Model.BookCopyResponse "Updated copy"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Copy not found"
+// @Failure 409 {object} map[string]string "Copy is on loan"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 500 {object} map[string]string "Failed to update copy"
+// @Router /admin/copies/{id} [patch]
+func updateCopyHandler(catalog Service.CatalogService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		copyID, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+		var request Model.UpdateBookCopyRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		item, err := catalog.SetCopyStatus(c.Request().Context(), copyID, request.Status)
+		if err != nil {
+			switch {
+			case errors.Is(err, Service.ErrCopyNotFound):
+				return echo.NewHTTPError(http.StatusNotFound, "Copy not found")
+			case errors.Is(err, Service.ErrCopyOnLoan):
+				return echo.NewHTTPError(http.StatusConflict, "Copy is on loan; it has to be returned first")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update copy").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusOK, Model.NewBookCopyResponse(item))
+	}
+}
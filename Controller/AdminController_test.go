@@ -0,0 +1,421 @@
+package Controller_test
+
+import (
+	"awesomeProject/Model"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginThrottling(t *testing.T) {
+	const tooMany = "Too many failed login attempts; try again later"
+
+	t.Run("backs off exponentially after a few failures", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+
+		for i := 0; i < 3; i++ {
+			expectStatus(t, s.attemptLogin(user.Email, "wrong-1", "192.0.2.1"), http.StatusUnauthorized)
+		}
+		expectStatus(t, s.attemptLogin(user.Email, "wrong-1", "192.0.2.1"), http.StatusUnauthorized)
+
+		rec := s.attemptLogin(user.Email, user.Password, "192.0.2.1")
+		expectError(t, rec, http.StatusTooManyRequests, tooMany)
+		if got := rec.Header().Get("Retry-After"); got != "1" {
+			t.Errorf("Retry-After = %q, want 1", got)
+		}
+
+		s.clock.Advance(time.Second)
+		expectStatus(t, s.attemptLogin(user.Email, "wrong-1", "192.0.2.1"), http.StatusUnauthorized)
+		rec = s.attemptLogin(user.Email, user.Password, "192.0.2.1")
+		if got := rec.Header().Get("Retry-After"); got != "2" {
+			t.Errorf("Retry-After = %q, want 2 after another failure", got)
+		}
+
+		s.clock.Advance(2 * time.Second)
+		expectStatus(t, s.attemptLogin(user.Email, user.Password, "192.0.2.1"), http.StatusOK)
+	})
+
+	t.Run("counts concurrent attempts", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+
+		codes := make(chan int, 20)
+		var wg sync.WaitGroup
+		for range cap(codes) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				codes <- s.attemptLogin(user.Email, "wrong-1", "192.0.2.1").Code
+			}()
+		}
+		wg.Wait()
+		close(codes)
+
+		refused := 0
+		for code := range codes {
+			if code == http.StatusUnauthorized {
+				refused++
+			}
+		}
+		if refused != 4 {
+			t.Errorf("%d of %d concurrent attempts checked the password, want 4", refused, cap(codes))
+		}
+	})
+
+	t.Run("does not count successful logins", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+
+		for i := 0; i < 25; i++ {
+			expectStatus(t, s.attemptLogin(user.Email, user.Password, "192.0.2.1"), http.StatusOK)
+		}
+		expectStatus(t, s.attemptLogin(uniqueEmail(), "wrong-1", "192.0.2.1"), http.StatusUnauthorized)
+	})
+
+	t.Run("locks unknown and known addresses alike", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+
+		for _, email := range []string{user.Email, "nobody@example.com"} {
+			for i := 0; i < 10; i++ {
+				rec := s.attemptLogin(email, "wrong-1", "192.0.2.1")
+				expectError(t, rec, http.StatusUnauthorized, "Invalid email or password")
+				s.clock.Advance(time.Minute)
+			}
+			rec := s.attemptLogin(email, "wrong-1", "192.0.2.1")
+			expectError(t, rec, http.StatusTooManyRequests, tooMany)
+			if got := rec.Header().Get("Retry-After"); got != "840" {
+				t.Errorf("%s: Retry-After = %q, want 840", email, got)
+			}
+		}
+	})
+
+	t.Run("limits clients spraying many addresses", func(t *testing.T) {
+		s := newTestServer(t)
+
+		for i := 0; i < 21; i++ {
+			expectStatus(t, s.attemptLogin(uniqueEmail(), "wrong-1", "192.0.2.1"), http.StatusUnauthorized)
+		}
+
+		expectStatus(t, s.attemptLogin(uniqueEmail(), "wrong-1", "192.0.2.1"), http.StatusTooManyRequests)
+		expectStatus(t, s.attemptLogin(uniqueEmail(), "wrong-1", "198.51.100.7"), http.StatusUnauthorized)
+	})
+
+	t.Run("ignores forwarded addresses by default", func(t *testing.T) {
+		s := newTestServer(t)
+		for i := 0; i < 21; i++ {
+			s.attemptLogin(uniqueEmail(), "wrong-1", "192.0.2.1")
+		}
+
+		body, _ := json.Marshal(map[string]string{"email": uniqueEmail(), "password": "wrong-1"})
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXForwardedFor, "203.0.113.9")
+		req.RemoteAddr = "192.0.2.1:40000"
+
+		expectStatus(t, s.serve(req), http.StatusTooManyRequests)
+	})
+}
+
+func TestUnlockUser(t *testing.T) {
+	t.Run("lifts a lockout", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		for i := 0; i < 10; i++ {
+			s.attemptLogin(user.Email, "wrong-1", "192.0.2.1")
+			s.clock.Advance(time.Minute)
+		}
+		expectStatus(t, s.attemptLogin(user.Email, user.Password, "198.51.100.7"), http.StatusTooManyRequests)
+
+		rec := s.do(http.MethodPost, "/admin/users/1/unlock", nil, s.loginAdmin())
+
+		expectStatus(t, rec, http.StatusOK)
+		expectStatus(t, s.attemptLogin(user.Email, user.Password, "198.51.100.7"), http.StatusOK)
+	})
+
+	t.Run("requires an admin", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/admin/users/1/unlock", nil, s.login())
+
+		expectError(t, rec, http.StatusForbidden, "Insufficient permissions")
+	})
+
+	t.Run("reports unknown users", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/admin/users/99/unlock", nil, s.loginAdmin())
+
+		expectError(t, rec, http.StatusNotFound, "User not found")
+	})
+}
+
+// createAPIKey has an admin issue a key with scopes and returns it.
+func (s *testServer) createAPIKey(admin string, scopes ...string) Model.CreatedAPIKeyResponse {
+	s.t.Helper()
+
+	rec := s.do(http.MethodPost, "/admin/api-keys", map[string]interface{}{"name": "Kiosk", "scopes": scopes}, admin)
+	expectStatus(s.t, rec, http.StatusCreated)
+	var key Model.CreatedAPIKeyResponse
+	decode(s.t, rec, &key)
+	return key
+}
+
+// withAPIKey sends a request authenticated by the X-API-Key header.
+func (s *testServer) withAPIKey(method, path, key string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-API-Key", key)
+	return s.serve(req)
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Run("grants access to scoped endpoints", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().create(s)
+		key := s.createAPIKey(s.loginAdmin(), Model.ScopeCatalogRead)
+
+		if !strings.HasPrefix(key.Key, key.Prefix+"_") || key.Prefix == "" {
+			t.Errorf("key %q does not start with its prefix %q", key.Key, key.Prefix)
+		}
+		expectStatus(t, s.withAPIKey(http.MethodGet, "/view/books", key.Key), http.StatusOK)
+		expectStatus(t, s.do(http.MethodGet, "/view/books", nil, key.Key), http.StatusOK)
+
+		var stored Model.APIKeyModel
+		s.db.First(&stored, key.ID)
+		if stored.KeyHash == key.Key || strings.Contains(stored.KeyHash, key.Prefix) {
+			t.Errorf("stored key = %+v, want only a hash", stored)
+		}
+	})
+
+	t.Run("records when a key was last used", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+		key := s.createAPIKey(admin, Model.ScopeCatalogRead)
+		s.clock.Advance(30 * time.Minute)
+		s.withAPIKey(http.MethodGet, "/view/books", key.Key)
+
+		rec := s.do(http.MethodGet, "/admin/api-keys", nil, admin)
+
+		expectStatus(t, rec, http.StatusOK)
+		var keys []map[string]interface{}
+		decode(t, rec, &keys)
+		if len(keys) != 1 || keys[0]["lastUsedAt"] != testStart.Add(30*time.Minute).UTC().Format(time.RFC3339) {
+			t.Errorf("keys = %v, want one used half an hour after the start", keys)
+		}
+		if _, leaked := keys[0]["key"]; leaked {
+			t.Error("listing exposes the key")
+		}
+	})
+
+	t.Run("are limited to their scopes", func(t *testing.T) {
+		s := newTestServer(t)
+		key := s.createAPIKey(s.loginAdmin(), Model.ScopeCatalogWrite)
+
+		expectError(t, s.withAPIKey(http.MethodGet, "/view/books", key.Key), http.StatusForbidden, "API key lacks the catalog:read scope")
+	})
+
+	t.Run("cannot act as a user", func(t *testing.T) {
+		s := newTestServer(t)
+		key := s.createAPIKey(s.loginAdmin(), Model.ScopeCatalogRead, Model.ScopeCatalogWrite)
+
+		expectError(t, s.withAPIKey(http.MethodGet, "/me", key.Key), http.StatusForbidden, "API keys cannot access this endpoint")
+		expectError(t, s.withAPIKey(http.MethodGet, "/admin/api-keys", key.Key), http.StatusForbidden, "API keys cannot access this endpoint")
+	})
+
+	t.Run("stop working when revoked", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+		key := s.createAPIKey(admin, Model.ScopeCatalogRead)
+
+		rec := s.do(http.MethodDelete, fmt.Sprintf("/admin/api-keys/%d", key.ID), nil, admin)
+
+		expectStatus(t, rec, http.StatusOK)
+		expectError(t, s.withAPIKey(http.MethodGet, "/view/books", key.Key), http.StatusUnauthorized, "Invalid or revoked API key")
+	})
+
+	t.Run("stop working when expired", func(t *testing.T) {
+		s := newTestServer(t)
+		rec := s.do(http.MethodPost, "/admin/api-keys", map[string]interface{}{
+			"name": "Nightly import", "scopes": []string{Model.ScopeCatalogRead}, "expiresAt": testStart.Add(24 * time.Hour),
+		}, s.loginAdmin())
+		var key Model.CreatedAPIKeyResponse
+		decode(t, rec, &key)
+		expectStatus(t, s.withAPIKey(http.MethodGet, "/view/books", key.Key), http.StatusOK)
+
+		s.clock.Advance(25 * time.Hour)
+
+		expectError(t, s.withAPIKey(http.MethodGet, "/view/books", key.Key), http.StatusUnauthorized, "Invalid or revoked API key")
+	})
+
+	t.Run("rejects forged keys", func(t *testing.T) {
+		s := newTestServer(t)
+		key := s.createAPIKey(s.loginAdmin(), Model.ScopeCatalogRead)
+
+		expectError(t, s.withAPIKey(http.MethodGet, "/view/books", key.Prefix+"_forged"), http.StatusUnauthorized, "Invalid or revoked API key")
+	})
+
+	t.Run("validates scopes", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/admin/api-keys", map[string]interface{}{"name": "Kiosk", "scopes": []string{"everything"}}, s.loginAdmin())
+
+		expectValidationError(t, rec, "scopes[0]")
+	})
+
+	t.Run("are managed by admins only", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/admin/api-keys", map[string]interface{}{"name": "Kiosk", "scopes": []string{Model.ScopeCatalogRead}}, s.login())
+
+		expectError(t, rec, http.StatusForbidden, "Insufficient permissions")
+	})
+}
+
+func TestBookCopies(t *testing.T) {
+	t.Run("adds and lists copies", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().create(s)
+		admin := s.loginAdmin()
+		acquired := testStart.AddDate(-1, 0, 0)
+
+		rec := s.do(http.MethodPost, fmt.Sprintf("/admin/books/%d/copies", book.ID), map[string]interface{}{"barcode": " LIB-7 ", "acquiredAt": acquired}, admin)
+
+		expectStatus(t, rec, http.StatusCreated)
+		var created Model.BookCopyResponse
+		decode(t, rec, &created)
+		if created.Barcode != "LIB-7" || created.Status != Model.CopyAvailable || !created.AcquiredAt.Equal(acquired) {
+			t.Errorf("created = %+v", created)
+		}
+		rec = s.do(http.MethodGet, fmt.Sprintf("/admin/books/%d/copies", book.ID), nil, admin)
+		expectStatus(t, rec, http.StatusOK)
+		var copies []Model.BookCopyResponse
+		decode(t, rec, &copies)
+		if len(copies) != 2 || copies[1] != created {
+			t.Errorf("copies = %+v, want the fixture's and %+v", copies, created)
+		}
+	})
+
+	t.Run("keeps barcodes unique", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().create(s)
+		admin := s.loginAdmin()
+		expectStatus(t, s.do(http.MethodPost, "/admin/books/1/copies", map[string]string{"barcode": "LIB-7"}, admin), http.StatusCreated)
+
+		rec := s.do(http.MethodPost, "/admin/books/1/copies", map[string]string{"barcode": "LIB-7"}, admin)
+
+		expectError(t, rec, http.StatusConflict, "Barcode already in use")
+	})
+
+	t.Run("404 for an unknown book", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/admin/books/42/copies", map[string]string{"barcode": "LIB-7"}, s.loginAdmin())
+
+		expectError(t, rec, http.StatusNotFound, "Book not found")
+	})
+
+	t.Run("leaves copies on loan alone", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().create(s)
+		expectStatus(t, s.do(http.MethodGet, "/view/borrow/1", nil, s.login()), http.StatusOK)
+
+		rec := s.do(http.MethodPatch, "/admin/copies/1", map[string]string{"status": Model.CopyLost}, s.loginAdmin())
+
+		expectError(t, rec, http.StatusConflict, "Copy is on loan; it has to be returned first")
+	})
+
+	t.Run("cannot put a copy on loan by hand", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().create(s)
+
+		rec := s.do(http.MethodPatch, "/admin/copies/1", map[string]string{"status": Model.CopyOnLoan}, s.loginAdmin())
+
+		expectValidationError(t, rec, "status")
+	})
+
+	t.Run("are managed by admins only", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().create(s)
+
+		rec := s.do(http.MethodPatch, "/admin/copies/1", map[string]string{"status": Model.CopyLost}, s.login())
+
+		expectError(t, rec, http.StatusForbidden, "Insufficient permissions")
+	})
+}
+
+func TestBookISBN(t *testing.T) {
+	t.Run("stores both forms of an ISBN", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().create(s)
+		admin := s.loginAdmin()
+
+		rec := s.do(http.MethodPut, fmt.Sprintf("/admin/books/%d/isbn", book.ID), map[string]string{"isbn": "0-8044-2957-x"}, admin)
+
+		expectStatus(t, rec, http.StatusOK)
+		var got Model.BookResponse
+		decode(t, rec, &got)
+		if got.ISBN13 != "9780804429573" || got.ISBN10 != "080442957X" {
+			t.Errorf("ISBNs = %q and %q, want 9780804429573 and 080442957X", got.ISBN13, got.ISBN10)
+		}
+
+		token := s.login()
+		for _, isbn := range []string{"9780804429573", "978-0-8044-2957-3", "080442957X"} {
+			var books []Model.BookResponse
+			decode(t, s.do(http.MethodGet, "/view/books?isbn="+isbn, nil, token), &books)
+			if len(books) != 1 || books[0].ID != book.ID {
+				t.Errorf("books with ISBN %s = %+v, want book %d", isbn, books, book.ID)
+			}
+		}
+	})
+
+	t.Run("has no ISBN-10 for 979 ISBNs", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().create(s)
+
+		rec := s.do(http.MethodPut, fmt.Sprintf("/admin/books/%d/isbn", book.ID), map[string]string{"isbn": "979-10-323-0569-0"}, s.loginAdmin())
+
+		expectStatus(t, rec, http.StatusOK)
+		var got Model.BookResponse
+		decode(t, rec, &got)
+		if got.ISBN13 != "9791032305690" || got.ISBN10 != "" {
+			t.Errorf("ISBNs = %q and %q, want 9791032305690 only", got.ISBN13, got.ISBN10)
+		}
+	})
+
+	t.Run("rejects bad checksums", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().create(s)
+		admin := s.loginAdmin()
+
+		for _, isbn := range []string{"9780804429574", "0804429571", "1234567890123", "not an isbn"} {
+			rec := s.do(http.MethodPut, fmt.Sprintf("/admin/books/%d/isbn", book.ID), map[string]string{"isbn": isbn}, admin)
+			expectValidationError(t, rec, "isbn")
+		}
+		expectValidationError(t, s.do(http.MethodGet, "/view/books?isbn=0804429571", nil, s.login()), "isbn")
+	})
+
+	t.Run("keeps one book per edition", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().withISBN("9780199535675").create(s)
+		aBook().create(s)
+		other := aBook().create(s)
+		admin := s.loginAdmin()
+
+		rec := s.do(http.MethodPut, fmt.Sprintf("/admin/books/%d/isbn", other.ID), map[string]string{"isbn": "0199535671"}, admin)
+		expectError(t, rec, http.StatusConflict, "Another book has this ISBN")
+
+		// Books without an ISBN do not collide with each other.
+		rec = s.do(http.MethodPut, fmt.Sprintf("/admin/books/%d/isbn", other.ID), map[string]string{"isbn": ""}, admin)
+		expectStatus(t, rec, http.StatusOK)
+	})
+}
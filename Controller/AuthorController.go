@@ -0,0 +1,224 @@
+package Controller
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Service"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// @Summary List authors
+// @Description List every author by name, or the authors known by any spelling of a name
+// @Tags authors
+// @Produce json
+// @Param name query string false "A name the author is known by, in any order or punctuation"
+// @Success 200 {array} Model.AuthorResponse "Authors"
+// @Failure 500 {object} map[string]string "Failed to retrieve authors"
+// @Router /view/authors [get]
+func viewAuthorsHandler(authors Service.AuthorService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		models, err := authors.List(c.Request().Context(), c.QueryParam("name"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve authors").SetInternal(err)
+		}
+
+		response := make([]Model.AuthorResponse, 0, len(models))
+		for _, author := range models {
+			response = append(response, Model.NewAuthorResponse(author))
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// @Summary Get an author
+// @Description Retrieve an author with the other spellings of their name and the books they are credited on
+// @Tags authors
+// @Produce json
+// @Param id path string true "Author ID"
+// @Success 200 {object} Model.AuthorDetailResponse "Author and works"
+// @Failure 404 {object} map[string]string "Author not found"
+// @Failure 422 {object} map[string]interface{} "Invalid author ID"
+// @Failure 500 {object} map[string]string "Failed to find author"
+// @Router /view/authors/{id} [get]
+func viewAuthorHandler(authors Service.AuthorService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+
+		author, names, works, err := authors.Find(c.Request().Context(), id)
+		if err != nil {
+			if errors.Is(err, Service.ErrAuthorNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "Author not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to find author").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusOK, newAuthorDetailResponse(author, names, works))
+	}
+}
+
+// @Summary Create an author
+// @Description Add an author with the spellings of their name found in catalogues. Names that differ only in punctuation or order are the same name.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param author body Model.AuthorRequest true "Name, variants and dates"
+// @Success 201 {object} Model.AuthorDetailResponse "Created author"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 500 {object} map[string]string "Failed to save author"
+// @Router /admin/authors [post]
+func createAuthorHandler(authors Service.AuthorService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.AuthorRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		author := Model.AuthorModel{Name: request.Name, BirthYear: request.BirthYear, DeathYear: request.DeathYear}
+		if err := authors.Create(c.Request().Context(), &author, request.Variants); err != nil {
+			return authorError(err)
+		}
+
+		return respondWithAuthor(c, authors, http.StatusCreated, author.ID)
+	}
+}
+
+// @Summary Update an author
+// @Description Replace an author's preferred name, variants and dates. Credits are kept.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Author ID"
+// @Param author body Model.AuthorRequest true "Name, variants and dates"
+// @Success 200 {object} Model.AuthorDetailResponse "Updated author"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Author not found"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 500 {object} map[string]string "Failed to save author"
+// @Router /admin/authors/{id} [put]
+func updateAuthorHandler(authors Service.AuthorService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+		var request Model.AuthorRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		author := Model.AuthorModel{ID: id, Name: request.Name, BirthYear: request.BirthYear, DeathYear: request.DeathYear}
+		if err := authors.Update(c.Request().Context(), &author, request.Variants); err != nil {
+			return authorError(err)
+		}
+
+		return respondWithAuthor(c, authors, http.StatusOK, id)
+	}
+}
+
+// @Summary Set the credits of a book
+// @Description Replace the people credited on a book, in title page order. The catalogued author statement is left as it is.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Book ID"
+// @Param credits body Model.SetBookCreditsRequest true "Authors and their roles"
+// @Success 200 {array} Model.ContributorResponse "Credits of the book"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Book not found"
+// @Failure 422 {object} map[string]interface{} "Validation failed or unknown author"
+// @Failure 500 {object} map[string]string "Failed to save credits"
+// @Router /admin/books/{id}/authors [put]
+func setBookCreditsHandler(authors Service.AuthorService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bookID, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+		var request Model.SetBookCreditsRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		credits := make([]Model.BookAuthorModel, 0, len(request.Credits))
+		for _, credit := range request.Credits {
+			credits = append(credits, Model.BookAuthorModel{AuthorID: credit.AuthorID, Role: credit.Role})
+		}
+		contributors, err := authors.SetCredits(c.Request().Context(), bookID, credits)
+		if err != nil {
+			switch {
+			case errors.Is(err, Service.ErrBookNotFound):
+				return echo.NewHTTPError(http.StatusNotFound, "Book not found")
+			case errors.Is(err, Service.ErrUnknownCreditAuthor):
+				return Config.NewValidationError("credits", "refers to an unknown author")
+			case errors.Is(err, Service.ErrDuplicateCredit):
+				return Config.NewValidationError("credits", "lists an author twice in the same role")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save credits").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusOK, newContributorResponses(contributors))
+	}
+}
+
+func respondWithAuthor(c echo.Context, authors Service.AuthorService, status, id int) error {
+	author, names, works, err := authors.Find(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to find author").SetInternal(err)
+	}
+	return c.JSON(status, newAuthorDetailResponse(author, names, works))
+}
+
+func authorError(err error) error {
+	switch {
+	case errors.Is(err, Service.ErrAuthorNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Author not found")
+	case errors.Is(err, Service.ErrInvalidAuthorName):
+		return Config.NewValidationError("name", "must contain letters or digits")
+	case errors.Is(err, Service.ErrAuthorDates):
+		return Config.NewValidationError("deathYear", "must not be before birthYear")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save author").SetInternal(err)
+}
+
+func newAuthorDetailResponse(author Model.AuthorModel, names []Model.AuthorNameModel, works []Service.Work) Model.AuthorDetailResponse {
+	response := Model.AuthorDetailResponse{
+		AuthorResponse: Model.NewAuthorResponse(author),
+		Variants:       []string{},
+		Works:          make([]Model.AuthorWorkResponse, 0, len(works)),
+	}
+	for _, name := range names {
+		if name.Name != author.Name {
+			response.Variants = append(response.Variants, name.Name)
+		}
+	}
+	for _, work := range works {
+		response.Works = append(response.Works, Model.AuthorWorkResponse{
+			BookID: work.Book.ID,
+			Slug:   work.Book.Slug,
+			Title:  work.Book.Title,
+			Role:   work.Role,
+		})
+	}
+	return response
+}
+
+func newContributorResponses(contributors []Service.Contributor) []Model.ContributorResponse {
+	response := make([]Model.ContributorResponse, 0, len(contributors))
+	for _, contributor := range contributors {
+		response = append(response, Model.ContributorResponse{
+			AuthorID: contributor.Author.ID,
+			Name:     contributor.Author.Name,
+			Role:     contributor.Role,
+		})
+	}
+	return response
+}
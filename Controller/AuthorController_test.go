@@ -0,0 +1,247 @@
+package Controller_test
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+// extractAuthors runs the migration that credits authors named in books'
+// author statements.
+func (s *testServer) extractAuthors() int {
+	s.t.Helper()
+
+	credited, err := s.services.Authors.ExtractFromBooks(context.Background())
+	if err != nil {
+		s.t.Fatalf("extract authors: %v", err)
+	}
+	return credited
+}
+
+func (s *testServer) createAuthor(admin string, request map[string]interface{}) Model.AuthorDetailResponse {
+	s.t.Helper()
+
+	rec := s.do(http.MethodPost, "/admin/authors", request, admin)
+	expectStatus(s.t, rec, http.StatusCreated)
+	var author Model.AuthorDetailResponse
+	decode(s.t, rec, &author)
+	return author
+}
+
+func TestExtractAuthors(t *testing.T) {
+	t.Run("merges spellings of one name", func(t *testing.T) {
+		s := newTestServer(t)
+		hobbit := aBook().withTitle("The Hobbit").withAuthor("J.R.R. Tolkien").create(s)
+		rings := aBook().withTitle("The Lord of the Rings").withAuthor("Tolkien, J. R. R.").create(s)
+
+		if credited := s.extractAuthors(); credited != 2 {
+			t.Errorf("credited %d books, want 2", credited)
+		}
+
+		var authors []Model.AuthorResponse
+		decode(t, s.do(http.MethodGet, "/view/authors", nil, s.login()), &authors)
+		if len(authors) != 1 || authors[0].Name != "J.R.R. Tolkien" {
+			t.Fatalf("authors = %+v, want Tolkien once", authors)
+		}
+		rec := s.do(http.MethodGet, fmt.Sprintf("/view/authors/%d", authors[0].ID), nil, s.login())
+		expectStatus(t, rec, http.StatusOK)
+		var detail Model.AuthorDetailResponse
+		decode(t, rec, &detail)
+		want := []Model.AuthorWorkResponse{
+			{BookID: hobbit.ID, Slug: hobbit.Slug, Title: "The Hobbit", Role: Model.CreditAuthor},
+			{BookID: rings.ID, Slug: rings.Slug, Title: "The Lord of the Rings", Role: Model.CreditAuthor},
+		}
+		if !slices.Equal(detail.Works, want) {
+			t.Errorf("works = %+v, want %+v", detail.Works, want)
+		}
+	})
+
+	t.Run("splits co-authors and roles", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().withTitle("Good Omens").withAuthor("Terry Pratchett & Neil Gaiman").create(s)
+		aBook().withTitle("Don Quixote").withAuthor("Miguel de Cervantes; Edith Grossman (trans.)").create(s)
+		s.extractAuthors()
+
+		token := s.login()
+		for ref, want := range map[string][]Model.ContributorResponse{
+			"good-omens-terry-pratchett-neil-gaiman": {
+				{AuthorID: 1, Name: "Terry Pratchett", Role: Model.CreditAuthor},
+				{AuthorID: 2, Name: "Neil Gaiman", Role: Model.CreditAuthor},
+			},
+			"don-quixote-miguel-de-cervantes-edith-grossman-trans": {
+				{AuthorID: 3, Name: "Miguel de Cervantes", Role: Model.CreditAuthor},
+				{AuthorID: 4, Name: "Edith Grossman", Role: Model.CreditTranslator},
+			},
+		} {
+			rec := s.do(http.MethodGet, "/view/description/"+ref, nil, token)
+			expectStatus(t, rec, http.StatusOK)
+			var book Model.BookDetailResponse
+			decode(t, rec, &book)
+			if !slices.Equal(book.Contributors, want) {
+				t.Errorf("%s: contributors = %+v, want %+v", ref, book.Contributors, want)
+			}
+		}
+	})
+
+	t.Run("leaves credited books alone", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().withAuthor("Homer").create(s)
+		s.extractAuthors()
+
+		if credited := s.extractAuthors(); credited != 0 {
+			t.Errorf("second run credited %d books, want 0", credited)
+		}
+	})
+}
+
+func TestAuthors(t *testing.T) {
+	t.Run("are found by any variant", func(t *testing.T) {
+		s := newTestServer(t)
+		created := s.createAuthor(s.loginAdmin(), map[string]interface{}{
+			"name":      "Leo Tolstoy",
+			"variants":  []string{"Tolstoy, Lev Nikolayevich", "Tolstoy, Leo"},
+			"birthYear": 1828,
+			"deathYear": 1910,
+		})
+		if !slices.Equal(created.Variants, []string{"Tolstoy, Lev Nikolayevich"}) || *created.BirthYear != 1828 {
+			t.Errorf("created = %+v, want one variant besides the preferred name", created)
+		}
+
+		rec := s.do(http.MethodGet, "/view/authors?name="+url.QueryEscape("lev nikolayevich tolstoy"), nil, s.login())
+
+		var authors []Model.AuthorResponse
+		decode(t, rec, &authors)
+		if len(authors) != 1 || authors[0].ID != created.ID {
+			t.Errorf("authors = %+v, want Tolstoy", authors)
+		}
+	})
+
+	t.Run("may share a name", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+		twain := s.createAuthor(admin, map[string]interface{}{"name": "Mark Twain", "variants": []string{"Samuel Clemens"}})
+		namesake := s.createAuthor(admin, map[string]interface{}{"name": "Clemens, Samuel", "birthYear": 1950})
+
+		var authors []Model.AuthorResponse
+		decode(t, s.do(http.MethodGet, "/view/authors?name="+url.QueryEscape("samuel clemens"), nil, s.login()), &authors)
+		if len(authors) != 2 || authors[0].ID != twain.ID || authors[1].ID != namesake.ID {
+			t.Errorf("authors = %+v, want both by ID", authors)
+		}
+
+		aBook().withAuthor("Samuel Clemens").create(s)
+		s.extractAuthors()
+		var detail Model.AuthorDetailResponse
+		decode(t, s.do(http.MethodGet, fmt.Sprintf("/view/authors/%d", twain.ID), nil, admin), &detail)
+		if len(detail.Works) != 1 {
+			t.Errorf("works = %+v, want the book credited to the first author of the name", detail.Works)
+		}
+	})
+
+	t.Run("checks dates", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/admin/authors", map[string]interface{}{"name": "Nobody", "birthYear": 1900, "deathYear": 1850}, s.loginAdmin())
+
+		expectValidationError(t, rec, "deathYear")
+	})
+
+	t.Run("updates names", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+		author := s.createAuthor(admin, map[string]interface{}{"name": "Lev Tolstoy"})
+
+		rec := s.do(http.MethodPut, fmt.Sprintf("/admin/authors/%d", author.ID), map[string]interface{}{"name": "Leo Tolstoy", "variants": []string{"Lev Tolstoy"}}, admin)
+
+		expectStatus(t, rec, http.StatusOK)
+		var updated Model.AuthorDetailResponse
+		decode(t, rec, &updated)
+		if updated.Name != "Leo Tolstoy" || !slices.Equal(updated.Variants, []string{"Lev Tolstoy"}) {
+			t.Errorf("updated = %+v", updated)
+		}
+	})
+
+	t.Run("404 for an unknown author", func(t *testing.T) {
+		s := newTestServer(t)
+
+		expectError(t, s.do(http.MethodGet, "/view/authors/42", nil, s.login()), http.StatusNotFound, "Author not found")
+	})
+}
+
+func TestBookCredits(t *testing.T) {
+	t.Run("credits several people in order", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().withTitle("The Annotated Alice").create(s)
+		admin := s.loginAdmin()
+		carroll := s.createAuthor(admin, map[string]interface{}{"name": "Lewis Carroll"})
+		tenniel := s.createAuthor(admin, map[string]interface{}{"name": "John Tenniel"})
+		gardner := s.createAuthor(admin, map[string]interface{}{"name": "Martin Gardner"})
+
+		rec := s.do(http.MethodPut, fmt.Sprintf("/admin/books/%d/authors", book.ID), map[string]interface{}{"credits": []map[string]interface{}{
+			{"authorId": carroll.ID, "role": Model.CreditAuthor},
+			{"authorId": tenniel.ID, "role": Model.CreditIllustrator},
+			{"authorId": gardner.ID, "role": Model.CreditEditor},
+		}}, admin)
+
+		expectStatus(t, rec, http.StatusOK)
+		var credits []Model.ContributorResponse
+		decode(t, rec, &credits)
+		want := []Model.ContributorResponse{
+			{AuthorID: carroll.ID, Name: "Lewis Carroll", Role: Model.CreditAuthor},
+			{AuthorID: tenniel.ID, Name: "John Tenniel", Role: Model.CreditIllustrator},
+			{AuthorID: gardner.ID, Name: "Martin Gardner", Role: Model.CreditEditor},
+		}
+		if !slices.Equal(credits, want) {
+			t.Errorf("credits = %+v, want %+v", credits, want)
+		}
+		var detail Model.AuthorDetailResponse
+		decode(t, s.do(http.MethodGet, fmt.Sprintf("/view/authors/%d", tenniel.ID), nil, admin), &detail)
+		if len(detail.Works) != 1 || detail.Works[0].BookID != book.ID || detail.Works[0].Role != Model.CreditIllustrator {
+			t.Errorf("works = %+v, want the book as illustrator", detail.Works)
+		}
+	})
+
+	t.Run("rewrites the author statement of the book", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().withTitle("Don Quixote").withAuthor("Cervantes").create(s)
+		admin := s.loginAdmin()
+		cervantes := s.createAuthor(admin, map[string]interface{}{"name": "Miguel de Cervantes"})
+		grossman := s.createAuthor(admin, map[string]interface{}{"name": "Edith Grossman"})
+
+		rec := s.do(http.MethodPut, fmt.Sprintf("/admin/books/%d/authors", book.ID), map[string]interface{}{"credits": []map[string]interface{}{
+			{"authorId": cervantes.ID, "role": Model.CreditAuthor},
+			{"authorId": grossman.ID, "role": Model.CreditTranslator},
+		}}, admin)
+
+		expectStatus(t, rec, http.StatusOK)
+		rec = s.do(http.MethodGet, "/view/description/"+book.Slug, nil, admin)
+		expectStatus(t, rec, http.StatusMovedPermanently)
+		var detail Model.BookDetailResponse
+		decode(t, s.do(http.MethodGet, rec.Header().Get(echo.HeaderLocation), nil, admin), &detail)
+		if detail.Author != "Miguel de Cervantes; Edith Grossman (trans.)" {
+			t.Errorf("author = %q, want the credits written out", detail.Author)
+		}
+	})
+
+	t.Run("rejects unknown authors", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().create(s)
+
+		rec := s.do(http.MethodPut, "/admin/books/1/authors", map[string]interface{}{"credits": []map[string]interface{}{{"authorId": 42, "role": Model.CreditAuthor}}}, s.loginAdmin())
+
+		expectValidationError(t, rec, "credits")
+	})
+
+	t.Run("rejects unknown roles", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().create(s)
+
+		rec := s.do(http.MethodPut, "/admin/books/1/authors", map[string]interface{}{"credits": []map[string]interface{}{{"authorId": 1, "role": "ghostwriter"}}}, s.loginAdmin())
+
+		expectValidationError(t, rec, "role")
+	})
+}
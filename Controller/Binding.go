@@ -0,0 +1,49 @@
+package Controller
+
+import (
+	"awesomeProject/Config"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+// bindAndValidate binds the request into target and checks the rules
+// declared in its `validate` tags, so handlers only ever see well-formed
+// input. Malformed bodies yield a 400, rule violations a 422 listing every
+// offending field.
+func bindAndValidate(c echo.Context, target interface{}) error {
+	if err := c.Bind(target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return Config.NewValidationError(typeErr.Field, "must be "+jsonKind(typeErr.Type))
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data").SetInternal(err)
+	}
+	return c.Validate(target)
+}
+
+// intParam reads the path parameter name as a positive integer.
+func intParam(c echo.Context, name string) (int, error) {
+	value, err := strconv.Atoi(c.Param(name))
+	if err != nil || value < 1 {
+		return 0, Config.NewValidationError(name, "must be a positive integer")
+	}
+	return value, nil
+}
+
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a number"
+}
@@ -0,0 +1,93 @@
+package Controller_test
+
+import (
+	"awesomeProject/Model"
+	"net/http"
+	"testing"
+)
+
+func TestRegisterValidation(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   map[string]interface{}
+		fields []string
+	}{
+		{
+			name:   "empty body",
+			body:   map[string]interface{}{},
+			fields: []string{"userName", "email", "password"},
+		},
+		{
+			name:   "blank name",
+			body:   map[string]interface{}{"userName": "   ", "email": "eve@example.com", "password": "hunter22"},
+			fields: []string{"userName"},
+		},
+		{
+			name:   "name too long",
+			body:   map[string]interface{}{"userName": string(make([]byte, 51)), "email": "eve@example.com", "password": "hunter22"},
+			fields: []string{"userName"},
+		},
+		{
+			name:   "malformed email",
+			body:   map[string]interface{}{"userName": "eve", "email": "eve-at-example", "password": "hunter22"},
+			fields: []string{"email"},
+		},
+		{
+			name:   "one-character password",
+			body:   map[string]interface{}{"userName": "eve", "email": "eve@example.com", "password": "x"},
+			fields: []string{"password"},
+		},
+		{
+			name:   "password without a digit",
+			body:   map[string]interface{}{"userName": "eve", "email": "eve@example.com", "password": "onlyletters"},
+			fields: []string{"password"},
+		},
+		{
+			name:   "wrong type",
+			body:   map[string]interface{}{"userName": 42, "email": "eve@example.com", "password": "hunter22"},
+			fields: []string{"userName"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t)
+
+			rec := s.do(http.MethodPost, "/register", tc.body, "")
+
+			expectValidationError(t, rec, tc.fields...)
+			var count int64
+			s.db.Model(&Model.UserModel{}).Count(&count)
+			if count != 0 {
+				t.Errorf("%d users stored from an invalid request", count)
+			}
+		})
+	}
+}
+
+func TestRegisterIgnoresServerControlledFields(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(http.MethodPost, "/register", map[string]interface{}{
+		"userId":   99,
+		"role":     Model.RoleAdmin,
+		"userName": "mallory",
+		"email":    "Mallory@Example.com",
+		"password": "hunter22",
+	}, "")
+
+	expectStatus(t, rec, http.StatusOK)
+	var user Model.UserModel
+	s.db.First(&user)
+	if user.UserId == 99 || user.Role != Model.RoleMember || user.Email != "mallory@example.com" {
+		t.Errorf("stored %+v, want a generated ID, the member role and a lower-cased email", user)
+	}
+}
+
+func TestLoginValidation(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(http.MethodPost, "/login", map[string]string{"email": "not an email"}, "")
+
+	expectValidationError(t, rec, "email", "password")
+}
@@ -0,0 +1,173 @@
+package Controller
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Service"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+// @Summary Upload a book cover
+// @Description Replace a book's cover with a JPEG or PNG image of at most 5 MB and 6000 pixels a side.
+// @Description Medium and small JPEG thumbnails are rendered from it.
+// @Tags admin
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Book ID"
+// @Param cover formData file true "Cover image"
+// @Success 200 {object} Model.BookResponse "Book with its new cover"
+// @Failure 400 {object} map[string]string "No cover image in the request"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Book not found"
+// @Failure 413 {object} map[string]string "Cover image too large"
+// @Failure 415 {object} map[string]string "Cover image is not a JPEG or PNG"
+// @Failure 422 {object} map[string]interface{} "Cover image cannot be read or is too large in pixels"
+// @Failure 500 {object} map[string]string "Failed to save cover"
+// @Router /admin/books/{id}/cover [put]
+func uploadCoverHandler(covers Service.CoverService, catalog Service.CatalogService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bookID, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+
+		// Leave room for the multipart framing around the image itself.
+		c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, Service.MaxCoverSize+64<<10)
+		file, err := c.FormFile("cover")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return coverTooLarge()
+			}
+			return echo.NewHTTPError(http.StatusBadRequest, "Send the cover image as the multipart field \"cover\"").SetInternal(err)
+		}
+		if file.Size > Service.MaxCoverSize {
+			return coverTooLarge()
+		}
+		data, err := readFormFile(file)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data").SetInternal(err)
+		}
+
+		book, err := covers.Upload(c.Request().Context(), bookID, data)
+		if err != nil {
+			switch {
+			case errors.Is(err, Service.ErrBookNotFound):
+				return echo.NewHTTPError(http.StatusNotFound, "Book not found")
+			case errors.Is(err, Service.ErrCoverTooLarge):
+				return coverTooLarge()
+			case errors.Is(err, Service.ErrCoverType):
+				return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Cover image must be a JPEG or PNG")
+			case errors.Is(err, Service.ErrCoverImage):
+				return Config.NewValidationError("cover", fmt.Sprintf("must be a readable image of at most %d pixels a side", Service.MaxCoverDimension))
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save cover").SetInternal(err)
+		}
+
+		availability, err := catalog.Availability(c.Request().Context(), book.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save cover").SetInternal(err)
+		}
+		return c.JSON(http.StatusOK, Model.NewBookResponse(book, availability[book.ID]))
+	}
+}
+
+// @Summary Remove a book cover
+// @Description Delete a book's cover and its thumbnails
+// @Tags admin
+// @Param id path string true "Book ID"
+// @Success 204 "Cover removed"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Book or cover not found"
+// @Failure 422 {object} map[string]interface{} "Invalid book ID"
+// @Failure 500 {object} map[string]string "Failed to remove cover"
+// @Router /admin/books/{id}/cover [delete]
+func removeCoverHandler(covers Service.CoverService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bookID, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+
+		if err := covers.Remove(c.Request().Context(), bookID); err != nil {
+			switch {
+			case errors.Is(err, Service.ErrBookNotFound):
+				return echo.NewHTTPError(http.StatusNotFound, "Book not found")
+			case errors.Is(err, Service.ErrCoverNotFound):
+				return echo.NewHTTPError(http.StatusNotFound, "Cover not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to remove cover").SetInternal(err)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// @Summary Get a book cover
+// @Description Serve a book's cover as uploaded, or one of its JPEG thumbnails. Covers are public so
+// @Description they can be used in image tags. Requests carrying the current version in v may be cached
+// @Description for good; conditional requests are answered with 304 Not Modified.
+// @Tags books
+// @Produce image/jpeg
+// @Produce image/png
+// @Param id path string true "Book ID"
+// @Param size path string true "original, medium or small"
+// @Param v query string false "Cover version, as found in coverUrl"
+// @Success 200 {file} file "Cover image"
+// @Success 304 "Not modified"
+// @Failure 404 {object} map[string]string "Cover not found"
+// @Failure 422 {object} map[string]interface{} "Invalid book ID or size"
+// @Failure 500 {object} map[string]string "Failed to load cover"
+// @Router /covers/{id}/{size} [get]
+func viewCoverHandler(covers Service.CoverService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bookID, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+		size := c.Param("size")
+		if _, thumbnail := Model.CoverWidths[size]; !thumbnail && size != Model.CoverOriginal {
+			return Config.NewValidationError("size", "must be one of original, medium, small")
+		}
+
+		cover, err := covers.Find(c.Request().Context(), bookID, size)
+		if err != nil {
+			if errors.Is(err, Service.ErrCoverNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "Cover not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load cover").SetInternal(err)
+		}
+
+		header := c.Response().Header()
+		header.Set(echo.HeaderContentType, cover.ContentType)
+		header.Set("ETag", fmt.Sprintf(`"%s-%s"`, cover.Version, size))
+		// A versioned URL always names the same bytes; an unversioned one
+		// changes with the next upload.
+		if c.QueryParam("v") == cover.Version {
+			header.Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			header.Set("Cache-Control", "public, max-age=300")
+		}
+		http.ServeContent(c.Response(), c.Request(), "", time.Time{}, bytes.NewReader(cover.Data))
+		return nil
+	}
+}
+
+func coverTooLarge() error {
+	return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Cover image must be at most %d MB", Service.MaxCoverSize>>20))
+}
+
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
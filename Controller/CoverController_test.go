@@ -0,0 +1,212 @@
+package Controller_test
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"awesomeProject/Service"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// aCover encodes a width by height PNG, red with a transparent top half.
+func aCover(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := height / 2; y < height; y++ {
+		for x := range width {
+			img.Set(x, y, color.NRGBA{R: 0xcc, A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode cover: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// uploadCover sends data as the multipart field "cover" of a cover upload.
+func (s *testServer) uploadCover(token string, bookID int, data []byte) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("cover", "cover.png")
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	part.Write(data)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/admin/books/%d/cover", bookID), &body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	return s.serve(req)
+}
+
+// hookedBlobs runs beforePut ahead of every write, so a test can slip other
+// changes in while an upload is under way.
+type hookedBlobs struct {
+	Config.BlobStore
+	beforePut func()
+}
+
+func (b hookedBlobs) Put(ctx context.Context, key string, data []byte) error {
+	b.beforePut()
+	return b.BlobStore.Put(ctx, key, data)
+}
+
+func TestBookCovers(t *testing.T) {
+	t.Run("serves the original and thumbnails", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().create(s)
+		original := aCover(t, 600, 900)
+
+		rec := s.uploadCover(s.loginAdmin(), book.ID, original)
+
+		expectStatus(t, rec, http.StatusOK)
+		var got Model.BookResponse
+		decode(t, rec, &got)
+		if !strings.HasPrefix(got.CoverURL, fmt.Sprintf("/covers/%d/original?v=", book.ID)) ||
+			!strings.HasPrefix(got.ThumbnailSmallURL, fmt.Sprintf("/covers/%d/small?v=", book.ID)) ||
+			!strings.HasPrefix(got.ThumbnailMediumURL, fmt.Sprintf("/covers/%d/medium?v=", book.ID)) {
+			t.Fatalf("URLs = %q, %q and %q", got.CoverURL, got.ThumbnailSmallURL, got.ThumbnailMediumURL)
+		}
+
+		rec = s.do(http.MethodGet, got.CoverURL, nil, "")
+		expectStatus(t, rec, http.StatusOK)
+		if rec.Header().Get(echo.HeaderContentType) != "image/png" || !bytes.Equal(rec.Body.Bytes(), original) {
+			t.Errorf("original served as %q, %d bytes", rec.Header().Get(echo.HeaderContentType), rec.Body.Len())
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "public, max-age=31536000, immutable" {
+			t.Errorf("Cache-Control = %q", got)
+		}
+
+		for size, width := range map[string]int{"medium": 400, "small": 120} {
+			rec := s.do(http.MethodGet, fmt.Sprintf("/covers/%d/%s", book.ID, size), nil, "")
+			expectStatus(t, rec, http.StatusOK)
+			if got := rec.Header().Get("Cache-Control"); got != "public, max-age=300" {
+				t.Errorf("unversioned Cache-Control = %q", got)
+			}
+			thumbnail, err := jpeg.Decode(rec.Body)
+			if err != nil {
+				t.Fatalf("decode %s thumbnail: %v", size, err)
+			}
+			bounds := thumbnail.Bounds()
+			if bounds.Dx() != width || bounds.Dy() != width*3/2 {
+				t.Errorf("%s thumbnail is %dx%d, want %dx%d", size, bounds.Dx(), bounds.Dy(), width, width*3/2)
+			}
+			// Transparency is flattened onto white rather than black.
+			if r, g, b, _ := thumbnail.At(width/2, 2).RGBA(); r>>8 < 0xf0 || g>>8 < 0xf0 || b>>8 < 0xf0 {
+				t.Errorf("%s thumbnail top is %d,%d,%d, want white", size, r>>8, g>>8, b>>8)
+			}
+		}
+	})
+
+	t.Run("answers conditional requests", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().create(s)
+		s.uploadCover(s.loginAdmin(), book.ID, aCover(t, 200, 300))
+
+		rec := s.do(http.MethodGet, fmt.Sprintf("/covers/%d/small", book.ID), nil, "")
+		etag := rec.Header().Get("ETag")
+		if etag == "" {
+			t.Fatal("no ETag")
+		}
+
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/covers/%d/small", book.ID), nil)
+		req.Header.Set("If-None-Match", etag)
+		expectStatus(t, s.serve(req), http.StatusNotModified)
+	})
+
+	t.Run("replaces and removes covers", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().create(s)
+		admin := s.loginAdmin()
+
+		var first, second Model.BookResponse
+		decode(t, s.uploadCover(admin, book.ID, aCover(t, 200, 300)), &first)
+		decode(t, s.uploadCover(admin, book.ID, aCover(t, 300, 300)), &second)
+		if first.CoverURL == second.CoverURL {
+			t.Errorf("cover URL %q did not change with the image", first.CoverURL)
+		}
+		if keys := s.blobs.Keys(); len(keys) != 3 {
+			t.Errorf("blobs = %v, want the three sizes of the new cover", keys)
+		}
+
+		var detail Model.BookDetailResponse
+		decode(t, s.do(http.MethodGet, "/view/description/"+book.Slug, nil, s.login()), &detail)
+		if detail.CoverURL != second.CoverURL || detail.ThumbnailSmallURL != second.ThumbnailSmallURL || detail.ThumbnailMediumURL != second.ThumbnailMediumURL {
+			t.Errorf("detail URLs = %q, %q and %q, want those of the list", detail.CoverURL, detail.ThumbnailSmallURL, detail.ThumbnailMediumURL)
+		}
+
+		expectStatus(t, s.do(http.MethodDelete, fmt.Sprintf("/admin/books/%d/cover", book.ID), nil, admin), http.StatusNoContent)
+		if keys := s.blobs.Keys(); len(keys) != 0 {
+			t.Errorf("blobs = %v after removal", keys)
+		}
+		expectError(t, s.do(http.MethodGet, second.CoverURL, nil, ""), http.StatusNotFound, "Cover not found")
+		expectError(t, s.do(http.MethodDelete, fmt.Sprintf("/admin/books/%d/cover", book.ID), nil, admin), http.StatusNotFound, "Cover not found")
+	})
+
+	t.Run("keeps edits made during an upload", func(t *testing.T) {
+		var once sync.Once
+		s := newTestServer(t, func(services *Service.Services, store Repository.Store) {
+			services.Covers = Service.NewCoverService(store, hookedBlobs{Config.NewMemoryBlobStore(), func() {
+				once.Do(func() {
+					book, err := store.Books().FindByID(context.Background(), 1)
+					book.Title = "Renamed meanwhile"
+					if err == nil {
+						err = store.Books().Save(context.Background(), &book)
+					}
+					if err != nil {
+						t.Error(err)
+					}
+				})
+			}})
+		})
+		book := aBook().create(s)
+
+		expectStatus(t, s.uploadCover(s.loginAdmin(), book.ID, aCover(t, 200, 300)), http.StatusOK)
+
+		var stored Model.BookModel
+		s.db.First(&stored, book.ID)
+		if stored.Title != "Renamed meanwhile" || stored.Cover == "" {
+			t.Errorf("stored book = %+v, want the new title and a cover", stored)
+		}
+	})
+
+	t.Run("rejects unsuitable uploads", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().create(s)
+		admin := s.loginAdmin()
+
+		rec := s.uploadCover(admin, book.ID, []byte("GIF89a not really"))
+		expectError(t, rec, http.StatusUnsupportedMediaType, "Cover image must be a JPEG or PNG")
+
+		rec = s.uploadCover(admin, book.ID, append(aCover(t, 10, 10)[:40], make([]byte, 6<<20)...))
+		expectError(t, rec, http.StatusRequestEntityTooLarge, "Cover image must be at most 5 MB")
+
+		rec = s.uploadCover(admin, book.ID, aCover(t, 10, 10)[:60])
+		expectValidationError(t, rec, "cover")
+
+		rec = s.uploadCover(admin, book.ID, aCover(t, 6001, 1))
+		expectValidationError(t, rec, "cover")
+
+		expectError(t, s.uploadCover(admin, 99, aCover(t, 10, 10)), http.StatusNotFound, "Book not found")
+		expectStatus(t, s.uploadCover(s.login(), book.ID, aCover(t, 10, 10)), http.StatusForbidden)
+		expectValidationError(t, s.do(http.MethodGet, fmt.Sprintf("/covers/%d/huge", book.ID), nil, ""), "size")
+	})
+}
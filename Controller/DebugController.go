@@ -0,0 +1,72 @@
+package Controller
+
+import (
+	"awesomeProject/Config"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"time"
+)
+
+type clockOffsetRequest struct {
+	Offset string `json:"offset" validate:"required,duration"`
+}
+
+type clockResponse struct {
+	Now    time.Time `json:"now"`
+	Offset string    `json:"offset"`
+}
+
+func clockState(clock *Config.OffsetClock) clockResponse {
+	return clockResponse{Now: clock.Now(), Offset: clock.Offset().String()}
+}
+
+// @Summary View the application clock
+// @Description Debug only: show the time the application currently believes it is, and its offset from the wall clock
+// @Tags debug
+// @Produce json
+// @Success 200 {object} clockResponse "Current clock"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Router /admin/debug/clock [get]
+func viewClockHandler(clock *Config.OffsetClock) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, clockState(clock))
+	}
+}
+
+// @Summary Offset the application clock
+// @Description Debug only: shift the application clock by a Go duration such as "72h" so due dates, fines and token expiry can be exercised in staging. Tokens expire relative to the shifted clock.
+// @Tags debug
+// @Accept json
+// @Produce json
+// @Param offset body clockOffsetRequest true "Offset, e.g. {\"offset\": \"72h\"}"
+// @Success 200 {object} clockResponse "Updated clock"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Router /admin/debug/clock [put]
+func setClockOffsetHandler(clock *Config.OffsetClock) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request clockOffsetRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		offset, _ := time.ParseDuration(request.Offset)
+		clock.SetOffset(offset)
+		return c.JSON(http.StatusOK, clockState(clock))
+	}
+}
+
+// @Summary Reset the application clock
+// @Description Debug only: remove any clock offset
+// @Tags debug
+// @Produce json
+// @Success 200 {object} clockResponse "Reset clock"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Router /admin/debug/clock [delete]
+func resetClockOffsetHandler(clock *Config.OffsetClock) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		clock.SetOffset(0)
+		return c.JSON(http.StatusOK, clockState(clock))
+	}
+}
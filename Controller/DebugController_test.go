@@ -0,0 +1,67 @@
+package Controller_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDebugClock(t *testing.T) {
+	t.Run("is not routed unless enabled", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodGet, "/admin/debug/clock", nil, s.loginAdmin())
+
+		expectError(t, rec, http.StatusNotFound, "Not Found")
+	})
+
+	t.Run("requires an administrator", func(t *testing.T) {
+		t.Setenv("DEBUG_ENDPOINTS", "true")
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPut, "/admin/debug/clock", map[string]string{"offset": "1h"}, s.login())
+
+		expectError(t, rec, http.StatusForbidden, "Insufficient permissions")
+	})
+
+	t.Run("rejects an invalid offset", func(t *testing.T) {
+		t.Setenv("DEBUG_ENDPOINTS", "true")
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPut, "/admin/debug/clock", map[string]string{"offset": "three days"}, s.loginAdmin())
+
+		expectValidationError(t, rec, "offset")
+	})
+
+	t.Run("shifts due dates and resets", func(t *testing.T) {
+		t.Setenv("DEBUG_ENDPOINTS", "true")
+		s := newTestServer(t)
+		aBook().create(s)
+		admin := s.loginAdmin()
+
+		rec := s.do(http.MethodPut, "/admin/debug/clock", map[string]string{"offset": "30m"}, admin)
+		expectStatus(t, rec, http.StatusOK)
+		var clock struct {
+			Now    time.Time `json:"now"`
+			Offset string    `json:"offset"`
+		}
+		decode(t, rec, &clock)
+		if want := testStart.Add(30 * time.Minute); !clock.Now.Equal(want) || clock.Offset != "30m0s" {
+			t.Errorf("clock = %+v, want now %v and offset 30m0s", clock, want)
+		}
+
+		expectStatus(t, s.do(http.MethodGet, "/view/borrow/1", nil, admin), http.StatusOK)
+		var dueAt time.Time
+		s.db.Table("loan_models").Select("due_at").Row().Scan(&dueAt)
+		if want := testStart.Add(30*time.Minute + 14*24*time.Hour); !dueAt.Equal(want) {
+			t.Errorf("dueAt = %v, want %v", dueAt, want)
+		}
+
+		rec = s.do(http.MethodDelete, "/admin/debug/clock", nil, admin)
+		expectStatus(t, rec, http.StatusOK)
+		decode(t, rec, &clock)
+		if !clock.Now.Equal(testStart) {
+			t.Errorf("now = %v after reset, want %v", clock.Now, testStart)
+		}
+	})
+}
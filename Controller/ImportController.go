@@ -0,0 +1,150 @@
+package Controller
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// maxImportFileSize bounds uploaded import files, in bytes.
+const maxImportFileSize = 20 << 20
+
+// @Summary Import books
+// @Description Start a bulk import of a CSV file with a header row, of JSON Lines with one object per line,
+// @Description or of MARC 21 records in ISO 2709 or MARCXML.
+// @Description Columns named title, author, description or isbn (isbn10 and isbn13 too) are picked up by name;
+// @Description mapping renames others. MARC records are read from 245 (title and author statement), 100 and 700
+// @Description (credits), 020 (ISBN), 520 (description) and 650 (subjects, created as needed), and replace the
+// @Description credits and subjects of their book. Books are matched by ISBN and updated, everything else is created.
+// @Description Rows that fail validation are skipped and listed in the job's error report. A dry run
+// @Description validates and counts without saving. Open to admins and to API keys with the catalog:write scope.
+// @Tags admin
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV, JSON Lines or MARC file of at most 20 MB"
+// @Param format formData string false "csv, jsonl, marc or marcxml; defaults to the file name's extension"
+// @Param mapping formData string false "JSON object from column names to title, author, description, isbn or \"\" to ignore"
+// @Param dryRun formData bool false "Validate without saving"
+// @Success 202 {object} Model.ImportJobResponse "Import queued"
+// @Failure 400 {object} map[string]string "No file in the request"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 413 {object} map[string]string "File too large"
+// @Failure 422 {object} map[string]interface{} "The file, format or mapping cannot be used"
+// @Failure 500 {object} map[string]string "Failed to start import"
+// @Router /admin/imports [post]
+func startImportHandler(imports Service.ImportService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxImportFileSize+64<<10)
+		header, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return importTooLarge()
+			}
+			return echo.NewHTTPError(http.StatusBadRequest, "Send the import as the multipart field \"file\"").SetInternal(err)
+		}
+		if header.Size > maxImportFileSize {
+			return importTooLarge()
+		}
+		var request Model.ImportRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		format := request.Format
+		if format == "" {
+			if format = Service.ImportFormat(header.Filename); format == "" {
+				return Config.NewValidationError("format", "is required when the file name does not end in .csv, .jsonl, .mrc or .xml")
+			}
+		}
+		var mapping map[string]string
+		if request.Mapping != "" {
+			if err := json.Unmarshal([]byte(request.Mapping), &mapping); err != nil {
+				return Config.NewValidationError("mapping", "must be a JSON object of strings")
+			}
+		}
+		data, err := readFormFile(header)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data").SetInternal(err)
+		}
+
+		file, err := imports.Parse(format, data, mapping)
+		if err != nil {
+			var invalid *Service.ImportFileError
+			if errors.As(err, &invalid) {
+				return Config.NewValidationError(invalid.Field, invalid.Reason)
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start import").SetInternal(err)
+		}
+		file.DryRun = request.DryRun
+
+		job, err := imports.Start(c.Request().Context(), file)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start import").SetInternal(err)
+		}
+
+		c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/admin/imports/%d", job.ID))
+		return c.JSON(http.StatusAccepted, Model.NewImportJobResponse(job))
+	}
+}
+
+// @Summary List imports
+// @Description List the latest import jobs, newest first
+// @Tags admin
+// @Produce json
+// @Success 200 {array} Model.ImportJobResponse "Import jobs"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 500 {object} map[string]string "Failed to retrieve imports"
+// @Router /admin/imports [get]
+func listImportsHandler(imports Service.ImportService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		jobs, err := imports.List(c.Request().Context())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve imports").SetInternal(err)
+		}
+
+		response := make([]Model.ImportJobResponse, 0, len(jobs))
+		for _, job := range jobs {
+			response = append(response, Model.NewImportJobResponse(job))
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// @Summary Get an import
+// @Description Retrieve the progress of an import job and, once it ran, the problems found in each rejected row
+// @Tags admin
+// @Produce json
+// @Param id path string true "Import job ID"
+// @Success 200 {object} Model.ImportJobDetailResponse "Import job and error report"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Import not found"
+// @Failure 422 {object} map[string]interface{} "Invalid import ID"
+// @Failure 500 {object} map[string]string "Failed to find import"
+// @Router /admin/imports/{id} [get]
+func viewImportHandler(imports Service.ImportService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+
+		job, problems, err := imports.Find(c.Request().Context(), id)
+		if err != nil {
+			if errors.Is(err, Service.ErrImportNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "Import not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to find import").SetInternal(err)
+		}
+		return c.JSON(http.StatusOK, Model.NewImportJobDetailResponse(job, problems))
+	}
+}
+
+func importTooLarge() error {
+	return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Import files must be at most %d MB", maxImportFileSize>>20))
+}
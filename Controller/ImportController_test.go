@@ -0,0 +1,205 @@
+package Controller_test
+
+import (
+	"awesomeProject/Model"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// startImport uploads content as the import file name, with fields as the
+// other form fields.
+func (s *testServer) startImport(token, name, content string, fields map[string]string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for key, value := range fields {
+		form.WriteField(key, value)
+	}
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/imports", &body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	return s.serve(req)
+}
+
+// runImport starts an import, waits for it and returns its report.
+func (s *testServer) runImport(token, name, content string, fields map[string]string) Model.ImportJobDetailResponse {
+	s.t.Helper()
+
+	rec := s.startImport(token, name, content, fields)
+	expectStatus(s.t, rec, http.StatusAccepted)
+	var job Model.ImportJobResponse
+	decode(s.t, rec, &job)
+	if err := s.services.Imports.Wait(context.Background()); err != nil {
+		s.t.Fatalf("wait for import: %v", err)
+	}
+
+	rec = s.do(http.MethodGet, rec.Header().Get(echo.HeaderLocation), nil, token)
+	expectStatus(s.t, rec, http.StatusOK)
+	var report Model.ImportJobDetailResponse
+	decode(s.t, rec, &report)
+	return report
+}
+
+func TestImportBooks(t *testing.T) {
+	t.Run("creates, updates and reports rejected rows", func(t *testing.T) {
+		s := newTestServer(t)
+		existing := aBook().withTitle("Dune").withAuthor("Frank Herbert").withISBN("9780441172719").create(s)
+		admin := s.loginAdmin()
+
+		csv := "Title,Author,ISBN-13,Shelf\n" +
+			"Dune,Frank Herbert,0-441-17271-7,A1\n" +
+			"\"Good Omens\",\"Terry Pratchett & Neil Gaiman\",,B2\n" +
+			",Nobody,,C3\n" +
+			"Broken,Somebody,9780441172710,D4\n" +
+			"Short row\n"
+		report := s.runImport(admin, "books.csv", csv, map[string]string{})
+
+		if report.Status != Model.ImportSucceeded || report.Rows != 5 || report.Created != 1 || report.Updated != 1 || report.Failed != 3 {
+			t.Errorf("report = %+v", report.ImportJobResponse)
+		}
+		want := []Model.ImportErrorResponse{
+			{Row: 4, Field: "title", Message: "is required"},
+			{Row: 5, Field: "isbn", Message: "must be a valid ISBN-10 or ISBN-13"},
+			{Row: 6, Message: "has 1 columns but the header has 4"},
+			{Row: 6, Field: "author", Message: "is required"},
+		}
+		if fmt.Sprint(report.Errors) != fmt.Sprint(want) {
+			t.Errorf("errors = %+v, want %+v", report.Errors, want)
+		}
+
+		var detail Model.BookDetailResponse
+		decode(t, s.do(http.MethodGet, "/view/description/"+existing.Slug, nil, admin), &detail)
+		if detail.Description != existing.Description {
+			t.Errorf("description = %q, want it kept as %q", detail.Description, existing.Description)
+		}
+		var books []Model.BookResponse
+		decode(t, s.do(http.MethodGet, "/view/books", nil, admin), &books)
+		if len(books) != 2 || books[1].Title != "Good Omens" {
+			t.Fatalf("books = %+v", books)
+		}
+		decode(t, s.do(http.MethodGet, "/view/description/"+books[1].Slug, nil, admin), &detail)
+		if len(detail.Contributors) != 2 {
+			t.Errorf("contributors = %+v, want both authors credited", detail.Contributors)
+		}
+	})
+
+	t.Run("maps JSON Lines fields", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+
+		jsonl := `{"name": "Hyperion", "by": "Dan Simmons", "isbn": 9780553283686, "blurb": "Pilgrims travel to the Time Tombs."}` + "\n" +
+			"\n" +
+			`not json` + "\n" +
+			`{"name": "Ilium", "by": ["Dan Simmons"]}` + "\n"
+		report := s.runImport(admin, "books.txt", jsonl, map[string]string{
+			"format":  "jsonl",
+			"mapping": `{"name": "title", "by": "author", "blurb": "description"}`,
+		})
+
+		if report.Created != 1 || report.Failed != 2 {
+			t.Errorf("report = %+v", report.ImportJobResponse)
+		}
+		want := []Model.ImportErrorResponse{
+			{Row: 3, Message: "is not a JSON object"},
+			{Row: 3, Field: "author", Message: "is required"},
+			{Row: 3, Field: "title", Message: "is required"},
+			{Row: 4, Field: "author", Message: "must be a string"},
+			{Row: 4, Field: "author", Message: "is required"},
+		}
+		if fmt.Sprint(report.Errors) != fmt.Sprint(want) {
+			t.Errorf("errors = %+v, want %+v", report.Errors, want)
+		}
+
+		if rec := s.do(http.MethodGet, "/view/description/9780553283686", nil, admin); rec.Code != http.StatusMovedPermanently {
+			t.Errorf("imported book not found by ISBN: status %d", rec.Code)
+		}
+	})
+
+	t.Run("imports files of several batches", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+
+		var csv strings.Builder
+		csv.WriteString("title,author\n")
+		for i := range 1200 {
+			fmt.Fprintf(&csv, "Volume %d,Anonymous\n", i+1)
+		}
+		csv.WriteString(",Nobody\n")
+		report := s.runImport(admin, "books.csv", csv.String(), nil)
+
+		if report.Status != Model.ImportSucceeded || report.Created != 1200 || report.Failed != 1 {
+			t.Errorf("report = %+v", report.ImportJobResponse)
+		}
+		if want := []Model.ImportErrorResponse{{Row: 1202, Field: "title", Message: "is required"}}; fmt.Sprint(report.Errors) != fmt.Sprint(want) {
+			t.Errorf("errors = %+v, want %+v", report.Errors, want)
+		}
+		var count int64
+		s.db.Model(&Model.BookModel{}).Count(&count)
+		if count != 1200 {
+			t.Errorf("%d books saved, want 1200", count)
+		}
+	})
+
+	t.Run("saves nothing on a dry run", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+
+		report := s.runImport(admin, "books.csv", "title,author\nDune,Frank Herbert\n,Nobody\n", map[string]string{"dryRun": "true"})
+
+		if !report.DryRun || report.Created != 1 || report.Failed != 1 || len(report.Errors) != 1 {
+			t.Errorf("report = %+v", report)
+		}
+		var books []Model.BookResponse
+		decode(t, s.do(http.MethodGet, "/view/books", nil, admin), &books)
+		if len(books) != 0 {
+			t.Errorf("books = %+v, want none", books)
+		}
+	})
+
+	t.Run("rejects unusable files", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+
+		expectValidationError(t, s.startImport(admin, "books.csv", "name,author\nDune,Frank Herbert\n", nil), "file")
+		expectValidationError(t, s.startImport(admin, "books.csv", "title,author\n", nil), "file")
+		expectValidationError(t, s.startImport(admin, "books.xlsx", "title,author\nDune,Frank Herbert\n", nil), "format")
+		expectValidationError(t, s.startImport(admin, "books.csv", "title,author\nDune,Frank Herbert\n", map[string]string{"mapping": `{"a": "price"}`}), "mapping")
+		expectValidationError(t, s.startImport(admin, "books.csv", "title,author\nDune,Frank Herbert\n", map[string]string{"mapping": `[1]`}), "mapping")
+		expectError(t, s.do(http.MethodGet, "/admin/imports/99", nil, admin), http.StatusNotFound, "Import not found")
+	})
+
+	t.Run("is open to admins and catalog writers only", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+		writer := s.createAPIKey(admin, Model.ScopeCatalogWrite)
+		reader := s.createAPIKey(admin, Model.ScopeCatalogRead)
+
+		report := s.runImport(writer.Key, "books.csv", "title,author\nDune,Frank Herbert\n", nil)
+		if report.Created != 1 {
+			t.Errorf("report = %+v", report.ImportJobResponse)
+		}
+		var jobs []Model.ImportJobResponse
+		decode(t, s.do(http.MethodGet, "/admin/imports", nil, admin), &jobs)
+		if len(jobs) != 1 || jobs[0].ID != report.ID {
+			t.Errorf("jobs = %+v", jobs)
+		}
+
+		expectError(t, s.startImport(reader.Key, "books.csv", "title,author\n", nil), http.StatusForbidden, "API key lacks the catalog:write scope")
+		expectError(t, s.startImport(s.login(), "books.csv", "title,author\n", nil), http.StatusForbidden, "Insufficient permissions")
+	})
+}
@@ -3,214 +3,419 @@ package Controller
 import (
 	"awesomeProject/Config"
 	"awesomeProject/Model"
+	"awesomeProject/Service"
 	_ "awesomeProject/docs"
 	"errors"
+	"fmt"
 	"github.com/labstack/echo/v4"
 	echoSwagger "github.com/swaggo/echo-swagger"
-	"gorm.io/gorm"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
-func Router(e *echo.Echo, db *gorm.DB) {
+func Router(e *echo.Echo, services *Service.Services) {
+	e.HTTPErrorHandler = Config.HTTPErrorHandler
+	e.Validator = Config.NewValidator()
+	e.IPExtractor = Config.IPExtractor()
+	e.Use(
+		Config.RequestIDMiddleware,
+		Config.TracingMiddleware(),
+		Config.AccessLogMiddleware,
+		Config.MetricsMiddleware,
+		Config.TimeoutMiddleware(Config.RequestTimeout()),
+	)
+
 	// Public
+	e.GET("/metrics", Config.MetricsHandler(services.Lending))
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
-	e.POST("/login", loginHandler(db))
-	e.POST("/register", registerHandlers(db))
+	e.POST("/login", loginHandler(services.Users, services.Sessions, services.Clock))
+	e.POST("/login/2fa", twoFactorLoginHandler(services.TwoFactor, services.Sessions))
+	if services.SingleSignOn != nil {
+		e.GET("/login/oidc", beginSingleSignOnHandler(services.SingleSignOn, services.Clock))
+		e.GET("/login/oidc/callback", completeSingleSignOnHandler(services.SingleSignOn, services.Sessions, services.Clock))
+	}
+	e.POST("/register", registerHandlers(services.Users))
+	e.GET("/verify-email", verifyEmailHandler(services.Users))
+	e.POST("/verify-email/resend", resendVerificationHandler(services.Users))
+	e.POST("/password/forgot", forgotPasswordHandler(services.Users))
+	e.GET("/password/reset", resetPasswordFormHandler())
+	e.POST("/password/reset", resetPasswordHandler(services.Users))
+	// Covers are shown in image tags, which cannot send a token.
+	e.GET("/covers/:id/:size", viewCoverHandler(services.Covers))
 
 	// Secured
-	e.GET("/view/books", Config.Middleware(viewAllBookHandler(db)))
-	e.GET("/view/description/:book", Config.Middleware(viewBookDetailHandler(db)))
-	e.GET("/view/borrow/:id", Config.Middleware(borrowBookHandler(db)))
-	e.GET("/view/return/:id", Config.Middleware(returnBookHandler(db)))
+	secured := Config.Middleware(services.Clock, services.Sessions, services.APIKeys)
+	catalogReader := Config.Middleware(services.Clock, services.Sessions, services.APIKeys, Model.ScopeCatalogRead)
+	catalogWriter := Config.Middleware(services.Clock, services.Sessions, services.APIKeys, Model.ScopeCatalogWrite)
+	e.GET("/me", secured(viewProfileHandler(services.Users)))
+	e.PATCH("/me", secured(updateProfileHandler(services.Users)))
+	e.DELETE("/me", secured(deleteAccountHandler(services.Users)))
+	e.POST("/me/password", secured(changePasswordHandler(services.Users, services.Sessions)))
+	e.POST("/me/email", secured(changeEmailHandler(services.Users)))
+	e.POST("/me/2fa", secured(enrollTwoFactorHandler(services.TwoFactor)))
+	e.POST("/me/2fa/confirm", secured(confirmTwoFactorHandler(services.TwoFactor)))
+	e.DELETE("/me/2fa", secured(disableTwoFactorHandler(services.TwoFactor)))
+	e.POST("/me/2fa/recovery-codes", secured(regenerateRecoveryCodesHandler(services.TwoFactor)))
+	e.GET("/me/sessions", secured(listSessionsHandler(services.Sessions)))
+	e.DELETE("/me/sessions", secured(revokeOtherSessionsHandler(services.Sessions)))
+	e.DELETE("/me/sessions/:id", secured(revokeSessionHandler(services.Sessions)))
+	e.GET("/view/books", catalogReader(viewAllBookHandler(services.Catalog)))
+	e.GET("/view/books/marc", catalogReader(exportMARCHandler(services.Catalog, services.MARC)))
+	e.GET("/view/description/:book", catalogReader(viewBookDetailHandler(services.Catalog, services.Authors, services.Taxonomy)))
+	e.GET("/view/authors", catalogReader(viewAuthorsHandler(services.Authors)))
+	e.GET("/view/authors/:id", catalogReader(viewAuthorHandler(services.Authors)))
+	e.GET("/view/subjects", catalogReader(viewSubjectTreeHandler(services.Taxonomy)))
+	e.GET("/view/subjects/:id", catalogReader(viewSubjectHandler(services.Taxonomy)))
+	e.GET("/view/subjects/:id/books", catalogReader(viewSubjectBooksHandler(services.Taxonomy, services.Catalog)))
+	e.GET("/view/tags", catalogReader(viewTagsHandler(services.Taxonomy)))
+	e.GET("/view/borrow/:book", secured(borrowBookHandler(services.Catalog, services.Lending)))
+	e.GET("/view/return/:book", secured(returnBookHandler(services.Catalog, services.Lending)))
+
+	// Admin
+	// Imports are open to admins and to API keys allowed to write the
+	// catalog, so they are registered outside the admin group.
+	e.POST("/admin/imports", startImportHandler(services.Imports), catalogWriter, Config.RequireUserRole(Model.RoleAdmin))
+	e.GET("/admin/imports", listImportsHandler(services.Imports), catalogWriter, Config.RequireUserRole(Model.RoleAdmin))
+	e.GET("/admin/imports/:id", viewImportHandler(services.Imports), catalogWriter, Config.RequireUserRole(Model.RoleAdmin))
+
+	admin := e.Group("/admin", secured, Config.RequireRole(Model.RoleAdmin))
+	admin.POST("/users/:id/unlock", unlockUserHandler(services.Users))
+	admin.POST("/api-keys", createAPIKeyHandler(services.APIKeys))
+	admin.GET("/api-keys", listAPIKeysHandler(services.APIKeys))
+	admin.DELETE("/api-keys/:id", revokeAPIKeyHandler(services.APIKeys))
+	admin.GET("/books/:id/copies", listCopiesHandler(services.Catalog))
+	admin.POST("/books/:id/copies", addCopyHandler(services.Catalog))
+	admin.PATCH("/copies/:id", updateCopyHandler(services.Catalog))
+	admin.PUT("/books/:id/isbn", setBookISBNHandler(services.Catalog))
+	admin.PUT("/books/:id/cover", uploadCoverHandler(services.Covers, services.Catalog))
+	admin.DELETE("/books/:id/cover", removeCoverHandler(services.Covers))
+	admin.PUT("/books/:id/authors", setBookCreditsHandler(services.Authors))
+	admin.POST("/authors", createAuthorHandler(services.Authors))
+	admin.PUT("/authors/:id", updateAuthorHandler(services.Authors))
+	admin.POST("/subjects", createSubjectHandler(services.Taxonomy))
+	admin.PUT("/subjects/:id", updateSubjectHandler(services.Taxonomy))
+	admin.PUT("/books/:id/subjects", setBookSubjectsHandler(services.Taxonomy))
+	admin.PUT("/books/:id/tags", setBookTagsHandler(services.Taxonomy))
+	if clock, ok := services.Clock.(*Config.OffsetClock); ok && Config.DebugEndpointsEnabled() {
+		admin.GET("/debug/clock", viewClockHandler(clock))
+		admin.PUT("/debug/clock", setClockOffsetHandler(clock))
+		admin.DELETE("/debug/clock", resetClockOffsetHandler(clock))
+	}
 }
 
 // @Summary Register a new user
-// @Description Register a new user with email and password
+// @Description Register a new user with email and password. The account stays unverified, unable to log in or borrow, until the link mailed to the address is followed.
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param user body Model.UserModel true "User registration details"
-// @Success 200 {string} string "Successfully created user"
-// @Failure 400 {object} map[string]string "Invalid JSON"
+// @Param user body Model.RegisterRequest true "User registration details"
+// @Success 200 {object} Model.UserResponse "Created user"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 409 {object} map[string]string "Email already registered"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
 // @Failure 500 {object} map[string]string "Failed to create user"
 // @Router /register [post]
-func registerHandlers(db *gorm.DB) echo.HandlerFunc {
+func registerHandlers(users Service.UserService) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var user Model.UserModel
-
-		if err := c.Bind(&user); err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid JSON"})
+		var request Model.RegisterRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
 		}
 
-		if err := db.Create(&user).Error; err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create user"})
+		user := Model.UserModel{
+			UserName: request.UserName,
+			Email:    request.Email,
+			Password: request.Password,
+		}
+		err := users.Register(c.Request().Context(), &user)
+		if errors.Is(err, Service.ErrEmailTaken) {
+			return echo.NewHTTPError(http.StatusConflict, "Email already registered")
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create user").SetInternal(err)
 		}
 
-		return c.JSON(http.StatusOK, "Successfully created user")
+		return c.JSON(http.StatusOK, Model.NewUserResponse(user))
 	}
 }
 
 // @Summary User login
-// @Description Login user and receive a JWT token
+// @Description Login user and receive a JWT token. Accounts with two-factor authentication
+// @Description receive a short-lived challenge token instead, to redeem at /login/2fa.
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param credentials body map[string]string true "Login credentials"
-// @Success 200 {object} map[string]string "JWT token"
+// @Param credentials body Model.LoginRequest true "Login credentials"
+// @Success 200 {object} Model.LoginResponse "JWT token or two-factor challenge"
 // @Failure 400 {object} map[string]string "Invalid request data"
-// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 401 {object} map[string]string "Invalid email or password"
+// @Failure 403 {object} map[string]string "Email address not verified"
+// @Failure 429 {object} map[string]string "Too many failed attempts; see Retry-After"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /login [post]
-func loginHandler(db *gorm.DB) echo.HandlerFunc {
+func loginHandler(users Service.UserService, sessions Service.SessionService, clock Config.Clock) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var loginData struct {
-			Email    string `json:"email"`
-			Password string `json:"password"`
-		}
-
-		if err := c.Bind(&loginData); err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request data"})
+		var request Model.LoginRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
 		}
 
-		var user Model.UserModel
-		result := db.Where("email = ?", loginData.Email).First(&user)
-
-		if result.Error != nil {
-			if result.Error == gorm.ErrRecordNotFound {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"message": "User not found"})
+		user, err := users.Authenticate(c.Request().Context(), request.Email, request.Password, c.RealIP())
+		if err != nil {
+			if throttled := throttledError(c, err); throttled != nil {
+				return throttled
 			}
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
+			switch {
+			case errors.Is(err, Service.ErrInvalidCredentials):
+				Config.FailedLogins.Inc()
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid email or password")
+			case errors.Is(err, Service.ErrEmailNotVerified):
+				return echo.NewHTTPError(http.StatusForbidden, "Email address not verified; follow the link we mailed you or request a new one")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Internal server error").SetInternal(err)
 		}
 
-		if user.Password != loginData.Password {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid credentials"})
-		}
+		return respondWithLogin(c, user, sessions, clock)
+	}
+}
 
-		token, err := Config.GenerateJWT()
+// respondWithLogin answers a login that proved who user is: with a JWT, or
+// with a challenge for the second factor when user has one enabled.
+func respondWithLogin(c echo.Context, user Model.UserModel, sessions Service.SessionService, clock Config.Clock) error {
+	if user.TwoFactorEnabled() {
+		challenge, err := Config.GenerateLoginChallenge(clock, user)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate JWT")
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate login challenge").SetInternal(err)
 		}
+		return c.JSON(http.StatusOK, Model.LoginResponse{TwoFactorRequired: true, ChallengeToken: challenge})
+	}
 
-		return c.JSON(http.StatusOK, map[string]string{
-			"token": token,
-		})
+	token, err := sessions.Start(c.Request().Context(), user, c.Request().UserAgent(), c.RealIP())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate JWT").SetInternal(err)
 	}
+
+	return c.JSON(http.StatusOK, Model.LoginResponse{Token: token})
+}
+
+// throttledError turns a *Service.ThrottledError into a 429 with Retry-After,
+// and returns nil for any other error.
+func throttledError(c echo.Context, err error) error {
+	var throttled *Service.ThrottledError
+	if !errors.As(err, &throttled) {
+		return nil
+	}
+	Config.ThrottledLogins.Inc()
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	return echo.NewHTTPError(http.StatusTooManyRequests, "Too many failed login attempts; try again later")
 }
 
 // @Summary Get all books
-// @Description Retrieve all books excluding their descriptions
+// @Description Retrieve all books excluding their descriptions, with how many of their copies are on the shelf.
+// @Description Filtering by subject includes the books filed under any subject below it;
+// @Description filtering by ISBN accepts either form, with or without hyphens.
 // @Tags books
 // @Produce json
+// @Param subject query int false "Only books filed under this subject or below it"
+// @Param tag query string false "Only books carrying this tag"
+// @Param isbn query string false "Only the book with this ISBN-10 or ISBN-13"
 // @Success 200 {array} Model.BookResponse "List of books"
+// @Failure 404 {object} map[string]string "Subject not found"
+// @Failure 422 {object} map[string]interface{} "Invalid subject ID or ISBN"
 // @Failure 500 {object} map[string]string "Failed to retrieve books"
 // @Router /view/books [get]
-func viewAllBookHandler(db *gorm.DB) echo.HandlerFunc {
+func viewAllBookHandler(catalog Service.CatalogService) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var books []Model.BookModel
+		books, err := listBooks(c, catalog)
+		if err != nil {
+			return err
+		}
+
+		return respondWithBooks(c, catalog, books)
+	}
+}
 
-		if err := db.Find(&books).Error; err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to retrieve books"})
+// listBooks lists the books matching the subject, tag and isbn query
+// parameters.
+func listBooks(c echo.Context, catalog Service.CatalogService) ([]Model.BookModel, error) {
+	filter := Service.BookFilter{Tag: c.QueryParam("tag"), ISBN: c.QueryParam("isbn")}
+	if filter.ISBN != "" && !Model.ValidISBN(filter.ISBN) {
+		return nil, Config.NewValidationError("isbn", "must be a valid ISBN-10 or ISBN-13")
+	}
+	if subject := c.QueryParam("subject"); subject != "" {
+		id, err := strconv.Atoi(subject)
+		if err != nil || id < 1 {
+			return nil, Config.NewValidationError("subject", "must be a positive integer")
 		}
+		filter.SubjectID = id
+	}
 
-		var response []Model.BookResponse
-		for _, book := range books {
-			response = append(response, Model.BookResponse{
-				ID:        book.ID,
-				Title:     book.Title,
-				Author:    book.Author,
-				Available: book.Available,
-			})
+	books, err := catalog.ListBooks(c.Request().Context(), filter)
+	if err != nil {
+		if errors.Is(err, Service.ErrSubjectNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "Subject not found")
 		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve books").SetInternal(err)
+	}
+	return books, nil
+}
 
-		return c.JSON(http.StatusOK, response)
+// respondWithBooks writes books as a listing, with their availability.
+func respondWithBooks(c echo.Context, catalog Service.CatalogService, books []Model.BookModel) error {
+	ids := make([]int, 0, len(books))
+	for _, book := range books {
+		ids = append(ids, book.ID)
 	}
+	availability, err := catalog.Availability(c.Request().Context(), ids...)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve books").SetInternal(err)
+	}
+
+	response := make([]Model.BookResponse, 0, len(books))
+	for _, book := range books {
+		response = append(response, Model.NewBookResponse(book, availability[book.ID]))
+	}
+	return c.JSON(http.StatusOK, response)
 }
 
 // @Summary Get book details
-// @Description Retrieve detailed information about a specific book
+// @Description Retrieve detailed information about a specific book. The book can be
+// @Description identified by its numeric ID, its ISBN-10 or ISBN-13 (with or without hyphens) or its
+// @Description slug; anything but the current slug redirects to the canonical URL.
 // @Tags books
 // @Produce json
-// @Param book path string true "Book ID"
-// @Success 200 {object} Model.BookModel "Book details"
+// @Param book path string true "Book ID, ISBN or slug"
+// @Success 200 {object} Model.BookDetailResponse "Book details"
+// @Success 301 {string} string "Moved to the book's canonical slug"
 // @Failure 404 {object} map[string]string "Book not found"
+// @Failure 500 {object} map[string]string "Failed to find book"
 // @Router /view/description/{book} [get]
-func viewBookDetailHandler(db *gorm.DB) echo.HandlerFunc {
+func viewBookDetailHandler(catalog Service.CatalogService, authors Service.AuthorService, taxonomy Service.TaxonomyService) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		bookID := c.Param("id")
-		var book Model.BookModel
+		ref := c.Param("book")
+
+		book, err := catalog.FindBook(c.Request().Context(), ref)
+		if err != nil {
+			if errors.Is(err, Service.ErrBookNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound,
+					fmt.Sprintf("Book not found: no book has the ID, ISBN or slug %q; see /view/books for valid identifiers", ref))
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to find book").SetInternal(err)
+		}
+
+		if ref != book.Slug {
+			return c.Redirect(http.StatusMovedPermanently, "/view/description/"+url.PathEscape(book.Slug))
+		}
+
+		availability, err := catalog.Availability(c.Request().Context(), book.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to find book").SetInternal(err)
+		}
 
-		if err := db.First(&book, bookID).Error; err != nil {
-			return c.JSON(http.StatusNotFound, map[string]string{"message": "Book not found"})
+		contributors, err := authors.Contributors(c.Request().Context(), book.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to find book").SetInternal(err)
 		}
 
-		return c.JSON(http.StatusOK, book)
+		subjects, err := taxonomy.SubjectsOfBook(c.Request().Context(), book.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to find book").SetInternal(err)
+		}
+		tags, err := taxonomy.TagsOfBook(c.Request().Context(), book.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to find book").SetInternal(err)
+		}
+
+		response := Model.NewBookDetailResponse(book, availability[book.ID], newContributorResponses(contributors))
+		response.Subjects = newSubjectResponses(subjects)
+		response.Tags = append(response.Tags, tags...)
+		return c.JSON(http.StatusOK, response)
 	}
 }
 
+// bookParam resolves the book path parameter, which like on the detail page
+// can be the book's ID, ISBN or slug.
+func bookParam(c echo.Context, catalog Service.CatalogService) (Model.BookModel, error) {
+	book, err := catalog.FindBook(c.Request().Context(), c.Param("book"))
+	if errors.Is(err, Service.ErrBookNotFound) {
+		return book, echo.NewHTTPError(http.StatusNotFound, "Book not found")
+	}
+	if err != nil {
+		return book, echo.NewHTTPError(http.StatusInternalServerError, "Failed to find book").SetInternal(err)
+	}
+	return book, nil
+}
+
 // @Summary Borrow a book
-// @Description Mark a book as borrowed
+// @Description Lend the signed-in user one of the book's available copies. Each user can hold one copy of a title at a time.
 // @Tags books
 // @Produce json
-// @Param id path string true "Book ID"
-// @Success 200 {object} map[string]string "Book borrowed successfully"
+// @Param book path string true "Book ID, ISBN or slug"
+// @Success 200 {object} Model.BorrowResponse "Book borrowed successfully"
+// @Failure 403 {object} map[string]string "Email address not verified"
 // @Failure 404 {object} map[string]string "Book not found"
-// @Failure 409 {object} map[string]string "Book is already borrowed"
+// @Failure 409 {object} map[string]string "No copy available, or the user already has one"
 // @Failure 500 {object} map[string]string "Failed to borrow book"
-// @Router /view/borrow/{id} [get]
-func borrowBookHandler(db *gorm.DB) echo.HandlerFunc {
+// @Router /view/borrow/{book} [get]
+func borrowBookHandler(catalog Service.CatalogService, lending Service.LendingService) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		bookID := c.Param("id")
-		var book Model.BookModel
-
-		if err := db.First(&book, bookID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return c.JSON(http.StatusNotFound, map[string]string{"message": "Book not found"})
-			}
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to find book"})
-		}
-
-		if !book.Available {
-			return c.JSON(http.StatusConflict, map[string]string{"message": "Book is already borrowed"})
+		book, err := bookParam(c, catalog)
+		if err != nil {
+			return err
 		}
 
-		book.Available = false
-		if err := db.Save(&book).Error; err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to borrow book"})
+		userID := c.Get(Config.UserIDKey).(int)
+		loan, item, err := lending.Borrow(c.Request().Context(), userID, book.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, Service.ErrBookNotFound):
+				return echo.NewHTTPError(http.StatusNotFound, "Book not found")
+			case errors.Is(err, Service.ErrBookUnavailable):
+				return echo.NewHTTPError(http.StatusConflict, "Book is already borrowed")
+			case errors.Is(err, Service.ErrAlreadyBorrowed):
+				return echo.NewHTTPError(http.StatusConflict, "You already have a copy of this book")
+			case errors.Is(err, Service.ErrEmailNotVerified):
+				return echo.NewHTTPError(http.StatusForbidden, "Verify your email address before borrowing books")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to borrow book").SetInternal(err)
 		}
 
-		return c.JSON(http.StatusOK, map[string]string{"message": "Book borrowed successfully"})
+		return c.JSON(http.StatusOK, Model.BorrowResponse{
+			Message: "Book borrowed successfully",
+			Loan:    Model.NewLoanResponse(loan),
+			Barcode: item.Barcode,
+		})
 	}
 }
 
 // @Summary Return a book
-// @Description Mark a book as returned
+// @Description Return the signed-in user's copy of the book
 // @Tags books
 // @Produce json
-// @Param id path string true "Book ID"
+// @Param book path string true "Book ID, ISBN or slug"
 // @Success 200 {object} map[string]string "Book returned successfully"
 // @Failure 404 {object} map[string]string "Book not found"
 // @Failure 409 {object} map[string]string "Book is already returned"
 // @Failure 500 {object} map[string]string "Failed to return book"
-// @Router /view/return/{id} [get]
-func returnBookHandler(db *gorm.DB) echo.HandlerFunc {
+// @Router /view/return/{book} [get]
+func returnBookHandler(catalog Service.CatalogService, lending Service.LendingService) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		bookID := c.Param("id")
-		var book Model.BookModel
-
-		if err := db.First(&book, bookID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return c.JSON(http.StatusNotFound, map[string]string{"message": "Book not found"})
-			}
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to find book"})
-		}
-
-		if book.Available {
-			return c.JSON(http.StatusConflict, map[string]string{"message": "Book is already returned"})
+		book, err := bookParam(c, catalog)
+		if err != nil {
+			return err
 		}
 
-		book.Available = true
-		if err := db.Save(&book).Error; err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to return book"})
+		if err := lending.Return(c.Request().Context(), c.Get(Config.UserIDKey).(int), book.ID); err != nil {
+			switch {
+			case errors.Is(err, Service.ErrBookNotFound):
+				return echo.NewHTTPError(http.StatusNotFound, "Book not found")
+			case errors.Is(err, Service.ErrBookNotBorrowed):
+				return echo.NewHTTPError(http.StatusConflict, "Book is already returned")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to return book").SetInternal(err)
 		}
 
 		return c.JSON(http.StatusOK, map[string]string{"message": "Book returned successfully"})
@@ -0,0 +1,584 @@
+package Controller_test
+
+import (
+	"awesomeProject/Model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+func TestRegister(t *testing.T) {
+	t.Run("creates the user", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/register", map[string]string{
+			"userName": "alice",
+			"email":    "alice@example.com",
+			"password": "wonderland1",
+		}, "")
+
+		expectStatus(t, rec, http.StatusOK)
+		var user Model.UserModel
+		if err := s.db.Where("email = ?", "alice@example.com").First(&user).Error; err != nil {
+			t.Fatalf("user not stored: %v", err)
+		}
+		if user.UserName != "alice" {
+			t.Errorf("userName = %q, want %q", user.UserName, "alice")
+		}
+	})
+
+	t.Run("rejects malformed JSON", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/register", `{"email":`, "")
+
+		expectError(t, rec, http.StatusBadRequest, "Invalid request data")
+	})
+
+	t.Run("refuses a taken email", func(t *testing.T) {
+		s := newTestServer(t)
+		aUser().withEmail("taken@example.com").create(s)
+
+		rec := s.do(http.MethodPost, "/register", map[string]string{
+			"userName": "bob",
+			"email":    "Taken@Example.com",
+			"password": "another-password1",
+		}, "")
+
+		expectError(t, rec, http.StatusConflict, "Email already registered")
+	})
+}
+
+func TestLogin(t *testing.T) {
+	t.Run("returns a token for valid credentials", func(t *testing.T) {
+		s := newTestServer(t)
+		aUser().withEmail("carol@example.com").withPassword("correct horse").create(s)
+
+		token := s.loginAs("carol@example.com", "correct horse")
+
+		expectStatus(t, s.do(http.MethodGet, "/view/books", nil, token), http.StatusOK)
+	})
+
+	t.Run("rejects an unknown email", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/login", map[string]string{"email": "nobody@example.com", "password": "x"}, "")
+
+		expectError(t, rec, http.StatusUnauthorized, "Invalid email or password")
+	})
+
+	t.Run("rejects a wrong password", func(t *testing.T) {
+		s := newTestServer(t)
+		aUser().withEmail("dave@example.com").withPassword("right").create(s)
+
+		rec := s.do(http.MethodPost, "/login", map[string]string{"email": "dave@example.com", "password": "wrong"}, "")
+
+		expectError(t, rec, http.StatusUnauthorized, "Invalid email or password")
+	})
+
+	t.Run("rejects malformed JSON", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/login", `not json`, "")
+
+		expectError(t, rec, http.StatusBadRequest, "Invalid request data")
+	})
+}
+
+func TestSecuredRoutesRequireValidToken(t *testing.T) {
+	secret := []byte("SUPER_SECRET_KEY")
+	routes := []string{"/me", "/view/books", "/view/description/1", "/view/borrow/1", "/view/return/1"}
+
+	cases := []struct {
+		name    string
+		header  func(t *testing.T) string
+		message string
+	}{
+		{
+			name:    "missing header",
+			header:  func(*testing.T) string { return "" },
+			message: "Missing Authorization Header",
+		},
+		{
+			name:    "not a bearer token",
+			header:  func(*testing.T) string { return "Basic dXNlcjpwYXNz" },
+			message: "Invalid Authorization Header",
+		},
+		{
+			name:    "malformed token",
+			header:  func(*testing.T) string { return "Bearer not-a-jwt" },
+			message: "Invalid or Expired Token",
+		},
+		{
+			name: "expired token",
+			header: func(t *testing.T) string {
+				return "Bearer " + signToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{
+					"sub": 1,
+					"exp": testStart.Add(-time.Minute).Unix(),
+				})
+			},
+			message: "Invalid or Expired Token",
+		},
+		{
+			name: "wrong secret",
+			header: func(t *testing.T) string {
+				return "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("not the secret"), jwt.MapClaims{
+					"sub": 1,
+					"exp": testStart.Add(time.Hour).Unix(),
+				})
+			},
+			message: "Invalid or Expired Token",
+		},
+		{
+			name: "no subject",
+			header: func(t *testing.T) string {
+				return "Bearer " + signToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{
+					"exp": testStart.Add(time.Hour).Unix(),
+				})
+			},
+			message: "Invalid Token Subject",
+		},
+		{
+			name: "unknown user",
+			header: func(t *testing.T) string {
+				return "Bearer " + signToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{
+					"sub": 999,
+					"exp": testStart.Add(time.Hour).Unix(),
+				})
+			},
+			message: "Token has been revoked",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t)
+			aBook().create(s)
+			header := tc.header(t)
+
+			for _, route := range routes {
+				req := httptest.NewRequest(http.MethodGet, route, nil)
+				if header != "" {
+					req.Header.Set(echo.HeaderAuthorization, header)
+				}
+				expectError(t, s.serve(req), http.StatusUnauthorized, tc.message)
+			}
+		})
+	}
+}
+
+func TestTokensExpireAfterAnHour(t *testing.T) {
+	s := newTestServer(t)
+	token := s.login()
+
+	s.clock.Advance(59 * time.Minute)
+	expectStatus(t, s.do(http.MethodGet, "/view/books", nil, token), http.StatusOK)
+
+	s.clock.Advance(2 * time.Minute)
+	expectError(t, s.do(http.MethodGet, "/view/books", nil, token), http.StatusUnauthorized, "Invalid or Expired Token")
+}
+
+func TestViewBooks(t *testing.T) {
+	t.Run("lists books without descriptions", func(t *testing.T) {
+		s := newTestServer(t)
+		first := aBook().withTitle("Dune").create(s)
+		second := aBook().withTitle("Emma").onLoan().create(s)
+
+		rec := s.do(http.MethodGet, "/view/books", nil, s.login())
+
+		expectStatus(t, rec, http.StatusOK)
+		if strings.Contains(rec.Body.String(), "description") {
+			t.Errorf("listing leaks descriptions: %s", rec.Body)
+		}
+		var books []Model.BookResponse
+		decode(t, rec, &books)
+		want := []Model.BookResponse{
+			{ID: first.ID, Slug: "dune-test-author", Title: "Dune", Author: first.Author, Available: true, AvailableCopies: 1, Copies: 1},
+			{ID: second.ID, Slug: "emma-test-author", Title: "Emma", Author: second.Author, Available: false, AvailableCopies: 0, Copies: 1},
+		}
+		if len(books) != len(want) {
+			t.Fatalf("got %d books, want %d", len(books), len(want))
+		}
+		for i := range want {
+			if books[i] != want[i] {
+				t.Errorf("book %d = %+v, want %+v", i, books[i], want[i])
+			}
+		}
+	})
+
+	t.Run("counts available copies", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().withCopies(3).create(s)
+		admin := s.loginAdmin()
+		expectStatus(t, s.do(http.MethodGet, "/view/borrow/1", nil, s.login()), http.StatusOK)
+		expectStatus(t, s.do(http.MethodPatch, "/admin/copies/2", map[string]string{"status": Model.CopyInRepair}, admin), http.StatusOK)
+		expectStatus(t, s.do(http.MethodPatch, "/admin/copies/3", map[string]string{"status": Model.CopyWithdrawn}, admin), http.StatusOK)
+
+		rec := s.do(http.MethodGet, "/view/books", nil, admin)
+
+		var books []Model.BookResponse
+		decode(t, rec, &books)
+		if len(books) != 1 || books[0].Available || books[0].AvailableCopies != 0 || books[0].Copies != 2 {
+			t.Errorf("books = %+v, want none of 2 copies available", books)
+		}
+	})
+}
+
+func TestViewBookDetail(t *testing.T) {
+	t.Run("returns the book by slug", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().withTitle("Ulysses").withAuthor("James Joyce").create(s)
+
+		rec := s.do(http.MethodGet, "/view/description/ulysses-james-joyce", nil, s.login())
+
+		expectStatus(t, rec, http.StatusOK)
+		var got Model.BookDetailResponse
+		decode(t, rec, &got)
+		if got.ID != book.ID || got.Slug != "ulysses-james-joyce" || got.Description != book.Description {
+			t.Errorf("got %+v, want %+v", got, book)
+		}
+	})
+
+	redirects := []struct {
+		name, ref string
+	}{
+		{"redirects an ID to the slug", "1"},
+		{"redirects an ISBN-13 to the slug", "9780199535675"},
+		{"redirects a hyphenated ISBN to the slug", "978-0-19-953567-5"},
+		{"redirects an ISBN-10 to the slug", "0-19-953567-1"},
+	}
+	for _, tc := range redirects {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t)
+			aBook().withTitle("Ulysses").withAuthor("James Joyce").withISBN("9780199535675").create(s)
+
+			rec := s.do(http.MethodGet, "/view/description/"+tc.ref, nil, s.login())
+
+			expectStatus(t, rec, http.StatusMovedPermanently)
+			if got := rec.Header().Get(echo.HeaderLocation); got != "/view/description/ulysses-james-joyce" {
+				t.Errorf("Location = %q", got)
+			}
+		})
+	}
+
+	t.Run("prefers the ID to an ISBN of the same digits", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().withTitle("War and Peace").withAuthor("Leo Tolstoy").withISBN("9781400079988").create(s)
+		aBook().withID(1400079985).withTitle("Anna Karenina").withAuthor("Leo Tolstoy").create(s)
+
+		rec := s.do(http.MethodGet, "/view/description/1400079985", nil, s.login())
+
+		expectStatus(t, rec, http.StatusMovedPermanently)
+		if got := rec.Header().Get(echo.HeaderLocation); got != "/view/description/anna-karenina-leo-tolstoy" {
+			t.Errorf("Location = %q", got)
+		}
+		rec = s.do(http.MethodGet, "/view/description/1-4000-7998-5", nil, s.login())
+		if got := rec.Header().Get(echo.HeaderLocation); got != "/view/description/war-and-peace-leo-tolstoy" {
+			t.Errorf("Location = %q for the hyphenated ISBN", got)
+		}
+	})
+
+	t.Run("redirects a former slug after a rename", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().withTitle("Ulyses").withAuthor("James Joyce").create(s)
+		book.Title = "Ulysses"
+		if err := s.db.Save(&book).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		rec := s.do(http.MethodGet, "/view/description/ulyses-james-joyce", nil, s.login())
+
+		expectStatus(t, rec, http.StatusMovedPermanently)
+		if got := rec.Header().Get(echo.HeaderLocation); got != "/view/description/ulysses-james-joyce" {
+			t.Errorf("Location = %q", got)
+		}
+	})
+
+	t.Run("keeps slugs unique", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().withTitle("Poems").withAuthor("Anonymous").create(s)
+		second := aBook().withTitle("Poems").withAuthor("Anonymous").create(s)
+
+		if second.Slug != "poems-anonymous-2" {
+			t.Errorf("slug = %q, want poems-anonymous-2", second.Slug)
+		}
+		rec := s.do(http.MethodGet, "/view/description/poems-anonymous-2", nil, s.login())
+		expectStatus(t, rec, http.StatusOK)
+	})
+
+	t.Run("explains a miss", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().create(s)
+		token := s.login()
+
+		// The last two have the shape of an ISBN but a wrong check digit.
+		for _, ref := range []string{"42", "no-such-book", "9780000000002", "9780000000003", "0199535670"} {
+			rec := s.do(http.MethodGet, "/view/description/"+ref, nil, token)
+			expectError(t, rec, http.StatusNotFound,
+				`Book not found: no book has the ID, ISBN or slug "`+ref+`"; see /view/books for valid identifiers`)
+		}
+	})
+}
+
+func TestBorrowBook(t *testing.T) {
+	t.Run("lends an available book", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().create(s)
+
+		rec := s.do(http.MethodGet, "/view/borrow/1", nil, s.login())
+
+		expectStatus(t, rec, http.StatusOK)
+		var body Model.BorrowResponse
+		decode(t, rec, &body)
+		var item Model.BookCopyModel
+		s.db.Where("book_id = ?", book.ID).First(&item)
+		if item.Status != Model.CopyOnLoan || body.Barcode != item.Barcode || body.Loan.CopyID != item.ID {
+			t.Errorf("copy = %+v, response = %+v, want the copy on loan", item, body)
+		}
+		var loan Model.LoanModel
+		if err := s.db.Where("book_id = ? AND returned_at IS NULL", book.ID).First(&loan).Error; err != nil {
+			t.Fatalf("no open loan recorded: %v", err)
+		}
+		if !loan.BorrowedAt.Equal(testStart) {
+			t.Errorf("borrowedAt = %v, want %v", loan.BorrowedAt, testStart)
+		}
+		if want := testStart.Add(14 * 24 * time.Hour); !loan.DueAt.Equal(want) {
+			t.Errorf("dueAt = %v, want %v", loan.DueAt, want)
+		}
+	})
+
+	t.Run("assigns each borrower their own copy", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().withCopies(2).create(s)
+
+		var barcodes []string
+		for range 2 {
+			rec := s.do(http.MethodGet, "/view/borrow/1", nil, s.login())
+			expectStatus(t, rec, http.StatusOK)
+			var body Model.BorrowResponse
+			decode(t, rec, &body)
+			barcodes = append(barcodes, body.Barcode)
+		}
+
+		if barcodes[0] == barcodes[1] {
+			t.Errorf("both borrowers got copy %s", barcodes[0])
+		}
+		expectError(t, s.do(http.MethodGet, "/view/borrow/1", nil, s.login()), http.StatusConflict, "Book is already borrowed")
+	})
+
+	t.Run("skips copies that are not on the shelf", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().withCopies(2).create(s)
+		expectStatus(t, s.do(http.MethodPatch, "/admin/copies/1", map[string]string{"status": Model.CopyLost}, s.loginAdmin()), http.StatusOK)
+
+		rec := s.do(http.MethodGet, "/view/borrow/1", nil, s.login())
+
+		expectStatus(t, rec, http.StatusOK)
+		var body Model.BorrowResponse
+		decode(t, rec, &body)
+		if body.Loan.CopyID != 2 {
+			t.Errorf("lent copy %d, want 2", body.Loan.CopyID)
+		}
+	})
+
+	t.Run("conflicts when the book is on loan", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().onLoan().create(s)
+
+		rec := s.do(http.MethodGet, "/view/borrow/1", nil, s.login())
+
+		expectError(t, rec, http.StatusConflict, "Book is already borrowed")
+	})
+
+	t.Run("conflicts when borrowed twice", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().withCopies(2).create(s)
+		token := s.login()
+
+		expectStatus(t, s.do(http.MethodGet, "/view/borrow/1", nil, token), http.StatusOK)
+		rec := s.do(http.MethodGet, "/view/borrow/1", nil, token)
+
+		expectError(t, rec, http.StatusConflict, "You already have a copy of this book")
+	})
+
+	t.Run("404 for an unknown book", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodGet, "/view/borrow/42", nil, s.login())
+
+		expectError(t, rec, http.StatusNotFound, "Book not found")
+	})
+
+	t.Run("404 for an unknown slug", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodGet, "/view/borrow/abc", nil, s.login())
+
+		expectError(t, rec, http.StatusNotFound, "Book not found")
+	})
+
+	t.Run("finds the book by ISBN or slug", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().withCopies(2).withISBN("9780441172719").create(s)
+
+		expectStatus(t, s.do(http.MethodGet, "/view/borrow/0-441-17271-7", nil, s.login()), http.StatusOK)
+		expectStatus(t, s.do(http.MethodGet, "/view/borrow/"+book.Slug, nil, s.login()), http.StatusOK)
+		expectError(t, s.do(http.MethodGet, "/view/borrow/"+book.Slug, nil, s.login()), http.StatusConflict, "Book is already borrowed")
+	})
+}
+
+func TestReturnBook(t *testing.T) {
+	t.Run("returns a borrowed book", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().create(s)
+		token := s.login()
+		expectStatus(t, s.do(http.MethodGet, "/view/borrow/1", nil, token), http.StatusOK)
+
+		rec := s.do(http.MethodGet, "/view/return/1", nil, token)
+
+		expectStatus(t, rec, http.StatusOK)
+		var item Model.BookCopyModel
+		s.db.Where("book_id = ?", book.ID).First(&item)
+		if item.Status != Model.CopyAvailable {
+			t.Errorf("copy status = %q after returning, want available", item.Status)
+		}
+		var open int64
+		s.db.Model(&Model.LoanModel{}).Where("returned_at IS NULL").Count(&open)
+		if open != 0 {
+			t.Errorf("%d loans still open", open)
+		}
+	})
+
+	t.Run("only returns the caller's copy", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().create(s)
+		expectStatus(t, s.do(http.MethodGet, "/view/borrow/1", nil, s.login()), http.StatusOK)
+
+		rec := s.do(http.MethodGet, "/view/return/1", nil, s.login())
+
+		expectError(t, rec, http.StatusConflict, "Book is already returned")
+		var item Model.BookCopyModel
+		s.db.First(&item, 1)
+		if item.Status != Model.CopyOnLoan {
+			t.Errorf("copy status = %q, want still on loan", item.Status)
+		}
+	})
+
+	t.Run("conflicts when the book is not on loan", func(t *testing.T) {
+		s := newTestServer(t)
+		aBook().create(s)
+
+		rec := s.do(http.MethodGet, "/view/return/1", nil, s.login())
+
+		expectError(t, rec, http.StatusConflict, "Book is already returned")
+	})
+
+	t.Run("404 for an unknown book", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodGet, "/view/return/42", nil, s.login())
+
+		expectError(t, rec, http.StatusNotFound, "Book not found")
+	})
+
+	t.Run("404 for an unknown slug", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodGet, "/view/return/abc", nil, s.login())
+
+		expectError(t, rec, http.StatusNotFound, "Book not found")
+	})
+
+	t.Run("finds the book by ISBN or slug", func(t *testing.T) {
+		s := newTestServer(t)
+		book := aBook().withISBN("9780441172719").create(s)
+		token := s.login()
+		expectStatus(t, s.do(http.MethodGet, "/view/borrow/"+book.Slug, nil, token), http.StatusOK)
+
+		expectStatus(t, s.do(http.MethodGet, "/view/return/9780441172719", nil, token), http.StatusOK)
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	s := newTestServer(t)
+	aBook().create(s)
+	expectStatus(t, s.do(http.MethodGet, "/view/borrow/1", nil, s.login()), http.StatusOK)
+
+	rec := s.do(http.MethodGet, "/metrics", nil, "")
+
+	expectStatus(t, rec, http.StatusOK)
+	for _, want := range []string{
+		"library_active_loans 1",
+		`http_requests_total{method="GET",route="/view/borrow/:book",status="200"}`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+}
+
+func TestMetricsCountOverdueLoans(t *testing.T) {
+	s := newTestServer(t)
+	aBook().create(s)
+	expectStatus(t, s.do(http.MethodGet, "/view/borrow/1", nil, s.login()), http.StatusOK)
+
+	s.clock.Advance(15 * 24 * time.Hour)
+	rec := s.do(http.MethodGet, "/metrics", nil, "")
+
+	if !strings.Contains(rec.Body.String(), "library_overdue_loans 1") {
+		t.Error("loan past its due date is not reported as overdue")
+	}
+}
+
+func TestSwagger(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(http.MethodGet, "/swagger/doc.json", nil, "")
+
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "/view/books") {
+		t.Error("swagger document does not describe /view/books")
+	}
+}
+
+func TestUnknownRoute(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(http.MethodGet, "/nope", nil, "")
+
+	expectError(t, rec, http.StatusNotFound, "Not Found")
+}
+
+func TestRequestTimeout(t *testing.T) {
+	t.Run("answers 504 once the deadline has passed", func(t *testing.T) {
+		t.Setenv("REQUEST_TIMEOUT", "1ns")
+		s := newTestServer(t)
+		user := aUser().create(s)
+
+		rec := s.do(http.MethodPost, "/login", map[string]string{"email": user.Email, "password": user.Password}, "")
+
+		expectError(t, rec, http.StatusGatewayTimeout, "Request timed out")
+	})
+
+	t.Run("keeps the responses handlers chose", func(t *testing.T) {
+		t.Setenv("REQUEST_TIMEOUT", "1ns")
+		s := newTestServer(t)
+
+		expectError(t, s.do(http.MethodGet, "/nope", nil, ""), http.StatusNotFound, "Not Found")
+		expectValidationError(t, s.do(http.MethodPost, "/login", map[string]string{"password": "secret-1"}, ""), "email")
+	})
+}
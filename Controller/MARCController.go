@@ -0,0 +1,57 @@
+package Controller
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Service"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// marcDownloads maps export formats to their media type and file name.
+var marcDownloads = map[string]struct{ contentType, fileName string }{
+	Model.ImportMARC:    {"application/marc", "catalog.mrc"},
+	Model.ImportMARCXML: {"application/marcxml+xml", "catalog.xml"},
+}
+
+// @Summary Export books as MARC
+// @Description Download books as MARC 21 bibliographic records, in ISO 2709 or MARCXML. Records carry the book ID
+// @Description (001), ISBNs (020), first author (100), title and author statement (245), description (520),
+// @Description subjects (650) and other contributors (700), and can be imported again.
+// @Description Takes the same filters as the book listing.
+// @Tags books
+// @Produce application/marc
+// @Produce application/marcxml+xml
+// @Param format query string false "marc or marcxml (the default)"
+// @Param subject query int false "Only books filed under this subject or below it"
+// @Param tag query string false "Only books carrying this tag"
+// @Param isbn query string false "Only the book with this ISBN-10 or ISBN-13"
+// @Success 200 {file} file "MARC records"
+// @Failure 404 {object} map[string]string "Subject not found"
+// @Failure 422 {object} map[string]interface{} "Invalid format, subject ID or ISBN"
+// @Failure 500 {object} map[string]string "Failed to export books"
+// @Router /view/books/marc [get]
+func exportMARCHandler(catalog Service.CatalogService, marc Service.MARCService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		format := c.QueryParam("format")
+		if format == "" {
+			format = Model.ImportMARCXML
+		}
+		download, ok := marcDownloads[format]
+		if !ok {
+			return Config.NewValidationError("format", "must be one of marc, marcxml")
+		}
+		books, err := listBooks(c, catalog)
+		if err != nil {
+			return err
+		}
+
+		data, err := marc.Export(c.Request().Context(), format, books)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to export books").SetInternal(err)
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", download.fileName))
+		return c.Blob(http.StatusOK, download.contentType, data)
+	}
+}
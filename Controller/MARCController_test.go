@@ -0,0 +1,210 @@
+package Controller_test
+
+import (
+	"awesomeProject/Model"
+	"bytes"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// readSample reads a file of Controller/testdata.
+func readSample(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// exportMARC downloads the catalog in format.
+func (s *testServer) exportMARC(token, format string) []byte {
+	s.t.Helper()
+	rec := s.do(http.MethodGet, "/view/books/marc?format="+format, nil, token)
+	expectStatus(s.t, rec, http.StatusOK)
+	return rec.Body.Bytes()
+}
+
+// bookByTitle returns the details of the listed book titled title.
+func (s *testServer) bookByTitle(token, title string) Model.BookDetailResponse {
+	s.t.Helper()
+	var books []Model.BookResponse
+	decode(s.t, s.do(http.MethodGet, "/view/books", nil, token), &books)
+	for _, book := range books {
+		if book.Title == title {
+			var detail Model.BookDetailResponse
+			decode(s.t, s.do(http.MethodGet, "/view/description/"+book.Slug, nil, token), &detail)
+			return detail
+		}
+	}
+	s.t.Fatalf("no book titled %q in %v", title, bookTitles(books))
+	return Model.BookDetailResponse{}
+}
+
+func subjectPaths(subjects []Model.SubjectResponse) []string {
+	paths := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		paths = append(paths, subject.Path)
+	}
+	return paths
+}
+
+func TestMARC(t *testing.T) {
+	t.Run("imports MARC 21 and MARCXML records alike", func(t *testing.T) {
+		var exports [][]byte
+		for name, format := range map[string]string{"records.mrc": Model.ImportMARC, "records.xml": Model.ImportMARCXML} {
+			s := newTestServer(t)
+			admin := s.loginAdmin()
+
+			report := s.runImport(admin, name, readSample(t, name), nil)
+			if report.Status != Model.ImportSucceeded || report.Format != format || report.Created != 2 || report.Failed != 0 {
+				t.Fatalf("%s: report = %+v", name, report)
+			}
+
+			hobbit := s.bookByTitle(admin, "The hobbit, or, There and back again")
+			if hobbit.Author != "J.R.R. Tolkien" || hobbit.ISBN13 != "9780547928227" || !strings.HasPrefix(hobbit.Description, "Bilbo Baggins") {
+				t.Errorf("%s: hobbit = %+v", name, hobbit)
+			}
+			if fmt.Sprint(hobbit.Contributors) != fmt.Sprint([]Model.ContributorResponse{{AuthorID: 1, Name: "J. R. R. Tolkien", Role: Model.CreditAuthor}}) {
+				t.Errorf("%s: hobbit contributors = %+v", name, hobbit.Contributors)
+			}
+			want := []string{"Baggins, Bilbo (Fictitious character) > Fiction", "Middle Earth (Imaginary place) > Fiction"}
+			if fmt.Sprint(subjectPaths(hobbit.Subjects)) != fmt.Sprint(want) {
+				t.Errorf("%s: hobbit subjects = %v, want %v", name, subjectPaths(hobbit.Subjects), want)
+			}
+
+			quixote := s.bookByTitle(admin, "Don Quixote")
+			if quixote.Author != "Miguel de Cervantes ; translated by Edith Grossman" || quixote.ISBN10 != "0060934344" {
+				t.Errorf("%s: quixote = %+v", name, quixote)
+			}
+			if paragraphs := strings.Split(quixote.Description, "\n\n"); len(paragraphs) != 2 {
+				t.Errorf("%s: quixote description = %q, want both summaries", name, quixote.Description)
+			}
+			wantCredits := []Model.ContributorResponse{
+				{AuthorID: 2, Name: "Miguel de Cervantes Saavedra", Role: Model.CreditAuthor},
+				{AuthorID: 3, Name: "Edith Grossman", Role: Model.CreditTranslator},
+			}
+			if fmt.Sprint(quixote.Contributors) != fmt.Sprint(wantCredits) {
+				t.Errorf("%s: quixote contributors = %+v, want %+v", name, quixote.Contributors, wantCredits)
+			}
+			if paths := subjectPaths(quixote.Subjects); fmt.Sprint(paths) != "[Knights and knighthood > Spain > Fiction]" {
+				t.Errorf("%s: quixote subjects = %v", name, paths)
+			}
+
+			exports = append(exports, s.exportMARC(admin, Model.ImportMARCXML))
+		}
+		if !bytes.Equal(exports[0], exports[1]) {
+			t.Errorf("catalogs differ after importing both samples:\n%s\n%s", exports[0], exports[1])
+		}
+	})
+
+	t.Run("round-trips exports", func(t *testing.T) {
+		for _, format := range []string{Model.ImportMARC, Model.ImportMARCXML} {
+			source := newTestServer(t)
+			admin := source.loginAdmin()
+			source.runImport(admin, "records.xml", readSample(t, "records.xml"), nil)
+			rec := source.do(http.MethodGet, "/view/books/marc?format="+format, nil, admin)
+			expectStatus(t, rec, http.StatusOK)
+			exported := rec.Body.Bytes()
+
+			target := newTestServer(t)
+			targetAdmin := target.loginAdmin()
+			report := target.runImport(targetAdmin, "catalog", string(exported), map[string]string{"format": format})
+			if report.Created != 2 || report.Failed != 0 {
+				t.Fatalf("%s: report = %+v", format, report)
+			}
+			if again := target.exportMARC(targetAdmin, format); !bytes.Equal(again, exported) {
+				t.Errorf("%s: export changed on re-import:\n%q\n%q", format, exported, again)
+			}
+		}
+	})
+
+	t.Run("exports the fields of each book", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+		s.runImport(admin, "records.mrc", readSample(t, "records.mrc"), nil)
+
+		rec := s.do(http.MethodGet, "/view/books/marc?isbn=0-06-093434-4", nil, admin)
+		expectStatus(t, rec, http.StatusOK)
+		if got := rec.Header().Get(echo.HeaderContentType); got != "application/marcxml+xml" {
+			t.Errorf("content type = %q", got)
+		}
+		if got := rec.Header().Get(echo.HeaderContentDisposition); got != `attachment; filename="catalog.xml"` {
+			t.Errorf("content disposition = %q", got)
+		}
+		xml := rec.Body.String()
+		for _, want := range []string{
+			`<collection xmlns="http://www.loc.gov/MARC21/slim">`,
+			`<controlfield tag="001">2</controlfield>`,
+			`<subfield code="a">9780060934347</subfield>`,
+			`<subfield code="a">0060934344</subfield>`,
+			`<datafield tag="100" ind1="1" ind2=" ">`,
+			`<subfield code="a">Don Quixote /</subfield>`,
+			`<subfield code="c">Miguel de Cervantes ; translated by Edith Grossman.</subfield>`,
+			`<datafield tag="650" ind1=" " ind2="4">`,
+			`<subfield code="x">Spain</subfield>`,
+			`<subfield code="a">Grossman, Edith,</subfield>`,
+			`<subfield code="e">translator.</subfield>`,
+		} {
+			if !strings.Contains(xml, want) {
+				t.Errorf("export lacks %s:\n%s", want, xml)
+			}
+		}
+		if strings.Count(xml, "<record>") != 1 {
+			t.Errorf("export has %d records, want the filtered one", strings.Count(xml, "<record>"))
+		}
+
+		binary := s.exportMARC(admin, Model.ImportMARC)
+		if bytes.Count(binary, []byte{0x1D}) != 2 || !bytes.HasPrefix(binary[5:], []byte("nam a22")) {
+			t.Errorf("binary export = %q", binary)
+		}
+	})
+
+	t.Run("reports records it cannot import", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+
+		binary := readSample(t, "records.mrc") + "garbage\x1d" + "00030nam  2200025   4500\xe9\x1e\x1d" +
+			"00048nam  2200037   4500" + "2450010-9999\x1e" + "10\x1faTitle\x1e\x1d"
+		report := s.runImport(admin, "books.mrc", binary, nil)
+		want := []Model.ImportErrorResponse{
+			{Row: 3, Message: "is too short for a MARC record"},
+			{Row: 4, Message: "is not UTF-8; only Unicode MARC records can be imported"},
+			{Row: 5, Message: "has a malformed directory entry for field 245"},
+		}
+		if report.Created != 2 || fmt.Sprint(report.Errors) != fmt.Sprint(want) {
+			t.Errorf("report = %+v, want errors %+v", report, want)
+		}
+
+		xml := `<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record><leader>00000nz  a2200000n  4500</leader><datafield tag="100" ind1="1" ind2=" "><subfield code="a">Herbert, Frank</subfield></datafield></record>
+  <record><leader>00000nam a2200000 i 4500</leader><datafield tag="020" ind1=" " ind2=" "><subfield code="a">9780441172710</subfield></datafield></record>
+</collection>`
+		report = s.runImport(admin, "books.xml", xml, nil)
+		want = []Model.ImportErrorResponse{
+			{Row: 1, Message: "is not a bibliographic record"},
+			{Row: 2, Field: "author", Message: "is required"},
+			{Row: 2, Field: "isbn", Message: "must be a valid ISBN-10 or ISBN-13"},
+			{Row: 2, Field: "title", Message: "is required"},
+		}
+		if report.Failed != 2 || fmt.Sprint(report.Errors) != fmt.Sprint(want) {
+			t.Errorf("report = %+v, want errors %+v", report, want)
+		}
+	})
+
+	t.Run("rejects unusable files and formats", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+
+		expectValidationError(t, s.startImport(admin, "books.mrc", "title,author\nDune,Frank Herbert\n", nil), "file")
+		expectValidationError(t, s.startImport(admin, "books.xml", "<collection><record>", nil), "file")
+		expectValidationError(t, s.startImport(admin, "books.xml", `<collection xmlns="http://www.loc.gov/MARC21/slim"/>`, nil), "file")
+		expectValidationError(t, s.startImport(admin, "books.xml", readSample(t, "records.xml"), map[string]string{"mapping": `{"245": "title"}`}), "mapping")
+		expectValidationError(t, s.do(http.MethodGet, "/view/books/marc?format=pdf", nil, admin), "format")
+		expectError(t, s.do(http.MethodGet, "/view/books/marc?subject=99", nil, admin), http.StatusNotFound, "Subject not found")
+	})
+}
@@ -0,0 +1,93 @@
+package Controller_test
+
+import (
+	"awesomeProject/Model"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestResponsesNeverContainPasswords(t *testing.T) {
+	t.Setenv("DEBUG_ENDPOINTS", "true")
+	s := newTestServer(t)
+	aBook().create(s)
+	admin := s.loginAdmin()
+
+	// serve fails the test on any response exposing a password field; this
+	// walks every route so new endpoints are covered as they are added.
+	requests := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{http.MethodPost, "/register", map[string]string{"userName": "ivy", "email": "ivy@example.com", "password": "poison1vy"}},
+		{http.MethodPost, "/register", map[string]string{"userName": "ivy", "email": "ivy@example.com", "password": "x"}},
+		{http.MethodPost, "/login", map[string]string{"email": "ivy@example.com", "password": "poison1vy"}},
+		{http.MethodPost, "/login", map[string]string{"email": "ivy@example.com", "password": "wrong"}},
+		{http.MethodPost, "/login/2fa", map[string]string{"challengeToken": "forged", "code": "000000"}},
+		{http.MethodGet, "/verify-email?token=forged", nil},
+		{http.MethodPost, "/verify-email/resend", map[string]string{"email": "ivy@example.com"}},
+		{http.MethodPost, "/password/forgot", map[string]string{"email": "ivy@example.com"}},
+		{http.MethodPost, "/password/reset", map[string]string{"token": "forged", "newPassword": "poison2vy"}},
+		{http.MethodGet, "/me", nil},
+		{http.MethodPatch, "/me", map[string]string{"userName": "root"}},
+		{http.MethodPost, "/me/email", map[string]string{"newEmail": "root@example.com", "password": "wrong"}},
+		{http.MethodPost, "/me/2fa", map[string]string{"password": "wrong"}},
+		{http.MethodPost, "/me/2fa/confirm", map[string]string{"code": "000000"}},
+		{http.MethodPost, "/me/2fa/recovery-codes", map[string]string{"password": "wrong"}},
+		{http.MethodDelete, "/me/2fa", map[string]string{"password": "wrong", "code": "000000"}},
+		{http.MethodGet, "/me/sessions", nil},
+		{http.MethodDelete, "/me/sessions/99", nil},
+		{http.MethodGet, "/view/books", nil},
+		{http.MethodGet, "/view/books/marc", nil},
+		{http.MethodGet, "/view/description/1", nil},
+		{http.MethodGet, "/view/borrow/1", nil},
+		{http.MethodGet, "/view/return/1", nil},
+		{http.MethodPost, "/admin/api-keys", map[string]interface{}{"name": "Kiosk", "scopes": []string{"catalog:read"}}},
+		{http.MethodGet, "/admin/api-keys", nil},
+		{http.MethodDelete, "/admin/api-keys/1", nil},
+		{http.MethodGet, "/admin/books/1/copies", nil},
+		{http.MethodPost, "/admin/books/1/copies", map[string]string{"barcode": "LIB-1"}},
+		{http.MethodPatch, "/admin/copies/1", map[string]string{"status": "lost"}},
+		{http.MethodPut, "/admin/books/1/isbn", map[string]string{"isbn": "9780199535675"}},
+		{http.MethodPut, "/admin/books/1/cover", nil},
+		{http.MethodDelete, "/admin/books/1/cover", nil},
+		{http.MethodGet, "/covers/1/small", nil},
+		{http.MethodPost, "/admin/imports", nil},
+		{http.MethodGet, "/admin/imports", nil},
+		{http.MethodGet, "/admin/imports/1", nil},
+		{http.MethodPost, "/admin/authors", map[string]interface{}{"name": "Ivy", "variants": []string{"I. V. Y."}}},
+		{http.MethodPut, "/admin/authors/1", map[string]interface{}{"name": "Ivy"}},
+		{http.MethodPut, "/admin/books/1/authors", map[string]interface{}{"credits": []map[string]interface{}{{"authorId": 1, "role": "author"}}}},
+		{http.MethodGet, "/view/authors", nil},
+		{http.MethodGet, "/view/authors/1", nil},
+		{http.MethodPost, "/admin/subjects", map[string]string{"name": "Fantasy"}},
+		{http.MethodPut, "/admin/subjects/1", map[string]string{"name": "Fantasy"}},
+		{http.MethodPut, "/admin/books/1/subjects", map[string]interface{}{"subjectIds": []int{1}}},
+		{http.MethodPut, "/admin/books/1/tags", map[string]interface{}{"tags": []string{"epic"}}},
+		{http.MethodGet, "/view/subjects", nil},
+		{http.MethodGet, "/view/subjects/1", nil},
+		{http.MethodGet, "/view/subjects/1/books", nil},
+		{http.MethodGet, "/view/tags", nil},
+		{http.MethodGet, "/admin/debug/clock", nil},
+		{http.MethodPut, "/admin/debug/clock", map[string]string{"offset": "1h"}},
+		{http.MethodDelete, "/admin/debug/clock", nil},
+	}
+	for _, r := range requests {
+		s.do(r.method, r.path, r.body, admin)
+	}
+
+	var user Model.UserModel
+	s.db.Where("email = ?", "ivy@example.com").First(&user)
+	if user.Password == "poison1vy" {
+		t.Error("password stored in plaintext")
+	}
+}
+
+func TestStorageModelsRefuseSerialization(t *testing.T) {
+	for _, model := range []interface{}{Model.UserModel{}, Model.BookModel{}, Model.BookCopyModel{}, Model.AuthorModel{}, Model.LoanModel{}} {
+		if _, err := json.Marshal(model); !errors.Is(err, Model.ErrStorageModelSerialized) {
+			t.Errorf("json.Marshal(%T) error = %v, want ErrStorageModelSerialized", model, err)
+		}
+	}
+}
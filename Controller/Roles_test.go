@@ -0,0 +1,76 @@
+package Controller_test
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"net/http"
+	"testing"
+)
+
+func TestRoles(t *testing.T) {
+	t.Run("make every registration a member", func(t *testing.T) {
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodPost, "/register", map[string]interface{}{
+			"userName": "mallory",
+			"email":    "mallory@example.com",
+			"password": "hunter22",
+			"role":     Model.RoleAdmin,
+		}, "")
+
+		expectStatus(t, rec, http.StatusOK)
+		var user Model.UserModel
+		if err := s.db.Where("email = ?", "mallory@example.com").First(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		if user.Role != Model.RoleMember {
+			t.Errorf("role = %q, want %q", user.Role, Model.RoleMember)
+		}
+	})
+
+	t.Run("keep members out of admin routes", func(t *testing.T) {
+		t.Setenv("DEBUG_ENDPOINTS", "true")
+		s := newTestServer(t)
+
+		rec := s.do(http.MethodGet, "/admin/debug/clock", nil, s.login())
+
+		expectError(t, rec, http.StatusForbidden, "Insufficient permissions")
+	})
+
+	t.Run("let administrators into admin routes", func(t *testing.T) {
+		t.Setenv("DEBUG_ENDPOINTS", "true")
+		s := newTestServer(t)
+
+		expectStatus(t, s.do(http.MethodGet, "/admin/debug/clock", nil, s.loginAdmin()), http.StatusOK)
+	})
+}
+
+func TestSeededAdmin(t *testing.T) {
+	t.Run("is created from the environment", func(t *testing.T) {
+		t.Setenv("ADMIN_EMAIL", "root@example.com")
+		t.Setenv("ADMIN_PASSWORD", "correct horse")
+
+		db := Config.InitializeDatabase(Config.NewFakeClock(testStart))
+
+		var admin Model.UserModel
+		if err := db.Where("email = ?", "root@example.com").First(&admin).Error; err != nil {
+			t.Fatalf("no admin seeded: %v", err)
+		}
+		if admin.Role != Model.RoleAdmin || admin.EmailVerifiedAt == nil || !admin.EmailVerifiedAt.Equal(testStart) {
+			t.Errorf("admin = %+v, want the admin role, verified at %v", admin, testStart)
+		}
+	})
+
+	t.Run("is skipped without a password", func(t *testing.T) {
+		t.Setenv("ADMIN_EMAIL", "root@example.com")
+		t.Setenv("ADMIN_PASSWORD", "")
+
+		db := Config.InitializeDatabase(Config.NewFakeClock(testStart))
+
+		var count int64
+		db.Model(&Model.UserModel{}).Count(&count)
+		if count != 0 {
+			t.Errorf("%d users seeded, want none", count)
+		}
+	})
+}
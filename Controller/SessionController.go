@@ -0,0 +1,81 @@
+package Controller
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Service"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// @Summary List own sessions
+// @Description List the logins whose tokens are still honoured, newest first. The session of the calling token is marked current.
+// @Tags account
+// @Produce json
+// @Success 200 {array} Model.SessionResponse "Active sessions"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Failed to list sessions"
+// @Router /me/sessions [get]
+func listSessionsHandler(sessions Service.SessionService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		list, err := sessions.List(c.Request().Context(), c.Get(Config.UserIDKey).(int))
+		if err != nil {
+			return accountError(err, "Failed to list sessions")
+		}
+
+		current, _ := c.Get(Config.TokenIDKey).(string)
+		response := make([]Model.SessionResponse, 0, len(list))
+		for _, session := range list {
+			response = append(response, Model.NewSessionResponse(session, current))
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// @Summary Sign out a session
+// @Description Revoke one of the signed-in user's sessions; its token stops working immediately. Revoking the current session signs this token out.
+// @Tags account
+// @Param id path string true "Session ID"
+// @Success 204 "Session revoked"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Session not found"
+// @Failure 422 {object} map[string]interface{} "Invalid session ID"
+// @Failure 500 {object} map[string]string "Failed to revoke session"
+// @Router /me/sessions/{id} [delete]
+func revokeSessionHandler(sessions Service.SessionService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+
+		err = sessions.Revoke(c.Request().Context(), c.Get(Config.UserIDKey).(int), id)
+		if errors.Is(err, Service.ErrSessionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke session").SetInternal(err)
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// @Summary Sign out all other sessions
+// @Description Revoke every session of the signed-in user except the one making the request.
+// @Tags account
+// @Success 204 "Other sessions revoked"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Failed to revoke sessions"
+// @Router /me/sessions [delete]
+func revokeOtherSessionsHandler(sessions Service.SessionService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		current, _ := c.Get(Config.TokenIDKey).(string)
+		if err := sessions.RevokeOthers(c.Request().Context(), c.Get(Config.UserIDKey).(int), current); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions").SetInternal(err)
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
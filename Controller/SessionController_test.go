@@ -0,0 +1,139 @@
+package Controller_test
+
+import (
+	"awesomeProject/Model"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// loginFrom logs in like loginAs, from a client with userAgent.
+func (s *testServer) loginFrom(email, password, userAgent string) string {
+	s.t.Helper()
+
+	body, err := json.Marshal(map[string]string{"email": email, "password": password})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("User-Agent", userAgent)
+	rec := s.serve(req)
+	expectStatus(s.t, rec, http.StatusOK)
+	var login Model.LoginResponse
+	decode(s.t, rec, &login)
+	return login.Token
+}
+
+func (s *testServer) sessions(token string) []Model.SessionResponse {
+	s.t.Helper()
+
+	rec := s.do(http.MethodGet, "/me/sessions", nil, token)
+	expectStatus(s.t, rec, http.StatusOK)
+	var sessions []Model.SessionResponse
+	decode(s.t, rec, &sessions)
+	return sessions
+}
+
+func TestSessions(t *testing.T) {
+	t.Run("lists logins newest first", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		s.loginFrom(user.Email, user.Password, "Laptop")
+		s.clock.Advance(time.Minute)
+		phone := s.loginFrom(user.Email, user.Password, "Phone")
+		s.clock.Advance(5 * time.Minute)
+
+		sessions := s.sessions(phone)
+
+		if len(sessions) != 2 || sessions[0].UserAgent != "Phone" || sessions[1].UserAgent != "Laptop" {
+			t.Fatalf("sessions = %+v, want Phone then Laptop", sessions)
+		}
+		if !sessions[0].Current || sessions[1].Current {
+			t.Errorf("current = %v, %v, want only the phone", sessions[0].Current, sessions[1].Current)
+		}
+		if sessions[0].IPAddress == "" || !sessions[0].LastSeenAt.Equal(s.clock.Now()) {
+			t.Errorf("phone session = %+v, want an address and last seen now", sessions[0])
+		}
+	})
+
+	t.Run("only lists own sessions", func(t *testing.T) {
+		s := newTestServer(t)
+		other := aUser().create(s)
+		s.loginAs(other.Email, other.Password)
+
+		if sessions := s.sessions(s.login()); len(sessions) != 1 {
+			t.Errorf("sessions = %+v, want just the caller's", sessions)
+		}
+	})
+
+	t.Run("revoking a session signs its token out", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		laptop := s.loginFrom(user.Email, user.Password, "Laptop")
+		phone := s.loginFrom(user.Email, user.Password, "Phone")
+		target := s.sessions(phone)[1]
+
+		rec := s.do(http.MethodDelete, fmt.Sprintf("/me/sessions/%d", target.ID), nil, phone)
+
+		expectStatus(t, rec, http.StatusNoContent)
+		expectError(t, s.do(http.MethodGet, "/me", nil, laptop), http.StatusUnauthorized, "Token has been revoked")
+		expectStatus(t, s.do(http.MethodGet, "/me", nil, phone), http.StatusOK)
+		if sessions := s.sessions(phone); len(sessions) != 1 || sessions[0].UserAgent != "Phone" {
+			t.Errorf("sessions = %+v, want only the phone", sessions)
+		}
+	})
+
+	t.Run("cannot revoke another user's session", func(t *testing.T) {
+		s := newTestServer(t)
+		victim := aUser().create(s)
+		victimToken := s.loginAs(victim.Email, victim.Password)
+		session := s.sessions(victimToken)[0]
+
+		rec := s.do(http.MethodDelete, fmt.Sprintf("/me/sessions/%d", session.ID), nil, s.login())
+
+		expectError(t, rec, http.StatusNotFound, "Session not found")
+		expectStatus(t, s.do(http.MethodGet, "/me", nil, victimToken), http.StatusOK)
+	})
+
+	t.Run("signs out everywhere else", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		first := s.loginAs(user.Email, user.Password)
+		second := s.loginAs(user.Email, user.Password)
+		current := s.loginAs(user.Email, user.Password)
+
+		expectStatus(t, s.do(http.MethodDelete, "/me/sessions", nil, current), http.StatusNoContent)
+
+		for _, token := range []string{first, second} {
+			expectError(t, s.do(http.MethodGet, "/me", nil, token), http.StatusUnauthorized, "Token has been revoked")
+		}
+		if sessions := s.sessions(current); len(sessions) != 1 || !sessions[0].Current {
+			t.Errorf("sessions = %+v, want only the current one", sessions)
+		}
+	})
+
+	t.Run("drops sessions ended by a password change", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		s.loginAs(user.Email, user.Password)
+		token := s.loginAs(user.Email, user.Password)
+
+		rec := s.do(http.MethodPost, "/me/password", map[string]string{
+			"currentPassword": user.Password,
+			"newPassword":     "brand-new-1",
+		}, token)
+		expectStatus(t, rec, http.StatusOK)
+		var body Model.TokenResponse
+		decode(t, rec, &body)
+
+		if sessions := s.sessions(body.Token); len(sessions) != 1 || !sessions[0].Current {
+			t.Errorf("sessions = %+v, want only the new one", sessions)
+		}
+	})
+}
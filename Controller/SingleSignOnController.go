@@ -0,0 +1,96 @@
+package Controller
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Service"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+// oidcFlowCookie parks the signed state, nonce and PKCE verifier of a login
+// in the browser while it visits the identity provider.
+const oidcFlowCookie = "oidc_flow"
+
+// @Summary Log in through the identity provider
+// @Description Start an OpenID Connect authorization code login with PKCE. Redirects to the identity provider, which returns to /login/oidc/callback.
+// @Tags users
+// @Success 302 {string} string "Redirect to the identity provider"
+// @Failure 502 {object} map[string]string "Identity provider unavailable"
+// @Router /login/oidc [get]
+func beginSingleSignOnHandler(sso Service.SingleSignOnService, clock Config.Clock) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		target, flow, err := sso.Begin(c.Request().Context())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadGateway, "Identity provider unavailable").SetInternal(err)
+		}
+
+		token, err := Config.GenerateOIDCFlowToken(clock, flow)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start login").SetInternal(err)
+		}
+		c.SetCookie(&http.Cookie{
+			Name:     oidcFlowCookie,
+			Value:    token,
+			Path:     "/login/oidc",
+			MaxAge:   int(Config.OIDCFlowTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   strings.HasPrefix(Config.AppBaseURL(), "https://"),
+			// Lax lets the cookie ride along the top-level redirect back
+			// from the provider.
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		return c.Redirect(http.StatusFound, target)
+	}
+}
+
+// @Summary Complete an identity provider login
+// @Description Redirect target of the identity provider. Links the identity to the account with the same verified email, or creates one, and returns the usual JWT.
+// @Description Accounts with two-factor authentication receive a challenge token instead, to redeem at /login/2fa.
+// @Tags users
+// @Produce json
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from /login/oidc"
+// @Success 200 {object} Model.LoginResponse "JWT token or two-factor challenge"
+// @Failure 400 {object} map[string]string "Login flow invalid or expired"
+// @Failure 401 {object} map[string]string "Login refused by the identity provider"
+// @Failure 403 {object} map[string]string "Email address not verified by the identity provider"
+// @Failure 502 {object} map[string]string "Identity provider unavailable"
+// @Failure 500 {object} map[string]string "Failed to complete login"
+// @Router /login/oidc/callback [get]
+func completeSingleSignOnHandler(sso Service.SingleSignOnService, sessions Service.SessionService, clock Config.Clock) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// The flow is single-use whatever the outcome.
+		c.SetCookie(&http.Cookie{Name: oidcFlowCookie, Path: "/login/oidc", MaxAge: -1, HttpOnly: true})
+
+		if reason := c.QueryParam("error"); reason != "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Login refused by the identity provider: "+reason)
+		}
+
+		cookie, err := c.Cookie(oidcFlowCookie)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Login flow invalid or expired; start again at /login/oidc")
+		}
+		flow, err := Config.ParseOIDCFlowToken(clock, cookie.Value)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Login flow invalid or expired; start again at /login/oidc")
+		}
+
+		user, err := sso.Complete(c.Request().Context(), flow, c.QueryParam("state"), c.QueryParam("code"))
+		switch {
+		case errors.Is(err, Service.ErrInvalidLoginFlow):
+			return echo.NewHTTPError(http.StatusBadRequest, "Login flow invalid or expired; start again at /login/oidc").SetInternal(err)
+		case errors.Is(err, Service.ErrExternalEmailNotVerified):
+			return echo.NewHTTPError(http.StatusForbidden, "The identity provider has not verified your email address")
+		case errors.Is(err, Service.ErrProviderUnavailable):
+			return echo.NewHTTPError(http.StatusBadGateway, "Identity provider unavailable").SetInternal(err)
+		case err != nil:
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to complete login").SetInternal(err)
+		}
+
+		// The provider vouches for the password, not for the second factor.
+		return respondWithLogin(c, user, sessions, clock)
+	}
+}
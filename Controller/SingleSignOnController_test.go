@@ -0,0 +1,447 @@
+package Controller_test
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"awesomeProject/Service"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+var providerKey = sync.OnceValue(func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+})
+
+// mockProvider is an in-process OpenID Connect provider. Every authorization
+// request logs in as user without asking.
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	now    func() time.Time
+
+	user struct {
+		subject, email, name string
+		emailVerified        bool
+		groups               []string
+	}
+	// nonce, when set, replaces the nonce of the request in ID tokens.
+	nonce string
+	// signingKey, when set, signs ID tokens instead of the key the JWKS
+	// publishes.
+	signingKey *rsa.PrivateKey
+	// kid, when set, names the signing key in ID tokens instead of the one
+	// the JWKS publishes.
+	kid string
+
+	mu       sync.Mutex
+	requests map[string]url.Values
+	// keyFetches counts requests for the JWKS.
+	keyFetches int
+}
+
+const (
+	mockClientID     = "library"
+	mockClientSecret = "library-secret"
+)
+
+func newMockProvider(t *testing.T) *mockProvider {
+	p := &mockProvider{t: t, now: time.Now, requests: map[string]url.Values{}}
+	p.user.subject = "u-1001"
+	p.user.email = "grace@uni.example"
+	p.user.name = "Grace Hopper"
+	p.user.emailVerified = true
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.keyFetches++
+		p.mu.Unlock()
+		public := providerKey().PublicKey
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}}})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *mockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != mockClientID || query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code, err := Config.NewSecretToken()
+	if err != nil {
+		p.t.Error(err)
+	}
+	p.mu.Lock()
+	p.requests[code] = query
+	p.mu.Unlock()
+
+	target := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	request, ok := p.requests[r.FormValue("code")]
+	delete(p.requests, r.FormValue("code"))
+	p.mu.Unlock()
+
+	if !ok || r.FormValue("client_id") != mockClientID || r.FormValue("client_secret") != mockClientSecret ||
+		r.FormValue("redirect_uri") != request.Get("redirect_uri") ||
+		(Config.OIDCFlow{Verifier: r.FormValue("code_verifier")}).CodeChallenge() != request.Get("code_challenge") {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	nonce := request.Get("nonce")
+	if p.nonce != "" {
+		nonce = p.nonce
+	}
+	claims := jwt.MapClaims{
+		"iss":            p.server.URL,
+		"sub":            p.user.subject,
+		"aud":            mockClientID,
+		"iat":            p.now().Unix(),
+		"exp":            p.now().Add(5 * time.Minute).Unix(),
+		"nonce":          nonce,
+		"email":          p.user.email,
+		"email_verified": p.user.emailVerified,
+		"name":           p.user.name,
+		"groups":         p.user.groups,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	if p.kid != "" {
+		token.Header["kid"] = p.kid
+	}
+	key := providerKey()
+	if p.signingKey != nil {
+		key = p.signingKey
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		p.t.Error(err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": signed})
+}
+
+func newSingleSignOnServer(t *testing.T) (*testServer, *mockProvider) {
+	provider := newMockProvider(t)
+	s := newTestServer(t, func(services *Service.Services, store Repository.Store) {
+		settings := Config.OIDCSettings{
+			Issuer:       provider.server.URL,
+			ClientID:     mockClientID,
+			ClientSecret: mockClientSecret,
+			RedirectURL:  Config.AppURL("/login/oidc/callback", nil),
+			GroupsClaim:  "groups",
+			RoleMapping:  map[string]string{"library-staff": Model.RoleAdmin},
+		}
+		client := provider.server.Client()
+		services.SingleSignOn = Service.NewSingleSignOnService(store, services.Clock, Config.NewOIDCProvider(settings, client))
+	})
+	provider.now = s.clock.Now
+	return s, provider
+}
+
+// singleSignOn walks the browser through /login/oidc, the provider and back,
+// returning the callback's response.
+func (s *testServer) singleSignOn(provider *mockProvider) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	rec := s.do(http.MethodGet, "/login/oidc", nil, "")
+	expectStatus(s.t, rec, http.StatusFound)
+	cookies := rec.Result().Cookies()
+
+	client := provider.server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(rec.Header().Get(echo.HeaderLocation))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get(echo.HeaderLocation))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		s.t.Fatalf("provider: status %d, location %q", resp.StatusCode, resp.Header.Get(echo.HeaderLocation))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	return s.serve(req)
+}
+
+func TestSingleSignOn(t *testing.T) {
+	t.Run("provisions a verified member on first login", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+
+		rec := s.singleSignOn(provider)
+
+		expectStatus(t, rec, http.StatusOK)
+		var body Model.TokenResponse
+		decode(t, rec, &body)
+		rec = s.do(http.MethodGet, "/me", nil, body.Token)
+		expectStatus(t, rec, http.StatusOK)
+		var profile Model.ProfileResponse
+		decode(t, rec, &profile)
+		if profile.Email != "grace@uni.example" || profile.UserName != "Grace Hopper" || profile.Role != Model.RoleMember || !profile.EmailVerified {
+			t.Errorf("profile = %+v, want a verified member named after the identity", profile.UserResponse)
+		}
+	})
+
+	t.Run("links an existing account by email", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		user := aUser().withEmail("grace@uni.example").unverified().create(s)
+
+		first := s.singleSignOn(provider)
+		provider.user.email = "g.hopper@uni.example"
+		second := s.singleSignOn(provider)
+
+		for _, rec := range []*httptest.ResponseRecorder{first, second} {
+			expectStatus(t, rec, http.StatusOK)
+			var body Model.TokenResponse
+			decode(t, rec, &body)
+			var profile Model.ProfileResponse
+			decode(t, s.do(http.MethodGet, "/me", nil, body.Token), &profile)
+			if profile.UserId != user.UserId || !profile.EmailVerified {
+				t.Errorf("profile = %+v, want user %d, verified", profile.UserResponse, user.UserId)
+			}
+		}
+		s.loginAs(user.Email, user.Password)
+	})
+
+	t.Run("asks accounts with two-factor authentication for a code", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		user := aUser().withEmail("grace@uni.example").asAdmin().create(s)
+		secret, _ := s.enableTwoFactor(user)
+
+		rec := s.singleSignOn(provider)
+
+		expectStatus(t, rec, http.StatusOK)
+		var body Model.LoginResponse
+		decode(t, rec, &body)
+		if !body.TwoFactorRequired || body.ChallengeToken == "" || body.Token != "" {
+			t.Fatalf("callback: want a challenge and no token, got %s", rec.Body)
+		}
+		expectStatus(t, s.completeLogin(body.ChallengeToken, s.totp(secret)), http.StatusOK)
+	})
+
+	t.Run("refuses addresses the provider has not verified", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		aUser().withEmail("grace@uni.example").create(s)
+		provider.user.emailVerified = false
+
+		expectError(t, s.singleSignOn(provider), http.StatusForbidden, "The identity provider has not verified your email address")
+	})
+
+	t.Run("maps provider groups to roles", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		provider.user.groups = []string{"physics", "library-staff"}
+		rec := s.singleSignOn(provider)
+		var staff Model.TokenResponse
+		decode(t, rec, &staff)
+		expectStatus(t, s.do(http.MethodPost, "/admin/users/1/unlock", nil, staff.Token), http.StatusOK)
+
+		provider.user.groups = []string{"physics"}
+		rec = s.singleSignOn(provider)
+		var member Model.TokenResponse
+		decode(t, rec, &member)
+
+		expectError(t, s.do(http.MethodGet, "/me", nil, staff.Token), http.StatusUnauthorized, "Token has been revoked")
+		expectStatus(t, s.do(http.MethodPost, "/admin/users/1/unlock", nil, member.Token), http.StatusForbidden)
+	})
+
+	t.Run("rejects a callback without the login cookie", func(t *testing.T) {
+		s, _ := newSingleSignOnServer(t)
+
+		rec := s.do(http.MethodGet, "/login/oidc/callback?code=abc&state=xyz", nil, "")
+
+		expectError(t, rec, http.StatusBadRequest, "Login flow invalid or expired; start again at /login/oidc")
+	})
+
+	t.Run("rejects an ID token with another nonce", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		provider.nonce = "replayed"
+
+		expectError(t, s.singleSignOn(provider), http.StatusBadRequest, "Login flow invalid or expired; start again at /login/oidc")
+	})
+
+	t.Run("rejects an ID token signed with an unpublished key", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		provider.signingKey = key
+
+		expectError(t, s.singleSignOn(provider), http.StatusBadRequest, "Login flow invalid or expired; start again at /login/oidc")
+		var count int64
+		s.db.Model(&Model.UserModel{}).Where("email = ?", "grace@uni.example").Count(&count)
+		if count != 0 {
+			t.Errorf("%d accounts provisioned from a forged token", count)
+		}
+	})
+
+	t.Run("asks for unknown signing keys at most once a minute", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		expectStatus(t, s.singleSignOn(provider), http.StatusOK)
+		fetches := func() int {
+			provider.mu.Lock()
+			defer provider.mu.Unlock()
+			return provider.keyFetches
+		}
+
+		s.clock.Advance(time.Minute)
+		for _, kid := range []string{"forged-1", "forged-2", "forged-1"} {
+			provider.kid = kid
+			expectError(t, s.singleSignOn(provider), http.StatusBadRequest, "Login flow invalid or expired; start again at /login/oidc")
+		}
+		if got := fetches(); got != 2 {
+			t.Errorf("key set fetched %d times, want once at first login and once for the first unknown key", got)
+		}
+
+		s.clock.Advance(time.Minute)
+		provider.kid = "forged-1"
+		s.singleSignOn(provider)
+		if got := fetches(); got != 2 {
+			t.Errorf("key set fetched %d times, want a key found missing not asked about again", got)
+		}
+		provider.kid = "forged-3"
+		s.singleSignOn(provider)
+		if got := fetches(); got != 3 {
+			t.Errorf("key set fetched %d times, want another fetch a minute later", got)
+		}
+	})
+
+	t.Run("rejects an expired ID token", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		provider.now = func() time.Time { return s.clock.Now().Add(-time.Hour) }
+
+		expectError(t, s.singleSignOn(provider), http.StatusBadRequest, "Login flow invalid or expired; start again at /login/oidc")
+	})
+
+	t.Run("reports an unreachable provider", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		provider.server.Close()
+
+		expectError(t, s.do(http.MethodGet, "/login/oidc", nil, ""), http.StatusBadGateway, "Identity provider unavailable")
+	})
+
+	t.Run("is off unless configured", func(t *testing.T) {
+		s := newTestServer(t)
+
+		expectStatus(t, s.do(http.MethodGet, "/login/oidc", nil, ""), http.StatusNotFound)
+	})
+}
+
+// provisionedLogin signs in through the provider as a new account without
+// a password and returns its token.
+func (s *testServer) provisionedLogin(provider *mockProvider) string {
+	s.t.Helper()
+	rec := s.singleSignOn(provider)
+	expectStatus(s.t, rec, http.StatusOK)
+	var body Model.TokenResponse
+	decode(s.t, rec, &body)
+	return body.Token
+}
+
+func TestPasswordlessAccounts(t *testing.T) {
+	t.Run("confirm changes with a recent login", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		token := s.provisionedLogin(provider)
+		s.clock.Advance(Service.ReauthenticationWindow)
+
+		expectStatus(t, s.do(http.MethodDelete, "/me", map[string]string{}, token), http.StatusNoContent)
+	})
+
+	t.Run("log in again once the login is not recent", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		token := s.provisionedLogin(provider)
+		s.clock.Advance(Service.ReauthenticationWindow + time.Minute)
+
+		rec := s.do(http.MethodPost, "/me/email", map[string]string{"newEmail": "grace@example.com"}, token)
+
+		expectError(t, rec, http.StatusUnauthorized, "Log in again, or send a two-factor code, to confirm this change")
+		rec = s.do(http.MethodPost, "/me/email", map[string]string{"newEmail": "grace@example.com"}, s.provisionedLogin(provider))
+		expectStatus(t, rec, http.StatusOK)
+	})
+
+	t.Run("confirm changes with a second-factor code", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		token := s.provisionedLogin(provider)
+		rec := s.do(http.MethodPost, "/me/2fa", map[string]string{}, token)
+		expectStatus(t, rec, http.StatusOK)
+		var enrollment Model.TwoFactorEnrollmentResponse
+		decode(t, rec, &enrollment)
+		expectStatus(t, s.do(http.MethodPost, "/me/2fa/confirm", map[string]string{"code": s.totp(enrollment.Secret)}, token), http.StatusOK)
+		s.clock.Advance(Service.ReauthenticationWindow + time.Minute)
+
+		expectStatus(t, s.do(http.MethodPost, "/me/2fa/recovery-codes", map[string]string{}, token), http.StatusUnauthorized)
+		expectValidationError(t, s.do(http.MethodPost, "/me/2fa/recovery-codes", map[string]string{"code": "000000"}, token), "code")
+		expectStatus(t, s.do(http.MethodPost, "/me/2fa/recovery-codes", map[string]string{"code": s.totp(enrollment.Secret)}, token), http.StatusOK)
+		s.clock.Advance(Config.TOTPPeriod)
+		expectStatus(t, s.do(http.MethodDelete, "/me/2fa", map[string]string{"code": s.totp(enrollment.Secret)}, token), http.StatusNoContent)
+	})
+
+	t.Run("can set a first password", func(t *testing.T) {
+		s, provider := newSingleSignOnServer(t)
+		token := s.provisionedLogin(provider)
+
+		rec := s.do(http.MethodPost, "/me/password", map[string]string{"newPassword": "brand-new-1"}, token)
+
+		expectStatus(t, rec, http.StatusOK)
+		s.loginAs("grace@uni.example", "brand-new-1")
+		var body Model.TokenResponse
+		decode(t, rec, &body)
+		expectValidationError(t, s.do(http.MethodDelete, "/me", map[string]string{}, body.Token), "password")
+	})
+}
+
+func TestParseRoleMapping(t *testing.T) {
+	mapping, err := Config.ParseRoleMapping(" library-staff = admin, students=member ,")
+	if err != nil || len(mapping) != 2 || mapping["library-staff"] != Model.RoleAdmin || mapping["students"] != Model.RoleMember {
+		t.Errorf("ParseRoleMapping = %v, %v", mapping, err)
+	}
+
+	for _, value := range []string{"staff", "staff=owner", "=admin"} {
+		if _, err := Config.ParseRoleMapping(value); err == nil {
+			t.Errorf("ParseRoleMapping(%q) succeeded, want an error", value)
+		}
+	}
+}
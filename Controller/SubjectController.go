@@ -0,0 +1,283 @@
+package Controller
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Service"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// @Summary Browse subjects
+// @Description Retrieve the subject and genre taxonomy as a tree, each level by name
+// @Tags subjects
+// @Produce json
+// @Success 200 {array} Model.SubjectTreeResponse "Root subjects with everything below them"
+// @Failure 500 {object} map[string]string "Failed to retrieve subjects"
+// @Router /view/subjects [get]
+func viewSubjectTreeHandler(taxonomy Service.TaxonomyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		tree, err := taxonomy.Tree(c.Request().Context())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve subjects").SetInternal(err)
+		}
+		return c.JSON(http.StatusOK, newSubjectTreeResponses(tree))
+	}
+}
+
+// @Summary Get a subject
+// @Description Retrieve a subject with its full path, as in "Fantasy > High Fantasy"
+// @Tags subjects
+// @Produce json
+// @Param id path string true "Subject ID"
+// @Success 200 {object} Model.SubjectResponse "Subject"
+// @Failure 404 {object} map[string]string "Subject not found"
+// @Failure 422 {object} map[string]interface{} "Invalid subject ID"
+// @Failure 500 {object} map[string]string "Failed to find subject"
+// @Router /view/subjects/{id} [get]
+func viewSubjectHandler(taxonomy Service.TaxonomyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+
+		path, err := taxonomy.Path(c.Request().Context(), id)
+		if err != nil {
+			if errors.Is(err, Service.ErrSubjectNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "Subject not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to find subject").SetInternal(err)
+		}
+		return c.JSON(http.StatusOK, newSubjectResponse(path))
+	}
+}
+
+// @Summary List the books under a subject
+// @Description Retrieve the books filed under a subject or any subject below it
+// @Tags subjects
+// @Produce json
+// @Param id path string true "Subject ID"
+// @Success 200 {array} Model.BookResponse "Books under the subject"
+// @Failure 404 {object} map[string]string "Subject not found"
+// @Failure 422 {object} map[string]interface{} "Invalid subject ID"
+// @Failure 500 {object} map[string]string "Failed to retrieve books"
+// @Router /view/subjects/{id}/books [get]
+func viewSubjectBooksHandler(taxonomy Service.TaxonomyService, catalog Service.CatalogService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+
+		books, err := taxonomy.BooksUnder(c.Request().Context(), id)
+		if err != nil {
+			if errors.Is(err, Service.ErrSubjectNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "Subject not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve books").SetInternal(err)
+		}
+		return respondWithBooks(c, catalog, books)
+	}
+}
+
+// @Summary List tags
+// @Description Retrieve every tag in use, alphabetically, with the number of books carrying it
+// @Tags subjects
+// @Produce json
+// @Success 200 {array} Model.TagResponse "Tags"
+// @Failure 500 {object} map[string]string "Failed to retrieve tags"
+// @Router /view/tags [get]
+func viewTagsHandler(taxonomy Service.TaxonomyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		counts, err := taxonomy.Tags(c.Request().Context())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve tags").SetInternal(err)
+		}
+
+		response := make([]Model.TagResponse, 0, len(counts))
+		for _, count := range counts {
+			response = append(response, Model.TagResponse{Tag: count.Tag, Books: count.Books})
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// @Summary Create a subject
+// @Description Add a subject below parentId, or a new root subject without one. Names are unique among siblings, ignoring case.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param subject body Model.SubjectRequest true "Name and parent"
+// @Success 201 {object} Model.SubjectResponse "Created subject"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 409 {object} map[string]string "The parent already has a subject of that name"
+// @Failure 422 {object} map[string]interface{} "Validation failed or unknown parent"
+// @Failure 500 {object} map[string]string "Failed to save subject"
+// @Router /admin/subjects [post]
+func createSubjectHandler(taxonomy Service.TaxonomyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.SubjectRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		subject := Model.SubjectModel{Name: request.Name, ParentID: request.ParentID}
+		path, err := taxonomy.Create(c.Request().Context(), &subject)
+		if err != nil {
+			return subjectError(err)
+		}
+		return c.JSON(http.StatusCreated, newSubjectResponse(path))
+	}
+}
+
+// @Summary Update a subject
+// @Description Rename a subject and move it, with everything below it, under parentId or to the root
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Subject ID"
+// @Param subject body Model.SubjectRequest true "Name and parent"
+// @Success 200 {object} Model.SubjectResponse "Updated subject"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Subject not found"
+// @Failure 409 {object} map[string]string "The parent already has a subject of that name"
+// @Failure 422 {object} map[string]interface{} "Validation failed, unknown parent or a move below itself"
+// @Failure 500 {object} map[string]string "Failed to save subject"
+// @Router /admin/subjects/{id} [put]
+func updateSubjectHandler(taxonomy Service.TaxonomyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+		var request Model.SubjectRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		subject := Model.SubjectModel{ID: id, Name: request.Name, ParentID: request.ParentID}
+		path, err := taxonomy.Update(c.Request().Context(), &subject)
+		if err != nil {
+			return subjectError(err)
+		}
+		return c.JSON(http.StatusOK, newSubjectResponse(path))
+	}
+}
+
+// @Summary Set the subjects of a book
+// @Description Replace the subjects a book is filed under
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Book ID"
+// @Param subjects body Model.SetBookSubjectsRequest true "Subject IDs"
+// @Success 200 {array} Model.SubjectResponse "Subjects of the book"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Book not found"
+// @Failure 422 {object} map[string]interface{} "Validation failed or unknown subject"
+// @Failure 500 {object} map[string]string "Failed to save subjects"
+// @Router /admin/books/{id}/subjects [put]
+func setBookSubjectsHandler(taxonomy Service.TaxonomyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bookID, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+		var request Model.SetBookSubjectsRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		paths, err := taxonomy.SetBookSubjects(c.Request().Context(), bookID, request.SubjectIDs)
+		if err != nil {
+			switch {
+			case errors.Is(err, Service.ErrBookNotFound):
+				return echo.NewHTTPError(http.StatusNotFound, "Book not found")
+			case errors.Is(err, Service.ErrUnknownSubject):
+				return Config.NewValidationError("subjectIds", "refers to an unknown subject")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save subjects").SetInternal(err)
+		}
+		return c.JSON(http.StatusOK, newSubjectResponses(paths))
+	}
+}
+
+// @Summary Set the tags of a book
+// @Description Replace the tags of a book. Tags are lower-cased with their spacing collapsed, so "Space Opera" and "space opera" are one tag.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Book ID"
+// @Param tags body Model.SetBookTagsRequest true "Tags"
+// @Success 200 {array} string "Tags of the book"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Book not found"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 500 {object} map[string]string "Failed to save tags"
+// @Router /admin/books/{id}/tags [put]
+func setBookTagsHandler(taxonomy Service.TaxonomyService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bookID, err := intParam(c, "id")
+		if err != nil {
+			return err
+		}
+		var request Model.SetBookTagsRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		tags, err := taxonomy.SetBookTags(c.Request().Context(), bookID, request.Tags)
+		if err != nil {
+			if errors.Is(err, Service.ErrBookNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "Book not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save tags").SetInternal(err)
+		}
+		return c.JSON(http.StatusOK, tags)
+	}
+}
+
+func subjectError(err error) error {
+	switch {
+	case errors.Is(err, Service.ErrSubjectNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Subject not found")
+	case errors.Is(err, Service.ErrSubjectExists):
+		return echo.NewHTTPError(http.StatusConflict, "The parent already has a subject of that name")
+	case errors.Is(err, Service.ErrUnknownSubject):
+		return Config.NewValidationError("parentId", "refers to an unknown subject")
+	case errors.Is(err, Service.ErrSubjectCycle):
+		return Config.NewValidationError("parentId", "must not be the subject or one below it")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save subject").SetInternal(err)
+}
+
+func newSubjectResponse(path Service.SubjectPath) Model.SubjectResponse {
+	subject := path.Subject()
+	return Model.SubjectResponse{ID: subject.ID, Name: subject.Name, ParentID: subject.ParentID, Path: path.String()}
+}
+
+func newSubjectResponses(paths []Service.SubjectPath) []Model.SubjectResponse {
+	response := make([]Model.SubjectResponse, 0, len(paths))
+	for _, path := range paths {
+		response = append(response, newSubjectResponse(path))
+	}
+	return response
+}
+
+func newSubjectTreeResponses(nodes []Service.SubjectNode) []Model.SubjectTreeResponse {
+	response := make([]Model.SubjectTreeResponse, 0, len(nodes))
+	for _, node := range nodes {
+		response = append(response, Model.SubjectTreeResponse{
+			ID:       node.Subject.ID,
+			Name:     node.Subject.Name,
+			Children: newSubjectTreeResponses(node.Children),
+		})
+	}
+	return response
+}
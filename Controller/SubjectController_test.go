@@ -0,0 +1,163 @@
+package Controller_test
+
+import (
+	"awesomeProject/Model"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func (s *testServer) createSubject(admin, name string, parentID *int) Model.SubjectResponse {
+	s.t.Helper()
+
+	rec := s.do(http.MethodPost, "/admin/subjects", map[string]interface{}{"name": name, "parentId": parentID}, admin)
+	expectStatus(s.t, rec, http.StatusCreated)
+	var subject Model.SubjectResponse
+	decode(s.t, rec, &subject)
+	return subject
+}
+
+func bookTitles(books []Model.BookResponse) []string {
+	titles := make([]string, 0, len(books))
+	for _, book := range books {
+		titles = append(titles, book.Title)
+	}
+	return titles
+}
+
+func TestSubjects(t *testing.T) {
+	t.Run("builds a tree with paths", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+		fantasy := s.createSubject(admin, "Fantasy", nil)
+		high := s.createSubject(admin, "High Fantasy", &fantasy.ID)
+		s.createSubject(admin, "Urban Fantasy", &fantasy.ID)
+		s.createSubject(admin, "Biography", nil)
+
+		if high.Path != "Fantasy > High Fantasy" {
+			t.Errorf("path = %q, want %q", high.Path, "Fantasy > High Fantasy")
+		}
+
+		var tree []Model.SubjectTreeResponse
+		decode(t, s.do(http.MethodGet, "/view/subjects", nil, s.login()), &tree)
+		if len(tree) != 2 || tree[0].Name != "Biography" || tree[1].Name != "Fantasy" {
+			t.Fatalf("roots = %+v, want Biography and Fantasy", tree)
+		}
+		if children := tree[1].Children; len(children) != 2 || children[0].Name != "High Fantasy" || children[1].Name != "Urban Fantasy" {
+			t.Errorf("children of Fantasy = %+v", children)
+		}
+	})
+
+	t.Run("rejects duplicate siblings and cycles", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+		fantasy := s.createSubject(admin, "Fantasy", nil)
+		high := s.createSubject(admin, "High Fantasy", &fantasy.ID)
+
+		rec := s.do(http.MethodPost, "/admin/subjects", map[string]interface{}{"name": "high  fantasy", "parentId": fantasy.ID}, admin)
+		expectError(t, rec, http.StatusConflict, "The parent already has a subject of that name")
+
+		rec = s.do(http.MethodPut, fmt.Sprintf("/admin/subjects/%d", fantasy.ID), map[string]interface{}{"name": "Fantasy", "parentId": high.ID}, admin)
+		expectValidationError(t, rec, "parentId")
+
+		rec = s.do(http.MethodPost, "/admin/subjects", map[string]interface{}{"name": "Epic", "parentId": 99}, admin)
+		expectValidationError(t, rec, "parentId")
+
+		rec = s.do(http.MethodPut, "/admin/subjects/99", map[string]interface{}{"name": "Epic"}, admin)
+		expectError(t, rec, http.StatusNotFound, "Subject not found")
+	})
+
+	t.Run("moves a subject with its children", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+		fiction := s.createSubject(admin, "Fiction", nil)
+		fantasy := s.createSubject(admin, "Fantasy", nil)
+		high := s.createSubject(admin, "High Fantasy", &fantasy.ID)
+
+		rec := s.do(http.MethodPut, fmt.Sprintf("/admin/subjects/%d", fantasy.ID), map[string]interface{}{"name": "Fantasy", "parentId": fiction.ID}, admin)
+		expectStatus(t, rec, http.StatusOK)
+
+		var subject Model.SubjectResponse
+		decode(t, s.do(http.MethodGet, fmt.Sprintf("/view/subjects/%d", high.ID), nil, s.login()), &subject)
+		if subject.Path != "Fiction > Fantasy > High Fantasy" {
+			t.Errorf("path = %q, want %q", subject.Path, "Fiction > Fantasy > High Fantasy")
+		}
+	})
+
+	t.Run("lists books under a subject and its descendants", func(t *testing.T) {
+		s := newTestServer(t)
+		admin := s.loginAdmin()
+		fantasy := s.createSubject(admin, "Fantasy", nil)
+		high := s.createSubject(admin, "High Fantasy", &fantasy.ID)
+		biography := s.createSubject(admin, "Biography", nil)
+		rings := aBook().withTitle("The Lord of the Rings").create(s)
+		earthsea := aBook().withTitle("A Wizard of Earthsea").create(s)
+		life := aBook().withTitle("Tolkien: A Biography").create(s)
+
+		for book, subjects := range map[int][]int{rings.ID: {high.ID}, earthsea.ID: {fantasy.ID}, life.ID: {biography.ID}} {
+			rec := s.do(http.MethodPut, fmt.Sprintf("/admin/books/%d/subjects", book), map[string]interface{}{"subjectIds": subjects}, admin)
+			expectStatus(t, rec, http.StatusOK)
+		}
+
+		token := s.login()
+		var books []Model.BookResponse
+		decode(t, s.do(http.MethodGet, fmt.Sprintf("/view/subjects/%d/books", fantasy.ID), nil, token), &books)
+		if got, want := bookTitles(books), []string{"The Lord of the Rings", "A Wizard of Earthsea"}; !slices.Equal(got, want) {
+			t.Errorf("books under Fantasy = %v, want %v", got, want)
+		}
+
+		decode(t, s.do(http.MethodGet, fmt.Sprintf("/view/books?subject=%d", high.ID), nil, token), &books)
+		if got, want := bookTitles(books), []string{"The Lord of the Rings"}; !slices.Equal(got, want) {
+			t.Errorf("books filtered by High Fantasy = %v, want %v", got, want)
+		}
+
+		var detail Model.BookDetailResponse
+		decode(t, s.do(http.MethodGet, "/view/description/"+rings.Slug, nil, token), &detail)
+		if len(detail.Subjects) != 1 || detail.Subjects[0].Path != "Fantasy > High Fantasy" {
+			t.Errorf("subjects of the book = %+v", detail.Subjects)
+		}
+
+		expectError(t, s.do(http.MethodGet, "/view/books?subject=99", nil, token), http.StatusNotFound, "Subject not found")
+		expectValidationError(t, s.do(http.MethodGet, "/view/books?subject=fantasy", nil, token), "subject")
+		expectValidationError(t, s.do(http.MethodPut, fmt.Sprintf("/admin/books/%d/subjects", rings.ID), map[string]interface{}{"subjectIds": []int{99}}, admin), "subjectIds")
+	})
+}
+
+func TestTags(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin()
+	dune := aBook().withTitle("Dune").create(s)
+	hyperion := aBook().withTitle("Hyperion").create(s)
+
+	rec := s.do(http.MethodPut, fmt.Sprintf("/admin/books/%d/tags", dune.ID), map[string]interface{}{"tags": []string{"Space Opera", "desert", " space  opera"}}, admin)
+	expectStatus(t, rec, http.StatusOK)
+	var tags []string
+	decode(t, rec, &tags)
+	if want := []string{"desert", "space opera"}; !slices.Equal(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+	s.do(http.MethodPut, fmt.Sprintf("/admin/books/%d/tags", hyperion.ID), map[string]interface{}{"tags": []string{"space opera"}}, admin)
+
+	token := s.login()
+	var counts []Model.TagResponse
+	decode(t, s.do(http.MethodGet, "/view/tags", nil, token), &counts)
+	if want := []Model.TagResponse{{Tag: "desert", Books: 1}, {Tag: "space opera", Books: 2}}; !slices.Equal(counts, want) {
+		t.Errorf("tags = %+v, want %+v", counts, want)
+	}
+
+	var books []Model.BookResponse
+	decode(t, s.do(http.MethodGet, "/view/books?tag=Desert", nil, token), &books)
+	if got, want := bookTitles(books), []string{"Dune"}; !slices.Equal(got, want) {
+		t.Errorf("books tagged desert = %v, want %v", got, want)
+	}
+
+	var detail Model.BookDetailResponse
+	decode(t, s.do(http.MethodGet, "/view/description/"+hyperion.Slug, nil, token), &detail)
+	if want := []string{"space opera"}; !slices.Equal(detail.Tags, want) {
+		t.Errorf("tags of the book = %v, want %v", detail.Tags, want)
+	}
+
+	expectError(t, s.do(http.MethodPut, "/admin/books/99/tags", map[string]interface{}{"tags": []string{"x"}}, admin), http.StatusNotFound, "Book not found")
+	expectValidationError(t, s.do(http.MethodPut, fmt.Sprintf("/admin/books/%d/tags", dune.ID), map[string]interface{}{"tags": []string{" "}}, admin), "tags[0]")
+}
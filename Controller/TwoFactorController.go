@@ -0,0 +1,196 @@
+package Controller
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Service"
+	"encoding/base64"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// @Summary Complete a two-factor login
+// @Description Redeem the challenge token from /login with a code from the authenticator app or an unused recovery code. Wrong codes count as failed logins.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param credentials body Model.TwoFactorLoginRequest true "Challenge token and code"
+// @Success 200 {object} Model.TokenResponse "JWT token"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 401 {object} map[string]string "Invalid code or expired challenge"
+// @Failure 429 {object} map[string]string "Too many failed attempts; see Retry-After"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /login/2fa [post]
+func twoFactorLoginHandler(twoFactor Service.TwoFactorService, sessions Service.SessionService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.TwoFactorLoginRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		user, err := twoFactor.CompleteLogin(c.Request().Context(), request.ChallengeToken, request.Code, c.RealIP())
+		if err != nil {
+			if throttled := throttledError(c, err); throttled != nil {
+				return throttled
+			}
+			switch {
+			case errors.Is(err, Service.ErrInvalidTwoFactorCode):
+				Config.FailedLogins.Inc()
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid two-factor code")
+			case errors.Is(err, Service.ErrInvalidLoginChallenge):
+				return echo.NewHTTPError(http.StatusUnauthorized, "Login challenge is invalid or expired; log in again")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Internal server error").SetInternal(err)
+		}
+
+		token, err := sessions.Start(c.Request().Context(), user, c.Request().UserAgent(), c.RealIP())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate JWT").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusOK, Model.TokenResponse{Token: token})
+	}
+}
+
+// @Summary Start two-factor enrollment
+// @Description Generate an authenticator secret for the signed-in user after confirming the password, or for accounts without one a recent login. It takes effect once confirmed with a code; enrolling again before that replaces it.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param confirmation body Model.EnrollTwoFactorRequest true "Current password"
+// @Success 200 {object} Model.TwoFactorEnrollmentResponse "Secret, otpauth URI and QR code"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 422 {object} map[string]interface{} "Validation failed or password incorrect"
+// @Failure 401 {object} map[string]string "Recent login required"
+// @Failure 409 {object} map[string]string "Two-factor authentication already enabled"
+// @Failure 500 {object} map[string]string "Failed to enroll"
+// @Router /me/2fa [post]
+func enrollTwoFactorHandler(twoFactor Service.TwoFactorService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.EnrollTwoFactorRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		user, err := twoFactor.Enroll(c.Request().Context(), c.Get(Config.UserIDKey).(int), reauthentication(c, request.Password, ""))
+		if err != nil {
+			return twoFactorError(err, "Failed to enroll")
+		}
+
+		uri := Config.TOTPURI(user.Email, user.TOTPSecret)
+		qrCode, err := Config.QRCodePNG(uri)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to render QR code").SetInternal(err)
+		}
+
+		return c.JSON(http.StatusOK, Model.TwoFactorEnrollmentResponse{
+			Secret:     user.TOTPSecret,
+			OTPAuthURI: uri,
+			QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(qrCode),
+		})
+	}
+}
+
+// @Summary Confirm two-factor enrollment
+// @Description Enable two-factor authentication with a current code from the authenticator app. The response lists recovery codes, shown only this once.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param code body Model.ConfirmTwoFactorRequest true "Authenticator code"
+// @Success 200 {object} Model.RecoveryCodesResponse "Recovery codes"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 422 {object} map[string]interface{} "Validation failed or code incorrect"
+// @Failure 409 {object} map[string]string "Already enabled or enrollment not started"
+// @Failure 500 {object} map[string]string "Failed to confirm"
+// @Router /me/2fa/confirm [post]
+func confirmTwoFactorHandler(twoFactor Service.TwoFactorService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.ConfirmTwoFactorRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		codes, err := twoFactor.Confirm(c.Request().Context(), c.Get(Config.UserIDKey).(int), request.Code)
+		if err != nil {
+			return twoFactorError(err, "Failed to confirm two-factor authentication")
+		}
+
+		return c.JSON(http.StatusOK, Model.RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// @Summary Disable two-factor authentication
+// @Description Turn two-factor authentication off after confirming the password and a code from the authenticator app or a recovery code. Accounts without a password need only the code.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param confirmation body Model.DisableTwoFactorRequest true "Current password and code"
+// @Success 204 "Two-factor authentication disabled"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 422 {object} map[string]interface{} "Validation failed, password or code incorrect"
+// @Failure 409 {object} map[string]string "Two-factor authentication not enabled"
+// @Failure 500 {object} map[string]string "Failed to disable"
+// @Router /me/2fa [delete]
+func disableTwoFactorHandler(twoFactor Service.TwoFactorService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.DisableTwoFactorRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		err := twoFactor.Disable(c.Request().Context(), c.Get(Config.UserIDKey).(int), reauthentication(c, request.Password, ""), request.Code)
+		if err != nil {
+			return twoFactorError(err, "Failed to disable two-factor authentication")
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replace every recovery code after confirming the password, or for accounts without one a recent login or a two-factor code. Codes issued before stop working.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param confirmation body Model.RegenerateRecoveryCodesRequest true "Current password"
+// @Success 200 {object} Model.RecoveryCodesResponse "Recovery codes"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 422 {object} map[string]interface{} "Validation failed or password incorrect"
+// @Failure 401 {object} map[string]string "Recent login required"
+// @Failure 409 {object} map[string]string "Two-factor authentication not enabled"
+// @Failure 500 {object} map[string]string "Failed to regenerate recovery codes"
+// @Router /me/2fa/recovery-codes [post]
+func regenerateRecoveryCodesHandler(twoFactor Service.TwoFactorService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request Model.RegenerateRecoveryCodesRequest
+		if err := bindAndValidate(c, &request); err != nil {
+			return err
+		}
+
+		codes, err := twoFactor.RegenerateRecoveryCodes(c.Request().Context(), c.Get(Config.UserIDKey).(int), reauthentication(c, request.Password, request.Code))
+		if err != nil {
+			return twoFactorError(err, "Failed to regenerate recovery codes")
+		}
+
+		return c.JSON(http.StatusOK, Model.RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// twoFactorError maps the errors the /me/2fa endpoints share.
+func twoFactorError(err error, message string) error {
+	switch {
+	case errors.Is(err, Service.ErrInvalidCredentials):
+		return Config.NewValidationError("password", "is incorrect")
+	case errors.Is(err, Service.ErrInvalidTwoFactorCode):
+		return Config.NewValidationError("code", "is incorrect or was already used")
+	case errors.Is(err, Service.ErrTwoFactorEnabled):
+		return echo.NewHTTPError(http.StatusConflict, "Two-factor authentication is already enabled")
+	case errors.Is(err, Service.ErrTwoFactorNotEnabled):
+		return echo.NewHTTPError(http.StatusConflict, "Two-factor authentication is not enabled")
+	case errors.Is(err, Service.ErrTwoFactorNotEnrolled):
+		return echo.NewHTTPError(http.StatusConflict, "Start enrollment with POST /me/2fa first")
+	}
+	return accountError(err, message)
+}
@@ -0,0 +1,291 @@
+package Controller_test
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// enableTwoFactor enrolls and confirms an authenticator for user, returning
+// its secret and recovery codes.
+func (s *testServer) enableTwoFactor(user Model.UserModel) (string, []string) {
+	s.t.Helper()
+	token := s.loginAs(user.Email, user.Password)
+
+	rec := s.do(http.MethodPost, "/me/2fa", map[string]string{"password": user.Password}, token)
+	expectStatus(s.t, rec, http.StatusOK)
+	var enrollment Model.TwoFactorEnrollmentResponse
+	decode(s.t, rec, &enrollment)
+
+	rec = s.do(http.MethodPost, "/me/2fa/confirm", map[string]string{"code": s.totp(enrollment.Secret)}, token)
+	expectStatus(s.t, rec, http.StatusOK)
+	var recovery Model.RecoveryCodesResponse
+	decode(s.t, rec, &recovery)
+
+	// Let the confirmation code's time step pass, so the next code differs.
+	s.clock.Advance(Config.TOTPPeriod)
+	return enrollment.Secret, recovery.RecoveryCodes
+}
+
+func (s *testServer) totp(secret string) string {
+	s.t.Helper()
+	code, err := Config.TOTPCode(secret, Config.TOTPStep(s.clock.Now()))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return code
+}
+
+// challenge logs in with the password and returns the two-factor challenge.
+func (s *testServer) challenge(user Model.UserModel) string {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/login", map[string]string{"email": user.Email, "password": user.Password}, "")
+	expectStatus(s.t, rec, http.StatusOK)
+
+	var body Model.LoginResponse
+	decode(s.t, rec, &body)
+	if !body.TwoFactorRequired || body.ChallengeToken == "" || body.Token != "" {
+		s.t.Fatalf("login: want a challenge and no token, got %s", rec.Body)
+	}
+	return body.ChallengeToken
+}
+
+func (s *testServer) completeLogin(challenge, code string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	return s.do(http.MethodPost, "/login/2fa", map[string]string{"challengeToken": challenge, "code": code}, "")
+}
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	t.Run("returns a secret with URI and QR code", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+
+		rec := s.do(http.MethodPost, "/me/2fa", map[string]string{"password": user.Password}, s.loginAs(user.Email, user.Password))
+
+		expectStatus(t, rec, http.StatusOK)
+		var body Model.TwoFactorEnrollmentResponse
+		decode(t, rec, &body)
+		if body.Secret == "" || !strings.HasPrefix(body.OTPAuthURI, "otpauth://totp/Library:") || !strings.Contains(body.OTPAuthURI, "secret="+body.Secret) {
+			t.Errorf("enrollment = %+v, want a secret and a matching otpauth URI", body)
+		}
+		if !strings.HasPrefix(body.QRCode, "data:image/png;base64,") {
+			t.Errorf("qrCode = %.40q, want a PNG data URI", body.QRCode)
+		}
+	})
+
+	t.Run("is not enforced until confirmed", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		s.do(http.MethodPost, "/me/2fa", map[string]string{"password": user.Password}, s.loginAs(user.Email, user.Password))
+
+		s.loginAs(user.Email, user.Password)
+	})
+
+	t.Run("confirms with a current code and issues recovery codes", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+
+		_, codes := s.enableTwoFactor(user)
+
+		if len(codes) != 10 {
+			t.Errorf("got %d recovery codes, want 10", len(codes))
+		}
+		var stored []Model.RecoveryCodeModel
+		s.db.Where("user_id = ?", user.UserId).Find(&stored)
+		for _, code := range stored {
+			if strings.Contains(strings.Join(codes, " "), code.CodeHash) {
+				t.Errorf("recovery code stored in plain text: %+v", code)
+			}
+		}
+	})
+
+	t.Run("rejects a wrong confirmation code", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		token := s.loginAs(user.Email, user.Password)
+		s.do(http.MethodPost, "/me/2fa", map[string]string{"password": user.Password}, token)
+
+		expectValidationError(t, s.do(http.MethodPost, "/me/2fa/confirm", map[string]string{"code": "000000"}, token), "code")
+	})
+
+	t.Run("needs the password", func(t *testing.T) {
+		s := newTestServer(t)
+
+		expectValidationError(t, s.do(http.MethodPost, "/me/2fa", map[string]string{"password": "not-it-1"}, s.login()), "password")
+	})
+
+	t.Run("conflicts when already enabled", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		secret, _ := s.enableTwoFactor(user)
+		rec := s.completeLogin(s.challenge(user), s.totp(secret))
+		var body Model.TokenResponse
+		decode(t, rec, &body)
+
+		rec = s.do(http.MethodPost, "/me/2fa", map[string]string{"password": user.Password}, body.Token)
+
+		expectError(t, rec, http.StatusConflict, "Two-factor authentication is already enabled")
+	})
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	t.Run("issues the JWT only after a valid code", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		secret, _ := s.enableTwoFactor(user)
+		challenge := s.challenge(user)
+
+		expectError(t, s.do(http.MethodGet, "/me", nil, challenge), http.StatusUnauthorized, "Invalid or Expired Token")
+		rec := s.completeLogin(challenge, s.totp(secret))
+
+		expectStatus(t, rec, http.StatusOK)
+		var body Model.TokenResponse
+		decode(t, rec, &body)
+		rec = s.do(http.MethodGet, "/me", nil, body.Token)
+		expectStatus(t, rec, http.StatusOK)
+		var profile Model.ProfileResponse
+		decode(t, rec, &profile)
+		if !profile.TwoFactorEnabled {
+			t.Error("twoFactorEnabled = false, want true")
+		}
+	})
+
+	t.Run("rejects a wrong code", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		s.enableTwoFactor(user)
+
+		expectError(t, s.completeLogin(s.challenge(user), "123456"), http.StatusUnauthorized, "Invalid two-factor code")
+	})
+
+	t.Run("accepts each code only once", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		secret, _ := s.enableTwoFactor(user)
+		code := s.totp(secret)
+		expectStatus(t, s.completeLogin(s.challenge(user), code), http.StatusOK)
+
+		expectError(t, s.completeLogin(s.challenge(user), code), http.StatusUnauthorized, "Invalid two-factor code")
+	})
+
+	t.Run("accepts a code once even from concurrent logins", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		secret, _ := s.enableTwoFactor(user)
+		code := s.totp(secret)
+		challenges := []string{s.challenge(user), s.challenge(user), s.challenge(user), s.challenge(user)}
+
+		statuses := make(chan int, len(challenges))
+		var wg sync.WaitGroup
+		for _, challenge := range challenges {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				statuses <- s.completeLogin(challenge, code).Code
+			}()
+		}
+		wg.Wait()
+		close(statuses)
+
+		counts := map[int]int{}
+		for status := range statuses {
+			counts[status]++
+		}
+		if counts[http.StatusOK] != 1 || counts[http.StatusUnauthorized] != len(challenges)-1 {
+			t.Errorf("statuses = %v, want the code accepted once and refused otherwise", counts)
+		}
+	})
+
+	t.Run("accepts each recovery code once", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		_, codes := s.enableTwoFactor(user)
+
+		expectStatus(t, s.completeLogin(s.challenge(user), strings.ToUpper(codes[0])), http.StatusOK)
+		expectError(t, s.completeLogin(s.challenge(user), codes[0]), http.StatusUnauthorized, "Invalid two-factor code")
+		expectStatus(t, s.completeLogin(s.challenge(user), codes[1]), http.StatusOK)
+	})
+
+	t.Run("expires the challenge", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		secret, _ := s.enableTwoFactor(user)
+		challenge := s.challenge(user)
+		s.clock.Advance(6 * time.Minute)
+
+		expectError(t, s.completeLogin(challenge, s.totp(secret)), http.StatusUnauthorized, "Login challenge is invalid or expired; log in again")
+	})
+
+	t.Run("throttles guessing", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		secret, _ := s.enableTwoFactor(user)
+		challenge := s.challenge(user)
+
+		for i := 0; i < 4; i++ {
+			expectStatus(t, s.completeLogin(challenge, "000000"), http.StatusUnauthorized)
+		}
+
+		expectError(t, s.completeLogin(challenge, s.totp(secret)), http.StatusTooManyRequests, "Too many failed login attempts; try again later")
+	})
+
+	t.Run("keeps counting wrong codes across password logins", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		s.enableTwoFactor(user)
+
+		for i := 0; i < 10; i++ {
+			s.clock.Advance(time.Minute)
+			expectStatus(t, s.completeLogin(s.challenge(user), "000000"), http.StatusUnauthorized)
+		}
+
+		s.clock.Advance(time.Minute)
+		expectError(t, s.do(http.MethodPost, "/login", map[string]string{"email": user.Email, "password": user.Password}, ""), http.StatusTooManyRequests, "Too many failed login attempts; try again later")
+	})
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	t.Run("needs a code and drops the recovery codes", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		secret, _ := s.enableTwoFactor(user)
+		rec := s.completeLogin(s.challenge(user), s.totp(secret))
+		var body Model.TokenResponse
+		decode(t, rec, &body)
+		s.clock.Advance(Config.TOTPPeriod)
+
+		rec = s.do(http.MethodDelete, "/me/2fa", map[string]string{"password": user.Password, "code": "000000"}, body.Token)
+		expectValidationError(t, rec, "code")
+		rec = s.do(http.MethodDelete, "/me/2fa", map[string]string{"password": user.Password, "code": s.totp(secret)}, body.Token)
+		expectStatus(t, rec, http.StatusNoContent)
+
+		s.loginAs(user.Email, user.Password)
+		var remaining int64
+		s.db.Model(&Model.RecoveryCodeModel{}).Where("user_id = ?", user.UserId).Count(&remaining)
+		if remaining != 0 {
+			t.Errorf("%d recovery codes left, want 0", remaining)
+		}
+	})
+
+	t.Run("regenerating recovery codes voids the old ones", func(t *testing.T) {
+		s := newTestServer(t)
+		user := aUser().create(s)
+		_, old := s.enableTwoFactor(user)
+		rec := s.completeLogin(s.challenge(user), old[0])
+		var body Model.TokenResponse
+		decode(t, rec, &body)
+
+		rec = s.do(http.MethodPost, "/me/2fa/recovery-codes", map[string]string{"password": user.Password}, body.Token)
+		expectStatus(t, rec, http.StatusOK)
+		var fresh Model.RecoveryCodesResponse
+		decode(t, rec, &fresh)
+
+		expectError(t, s.completeLogin(s.challenge(user), old[1]), http.StatusUnauthorized, "Invalid two-factor code")
+		expectStatus(t, s.completeLogin(s.challenge(user), fresh.RecoveryCodes[0]), http.StatusOK)
+	})
+}
@@ -0,0 +1,460 @@
+package Controller_test
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Controller"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"awesomeProject/Service"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	Config.PasswordHashCost = bcrypt.MinCost
+	os.Exit(m.Run())
+}
+
+// testStart is where every test server's clock starts.
+var testStart = time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+
+// testServer is a Router booted against its own SQLite database, a fake
+// clock that only moves when the test advances it, an in-memory outbox and
+// an in-memory blob store.
+type testServer struct {
+	t      *testing.T
+	echo   *echo.Echo
+	db     *gorm.DB
+	clock  *Config.FakeClock
+	outbox *Config.MemoryOutbox
+	blobs  *Config.MemoryBlobStore
+
+	services *Service.Services
+}
+
+// newTestServer starts the application on a fresh database. configure may
+// adjust the services before routes are registered, such as to enable
+// optional ones.
+func newTestServer(t *testing.T, configure ...func(*Service.Services, Repository.Store)) *testServer {
+	t.Helper()
+
+	db, err := Config.OpenDatabase(filepath.Join(t.TempDir(), "library.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	clock := Config.NewFakeClock(testStart)
+	outbox := Config.NewMemoryOutbox()
+	blobs := Config.NewMemoryBlobStore()
+	e := echo.New()
+	store := Repository.NewGormStore(db)
+	services := Service.New(store, Config.NewOffsetClock(clock), outbox, blobs)
+	for _, f := range configure {
+		f(services, store)
+	}
+	Controller.Router(e, services)
+
+	return &testServer{t: t, echo: e, db: db, clock: clock, outbox: outbox, blobs: blobs, services: services}
+}
+
+// do sends a request through the router. body is JSON-encoded unless it is
+// already a string; token, when set, is sent as a Bearer token.
+func (s *testServer) do(method, path string, body interface{}, token string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatalf("encode body: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if reader != nil {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+
+	return s.serve(req)
+}
+
+// serve runs req through the router and fails the test if an API response
+// exposes anything that looks like a password. The Swagger document
+// describes request schemas, so it is exempt.
+func (s *testServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+
+	if !strings.HasPrefix(req.URL.Path, "/swagger/") && strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		var body interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err == nil {
+			if path := findPasswordField(body, "$"); path != "" {
+				s.t.Errorf("%s %s: response exposes %s: %s", req.Method, req.URL.Path, path, rec.Body)
+			}
+		}
+	}
+	return rec
+}
+
+// findPasswordField returns the JSON path of the first key mentioning
+// "password" in value, or "" when there is none. The validation error map
+// names request fields rather than carrying their values, so it is skipped.
+func findPasswordField(value interface{}, path string) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if path == "$" && key == "errors" {
+				continue
+			}
+			if strings.Contains(strings.ToLower(key), "password") {
+				return path + "." + key
+			}
+			if found := findPasswordField(child, path+"."+key); found != "" {
+				return found
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if found := findPasswordField(child, fmt.Sprintf("%s[%d]", path, i)); found != "" {
+				return found
+			}
+		}
+	}
+	return ""
+}
+
+// userFixture describes a user to insert; zero fields get unique defaults.
+// The stored password is hashed, but create returns the plaintext in
+// Password so tests can log in.
+type userFixture struct {
+	Model.UserModel
+	unconfirmed bool
+}
+
+func aUser() *userFixture {
+	return &userFixture{}
+}
+
+func (f *userFixture) withEmail(email string) *userFixture {
+	f.Email = email
+	return f
+}
+
+func (f *userFixture) withPassword(password string) *userFixture {
+	f.Password = password
+	return f
+}
+
+// unverified leaves the email address unconfirmed; fixtures are verified by
+// default.
+func (f *userFixture) unverified() *userFixture {
+	f.unconfirmed = true
+	return f
+}
+
+func (f *userFixture) asAdmin() *userFixture {
+	f.Role = Model.RoleAdmin
+	return f
+}
+
+var fixtureSeq int
+
+func (f *userFixture) create(s *testServer) Model.UserModel {
+	s.t.Helper()
+
+	fixtureSeq++
+	user := f.UserModel
+	if user.UserName == "" {
+		user.UserName = fmt.Sprintf("user%d", fixtureSeq)
+	}
+	if user.Email == "" {
+		user.Email = fmt.Sprintf("user%d@example.com", fixtureSeq)
+	}
+	if user.Password == "" {
+		user.Password = fmt.Sprintf("password-%d", fixtureSeq)
+	}
+	if user.Role == "" {
+		user.Role = Model.RoleMember
+	}
+	if !f.unconfirmed {
+		verifiedAt := testStart
+		user.EmailVerifiedAt = &verifiedAt
+	}
+
+	stored := user
+	hash, err := Config.HashPassword(user.Password)
+	if err != nil {
+		s.t.Fatalf("hash password: %v", err)
+	}
+	stored.Password = hash
+	if err := s.db.Create(&stored).Error; err != nil {
+		s.t.Fatalf("create user: %v", err)
+	}
+
+	user.UserId = stored.UserId
+	return user
+}
+
+// bookFixture describes a book to insert; zero fields get defaults. Books
+// get one copy unless told otherwise.
+type bookFixture struct {
+	Model.BookModel
+	isbn     string
+	copies   int
+	borrowed bool
+}
+
+func aBook() *bookFixture {
+	return &bookFixture{}
+}
+
+func (f *bookFixture) withID(id int) *bookFixture {
+	f.ID = id
+	return f
+}
+
+func (f *bookFixture) withTitle(title string) *bookFixture {
+	f.Title = title
+	return f
+}
+
+func (f *bookFixture) withAuthor(author string) *bookFixture {
+	f.Author = author
+	return f
+}
+
+func (f *bookFixture) withISBN(isbn string) *bookFixture {
+	f.isbn = isbn
+	return f
+}
+
+func (f *bookFixture) withCopies(n int) *bookFixture {
+	f.copies = n
+	return f
+}
+
+// onLoan marks every copy as on loan, without anyone holding it.
+func (f *bookFixture) onLoan() *bookFixture {
+	f.borrowed = true
+	return f
+}
+
+func (f *bookFixture) create(s *testServer) Model.BookModel {
+	s.t.Helper()
+
+	fixtureSeq++
+	book := f.BookModel
+	if book.Title == "" {
+		book.Title = fmt.Sprintf("Book %d", fixtureSeq)
+	}
+	if book.Author == "" {
+		book.Author = "Test Author"
+	}
+	if book.Description == "" {
+		book.Description = "A book created by a test."
+	}
+	if f.copies == 0 {
+		f.copies = 1
+	}
+	if err := book.SetISBN(f.isbn); err != nil {
+		s.t.Fatalf("set ISBN %q: %v", f.isbn, err)
+	}
+
+	if err := s.db.Create(&book).Error; err != nil {
+		s.t.Fatalf("create book: %v", err)
+	}
+	for n := range f.copies {
+		item := Model.BookCopyModel{
+			BookID:     book.ID,
+			Barcode:    fmt.Sprintf("T-%05d", fixtureSeq*100+n),
+			Status:     Model.CopyAvailable,
+			AcquiredAt: testStart,
+		}
+		if f.borrowed {
+			item.Status = Model.CopyOnLoan
+		}
+		if err := s.db.Create(&item).Error; err != nil {
+			s.t.Fatalf("create copy: %v", err)
+		}
+	}
+	return book
+}
+
+// login registers a fresh user through /register, verifies the address,
+// logs in through /login and returns the user's token.
+func (s *testServer) login() string {
+	s.t.Helper()
+
+	fixtureSeq++
+	credentials := map[string]string{
+		"userName": fmt.Sprintf("member%d", fixtureSeq),
+		"email":    fmt.Sprintf("member%d@example.com", fixtureSeq),
+		"password": fmt.Sprintf("secret-%d", fixtureSeq),
+	}
+
+	if rec := s.do(http.MethodPost, "/register", credentials, ""); rec.Code != http.StatusOK {
+		s.t.Fatalf("register: status %d, body %s", rec.Code, rec.Body)
+	}
+	s.verifyEmail(credentials["email"])
+	return s.loginAs(credentials["email"], credentials["password"])
+}
+
+var mailLink = regexp.MustCompile(`https?://\S+`)
+
+// lastLink returns the path and query of the link in the latest mail sent
+// to email whose subject contains subject.
+func (s *testServer) lastLink(email, subject string) string {
+	s.t.Helper()
+
+	messages := s.outbox.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		mail := messages[i]
+		if mail.To != email || !strings.Contains(mail.Subject, subject) {
+			continue
+		}
+		link, err := url.Parse(mailLink.FindString(mail.Body))
+		if err != nil || link.Path == "" {
+			s.t.Fatalf("no link in mail %q", mail.Body)
+		}
+		return link.RequestURI()
+	}
+	s.t.Fatalf("no %q mail sent to %s", subject, email)
+	return ""
+}
+
+// verifyEmail follows the verification link last mailed to email.
+func (s *testServer) verifyEmail(email string) {
+	s.t.Helper()
+
+	if rec := s.do(http.MethodGet, s.lastLink(email, "Verify"), nil, ""); rec.Code != http.StatusOK {
+		s.t.Fatalf("verify email: status %d, body %s", rec.Code, rec.Body)
+	}
+}
+
+// loginAdmin creates an administrator and returns their token.
+func (s *testServer) loginAdmin() string {
+	s.t.Helper()
+
+	admin := aUser().asAdmin().create(s)
+	return s.loginAs(admin.Email, admin.Password)
+}
+
+// attemptLogin posts credentials to /login as if sent from clientIP.
+func (s *testServer) attemptLogin(email, password, clientIP string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.RemoteAddr = clientIP + ":40000"
+	return s.serve(req)
+}
+
+// uniqueEmail returns an address no other call has returned.
+func uniqueEmail() string {
+	fixtureSeq++
+	return fmt.Sprintf("someone%d@example.com", fixtureSeq)
+}
+
+// loginAs logs in with existing credentials and returns the token.
+func (s *testServer) loginAs(email, password string) string {
+	s.t.Helper()
+
+	rec := s.do(http.MethodPost, "/login", map[string]string{"email": email, "password": password}, "")
+	if rec.Code != http.StatusOK {
+		s.t.Fatalf("login: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	var body Model.LoginResponse
+	decode(s.t, rec, &body)
+	if body.Token == "" {
+		s.t.Fatalf("login: no token in %s", rec.Body)
+	}
+	return body.Token
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, target interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(rec.Body.Bytes(), target); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body, err)
+	}
+}
+
+// expectStatus fails the test unless rec has the status code want.
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, want, rec.Body)
+	}
+}
+
+// expectValidationError fails the test unless rec is a 422 naming exactly
+// the given fields as invalid.
+func expectValidationError(t *testing.T, rec *httptest.ResponseRecorder, fields ...string) {
+	t.Helper()
+
+	expectError(t, rec, http.StatusUnprocessableEntity, "Validation failed")
+
+	var body struct {
+		Errors map[string]string `json:"errors"`
+	}
+	decode(t, rec, &body)
+	for _, field := range fields {
+		if body.Errors[field] == "" {
+			t.Errorf("no error for field %q in %v", field, body.Errors)
+		}
+	}
+	if len(body.Errors) != len(fields) {
+		t.Errorf("errors = %v, want exactly %v", body.Errors, fields)
+	}
+}
+
+// expectError fails the test unless rec is an error response with the given
+// status and message.
+func expectError(t *testing.T, rec *httptest.ResponseRecorder, status int, message string) {
+	t.Helper()
+
+	expectStatus(t, rec, status)
+
+	var body map[string]interface{}
+	decode(t, rec, &body)
+	if body["message"] != message {
+		t.Errorf("message = %v, want %q", body["message"], message)
+	}
+	if body["requestId"] == "" || body["requestId"] != rec.Header().Get(Config.RequestIDHeader) {
+		t.Errorf("requestId = %v, want the %s header %q", body["requestId"], Config.RequestIDHeader, rec.Header().Get(Config.RequestIDHeader))
+	}
+}
@@ -0,0 +1,44 @@
+package Model
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// API key scopes. Keys only reach endpoints that accept one of their scopes;
+// user tokens are limited by role instead.
+const (
+	ScopeCatalogRead  = "catalog:read"
+	ScopeCatalogWrite = "catalog:write"
+)
+
+// APIKeyModel is a long-lived credential for clients that cannot log in
+// interactively. Only a hash of the key is stored; Prefix is kept in clear
+// so keys can be told apart in listings and looked up.
+type APIKeyModel struct {
+	ID      int    `gorm:"primaryKey;autoIncrement"`
+	Name    string `gorm:"not null"`
+	Prefix  string `gorm:"uniqueIndex;not null"`
+	KeyHash string `gorm:"not null"`
+	// Scopes is a space separated list.
+	Scopes      string    `gorm:"not null"`
+	CreatedByID int       `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"not null"`
+	ExpiresAt   *time.Time
+	LastUsedAt  *time.Time
+	RevokedAt   *time.Time
+}
+
+func (k APIKeyModel) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+func (k APIKeyModel) HasScope(scope string) bool {
+	return slices.Contains(k.ScopeList(), scope)
+}
+
+// Active reports whether the key may be used at now.
+func (k APIKeyModel) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
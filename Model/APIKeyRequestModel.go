@@ -0,0 +1,10 @@
+package Model
+
+import "time"
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,notblank,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=catalog:read catalog:write"`
+	// ExpiresAt is optional; keys without it stay valid until revoked.
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,67 @@
+package Model
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Roles a person can have on a book.
+const (
+	CreditAuthor      = "author"
+	CreditEditor      = "editor"
+	CreditTranslator  = "translator"
+	CreditIllustrator = "illustrator"
+)
+
+// AuthorModel is a person credited on books, whatever the role.
+type AuthorModel struct {
+	ID int `gorm:"primaryKey;autoIncrement"`
+	// Name is the preferred form, in natural order.
+	Name string `gorm:"not null"`
+	// BirthYear and DeathYear are nil when unknown; years BCE are negative.
+	BirthYear *int
+	DeathYear *int
+}
+
+// AuthorNameModel is one way of writing an author's name, the preferred one
+// included. Key makes differently punctuated or inverted forms collide. Two
+// authors may share a name, so a key can belong to several of them.
+type AuthorNameModel struct {
+	ID       int    `gorm:"primaryKey;autoIncrement"`
+	AuthorID int    `gorm:"not null;index"`
+	Name     string `gorm:"not null"`
+	Key      string `gorm:"not null;index:idx_author_name_models_name_key"`
+}
+
+// BookAuthorModel credits an author on a book in a role. Position orders the
+// credits of a book as they appear on the title page.
+type BookAuthorModel struct {
+	BookID   int    `gorm:"primaryKey"`
+	AuthorID int    `gorm:"primaryKey;index"`
+	Role     string `gorm:"primaryKey"`
+	Position int    `gorm:"not null"`
+}
+
+// NaturalName turns an inverted name such as "Tolkien, J. R. R." into
+// natural order; other names are only trimmed.
+func NaturalName(name string) string {
+	name = strings.TrimSpace(name)
+	last, first, inverted := strings.Cut(name, ",")
+	if !inverted || strings.Contains(first, ",") {
+		return name
+	}
+	return strings.TrimSpace(strings.TrimSpace(first) + " " + strings.TrimSpace(last))
+}
+
+// AuthorNameKey reduces a name to the letters and digits of its natural
+// form, lower-cased, so "J.R.R. Tolkien" and "Tolkien, J. R. R." share a
+// key. It is "" for names without any.
+func AuthorNameKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(NaturalName(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
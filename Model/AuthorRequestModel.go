@@ -0,0 +1,20 @@
+package Model
+
+// AuthorRequest creates an author or replaces one's details. Variants are
+// further spellings of the name; the preferred one is always included.
+type AuthorRequest struct {
+	Name      string   `json:"name" validate:"required,notblank,max=200"`
+	Variants  []string `json:"variants" validate:"max=50,dive,notblank,max=200"`
+	BirthYear *int     `json:"birthYear" validate:"omitempty,min=-3000,max=2100"`
+	DeathYear *int     `json:"deathYear" validate:"omitempty,min=-3000,max=2100"`
+}
+
+type BookCreditRequest struct {
+	AuthorID int    `json:"authorId" validate:"required,min=1"`
+	Role     string `json:"role" validate:"required,oneof=author editor translator illustrator"`
+}
+
+// SetBookCreditsRequest replaces every credit of a book, in title page order.
+type SetBookCreditsRequest struct {
+	Credits []BookCreditRequest `json:"credits" validate:"required,min=1,max=50,dive"`
+}
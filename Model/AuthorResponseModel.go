@@ -0,0 +1,39 @@
+package Model
+
+type AuthorResponse struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	BirthYear *int   `json:"birthYear"`
+	DeathYear *int   `json:"deathYear"`
+}
+
+type AuthorDetailResponse struct {
+	AuthorResponse
+	// Variants are the other spellings the author is known by.
+	Variants []string             `json:"variants"`
+	Works    []AuthorWorkResponse `json:"works"`
+}
+
+// AuthorWorkResponse is a book an author is credited on, in one role.
+type AuthorWorkResponse struct {
+	BookID int    `json:"bookId"`
+	Slug   string `json:"slug"`
+	Title  string `json:"title"`
+	Role   string `json:"role"`
+}
+
+// ContributorResponse is one credit of a book.
+type ContributorResponse struct {
+	AuthorID int    `json:"authorId"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+}
+
+func NewAuthorResponse(author AuthorModel) AuthorResponse {
+	return AuthorResponse{
+		ID:        author.ID,
+		Name:      author.Name,
+		BirthYear: author.BirthYear,
+		DeathYear: author.DeathYear,
+	}
+}
@@ -0,0 +1,29 @@
+package Model
+
+import "time"
+
+// Copy statuses. Only lending moves a copy to or from CopyOnLoan; the others
+// are set by staff.
+const (
+	CopyAvailable = "available"
+	CopyOnLoan    = "on_loan"
+	CopyLost      = "lost"
+	CopyInRepair  = "in_repair"
+	CopyWithdrawn = "withdrawn"
+)
+
+// BookCopyModel is one physical item of a title in BookModel.
+type BookCopyModel struct {
+	ID         int       `gorm:"primaryKey;autoIncrement"`
+	BookID     int       `gorm:"not null;index"`
+	Barcode    string    `gorm:"uniqueIndex;not null"`
+	Status     string    `gorm:"not null;default:available;index"`
+	AcquiredAt time.Time `gorm:"not null"`
+}
+
+// BookAvailability summarizes the copies of a title. Withdrawn copies are
+// no longer part of the collection and are not counted.
+type BookAvailability struct {
+	Copies    int
+	Available int
+}
@@ -0,0 +1,14 @@
+package Model
+
+import "time"
+
+type AddBookCopyRequest struct {
+	Barcode string `json:"barcode" validate:"required,notblank,max=50"`
+	// AcquiredAt defaults to now.
+	AcquiredAt *time.Time `json:"acquiredAt"`
+}
+
+type UpdateBookCopyRequest struct {
+	// Status cannot be set to on_loan; only borrowing does that.
+	Status string `json:"status" validate:"required,oneof=available lost in_repair withdrawn"`
+}
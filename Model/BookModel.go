@@ -1,9 +1,136 @@
 package Model
 
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
 type BookModel struct {
-	ID          int    `json:"id" gorm:"primaryKey;autoIncrement"`
-	Title       string `json:"title" gorm:"not null"`
-	Author      string `json:"author" gorm:"not null"`
-	Description string `json:"description" gorm:"not null"`
-	Available   bool   `json:"available" gorm:"not null"`
+	ID          int    `gorm:"primaryKey;autoIncrement"`
+	Title       string `gorm:"not null"`
+	Author      string `gorm:"not null"`
+	Description string `gorm:"not null"`
+	// ISBN13 identifies the edition, normalized as by NormalizeISBN; no two
+	// books share one. Empty when unknown. Set it through SetISBN.
+	ISBN13 string `gorm:"uniqueIndex:idx_book_models_isbn13,where:isbn13 <> ''"`
+	// ISBN10 is the ten-digit form of ISBN13, empty for 979 ISBNs.
+	ISBN10 string `gorm:"index"`
+	// Cover names the current version of the cover image and changes with
+	// every upload, so image URLs can be cached for good. Empty without
+	// one.
+	Cover string
+	// Slug is the canonical human-readable identifier, derived from the title
+	// and author by the save hook.
+	Slug string `gorm:"uniqueIndex;not null"`
+}
+
+// BookSlugModel remembers slugs a book no longer uses, so links to them can
+// be redirected to the current one.
+type BookSlugModel struct {
+	Slug   string `gorm:"primaryKey"`
+	BookID int    `gorm:"not null;index"`
+}
+
+// BeforeSave keeps Slug in step with the title and author, recording the slug
+// it replaces.
+func (b *BookModel) BeforeSave(tx *gorm.DB) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+	previous, err := AssignSlug(b, func(slug string) (bool, error) {
+		var count int64
+		err := db.Model(&BookModel{}).Where("slug = ? AND id <> ?", slug, b.ID).Count(&count).Error
+		if err != nil || count > 0 {
+			return count > 0, err
+		}
+		err = db.Model(&BookSlugModel{}).Where("slug = ? AND book_id <> ?", slug, b.ID).Count(&count).Error
+		return count > 0, err
+	})
+	if err != nil || previous == "" || b.ID == 0 {
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&BookSlugModel{Slug: previous, BookID: b.ID}).Error
+}
+
+// AssignSlug derives book.Slug from its title and author unless the current
+// one still fits them. taken reports whether a slug belongs to another book,
+// in which case a numeric suffix is added. It returns the slug that was
+// replaced, or "" when the slug did not change.
+func AssignSlug(book *BookModel, taken func(slug string) (bool, error)) (string, error) {
+	base := Slugify(book.Title, book.Author)
+	if slugFits(book.Slug, base) {
+		return "", nil
+	}
+
+	slug := base
+	for n := 2; ; n++ {
+		used, err := taken(slug)
+		if err != nil {
+			return "", err
+		}
+		if !used {
+			break
+		}
+		slug = base + "-" + strconv.Itoa(n)
+	}
+
+	previous := book.Slug
+	book.Slug = slug
+	return previous, nil
+}
+
+// Slugify joins parts into a lower-case, hyphen-separated identifier made of
+// letters and digits only. The result never consists of digits alone, so it
+// cannot be mistaken for a book ID or ISBN.
+func Slugify(parts ...string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.Join(parts, " ")) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '\'' || r == '’' || r == '.':
+			// Drop apostrophes and initials' dots rather than splitting
+			// words on them: "J.D. Salinger" becomes "jd-salinger".
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if strings.Trim(slug, "0123456789-") == "" {
+		slug = strings.TrimSuffix("book-"+slug, "-")
+	}
+	return slug
+}
+
+// slugFits reports whether slug is base, possibly with the numeric suffix
+// AssignSlug adds to resolve collisions.
+func slugFits(slug, base string) bool {
+	if slug == base {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(slug, base+"-")
+	if !ok {
+		return false
+	}
+	n, err := strconv.Atoi(suffix)
+	return err == nil && n >= 2 && strconv.Itoa(n) == suffix
+}
+
+// SetISBN records isbn, an ISBN-10 or ISBN-13 in any hyphenation, in both
+// forms. An empty isbn clears them; anything else invalid fails with
+// ErrInvalidISBN.
+func (b *BookModel) SetISBN(isbn string) error {
+	if strings.TrimSpace(isbn) == "" {
+		b.ISBN13, b.ISBN10 = "", ""
+		return nil
+	}
+	isbn13, isbn10, err := ParseISBN(isbn)
+	if err != nil {
+		return err
+	}
+	b.ISBN13, b.ISBN10 = isbn13, isbn10
+	return nil
 }
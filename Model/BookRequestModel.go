@@ -0,0 +1,7 @@
+package Model
+
+// SetBookISBNRequest gives a book its ISBN. Either form is accepted, with or
+// without hyphens; an empty ISBN clears it.
+type SetBookISBNRequest struct {
+	ISBN string `json:"isbn" validate:"omitempty,isbn"`
+}
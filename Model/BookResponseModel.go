@@ -1,8 +1,101 @@
 package Model
 
+import "time"
+
 type BookResponse struct {
-	ID        int    `json:"id"`
-	Title     string `json:"title"`
-	Author    string `json:"author"`
-	Available bool   `json:"available"`
+	ID     int    `json:"id"`
+	Slug   string `json:"slug"`
+	ISBN13 string `json:"isbn13,omitempty"`
+	ISBN10 string `json:"isbn10,omitempty"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	// Available is true while at least one copy can be borrowed.
+	Available       bool `json:"available"`
+	AvailableCopies int  `json:"availableCopies"`
+	Copies          int  `json:"copies"`
+	// CoverURL and the thumbnail URLs are omitted for books without a
+	// cover.
+	CoverURL           string `json:"coverUrl,omitempty"`
+	ThumbnailSmallURL  string `json:"thumbnailSmallUrl,omitempty"`
+	ThumbnailMediumURL string `json:"thumbnailMediumUrl,omitempty"`
+}
+
+type BookDetailResponse struct {
+	ID                 int    `json:"id"`
+	Slug               string `json:"slug"`
+	ISBN13             string `json:"isbn13,omitempty"`
+	ISBN10             string `json:"isbn10,omitempty"`
+	Title              string `json:"title"`
+	Author             string `json:"author"`
+	Description        string `json:"description"`
+	Available          bool   `json:"available"`
+	AvailableCopies    int    `json:"availableCopies"`
+	Copies             int    `json:"copies"`
+	CoverURL           string `json:"coverUrl,omitempty"`
+	ThumbnailSmallURL  string `json:"thumbnailSmallUrl,omitempty"`
+	ThumbnailMediumURL string `json:"thumbnailMediumUrl,omitempty"`
+	// Contributors are the credited people in title page order; Author is
+	// the name statement as catalogued.
+	Contributors []ContributorResponse `json:"contributors"`
+	Subjects     []SubjectResponse     `json:"subjects"`
+	Tags         []string              `json:"tags"`
+}
+
+type BookCopyResponse struct {
+	ID         int       `json:"id"`
+	BookID     int       `json:"bookId"`
+	Barcode    string    `json:"barcode"`
+	Status     string    `json:"status"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+}
+
+func NewBookResponse(book BookModel, availability BookAvailability) BookResponse {
+	return BookResponse{
+		ID:                 book.ID,
+		Slug:               book.Slug,
+		ISBN13:             book.ISBN13,
+		ISBN10:             book.ISBN10,
+		Title:              book.Title,
+		Author:             book.Author,
+		Available:          availability.Available > 0,
+		AvailableCopies:    availability.Available,
+		Copies:             availability.Copies,
+		CoverURL:           CoverURL(book, CoverOriginal),
+		ThumbnailSmallURL:  CoverURL(book, CoverSmall),
+		ThumbnailMediumURL: CoverURL(book, CoverMedium),
+	}
+}
+
+func NewBookDetailResponse(book BookModel, availability BookAvailability, contributors []ContributorResponse) BookDetailResponse {
+	if contributors == nil {
+		contributors = []ContributorResponse{}
+	}
+	return BookDetailResponse{
+		ID:                 book.ID,
+		Slug:               book.Slug,
+		ISBN13:             book.ISBN13,
+		ISBN10:             book.ISBN10,
+		Title:              book.Title,
+		Author:             book.Author,
+		Description:        book.Description,
+		Available:          availability.Available > 0,
+		AvailableCopies:    availability.Available,
+		Copies:             availability.Copies,
+		CoverURL:           CoverURL(book, CoverOriginal),
+		ThumbnailSmallURL:  CoverURL(book, CoverSmall),
+		ThumbnailMediumURL: CoverURL(book, CoverMedium),
+		Contributors:       contributors,
+		Subjects:           []SubjectResponse{},
+		Tags:               []string{},
+	}
+}
+
+func NewBookCopyResponse(item BookCopyModel) BookCopyResponse {
+	return BookCopyResponse{
+		ID:         item.ID,
+		BookID:     item.BookID,
+		Barcode:    item.Barcode,
+		Status:     item.Status,
+		AcquiredAt: item.AcquiredAt,
+	}
 }
@@ -0,0 +1,27 @@
+package Model
+
+import "fmt"
+
+// Cover image sizes. Thumbnails are JPEGs scaled to the width in
+// CoverWidths; the original is served as uploaded.
+const (
+	CoverOriginal = "original"
+	CoverMedium   = "medium"
+	CoverSmall    = "small"
+)
+
+// CoverWidths maps each thumbnail size to its width in pixels. Images
+// narrower than that are not enlarged.
+var CoverWidths = map[string]int{
+	CoverMedium: 400,
+	CoverSmall:  120,
+}
+
+// CoverURL is where the size version of book's cover is served, or "" when
+// the book has none. The version query changes with every upload.
+func CoverURL(book BookModel, size string) string {
+	if book.Cover == "" {
+		return ""
+	}
+	return fmt.Sprintf("/covers/%d/%s?v=%s", book.ID, size, book.Cover)
+}
@@ -0,0 +1,15 @@
+package Model
+
+import "time"
+
+// ExternalIdentityModel links an account at an OpenID Connect provider to a
+// local user, so later logins find the user even after their email changes
+// on either side.
+type ExternalIdentityModel struct {
+	ID      int    `gorm:"primaryKey;autoIncrement"`
+	Issuer  string `gorm:"not null;uniqueIndex:idx_external_identity"`
+	Subject string `gorm:"not null;uniqueIndex:idx_external_identity"`
+	UserID  int    `gorm:"not null;index"`
+	// CreatedAt is when the accounts were linked.
+	CreatedAt time.Time `gorm:"not null"`
+}
@@ -0,0 +1,92 @@
+package Model
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidISBN is returned for identifiers that are neither a valid
+// ISBN-10 nor a valid ISBN-13.
+var ErrInvalidISBN = errors.New("invalid ISBN")
+
+// NormalizeISBN strips the hyphens and spaces ISBNs are often printed with
+// and upper-cases the ISBN-10 check character.
+func NormalizeISBN(isbn string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isbn)))
+}
+
+// ParseISBN accepts an ISBN-10 or ISBN-13 in any hyphenation and returns
+// both forms of it. isbn10 is empty for ISBNs starting with 979, which have
+// no ten-digit form.
+func ParseISBN(isbn string) (isbn13, isbn10 string, err error) {
+	isbn = NormalizeISBN(isbn)
+	switch {
+	case validISBN10(isbn):
+		return ISBN10To13(isbn), isbn, nil
+	case validISBN13(isbn):
+		isbn10, _ = ISBN13To10(isbn)
+		return isbn, isbn10, nil
+	}
+	return "", "", ErrInvalidISBN
+}
+
+// ValidISBN reports whether isbn is a valid ISBN-10 or ISBN-13 in any
+// hyphenation.
+func ValidISBN(isbn string) bool {
+	_, _, err := ParseISBN(isbn)
+	return err == nil
+}
+
+// ISBN10To13 converts a valid, normalized ISBN-10 to its ISBN-13.
+func ISBN10To13(isbn10 string) string {
+	body := "978" + isbn10[:9]
+	return body + string(isbn13Check(body))
+}
+
+// ISBN13To10 converts a valid, normalized ISBN-13 to its ISBN-10. Only ISBNs
+// starting with 978 have one.
+func ISBN13To10(isbn13 string) (string, bool) {
+	if !strings.HasPrefix(isbn13, "978") {
+		return "", false
+	}
+	body := isbn13[3:12]
+	return body + string(isbn10Check(body)), true
+}
+
+func validISBN10(isbn string) bool {
+	return len(isbn) == 10 && digits(isbn[:9]) && isbn[9] == isbn10Check(isbn[:9])
+}
+
+func validISBN13(isbn string) bool {
+	return len(isbn) == 13 && digits(isbn) && (strings.HasPrefix(isbn, "978") || strings.HasPrefix(isbn, "979")) &&
+		isbn[12] == isbn13Check(isbn[:12])
+}
+
+// isbn10Check computes the check character of the nine digits in body:
+// weights 10 down to 2, modulo 11, with X standing for 10.
+func isbn10Check(body string) byte {
+	sum := 0
+	for i := range 9 {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	switch check := (11 - sum%11) % 11; check {
+	case 10:
+		return 'X'
+	default:
+		return byte('0' + check)
+	}
+}
+
+// isbn13Check computes the check digit of the twelve digits in body:
+// alternating weights 1 and 3, modulo 10.
+func isbn13Check(body string) byte {
+	sum := 0
+	for i := range 12 {
+		sum += int(body[i]-'0') * (1 + 2*(i%2))
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func digits(s string) bool {
+	return strings.Trim(s, "0123456789") == ""
+}
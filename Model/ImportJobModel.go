@@ -0,0 +1,56 @@
+package Model
+
+import "time"
+
+// Import job statuses. Jobs are queued when the file has been read, and
+// finish as succeeded even when some rows were rejected; failed means no
+// row was saved.
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportSucceeded = "succeeded"
+	ImportFailed    = "failed"
+)
+
+// Import file formats. MARC files hold MARC 21 bibliographic records,
+// either in ISO 2709 (MARC) or in MARCXML; the catalog can be exported in
+// both.
+const (
+	ImportCSV       = "csv"
+	ImportJSONLines = "jsonl"
+	ImportMARC      = "marc"
+	ImportMARCXML   = "marcxml"
+)
+
+// ImportFields are the book fields import columns can be mapped to.
+var ImportFields = []string{"title", "author", "description", "isbn"}
+
+// ImportJobModel tracks a bulk catalog import. For dry runs the counts
+// describe what the import would have done.
+type ImportJobModel struct {
+	ID     int    `gorm:"primaryKey;autoIncrement"`
+	Format string `gorm:"not null"`
+	DryRun bool   `gorm:"not null"`
+	Status string `gorm:"not null"`
+	// Rows counts the data rows of the file; Failed those rejected.
+	Rows    int `gorm:"not null"`
+	Created int `gorm:"not null"`
+	Updated int `gorm:"not null"`
+	Failed  int `gorm:"not null"`
+	// Error explains why a failed job failed.
+	Error      string
+	CreatedAt  time.Time `gorm:"not null"`
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// ImportErrorModel is a problem with one row of an import. Row is the line
+// of the file the row starts on, or the position of the record in MARC
+// files.
+type ImportErrorModel struct {
+	ID      int    `gorm:"primaryKey;autoIncrement"`
+	JobID   int    `gorm:"not null;index"`
+	Row     int    `gorm:"not null"`
+	Field   string `gorm:"not null"`
+	Message string `gorm:"not null"`
+}
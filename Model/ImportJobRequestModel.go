@@ -0,0 +1,12 @@
+package Model
+
+// ImportRequest holds the form fields sent along with an import file.
+type ImportRequest struct {
+	// Format defaults to the one the file name's extension suggests.
+	Format string `json:"format" form:"format" validate:"omitempty,oneof=csv jsonl marc marcxml"`
+	// Mapping is a JSON object from column names to ImportFields, or to ""
+	// to ignore a column. Columns named like a field need no mapping, and
+	// MARC records take none.
+	Mapping string `json:"mapping" form:"mapping"`
+	DryRun  bool   `json:"dryRun" form:"dryRun"`
+}
@@ -0,0 +1,59 @@
+package Model
+
+import "time"
+
+type ImportJobResponse struct {
+	ID     int    `json:"id"`
+	Format string `json:"format"`
+	DryRun bool   `json:"dryRun"`
+	Status string `json:"status"`
+	// Created and Updated count what a dry run would have done.
+	Rows       int        `json:"rows"`
+	Created    int        `json:"created"`
+	Updated    int        `json:"updated"`
+	Failed     int        `json:"failed"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+// ImportJobDetailResponse adds the per-row error report, in file order.
+type ImportJobDetailResponse struct {
+	ImportJobResponse
+	Errors []ImportErrorResponse `json:"errors"`
+}
+
+type ImportErrorResponse struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func NewImportJobResponse(job ImportJobModel) ImportJobResponse {
+	return ImportJobResponse{
+		ID:         job.ID,
+		Format:     job.Format,
+		DryRun:     job.DryRun,
+		Status:     job.Status,
+		Rows:       job.Rows,
+		Created:    job.Created,
+		Updated:    job.Updated,
+		Failed:     job.Failed,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+	}
+}
+
+func NewImportJobDetailResponse(job ImportJobModel, errors []ImportErrorModel) ImportJobDetailResponse {
+	response := ImportJobDetailResponse{
+		ImportJobResponse: NewImportJobResponse(job),
+		Errors:            make([]ImportErrorResponse, 0, len(errors)),
+	}
+	for _, problem := range errors {
+		response.Errors = append(response.Errors, ImportErrorResponse{Row: problem.Row, Field: problem.Field, Message: problem.Message})
+	}
+	return response
+}
@@ -0,0 +1,13 @@
+package Model
+
+import "time"
+
+type LoanModel struct {
+	ID         int       `gorm:"primaryKey;autoIncrement"`
+	BookID     int       `gorm:"not null;index"`
+	CopyID     int       `gorm:"not null;index"`
+	UserID     int       `gorm:"not null;index"`
+	BorrowedAt time.Time `gorm:"not null"`
+	DueAt      time.Time `gorm:"not null"`
+	ReturnedAt *time.Time
+}
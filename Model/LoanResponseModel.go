@@ -0,0 +1,32 @@
+package Model
+
+import "time"
+
+type LoanResponse struct {
+	ID         int        `json:"id"`
+	BookID     int        `json:"bookId"`
+	CopyID     int        `json:"copyId"`
+	UserID     int        `json:"userId"`
+	BorrowedAt time.Time  `json:"borrowedAt"`
+	DueAt      time.Time  `json:"dueAt"`
+	ReturnedAt *time.Time `json:"returnedAt"`
+}
+
+type BorrowResponse struct {
+	Message string       `json:"message"`
+	Loan    LoanResponse `json:"loan"`
+	// Barcode identifies the copy handed out.
+	Barcode string `json:"barcode"`
+}
+
+func NewLoanResponse(loan LoanModel) LoanResponse {
+	return LoanResponse{
+		ID:         loan.ID,
+		BookID:     loan.BookID,
+		CopyID:     loan.CopyID,
+		UserID:     loan.UserID,
+		BorrowedAt: loan.BorrowedAt,
+		DueAt:      loan.DueAt,
+		ReturnedAt: loan.ReturnedAt,
+	}
+}
@@ -0,0 +1,15 @@
+package Model
+
+import "time"
+
+// PasswordResetModel is an outstanding password reset. Only a hash of the
+// mailed token is stored.
+type PasswordResetModel struct {
+	ID        int       `gorm:"primaryKey;autoIncrement"`
+	UserID    int       `gorm:"not null;index"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	CreatedAt time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	// UsedAt is set once the token is consumed or superseded.
+	UsedAt *time.Time
+}
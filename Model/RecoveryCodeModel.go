@@ -0,0 +1,12 @@
+package Model
+
+import "time"
+
+// RecoveryCodeModel is a single-use code that stands in for a TOTP code when
+// the authenticator is lost. Only its hash is stored.
+type RecoveryCodeModel struct {
+	ID       int    `gorm:"primaryKey;autoIncrement"`
+	UserID   int    `gorm:"not null;index"`
+	CodeHash string `gorm:"not null;index"`
+	UsedAt   *time.Time
+}
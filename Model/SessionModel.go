@@ -0,0 +1,26 @@
+package Model
+
+import "time"
+
+// SessionModel records one login. The access token issued for it carries
+// TokenID as its jti, so revoking the session revokes the token.
+type SessionModel struct {
+	ID      int    `gorm:"primaryKey;autoIncrement"`
+	UserID  int    `gorm:"not null;index"`
+	TokenID string `gorm:"uniqueIndex;not null"`
+	// TokenVersion is the user's token version at login; sessions from
+	// before a password change are dead even when not revoked.
+	TokenVersion int       `gorm:"not null;default:0"`
+	UserAgent    string    `gorm:"not null"`
+	IPAddress    string    `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"not null"`
+	LastSeenAt   time.Time `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	RevokedAt    *time.Time
+}
+
+// Active reports whether the session's token is still honoured at now for
+// a user at tokenVersion.
+func (s SessionModel) Active(now time.Time, tokenVersion int) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt) && s.TokenVersion == tokenVersion
+}
@@ -0,0 +1,26 @@
+package Model
+
+import "time"
+
+type SessionResponse struct {
+	ID         int       `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	// Current marks the session of the token making the request.
+	Current bool `json:"current"`
+}
+
+func NewSessionResponse(session SessionModel, currentTokenID string) SessionResponse {
+	return SessionResponse{
+		ID:         session.ID,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.ExpiresAt,
+		Current:    session.TokenID == currentTokenID,
+	}
+}
@@ -0,0 +1,31 @@
+package Model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStorageModelSerialized is returned when a storage model is marshalled
+// to JSON. Storage models may hold secrets such as password hashes, so API
+// responses must always go through one of the response types instead.
+var ErrStorageModelSerialized = errors.New("storage models cannot be serialized; map them to a response type")
+
+func (UserModel) MarshalJSON() ([]byte, error) {
+	return nil, fmt.Errorf("UserModel: %w", ErrStorageModelSerialized)
+}
+
+func (BookModel) MarshalJSON() ([]byte, error) {
+	return nil, fmt.Errorf("BookModel: %w", ErrStorageModelSerialized)
+}
+
+func (BookCopyModel) MarshalJSON() ([]byte, error) {
+	return nil, fmt.Errorf("BookCopyModel: %w", ErrStorageModelSerialized)
+}
+
+func (AuthorModel) MarshalJSON() ([]byte, error) {
+	return nil, fmt.Errorf("AuthorModel: %w", ErrStorageModelSerialized)
+}
+
+func (LoanModel) MarshalJSON() ([]byte, error) {
+	return nil, fmt.Errorf("LoanModel: %w", ErrStorageModelSerialized)
+}
@@ -0,0 +1,30 @@
+package Model
+
+import "strings"
+
+// SubjectModel is a node of the subject and genre taxonomy. Roots have no
+// parent.
+type SubjectModel struct {
+	ID       int    `gorm:"primaryKey;autoIncrement"`
+	ParentID *int   `gorm:"index"`
+	Name     string `gorm:"not null"`
+}
+
+// BookSubjectModel files a book under a subject.
+type BookSubjectModel struct {
+	BookID    int `gorm:"primaryKey"`
+	SubjectID int `gorm:"primaryKey;index"`
+}
+
+// BookTagModel attaches a free-form tag, normalized by NormalizeTag, to a
+// book.
+type BookTagModel struct {
+	BookID int    `gorm:"primaryKey"`
+	Tag    string `gorm:"primaryKey;index"`
+}
+
+// NormalizeTag lower-cases tag and collapses its whitespace, so "Space
+// Opera" and " space  opera" are one tag.
+func NormalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+}
@@ -0,0 +1,18 @@
+package Model
+
+// SubjectRequest creates a subject or renames and moves one. ParentID nil
+// makes it a root.
+type SubjectRequest struct {
+	Name     string `json:"name" validate:"required,notblank,max=100"`
+	ParentID *int   `json:"parentId" validate:"omitempty,min=1"`
+}
+
+// SetBookSubjectsRequest replaces the subjects a book is filed under.
+type SetBookSubjectsRequest struct {
+	SubjectIDs []int `json:"subjectIds" validate:"max=20,dive,min=1"`
+}
+
+// SetBookTagsRequest replaces the tags of a book.
+type SetBookTagsRequest struct {
+	Tags []string `json:"tags" validate:"max=20,dive,notblank,max=50"`
+}
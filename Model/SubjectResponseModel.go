@@ -0,0 +1,23 @@
+package Model
+
+type SubjectResponse struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	ParentID *int   `json:"parentId"`
+	// Path names the subject and its ancestors, root first, as in
+	// "Fantasy > High Fantasy".
+	Path string `json:"path"`
+}
+
+// SubjectTreeResponse is a subject with everything below it.
+type SubjectTreeResponse struct {
+	ID       int                   `json:"id"`
+	Name     string                `json:"name"`
+	Children []SubjectTreeResponse `json:"children"`
+}
+
+type TagResponse struct {
+	Tag string `json:"tag"`
+	// Books counts the books carrying the tag.
+	Books int `json:"books"`
+}
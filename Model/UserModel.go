@@ -1,8 +1,44 @@
 package Model
 
+import "time"
+
+const (
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+)
+
 type UserModel struct {
-	UserId   int    `json:"userId" gorm:"primaryKey;autoIncrement"`
-	UserName string `json:"userName" gorm:"not null"`
-	Email    string `json:"email" gorm:"unique;not null"`
-	Password string `json:"password" gorm:"unique;not null"`
+	UserId   int    `gorm:"primaryKey;autoIncrement"`
+	UserName string `gorm:"not null"`
+	Email    string `gorm:"unique;not null"`
+	// Password holds the bcrypt hash, never the password itself.
+	Password string `gorm:"not null"`
+	// Role is baked into issued tokens and trusted until they expire, so
+	// whatever changes it must bump TokenVersion too for the change to
+	// take effect.
+	Role string `gorm:"not null;default:member"`
+	// TokenVersion is embedded in issued tokens; bumping it revokes every
+	// token issued before.
+	TokenVersion int `gorm:"not null;default:0"`
+	// EmailVerifiedAt is nil until the user follows the link mailed to Email.
+	EmailVerifiedAt *time.Time
+	// PendingEmail is the address the user asked to move to. Email stays in
+	// use until the link mailed to PendingEmail is followed.
+	PendingEmail string
+
+	// TOTPSecret is the base32 authenticator secret. It is set at enrollment
+	// but only enforced once TOTPEnabledAt is set by the confirmation step.
+	TOTPSecret    string
+	TOTPEnabledAt *time.Time
+	// TOTPLastStep is the time step of the last accepted code, so a code
+	// cannot be used twice.
+	TOTPLastStep int64 `gorm:"not null;default:0"`
+}
+
+func (u UserModel) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u UserModel) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
 }
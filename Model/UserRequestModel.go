@@ -0,0 +1,76 @@
+package Model
+
+type RegisterRequest struct {
+	UserName string `json:"userName" validate:"notblank,max=50"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// UpdateProfileRequest changes only the fields that are present.
+type UpdateProfileRequest struct {
+	UserName *string `json:"userName" validate:"omitempty,notblank,max=50"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required,password"`
+}
+
+// ChangePasswordRequest, like the other requests confirming a sensitive
+// change, needs the password only from accounts that have one. Accounts
+// provisioned through single sign-on confirm with a recent login or a
+// two-factor code instead.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	Code            string `json:"code,omitempty" validate:"max=32"`
+	NewPassword     string `json:"newPassword" validate:"required,password"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"newEmail" validate:"required,email,max=254"`
+	Password string `json:"password"`
+	Code     string `json:"code,omitempty" validate:"max=32"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code,omitempty" validate:"max=32"`
+}
+
+// TwoFactorLoginRequest completes a login with the second factor: a code from
+// the authenticator or one of the recovery codes.
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required,max=32"`
+}
+
+type EnrollTwoFactorRequest struct {
+	Password string `json:"password"`
+}
+
+type ConfirmTwoFactorRequest struct {
+	Code string `json:"code" validate:"required,numeric,len=6"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+type RegenerateRecoveryCodesRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code,omitempty" validate:"max=32"`
+}
@@ -0,0 +1,76 @@
+package Model
+
+type UserResponse struct {
+	UserId   int    `json:"userId"`
+	UserName string `json:"userName"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	// EmailVerified is false until the address is confirmed; unverified users
+	// can neither log in nor borrow.
+	EmailVerified bool `json:"emailVerified"`
+	// PendingEmail is the address the account moves to once the link mailed
+	// there is followed.
+	PendingEmail string `json:"pendingEmail,omitempty"`
+	// TwoFactorEnabled is true once an authenticator has been confirmed;
+	// logging in then takes a code besides the password.
+	TwoFactorEnabled bool `json:"twoFactorEnabled"`
+}
+
+// ProfileResponse is what a user sees of their own account.
+type ProfileResponse struct {
+	UserResponse
+	Loans []LoanResponse `json:"loans"`
+}
+
+// TokenResponse carries a freshly issued JWT.
+type TokenResponse struct {
+	Token string `json:"token"`
+}
+
+// LoginResponse answers a correct password. Accounts with two-factor
+// authentication get a challenge token to redeem at /login/2fa with a code
+// instead of the JWT.
+type LoginResponse struct {
+	Token             string `json:"token,omitempty"`
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	ChallengeToken    string `json:"challengeToken,omitempty"`
+}
+
+// TwoFactorEnrollmentResponse carries a new authenticator secret, both as
+// text and as the otpauth:// URI rendered into a QR code image.
+type TwoFactorEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+	// QRCode is a data: URI of a PNG image.
+	QRCode string `json:"qrCode"`
+}
+
+// RecoveryCodesResponse lists freshly issued recovery codes. They are shown
+// this once; only their hashes are kept.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+func NewProfileResponse(user UserModel, loans []LoanModel) ProfileResponse {
+	response := ProfileResponse{
+		UserResponse: NewUserResponse(user),
+		Loans:        make([]LoanResponse, 0, len(loans)),
+	}
+	for _, loan := range loans {
+		response.Loans = append(response.Loans, NewLoanResponse(loan))
+	}
+	return response
+}
+
+func NewUserResponse(user UserModel) UserResponse {
+	return UserResponse{
+		UserId:   user.UserId,
+		UserName: user.UserName,
+		Email:    user.Email,
+		Role:     user.Role,
+
+		EmailVerified:    user.EmailVerified(),
+		PendingEmail:     user.PendingEmail,
+		TwoFactorEnabled: user.TwoFactorEnabled(),
+	}
+}
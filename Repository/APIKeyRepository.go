@@ -0,0 +1,55 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type APIKeyRepository interface {
+	FindAll(ctx context.Context) ([]Model.APIKeyModel, error)
+	FindByID(ctx context.Context, id int) (Model.APIKeyModel, error)
+	FindByPrefix(ctx context.Context, prefix string) (Model.APIKeyModel, error)
+	Create(ctx context.Context, key *Model.APIKeyModel) error
+	Save(ctx context.Context, key *Model.APIKeyModel) error
+	// Touch sets the LastUsedAt of key id to at, unless the key has been
+	// revoked. Only that column is written, so it cannot undo a revocation
+	// that raced with it.
+	Touch(ctx context.Context, id int, at time.Time) error
+}
+
+type gormAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAPIKeyRepository) FindAll(ctx context.Context) ([]Model.APIKeyModel, error) {
+	var keys []Model.APIKeyModel
+	err := r.db.WithContext(ctx).Order("id").Find(&keys).Error
+	return keys, translateError(err)
+}
+
+func (r *gormAPIKeyRepository) FindByID(ctx context.Context, id int) (Model.APIKeyModel, error) {
+	var key Model.APIKeyModel
+	err := r.db.WithContext(ctx).First(&key, id).Error
+	return key, translateError(err)
+}
+
+func (r *gormAPIKeyRepository) FindByPrefix(ctx context.Context, prefix string) (Model.APIKeyModel, error) {
+	var key Model.APIKeyModel
+	err := r.db.WithContext(ctx).Where("prefix = ?", prefix).First(&key).Error
+	return key, translateError(err)
+}
+
+func (r *gormAPIKeyRepository) Create(ctx context.Context, key *Model.APIKeyModel) error {
+	return translateError(r.db.WithContext(ctx).Create(key).Error)
+}
+
+func (r *gormAPIKeyRepository) Save(ctx context.Context, key *Model.APIKeyModel) error {
+	return translateError(r.db.WithContext(ctx).Save(key).Error)
+}
+
+func (r *gormAPIKeyRepository) Touch(ctx context.Context, id int, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&Model.APIKeyModel{}).Where("id = ? AND revoked_at IS NULL", id).UpdateColumn("last_used_at", at).Error
+	return translateError(err)
+}
@@ -0,0 +1,115 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"gorm.io/gorm"
+)
+
+type AuthorRepository interface {
+	// FindAll lists authors by name.
+	FindAll(ctx context.Context) ([]Model.AuthorModel, error)
+	FindByID(ctx context.Context, id int) (Model.AuthorModel, error)
+	FindByIDs(ctx context.Context, ids []int) ([]Model.AuthorModel, error)
+	// FindByNameKey lists the authors one of whose names has key, by ID,
+	// see Model.AuthorNameKey.
+	FindByNameKey(ctx context.Context, key string) ([]Model.AuthorModel, error)
+	Create(ctx context.Context, author *Model.AuthorModel) error
+	Save(ctx context.Context, author *Model.AuthorModel) error
+
+	// Names lists the names of authorID in the order they were added.
+	Names(ctx context.Context, authorID int) ([]Model.AuthorNameModel, error)
+	// ReplaceNames swaps the names of authorID for names.
+	ReplaceNames(ctx context.Context, authorID int, names []Model.AuthorNameModel) error
+
+	// CreditsOfBook lists the credits of bookID by position.
+	CreditsOfBook(ctx context.Context, bookID int) ([]Model.BookAuthorModel, error)
+	// CreditsOfAuthor lists the credits of authorID by book.
+	CreditsOfAuthor(ctx context.Context, authorID int) ([]Model.BookAuthorModel, error)
+	ReplaceCredits(ctx context.Context, bookID int, credits []Model.BookAuthorModel) error
+}
+
+type gormAuthorRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAuthorRepository) FindAll(ctx context.Context) ([]Model.AuthorModel, error) {
+	var authors []Model.AuthorModel
+	err := r.db.WithContext(ctx).Order("name, id").Find(&authors).Error
+	return authors, translateError(err)
+}
+
+func (r *gormAuthorRepository) FindByID(ctx context.Context, id int) (Model.AuthorModel, error) {
+	var author Model.AuthorModel
+	err := r.db.WithContext(ctx).First(&author, id).Error
+	return author, translateError(err)
+}
+
+func (r *gormAuthorRepository) FindByIDs(ctx context.Context, ids []int) ([]Model.AuthorModel, error) {
+	var authors []Model.AuthorModel
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Order("id").Find(&authors).Error
+	return authors, translateError(err)
+}
+
+func (r *gormAuthorRepository) FindByNameKey(ctx context.Context, key string) ([]Model.AuthorModel, error) {
+	var authors []Model.AuthorModel
+	named := r.db.WithContext(ctx).Model(&Model.AuthorNameModel{}).Select("author_id").Where("key = ?", key)
+	err := r.db.WithContext(ctx).Where("id IN (?)", named).Order("id").Find(&authors).Error
+	return authors, translateError(err)
+}
+
+func (r *gormAuthorRepository) Create(ctx context.Context, author *Model.AuthorModel) error {
+	return translateError(r.db.WithContext(ctx).Create(author).Error)
+}
+
+func (r *gormAuthorRepository) Save(ctx context.Context, author *Model.AuthorModel) error {
+	return translateError(r.db.WithContext(ctx).Save(author).Error)
+}
+
+func (r *gormAuthorRepository) Names(ctx context.Context, authorID int) ([]Model.AuthorNameModel, error) {
+	var names []Model.AuthorNameModel
+	err := r.db.WithContext(ctx).Where("author_id = ?", authorID).Order("id").Find(&names).Error
+	return names, translateError(err)
+}
+
+func (r *gormAuthorRepository) ReplaceNames(ctx context.Context, authorID int, names []Model.AuthorNameModel) error {
+	return translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("author_id = ?", authorID).Delete(&Model.AuthorNameModel{}).Error; err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return nil
+		}
+		for i := range names {
+			names[i].AuthorID = authorID
+		}
+		return tx.Create(&names).Error
+	}))
+}
+
+func (r *gormAuthorRepository) CreditsOfBook(ctx context.Context, bookID int) ([]Model.BookAuthorModel, error) {
+	var credits []Model.BookAuthorModel
+	err := r.db.WithContext(ctx).Where("book_id = ?", bookID).Order("position").Find(&credits).Error
+	return credits, translateError(err)
+}
+
+func (r *gormAuthorRepository) CreditsOfAuthor(ctx context.Context, authorID int) ([]Model.BookAuthorModel, error) {
+	var credits []Model.BookAuthorModel
+	err := r.db.WithContext(ctx).Where("author_id = ?", authorID).Order("book_id, position").Find(&credits).Error
+	return credits, translateError(err)
+}
+
+func (r *gormAuthorRepository) ReplaceCredits(ctx context.Context, bookID int, credits []Model.BookAuthorModel) error {
+	return translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("book_id = ?", bookID).Delete(&Model.BookAuthorModel{}).Error; err != nil {
+			return err
+		}
+		if len(credits) == 0 {
+			return nil
+		}
+		for i := range credits {
+			credits[i].BookID = bookID
+		}
+		return tx.Create(&credits).Error
+	}))
+}
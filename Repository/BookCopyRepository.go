@@ -0,0 +1,72 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"gorm.io/gorm"
+)
+
+type BookCopyRepository interface {
+	FindByID(ctx context.Context, id int) (Model.BookCopyModel, error)
+	// FindByBook lists every copy of bookID, withdrawn ones included, by ID.
+	FindByBook(ctx context.Context, bookID int) ([]Model.BookCopyModel, error)
+	// FindAvailable returns the available copy of bookID with the lowest ID.
+	FindAvailable(ctx context.Context, bookID int) (Model.BookCopyModel, error)
+	// Availability counts the copies of the given books; books without
+	// copies are left out of the result.
+	Availability(ctx context.Context, bookIDs []int) (map[int]Model.BookAvailability, error)
+	Create(ctx context.Context, item *Model.BookCopyModel) error
+	Save(ctx context.Context, item *Model.BookCopyModel) error
+}
+
+type gormBookCopyRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormBookCopyRepository) FindByID(ctx context.Context, id int) (Model.BookCopyModel, error) {
+	var item Model.BookCopyModel
+	err := r.db.WithContext(ctx).First(&item, id).Error
+	return item, translateError(err)
+}
+
+func (r *gormBookCopyRepository) FindByBook(ctx context.Context, bookID int) ([]Model.BookCopyModel, error) {
+	var items []Model.BookCopyModel
+	err := r.db.WithContext(ctx).Where("book_id = ?", bookID).Order("id").Find(&items).Error
+	return items, translateError(err)
+}
+
+func (r *gormBookCopyRepository) FindAvailable(ctx context.Context, bookID int) (Model.BookCopyModel, error) {
+	var item Model.BookCopyModel
+	err := r.db.WithContext(ctx).Where("book_id = ? AND status = ?", bookID, Model.CopyAvailable).Order("id").First(&item).Error
+	return item, translateError(err)
+}
+
+func (r *gormBookCopyRepository) Availability(ctx context.Context, bookIDs []int) (map[int]Model.BookAvailability, error) {
+	var rows []struct {
+		BookID    int
+		Copies    int
+		Available int
+	}
+	err := r.db.WithContext(ctx).Model(&Model.BookCopyModel{}).
+		Select("book_id, COUNT(*) AS copies, SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS available", Model.CopyAvailable).
+		Where("book_id IN ? AND status <> ?", bookIDs, Model.CopyWithdrawn).
+		Group("book_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	availability := make(map[int]Model.BookAvailability, len(rows))
+	for _, row := range rows {
+		availability[row.BookID] = Model.BookAvailability{Copies: row.Copies, Available: row.Available}
+	}
+	return availability, nil
+}
+
+func (r *gormBookCopyRepository) Create(ctx context.Context, item *Model.BookCopyModel) error {
+	return translateError(r.db.WithContext(ctx).Create(item).Error)
+}
+
+func (r *gormBookCopyRepository) Save(ctx context.Context, item *Model.BookCopyModel) error {
+	return translateError(r.db.WithContext(ctx).Save(item).Error)
+}
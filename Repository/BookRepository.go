@@ -0,0 +1,91 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+)
+
+type BookRepository interface {
+	FindAll(ctx context.Context) ([]Model.BookModel, error)
+	FindByID(ctx context.Context, id int) (Model.BookModel, error)
+	// FindByIDs returns the books among ids that exist, by ID.
+	FindByIDs(ctx context.Context, ids []int) ([]Model.BookModel, error)
+	// FindUncredited lists the books without any author credits, by ID.
+	FindUncredited(ctx context.Context) ([]Model.BookModel, error)
+	// FindByISBN expects an ISBN-13 as produced by Model.ParseISBN.
+	FindByISBN(ctx context.Context, isbn13 string) (Model.BookModel, error)
+	// FindBySlug matches the current slugs first, then the ones books used
+	// before being renamed.
+	FindBySlug(ctx context.Context, slug string) (Model.BookModel, error)
+	Create(ctx context.Context, book *Model.BookModel) error
+	Save(ctx context.Context, book *Model.BookModel) error
+	// SetCover sets the cover version of book id, "" for none, failing with
+	// ErrNotFound when the book is gone. Only that column is written, so
+	// edits made to the book meanwhile are kept.
+	SetCover(ctx context.Context, id int, version string) error
+}
+
+type gormBookRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormBookRepository) FindAll(ctx context.Context) ([]Model.BookModel, error) {
+	var books []Model.BookModel
+	err := r.db.WithContext(ctx).Order("id").Find(&books).Error
+	return books, translateError(err)
+}
+
+func (r *gormBookRepository) FindByID(ctx context.Context, id int) (Model.BookModel, error) {
+	var book Model.BookModel
+	err := r.db.WithContext(ctx).First(&book, id).Error
+	return book, translateError(err)
+}
+
+func (r *gormBookRepository) FindByIDs(ctx context.Context, ids []int) ([]Model.BookModel, error) {
+	var books []Model.BookModel
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Order("id").Find(&books).Error
+	return books, translateError(err)
+}
+
+func (r *gormBookRepository) FindUncredited(ctx context.Context) ([]Model.BookModel, error) {
+	var books []Model.BookModel
+	credited := r.db.WithContext(ctx).Model(&Model.BookAuthorModel{}).Select("book_id")
+	err := r.db.WithContext(ctx).Where("id NOT IN (?)", credited).Order("id").Find(&books).Error
+	return books, translateError(err)
+}
+
+func (r *gormBookRepository) FindByISBN(ctx context.Context, isbn13 string) (Model.BookModel, error) {
+	var book Model.BookModel
+	err := r.db.WithContext(ctx).Where("isbn13 = ?", isbn13).First(&book).Error
+	return book, translateError(err)
+}
+
+func (r *gormBookRepository) FindBySlug(ctx context.Context, slug string) (Model.BookModel, error) {
+	var book Model.BookModel
+	err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&book).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return book, translateError(err)
+	}
+
+	former := r.db.WithContext(ctx).Model(&Model.BookSlugModel{}).Select("book_id").Where("slug = ?", slug)
+	err = r.db.WithContext(ctx).Where("id = (?)", former).First(&book).Error
+	return book, translateError(err)
+}
+
+func (r *gormBookRepository) Create(ctx context.Context, book *Model.BookModel) error {
+	return translateError(r.db.WithContext(ctx).Create(book).Error)
+}
+
+func (r *gormBookRepository) Save(ctx context.Context, book *Model.BookModel) error {
+	return translateError(r.db.WithContext(ctx).Save(book).Error)
+}
+
+func (r *gormBookRepository) SetCover(ctx context.Context, id int, version string) error {
+	result := r.db.WithContext(ctx).Model(&Model.BookModel{}).Where("id = ?", id).UpdateColumn("cover", version)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return translateError(result.Error)
+}
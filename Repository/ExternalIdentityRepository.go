@@ -0,0 +1,31 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"gorm.io/gorm"
+)
+
+type ExternalIdentityRepository interface {
+	Find(ctx context.Context, issuer, subject string) (Model.ExternalIdentityModel, error)
+	Create(ctx context.Context, identity *Model.ExternalIdentityModel) error
+	DeleteForUser(ctx context.Context, userID int) error
+}
+
+type gormExternalIdentityRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormExternalIdentityRepository) Find(ctx context.Context, issuer, subject string) (Model.ExternalIdentityModel, error) {
+	var identity Model.ExternalIdentityModel
+	err := r.db.WithContext(ctx).Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+	return identity, translateError(err)
+}
+
+func (r *gormExternalIdentityRepository) Create(ctx context.Context, identity *Model.ExternalIdentityModel) error {
+	return translateError(r.db.WithContext(ctx).Create(identity).Error)
+}
+
+func (r *gormExternalIdentityRepository) DeleteForUser(ctx context.Context, userID int) error {
+	return translateError(r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&Model.ExternalIdentityModel{}).Error)
+}
@@ -0,0 +1,55 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"gorm.io/gorm"
+)
+
+type ImportJobRepository interface {
+	FindByID(ctx context.Context, id int) (Model.ImportJobModel, error)
+	// FindRecent returns the latest limit jobs, newest first.
+	FindRecent(ctx context.Context, limit int) ([]Model.ImportJobModel, error)
+	Create(ctx context.Context, job *Model.ImportJobModel) error
+	Save(ctx context.Context, job *Model.ImportJobModel) error
+	AddErrors(ctx context.Context, errors []Model.ImportErrorModel) error
+	// Errors returns the row errors of jobID in file order.
+	Errors(ctx context.Context, jobID int) ([]Model.ImportErrorModel, error)
+}
+
+type gormImportJobRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormImportJobRepository) FindByID(ctx context.Context, id int) (Model.ImportJobModel, error) {
+	var job Model.ImportJobModel
+	err := r.db.WithContext(ctx).First(&job, id).Error
+	return job, translateError(err)
+}
+
+func (r *gormImportJobRepository) FindRecent(ctx context.Context, limit int) ([]Model.ImportJobModel, error) {
+	var jobs []Model.ImportJobModel
+	err := r.db.WithContext(ctx).Order("id DESC").Limit(limit).Find(&jobs).Error
+	return jobs, translateError(err)
+}
+
+func (r *gormImportJobRepository) Create(ctx context.Context, job *Model.ImportJobModel) error {
+	return translateError(r.db.WithContext(ctx).Create(job).Error)
+}
+
+func (r *gormImportJobRepository) Save(ctx context.Context, job *Model.ImportJobModel) error {
+	return translateError(r.db.WithContext(ctx).Save(job).Error)
+}
+
+func (r *gormImportJobRepository) AddErrors(ctx context.Context, errors []Model.ImportErrorModel) error {
+	if len(errors) == 0 {
+		return nil
+	}
+	return translateError(r.db.WithContext(ctx).CreateInBatches(errors, 500).Error)
+}
+
+func (r *gormImportJobRepository) Errors(ctx context.Context, jobID int) ([]Model.ImportErrorModel, error) {
+	var errors []Model.ImportErrorModel
+	err := r.db.WithContext(ctx).Where("job_id = ?", jobID).Order("row, id").Find(&errors).Error
+	return errors, translateError(err)
+}
@@ -0,0 +1,51 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type LoanRepository interface {
+	// FindOpenByUser lists the loans of userID that have not been returned
+	// yet, oldest first.
+	FindOpenByUser(ctx context.Context, userID int) ([]Model.LoanModel, error)
+	Create(ctx context.Context, loan *Model.LoanModel) error
+	Save(ctx context.Context, loan *Model.LoanModel) error
+	CountOpen(ctx context.Context) (int64, error)
+	// CountOverdue counts open loans whose due date is before now.
+	CountOverdue(ctx context.Context, now time.Time) (int64, error)
+}
+
+type gormLoanRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormLoanRepository) FindOpenByUser(ctx context.Context, userID int) ([]Model.LoanModel, error) {
+	var loans []Model.LoanModel
+	err := r.db.WithContext(ctx).Where("user_id = ? AND returned_at IS NULL", userID).Order("borrowed_at, id").Find(&loans).Error
+	return loans, translateError(err)
+}
+
+func (r *gormLoanRepository) Create(ctx context.Context, loan *Model.LoanModel) error {
+	return translateError(r.db.WithContext(ctx).Create(loan).Error)
+}
+
+func (r *gormLoanRepository) Save(ctx context.Context, loan *Model.LoanModel) error {
+	return translateError(r.db.WithContext(ctx).Save(loan).Error)
+}
+
+func (r *gormLoanRepository) CountOpen(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Model.LoanModel{}).Where("returned_at IS NULL").Count(&count).Error
+	return count, err
+}
+
+func (r *gormLoanRepository) CountOverdue(ctx context.Context, now time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Model.LoanModel{}).
+		Where("returned_at IS NULL AND due_at < ?", now).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,1552 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryData holds the tables of an in-memory Store.
+type memoryData struct {
+	books map[int]Model.BookModel
+	// formerSlugs maps slugs books no longer use to their book IDs.
+	formerSlugs map[string]int
+	copies      map[int]Model.BookCopyModel
+	authors     map[int]Model.AuthorModel
+	authorNames map[int]Model.AuthorNameModel
+	// credits is keyed by book ID and kept in position order.
+	credits map[int][]Model.BookAuthorModel
+	// bookSubjects and bookTags are keyed by book ID.
+	subjects     map[int]Model.SubjectModel
+	bookSubjects map[int][]int
+	bookTags     map[int][]string
+	users        map[int]Model.UserModel
+	loans        map[int]Model.LoanModel
+	// passwordResets is keyed by token hash.
+	passwordResets map[string]Model.PasswordResetModel
+	recoveryCodes  map[int]Model.RecoveryCodeModel
+	// externalIdentities is keyed by issuer and subject.
+	externalIdentities map[externalIdentityKey]Model.ExternalIdentityModel
+	apiKeys            map[int]Model.APIKeyModel
+	sessions           map[int]Model.SessionModel
+	importJobs         map[int]Model.ImportJobModel
+	importErrors       map[int]Model.ImportErrorModel
+
+	nextBookID             int
+	nextCopyID             int
+	nextAuthorID           int
+	nextAuthorNameID       int
+	nextSubjectID          int
+	nextUserID             int
+	nextLoanID             int
+	nextPasswordResetID    int
+	nextRecoveryCodeID     int
+	nextExternalIdentityID int
+	nextAPIKeyID           int
+	nextSessionID          int
+	nextImportJobID        int
+	nextImportErrorID      int
+}
+
+type externalIdentityKey struct {
+	issuer, subject string
+}
+
+func (d *memoryData) clone() *memoryData {
+	copied := *d
+	copied.books = maps.Clone(d.books)
+	copied.formerSlugs = maps.Clone(d.formerSlugs)
+	copied.copies = maps.Clone(d.copies)
+	copied.authors = maps.Clone(d.authors)
+	copied.authorNames = maps.Clone(d.authorNames)
+	copied.credits = maps.Clone(d.credits)
+	copied.subjects = maps.Clone(d.subjects)
+	copied.bookSubjects = maps.Clone(d.bookSubjects)
+	copied.bookTags = maps.Clone(d.bookTags)
+	copied.users = maps.Clone(d.users)
+	copied.loans = maps.Clone(d.loans)
+	copied.passwordResets = maps.Clone(d.passwordResets)
+	copied.recoveryCodes = maps.Clone(d.recoveryCodes)
+	copied.externalIdentities = maps.Clone(d.externalIdentities)
+	copied.apiKeys = maps.Clone(d.apiKeys)
+	copied.sessions = maps.Clone(d.sessions)
+	copied.importJobs = maps.Clone(d.importJobs)
+	copied.importErrors = maps.Clone(d.importErrors)
+	return &copied
+}
+
+type memoryStore struct {
+	mu   *sync.Mutex
+	data *memoryData
+	// inTx is set on the Store handed to a Transaction callback, which
+	// already holds mu.
+	inTx bool
+}
+
+// NewMemoryStore returns an empty Store kept entirely in memory, for tests
+// and for running without a database.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		mu: &sync.Mutex{},
+		data: &memoryData{
+			books:       map[int]Model.BookModel{},
+			formerSlugs: map[string]int{},
+			copies:      map[int]Model.BookCopyModel{},
+			authors:     map[int]Model.AuthorModel{},
+			authorNames: map[int]Model.AuthorNameModel{},
+			credits:     map[int][]Model.BookAuthorModel{},
+
+			subjects:     map[int]Model.SubjectModel{},
+			bookSubjects: map[int][]int{},
+			bookTags:     map[int][]string{},
+			users:        map[int]Model.UserModel{},
+			loans:        map[int]Model.LoanModel{},
+
+			passwordResets: map[string]Model.PasswordResetModel{},
+			recoveryCodes:  map[int]Model.RecoveryCodeModel{},
+
+			externalIdentities: map[externalIdentityKey]Model.ExternalIdentityModel{},
+			apiKeys:            map[int]Model.APIKeyModel{},
+			sessions:           map[int]Model.SessionModel{},
+			importJobs:         map[int]Model.ImportJobModel{},
+			importErrors:       map[int]Model.ImportErrorModel{},
+		},
+	}
+}
+
+func (s *memoryStore) Books() BookRepository {
+	return &memoryBookRepository{store: s}
+}
+
+func (s *memoryStore) Copies() BookCopyRepository {
+	return &memoryBookCopyRepository{store: s}
+}
+
+func (s *memoryStore) Authors() AuthorRepository {
+	return &memoryAuthorRepository{store: s}
+}
+
+func (s *memoryStore) Subjects() SubjectRepository {
+	return &memorySubjectRepository{store: s}
+}
+
+func (s *memoryStore) Tags() TagRepository {
+	return &memoryTagRepository{store: s}
+}
+
+func (s *memoryStore) Users() UserRepository {
+	return &memoryUserRepository{store: s}
+}
+
+func (s *memoryStore) Loans() LoanRepository {
+	return &memoryLoanRepository{store: s}
+}
+
+func (s *memoryStore) PasswordResets() PasswordResetRepository {
+	return &memoryPasswordResetRepository{store: s}
+}
+
+func (s *memoryStore) RecoveryCodes() RecoveryCodeRepository {
+	return &memoryRecoveryCodeRepository{store: s}
+}
+
+func (s *memoryStore) ExternalIdentities() ExternalIdentityRepository {
+	return &memoryExternalIdentityRepository{store: s}
+}
+
+func (s *memoryStore) APIKeys() APIKeyRepository {
+	return &memoryAPIKeyRepository{store: s}
+}
+
+func (s *memoryStore) Sessions() SessionRepository {
+	return &memorySessionRepository{store: s}
+}
+
+func (s *memoryStore) ImportJobs() ImportJobRepository {
+	return &memoryImportJobRepository{store: s}
+}
+
+func (s *memoryStore) Transaction(ctx context.Context, fn func(Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	snapshot := s.data.clone()
+	if err := fn(&memoryStore{mu: s.mu, data: s.data, inTx: true}); err != nil {
+		*s.data = *snapshot
+		return err
+	}
+	return nil
+}
+
+// access locks the store unless it is already inside a transaction and fails
+// fast when ctx is done, mirroring how database calls behave.
+func (s *memoryStore) access(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.inTx {
+		return func() {}, nil
+	}
+	s.mu.Lock()
+	return s.mu.Unlock, nil
+}
+
+type memoryBookRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryBookRepository) FindAll(ctx context.Context) ([]Model.BookModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	books := slices.Collect(maps.Values(r.store.data.books))
+	slices.SortFunc(books, func(a, b Model.BookModel) int { return a.ID - b.ID })
+	return books, nil
+}
+
+func (r *memoryBookRepository) FindByID(ctx context.Context, id int) (Model.BookModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.BookModel{}, err
+	}
+	defer unlock()
+
+	book, ok := r.store.data.books[id]
+	if !ok {
+		return Model.BookModel{}, ErrNotFound
+	}
+	return book, nil
+}
+
+func (r *memoryBookRepository) FindByIDs(ctx context.Context, ids []int) ([]Model.BookModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var books []Model.BookModel
+	for _, id := range slices.Sorted(slices.Values(ids)) {
+		if book, ok := r.store.data.books[id]; ok && !slices.ContainsFunc(books, func(b Model.BookModel) bool { return b.ID == id }) {
+			books = append(books, book)
+		}
+	}
+	return books, nil
+}
+
+func (r *memoryBookRepository) FindUncredited(ctx context.Context) ([]Model.BookModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var books []Model.BookModel
+	for id, book := range r.store.data.books {
+		if len(r.store.data.credits[id]) == 0 {
+			books = append(books, book)
+		}
+	}
+	slices.SortFunc(books, func(a, b Model.BookModel) int { return a.ID - b.ID })
+	return books, nil
+}
+
+func (r *memoryBookRepository) FindByISBN(ctx context.Context, isbn13 string) (Model.BookModel, error) {
+	return r.find(ctx, func(book Model.BookModel) bool { return book.ISBN13 == isbn13 })
+}
+
+func (r *memoryBookRepository) FindBySlug(ctx context.Context, slug string) (Model.BookModel, error) {
+	book, err := r.find(ctx, func(book Model.BookModel) bool { return book.Slug == slug })
+	if err != ErrNotFound {
+		return book, err
+	}
+
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.BookModel{}, err
+	}
+	defer unlock()
+
+	book, ok := r.store.data.books[r.store.data.formerSlugs[slug]]
+	if !ok {
+		return Model.BookModel{}, ErrNotFound
+	}
+	return book, nil
+}
+
+func (r *memoryBookRepository) find(ctx context.Context, match func(Model.BookModel) bool) (Model.BookModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.BookModel{}, err
+	}
+	defer unlock()
+
+	for _, book := range r.store.data.books {
+		if match(book) {
+			return book, nil
+		}
+	}
+	return Model.BookModel{}, ErrNotFound
+}
+
+func (r *memoryBookRepository) Create(ctx context.Context, book *Model.BookModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if book.ID == 0 {
+		r.store.data.nextBookID++
+		book.ID = r.store.data.nextBookID
+	} else if _, exists := r.store.data.books[book.ID]; exists {
+		return ErrDuplicate
+	}
+	if r.isbnTaken(*book) {
+		return ErrDuplicate
+	}
+	r.store.data.nextBookID = max(r.store.data.nextBookID, book.ID)
+	if _, err := Model.AssignSlug(book, r.slugTaken(book.ID)); err != nil {
+		return err
+	}
+	r.store.data.books[book.ID] = *book
+	return nil
+}
+
+func (r *memoryBookRepository) Save(ctx context.Context, book *Model.BookModel) error {
+	if book.ID == 0 {
+		return r.Create(ctx, book)
+	}
+
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if r.isbnTaken(*book) {
+		return ErrDuplicate
+	}
+	r.store.data.nextBookID = max(r.store.data.nextBookID, book.ID)
+	previous, err := Model.AssignSlug(book, r.slugTaken(book.ID))
+	if err != nil {
+		return err
+	}
+	if previous != "" {
+		r.store.data.formerSlugs[previous] = book.ID
+	}
+	r.store.data.books[book.ID] = *book
+	return nil
+}
+
+func (r *memoryBookRepository) SetCover(ctx context.Context, id int, version string) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	book, ok := r.store.data.books[id]
+	if !ok {
+		return ErrNotFound
+	}
+	book.Cover = version
+	r.store.data.books[id] = book
+	return nil
+}
+
+// isbnTaken reports whether another book has book's ISBN-13, mirroring the
+// unique index of the gorm store.
+func (r *memoryBookRepository) isbnTaken(book Model.BookModel) bool {
+	for _, other := range r.store.data.books {
+		if book.ISBN13 != "" && other.ID != book.ID && other.ISBN13 == book.ISBN13 {
+			return true
+		}
+	}
+	return false
+}
+
+// slugTaken mirrors the uniqueness checks of Model.BookModel's save hook.
+func (r *memoryBookRepository) slugTaken(bookID int) func(string) (bool, error) {
+	return func(slug string) (bool, error) {
+		for id, book := range r.store.data.books {
+			if id != bookID && book.Slug == slug {
+				return true, nil
+			}
+		}
+		owner, former := r.store.data.formerSlugs[slug]
+		return former && owner != bookID, nil
+	}
+}
+
+type memoryBookCopyRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryBookCopyRepository) FindByID(ctx context.Context, id int) (Model.BookCopyModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.BookCopyModel{}, err
+	}
+	defer unlock()
+
+	item, ok := r.store.data.copies[id]
+	if !ok {
+		return Model.BookCopyModel{}, ErrNotFound
+	}
+	return item, nil
+}
+
+func (r *memoryBookCopyRepository) FindByBook(ctx context.Context, bookID int) ([]Model.BookCopyModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var items []Model.BookCopyModel
+	for _, item := range r.store.data.copies {
+		if item.BookID == bookID {
+			items = append(items, item)
+		}
+	}
+	slices.SortFunc(items, func(a, b Model.BookCopyModel) int { return a.ID - b.ID })
+	return items, nil
+}
+
+func (r *memoryBookCopyRepository) FindAvailable(ctx context.Context, bookID int) (Model.BookCopyModel, error) {
+	items, err := r.FindByBook(ctx, bookID)
+	if err != nil {
+		return Model.BookCopyModel{}, err
+	}
+	for _, item := range items {
+		if item.Status == Model.CopyAvailable {
+			return item, nil
+		}
+	}
+	return Model.BookCopyModel{}, ErrNotFound
+}
+
+func (r *memoryBookCopyRepository) Availability(ctx context.Context, bookIDs []int) (map[int]Model.BookAvailability, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	availability := map[int]Model.BookAvailability{}
+	for _, item := range r.store.data.copies {
+		if item.Status == Model.CopyWithdrawn || !slices.Contains(bookIDs, item.BookID) {
+			continue
+		}
+		counts := availability[item.BookID]
+		counts.Copies++
+		if item.Status == Model.CopyAvailable {
+			counts.Available++
+		}
+		availability[item.BookID] = counts
+	}
+	return availability, nil
+}
+
+func (r *memoryBookCopyRepository) Create(ctx context.Context, item *Model.BookCopyModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if r.barcodeTaken(item) {
+		return ErrDuplicate
+	}
+	if item.Status == "" {
+		item.Status = Model.CopyAvailable
+	}
+	r.store.data.nextCopyID++
+	item.ID = r.store.data.nextCopyID
+	r.store.data.copies[item.ID] = *item
+	return nil
+}
+
+func (r *memoryBookCopyRepository) Save(ctx context.Context, item *Model.BookCopyModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.store.data.copies[item.ID]; !ok {
+		return ErrNotFound
+	}
+	if r.barcodeTaken(item) {
+		return ErrDuplicate
+	}
+	r.store.data.copies[item.ID] = *item
+	return nil
+}
+
+func (r *memoryBookCopyRepository) barcodeTaken(item *Model.BookCopyModel) bool {
+	for id, other := range r.store.data.copies {
+		if id != item.ID && other.Barcode == item.Barcode {
+			return true
+		}
+	}
+	return false
+}
+
+type memoryAuthorRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryAuthorRepository) FindAll(ctx context.Context) ([]Model.AuthorModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	authors := slices.Collect(maps.Values(r.store.data.authors))
+	slices.SortFunc(authors, func(a, b Model.AuthorModel) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return a.ID - b.ID
+	})
+	return authors, nil
+}
+
+func (r *memoryAuthorRepository) FindByID(ctx context.Context, id int) (Model.AuthorModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.AuthorModel{}, err
+	}
+	defer unlock()
+
+	author, ok := r.store.data.authors[id]
+	if !ok {
+		return Model.AuthorModel{}, ErrNotFound
+	}
+	return author, nil
+}
+
+func (r *memoryAuthorRepository) FindByIDs(ctx context.Context, ids []int) ([]Model.AuthorModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var authors []Model.AuthorModel
+	for id, author := range r.store.data.authors {
+		if slices.Contains(ids, id) {
+			authors = append(authors, author)
+		}
+	}
+	slices.SortFunc(authors, func(a, b Model.AuthorModel) int { return a.ID - b.ID })
+	return authors, nil
+}
+
+func (r *memoryAuthorRepository) FindByNameKey(ctx context.Context, key string) ([]Model.AuthorModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var authors []Model.AuthorModel
+	for _, name := range r.store.data.authorNames {
+		if name.Key != key {
+			continue
+		}
+		author, ok := r.store.data.authors[name.AuthorID]
+		if ok && !slices.ContainsFunc(authors, func(a Model.AuthorModel) bool { return a.ID == author.ID }) {
+			authors = append(authors, author)
+		}
+	}
+	slices.SortFunc(authors, func(a, b Model.AuthorModel) int { return a.ID - b.ID })
+	return authors, nil
+}
+
+func (r *memoryAuthorRepository) Create(ctx context.Context, author *Model.AuthorModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	r.store.data.nextAuthorID++
+	author.ID = r.store.data.nextAuthorID
+	r.store.data.authors[author.ID] = *author
+	return nil
+}
+
+func (r *memoryAuthorRepository) Save(ctx context.Context, author *Model.AuthorModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.store.data.authors[author.ID]; !ok {
+		return ErrNotFound
+	}
+	r.store.data.authors[author.ID] = *author
+	return nil
+}
+
+func (r *memoryAuthorRepository) Names(ctx context.Context, authorID int) ([]Model.AuthorNameModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var names []Model.AuthorNameModel
+	for _, name := range r.store.data.authorNames {
+		if name.AuthorID == authorID {
+			names = append(names, name)
+		}
+	}
+	slices.SortFunc(names, func(a, b Model.AuthorNameModel) int { return a.ID - b.ID })
+	return names, nil
+}
+
+func (r *memoryAuthorRepository) ReplaceNames(ctx context.Context, authorID int, names []Model.AuthorNameModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for id, existing := range r.store.data.authorNames {
+		if existing.AuthorID == authorID {
+			delete(r.store.data.authorNames, id)
+		}
+	}
+	for i := range names {
+		r.store.data.nextAuthorNameID++
+		names[i].ID = r.store.data.nextAuthorNameID
+		names[i].AuthorID = authorID
+		r.store.data.authorNames[names[i].ID] = names[i]
+	}
+	return nil
+}
+
+func (r *memoryAuthorRepository) CreditsOfBook(ctx context.Context, bookID int) ([]Model.BookAuthorModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return slices.Clone(r.store.data.credits[bookID]), nil
+}
+
+func (r *memoryAuthorRepository) CreditsOfAuthor(ctx context.Context, authorID int) ([]Model.BookAuthorModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var credits []Model.BookAuthorModel
+	for _, bookCredits := range r.store.data.credits {
+		for _, credit := range bookCredits {
+			if credit.AuthorID == authorID {
+				credits = append(credits, credit)
+			}
+		}
+	}
+	slices.SortFunc(credits, func(a, b Model.BookAuthorModel) int {
+		if a.BookID != b.BookID {
+			return a.BookID - b.BookID
+		}
+		return a.Position - b.Position
+	})
+	return credits, nil
+}
+
+func (r *memoryAuthorRepository) ReplaceCredits(ctx context.Context, bookID int, credits []Model.BookAuthorModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	type creditKey struct {
+		authorID int
+		role     string
+	}
+	seen := map[creditKey]bool{}
+	for _, credit := range credits {
+		key := creditKey{credit.AuthorID, credit.Role}
+		if seen[key] {
+			return ErrDuplicate
+		}
+		seen[key] = true
+	}
+
+	sorted := slices.Clone(credits)
+	for i := range sorted {
+		sorted[i].BookID = bookID
+	}
+	slices.SortStableFunc(sorted, func(a, b Model.BookAuthorModel) int { return a.Position - b.Position })
+	if len(sorted) == 0 {
+		delete(r.store.data.credits, bookID)
+	} else {
+		r.store.data.credits[bookID] = sorted
+	}
+	return nil
+}
+
+type memorySubjectRepository struct {
+	store *memoryStore
+}
+
+func (r *memorySubjectRepository) FindAll(ctx context.Context) ([]Model.SubjectModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	subjects := slices.Collect(maps.Values(r.store.data.subjects))
+	slices.SortFunc(subjects, func(a, b Model.SubjectModel) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return a.ID - b.ID
+	})
+	return subjects, nil
+}
+
+func (r *memorySubjectRepository) FindByID(ctx context.Context, id int) (Model.SubjectModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.SubjectModel{}, err
+	}
+	defer unlock()
+
+	subject, ok := r.store.data.subjects[id]
+	if !ok {
+		return Model.SubjectModel{}, ErrNotFound
+	}
+	return subject, nil
+}
+
+func (r *memorySubjectRepository) Create(ctx context.Context, subject *Model.SubjectModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	r.store.data.nextSubjectID++
+	subject.ID = r.store.data.nextSubjectID
+	r.store.data.subjects[subject.ID] = *subject
+	return nil
+}
+
+func (r *memorySubjectRepository) Save(ctx context.Context, subject *Model.SubjectModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.store.data.subjects[subject.ID]; !ok {
+		return ErrNotFound
+	}
+	r.store.data.subjects[subject.ID] = *subject
+	return nil
+}
+
+func (r *memorySubjectRepository) SubjectIDsOfBook(ctx context.Context, bookID int) ([]int, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return slices.Clone(r.store.data.bookSubjects[bookID]), nil
+}
+
+func (r *memorySubjectRepository) BookIDsUnder(ctx context.Context, subjectIDs []int) ([]int, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var ids []int
+	for bookID, filed := range r.store.data.bookSubjects {
+		if slices.ContainsFunc(filed, func(id int) bool { return slices.Contains(subjectIDs, id) }) {
+			ids = append(ids, bookID)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func (r *memorySubjectRepository) ReplaceBookSubjects(ctx context.Context, bookID int, subjectIDs []int) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ids := slices.Sorted(slices.Values(subjectIDs))
+	if len(slices.Compact(slices.Clone(ids))) != len(ids) {
+		return ErrDuplicate
+	}
+	if len(ids) == 0 {
+		delete(r.store.data.bookSubjects, bookID)
+	} else {
+		r.store.data.bookSubjects[bookID] = ids
+	}
+	return nil
+}
+
+type memoryTagRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryTagRepository) TagsOfBook(ctx context.Context, bookID int) ([]string, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return slices.Clone(r.store.data.bookTags[bookID]), nil
+}
+
+func (r *memoryTagRepository) BookIDsTagged(ctx context.Context, tag string) ([]int, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var ids []int
+	for bookID, tags := range r.store.data.bookTags {
+		if slices.Contains(tags, tag) {
+			ids = append(ids, bookID)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func (r *memoryTagRepository) Counts(ctx context.Context) ([]TagCount, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	books := map[string]int{}
+	for _, tags := range r.store.data.bookTags {
+		for _, tag := range tags {
+			books[tag]++
+		}
+	}
+	counts := make([]TagCount, 0, len(books))
+	for _, tag := range slices.Sorted(maps.Keys(books)) {
+		counts = append(counts, TagCount{Tag: tag, Books: books[tag]})
+	}
+	return counts, nil
+}
+
+func (r *memoryTagRepository) ReplaceBookTags(ctx context.Context, bookID int, tags []string) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	sorted := slices.Sorted(slices.Values(tags))
+	if len(slices.Compact(slices.Clone(sorted))) != len(sorted) {
+		return ErrDuplicate
+	}
+	if len(sorted) == 0 {
+		delete(r.store.data.bookTags, bookID)
+	} else {
+		r.store.data.bookTags[bookID] = sorted
+	}
+	return nil
+}
+
+type memoryUserRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryUserRepository) FindByID(ctx context.Context, id int) (Model.UserModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.UserModel{}, err
+	}
+	defer unlock()
+
+	user, ok := r.store.data.users[id]
+	if !ok {
+		return Model.UserModel{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (r *memoryUserRepository) FindByEmail(ctx context.Context, email string) (Model.UserModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.UserModel{}, err
+	}
+	defer unlock()
+
+	for _, user := range r.store.data.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return Model.UserModel{}, ErrNotFound
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *Model.UserModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := r.checkUnique(user); err != nil {
+		return err
+	}
+	if user.UserId == 0 {
+		r.store.data.nextUserID++
+		user.UserId = r.store.data.nextUserID
+	}
+	r.store.data.nextUserID = max(r.store.data.nextUserID, user.UserId)
+	r.store.data.users[user.UserId] = *user
+	return nil
+}
+
+func (r *memoryUserRepository) Save(ctx context.Context, user *Model.UserModel) error {
+	if user.UserId == 0 {
+		return r.Create(ctx, user)
+	}
+
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := r.checkUnique(user); err != nil {
+		return err
+	}
+	r.store.data.nextUserID = max(r.store.data.nextUserID, user.UserId)
+	r.store.data.users[user.UserId] = *user
+	return nil
+}
+
+func (r *memoryUserRepository) AdvanceTOTPStep(ctx context.Context, id int, step int64) (bool, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	user, ok := r.store.data.users[id]
+	if !ok || user.TOTPLastStep >= step {
+		return false, nil
+	}
+	user.TOTPLastStep = step
+	r.store.data.users[id] = user
+	return true, nil
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id int) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.store.data.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.data.users, id)
+	return nil
+}
+
+// checkUnique enforces the unique email column of the UserModel schema.
+func (r *memoryUserRepository) checkUnique(user *Model.UserModel) error {
+	for id, existing := range r.store.data.users {
+		if id == user.UserId && user.UserId != 0 {
+			continue
+		}
+		if existing.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+	return nil
+}
+
+type memoryLoanRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryLoanRepository) FindOpenByUser(ctx context.Context, userID int) ([]Model.LoanModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var loans []Model.LoanModel
+	for _, loan := range r.store.data.loans {
+		if loan.UserID == userID && loan.ReturnedAt == nil {
+			loans = append(loans, loan)
+		}
+	}
+	slices.SortFunc(loans, func(a, b Model.LoanModel) int {
+		if c := a.BorrowedAt.Compare(b.BorrowedAt); c != 0 {
+			return c
+		}
+		return a.ID - b.ID
+	})
+	return loans, nil
+}
+
+func (r *memoryLoanRepository) Create(ctx context.Context, loan *Model.LoanModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if loan.ID == 0 {
+		r.store.data.nextLoanID++
+		loan.ID = r.store.data.nextLoanID
+	} else if _, exists := r.store.data.loans[loan.ID]; exists {
+		return ErrDuplicate
+	}
+	r.store.data.nextLoanID = max(r.store.data.nextLoanID, loan.ID)
+	r.store.data.loans[loan.ID] = *loan
+	return nil
+}
+
+func (r *memoryLoanRepository) Save(ctx context.Context, loan *Model.LoanModel) error {
+	if loan.ID == 0 {
+		return r.Create(ctx, loan)
+	}
+
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	r.store.data.nextLoanID = max(r.store.data.nextLoanID, loan.ID)
+	r.store.data.loans[loan.ID] = *loan
+	return nil
+}
+
+func (r *memoryLoanRepository) CountOpen(ctx context.Context) (int64, error) {
+	return r.count(ctx, func(loan Model.LoanModel) bool {
+		return loan.ReturnedAt == nil
+	})
+}
+
+func (r *memoryLoanRepository) CountOverdue(ctx context.Context, now time.Time) (int64, error) {
+	return r.count(ctx, func(loan Model.LoanModel) bool {
+		return loan.ReturnedAt == nil && loan.DueAt.Before(now)
+	})
+}
+
+func (r *memoryLoanRepository) count(ctx context.Context, match func(Model.LoanModel) bool) (int64, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	var count int64
+	for _, loan := range r.store.data.loans {
+		if match(loan) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type memoryPasswordResetRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryPasswordResetRepository) Create(ctx context.Context, reset *Model.PasswordResetModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, exists := r.store.data.passwordResets[reset.TokenHash]; exists {
+		return ErrDuplicate
+	}
+	r.store.data.nextPasswordResetID++
+	reset.ID = r.store.data.nextPasswordResetID
+	r.store.data.passwordResets[reset.TokenHash] = *reset
+	return nil
+}
+
+func (r *memoryPasswordResetRepository) FindByTokenHash(ctx context.Context, hash string) (Model.PasswordResetModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.PasswordResetModel{}, err
+	}
+	defer unlock()
+
+	reset, ok := r.store.data.passwordResets[hash]
+	if !ok {
+		return Model.PasswordResetModel{}, ErrNotFound
+	}
+	return reset, nil
+}
+
+func (r *memoryPasswordResetRepository) InvalidateForUser(ctx context.Context, userID int, now time.Time) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for hash, reset := range r.store.data.passwordResets {
+		if reset.UserID == userID && reset.UsedAt == nil {
+			reset.UsedAt = &now
+			r.store.data.passwordResets[hash] = reset
+		}
+	}
+	return nil
+}
+
+type memoryRecoveryCodeRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryRecoveryCodeRepository) ReplaceForUser(ctx context.Context, userID int, codes []Model.RecoveryCodeModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for id, code := range r.store.data.recoveryCodes {
+		if code.UserID == userID {
+			delete(r.store.data.recoveryCodes, id)
+		}
+	}
+	for i := range codes {
+		r.store.data.nextRecoveryCodeID++
+		codes[i].ID = r.store.data.nextRecoveryCodeID
+		r.store.data.recoveryCodes[codes[i].ID] = codes[i]
+	}
+	return nil
+}
+
+func (r *memoryRecoveryCodeRepository) FindUnused(ctx context.Context, userID int, hash string) (Model.RecoveryCodeModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.RecoveryCodeModel{}, err
+	}
+	defer unlock()
+
+	for _, code := range r.store.data.recoveryCodes {
+		if code.UserID == userID && code.CodeHash == hash && code.UsedAt == nil {
+			return code, nil
+		}
+	}
+	return Model.RecoveryCodeModel{}, ErrNotFound
+}
+
+func (r *memoryRecoveryCodeRepository) Use(ctx context.Context, id int, at time.Time) (bool, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	code, ok := r.store.data.recoveryCodes[id]
+	if !ok || code.UsedAt != nil {
+		return false, nil
+	}
+	code.UsedAt = &at
+	r.store.data.recoveryCodes[id] = code
+	return true, nil
+}
+
+type memoryExternalIdentityRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryExternalIdentityRepository) Find(ctx context.Context, issuer, subject string) (Model.ExternalIdentityModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.ExternalIdentityModel{}, err
+	}
+	defer unlock()
+
+	identity, ok := r.store.data.externalIdentities[externalIdentityKey{issuer, subject}]
+	if !ok {
+		return Model.ExternalIdentityModel{}, ErrNotFound
+	}
+	return identity, nil
+}
+
+func (r *memoryExternalIdentityRepository) Create(ctx context.Context, identity *Model.ExternalIdentityModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	key := externalIdentityKey{identity.Issuer, identity.Subject}
+	if _, exists := r.store.data.externalIdentities[key]; exists {
+		return ErrDuplicate
+	}
+	r.store.data.nextExternalIdentityID++
+	identity.ID = r.store.data.nextExternalIdentityID
+	r.store.data.externalIdentities[key] = *identity
+	return nil
+}
+
+func (r *memoryExternalIdentityRepository) DeleteForUser(ctx context.Context, userID int) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for key, identity := range r.store.data.externalIdentities {
+		if identity.UserID == userID {
+			delete(r.store.data.externalIdentities, key)
+		}
+	}
+	return nil
+}
+
+type memoryAPIKeyRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryAPIKeyRepository) FindAll(ctx context.Context) ([]Model.APIKeyModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	keys := slices.Collect(maps.Values(r.store.data.apiKeys))
+	slices.SortFunc(keys, func(a, b Model.APIKeyModel) int { return a.ID - b.ID })
+	return keys, nil
+}
+
+func (r *memoryAPIKeyRepository) FindByID(ctx context.Context, id int) (Model.APIKeyModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.APIKeyModel{}, err
+	}
+	defer unlock()
+
+	key, ok := r.store.data.apiKeys[id]
+	if !ok {
+		return Model.APIKeyModel{}, ErrNotFound
+	}
+	return key, nil
+}
+
+func (r *memoryAPIKeyRepository) FindByPrefix(ctx context.Context, prefix string) (Model.APIKeyModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.APIKeyModel{}, err
+	}
+	defer unlock()
+
+	for _, key := range r.store.data.apiKeys {
+		if key.Prefix == prefix {
+			return key, nil
+		}
+	}
+	return Model.APIKeyModel{}, ErrNotFound
+}
+
+func (r *memoryAPIKeyRepository) Create(ctx context.Context, key *Model.APIKeyModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, existing := range r.store.data.apiKeys {
+		if existing.Prefix == key.Prefix {
+			return ErrDuplicate
+		}
+	}
+	r.store.data.nextAPIKeyID++
+	key.ID = r.store.data.nextAPIKeyID
+	r.store.data.apiKeys[key.ID] = *key
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) Save(ctx context.Context, key *Model.APIKeyModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.store.data.apiKeys[key.ID]; !ok {
+		return ErrNotFound
+	}
+	r.store.data.apiKeys[key.ID] = *key
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) Touch(ctx context.Context, id int, at time.Time) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if key, ok := r.store.data.apiKeys[id]; ok && key.RevokedAt == nil {
+		key.LastUsedAt = &at
+		r.store.data.apiKeys[id] = key
+	}
+	return nil
+}
+
+type memorySessionRepository struct {
+	store *memoryStore
+}
+
+func (r *memorySessionRepository) FindByTokenID(ctx context.Context, tokenID string) (Model.SessionModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.SessionModel{}, err
+	}
+	defer unlock()
+
+	for _, session := range r.store.data.sessions {
+		if session.TokenID == tokenID {
+			return session, nil
+		}
+	}
+	return Model.SessionModel{}, ErrNotFound
+}
+
+func (r *memorySessionRepository) FindByUser(ctx context.Context, userID int, now time.Time) ([]Model.SessionModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var sessions []Model.SessionModel
+	for _, session := range r.store.data.sessions {
+		if session.UserID == userID && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b Model.SessionModel) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return b.ID - a.ID
+	})
+	return sessions, nil
+}
+
+func (r *memorySessionRepository) Create(ctx context.Context, session *Model.SessionModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, existing := range r.store.data.sessions {
+		if existing.TokenID == session.TokenID {
+			return ErrDuplicate
+		}
+	}
+	r.store.data.nextSessionID++
+	session.ID = r.store.data.nextSessionID
+	r.store.data.sessions[session.ID] = *session
+	return nil
+}
+
+func (r *memorySessionRepository) Save(ctx context.Context, session *Model.SessionModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.store.data.sessions[session.ID]; !ok {
+		return ErrNotFound
+	}
+	r.store.data.sessions[session.ID] = *session
+	return nil
+}
+
+func (r *memorySessionRepository) Touch(ctx context.Context, id int, at time.Time) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if session, ok := r.store.data.sessions[id]; ok && session.RevokedAt == nil {
+		session.LastSeenAt = at
+		r.store.data.sessions[id] = session
+	}
+	return nil
+}
+
+func (r *memorySessionRepository) DeleteExpired(ctx context.Context, userID int, now time.Time) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for id, session := range r.store.data.sessions {
+		if session.UserID == userID && !session.ExpiresAt.After(now) {
+			delete(r.store.data.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (r *memorySessionRepository) DeleteForUser(ctx context.Context, userID int) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for id, session := range r.store.data.sessions {
+		if session.UserID == userID {
+			delete(r.store.data.sessions, id)
+		}
+	}
+	return nil
+}
+
+type memoryImportJobRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryImportJobRepository) FindByID(ctx context.Context, id int) (Model.ImportJobModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return Model.ImportJobModel{}, err
+	}
+	defer unlock()
+
+	job, ok := r.store.data.importJobs[id]
+	if !ok {
+		return Model.ImportJobModel{}, ErrNotFound
+	}
+	return job, nil
+}
+
+func (r *memoryImportJobRepository) FindRecent(ctx context.Context, limit int) ([]Model.ImportJobModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	jobs := slices.Collect(maps.Values(r.store.data.importJobs))
+	slices.SortFunc(jobs, func(a, b Model.ImportJobModel) int { return b.ID - a.ID })
+	return jobs[:min(limit, len(jobs))], nil
+}
+
+func (r *memoryImportJobRepository) Create(ctx context.Context, job *Model.ImportJobModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	r.store.data.nextImportJobID++
+	job.ID = r.store.data.nextImportJobID
+	r.store.data.importJobs[job.ID] = *job
+	return nil
+}
+
+func (r *memoryImportJobRepository) Save(ctx context.Context, job *Model.ImportJobModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.store.data.importJobs[job.ID]; !ok {
+		return ErrNotFound
+	}
+	r.store.data.importJobs[job.ID] = *job
+	return nil
+}
+
+func (r *memoryImportJobRepository) AddErrors(ctx context.Context, errors []Model.ImportErrorModel) error {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for i := range errors {
+		r.store.data.nextImportErrorID++
+		errors[i].ID = r.store.data.nextImportErrorID
+		r.store.data.importErrors[errors[i].ID] = errors[i]
+	}
+	return nil
+}
+
+func (r *memoryImportJobRepository) Errors(ctx context.Context, jobID int) ([]Model.ImportErrorModel, error) {
+	unlock, err := r.store.access(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var errors []Model.ImportErrorModel
+	for _, problem := range r.store.data.importErrors {
+		if problem.JobID == jobID {
+			errors = append(errors, problem)
+		}
+	}
+	slices.SortFunc(errors, func(a, b Model.ImportErrorModel) int {
+		if a.Row != b.Row {
+			return a.Row - b.Row
+		}
+		return a.ID - b.ID
+	})
+	return errors, nil
+}
@@ -0,0 +1,36 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, reset *Model.PasswordResetModel) error
+	FindByTokenHash(ctx context.Context, hash string) (Model.PasswordResetModel, error)
+	// InvalidateForUser marks every unused reset of userID as used at now.
+	InvalidateForUser(ctx context.Context, userID int, now time.Time) error
+}
+
+type gormPasswordResetRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormPasswordResetRepository) Create(ctx context.Context, reset *Model.PasswordResetModel) error {
+	return translateError(r.db.WithContext(ctx).Create(reset).Error)
+}
+
+func (r *gormPasswordResetRepository) FindByTokenHash(ctx context.Context, hash string) (Model.PasswordResetModel, error) {
+	var reset Model.PasswordResetModel
+	err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&reset).Error
+	return reset, translateError(err)
+}
+
+func (r *gormPasswordResetRepository) InvalidateForUser(ctx context.Context, userID int, now time.Time) error {
+	err := r.db.WithContext(ctx).Model(&Model.PasswordResetModel{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", now).Error
+	return translateError(err)
+}
@@ -0,0 +1,44 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type RecoveryCodeRepository interface {
+	// ReplaceForUser deletes every code of userID and stores codes instead.
+	ReplaceForUser(ctx context.Context, userID int, codes []Model.RecoveryCodeModel) error
+	// FindUnused returns the code of userID with hash that has not been used.
+	FindUnused(ctx context.Context, userID int, hash string) (Model.RecoveryCodeModel, error)
+	// Use marks code id used at at, unless it was used already. It reports
+	// whether it did, so concurrent logins cannot share a code.
+	Use(ctx context.Context, id int, at time.Time) (bool, error)
+}
+
+type gormRecoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormRecoveryCodeRepository) ReplaceForUser(ctx context.Context, userID int, codes []Model.RecoveryCodeModel) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Where("user_id = ?", userID).Delete(&Model.RecoveryCodeModel{}).Error; err != nil {
+		return translateError(err)
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return translateError(db.Create(&codes).Error)
+}
+
+func (r *gormRecoveryCodeRepository) FindUnused(ctx context.Context, userID int, hash string) (Model.RecoveryCodeModel, error) {
+	var code Model.RecoveryCodeModel
+	err := r.db.WithContext(ctx).Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).First(&code).Error
+	return code, translateError(err)
+}
+
+func (r *gormRecoveryCodeRepository) Use(ctx context.Context, id int, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&Model.RecoveryCodeModel{}).Where("id = ? AND used_at IS NULL", id).UpdateColumn("used_at", at)
+	return result.RowsAffected > 0, translateError(result.Error)
+}
@@ -0,0 +1,115 @@
+package Repository
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound is returned when no record matches the lookup.
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a write violates a unique constraint.
+	ErrDuplicate = errors.New("duplicate record")
+)
+
+// Store groups the repositories and lets callers run several writes
+// atomically.
+type Store interface {
+	Books() BookRepository
+	Copies() BookCopyRepository
+	Authors() AuthorRepository
+	Subjects() SubjectRepository
+	Tags() TagRepository
+	Users() UserRepository
+	Loans() LoanRepository
+	PasswordResets() PasswordResetRepository
+	RecoveryCodes() RecoveryCodeRepository
+	ExternalIdentities() ExternalIdentityRepository
+	APIKeys() APIKeyRepository
+	Sessions() SessionRepository
+	ImportJobs() ImportJobRepository
+
+	// Transaction runs fn against a Store whose writes are only kept when fn
+	// returns nil.
+	Transaction(ctx context.Context, fn func(Store) error) error
+}
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore returns a Store backed by db. db should be opened with
+// TranslateError enabled so unique violations surface as ErrDuplicate.
+func NewGormStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) Books() BookRepository {
+	return &gormBookRepository{db: s.db}
+}
+
+func (s *gormStore) Copies() BookCopyRepository {
+	return &gormBookCopyRepository{db: s.db}
+}
+
+func (s *gormStore) Authors() AuthorRepository {
+	return &gormAuthorRepository{db: s.db}
+}
+
+func (s *gormStore) Subjects() SubjectRepository {
+	return &gormSubjectRepository{db: s.db}
+}
+
+func (s *gormStore) Tags() TagRepository {
+	return &gormTagRepository{db: s.db}
+}
+
+func (s *gormStore) Users() UserRepository {
+	return &gormUserRepository{db: s.db}
+}
+
+func (s *gormStore) Loans() LoanRepository {
+	return &gormLoanRepository{db: s.db}
+}
+
+func (s *gormStore) PasswordResets() PasswordResetRepository {
+	return &gormPasswordResetRepository{db: s.db}
+}
+
+func (s *gormStore) RecoveryCodes() RecoveryCodeRepository {
+	return &gormRecoveryCodeRepository{db: s.db}
+}
+
+func (s *gormStore) ExternalIdentities() ExternalIdentityRepository {
+	return &gormExternalIdentityRepository{db: s.db}
+}
+
+func (s *gormStore) APIKeys() APIKeyRepository {
+	return &gormAPIKeyRepository{db: s.db}
+}
+
+func (s *gormStore) Sessions() SessionRepository {
+	return &gormSessionRepository{db: s.db}
+}
+
+func (s *gormStore) ImportJobs() ImportJobRepository {
+	return &gormImportJobRepository{db: s.db}
+}
+
+func (s *gormStore) Transaction(ctx context.Context, fn func(Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormStore{db: tx})
+	})
+}
+
+// translateError maps GORM's sentinel errors onto the repository ones.
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type SessionRepository interface {
+	FindByTokenID(ctx context.Context, tokenID string) (Model.SessionModel, error)
+	// FindByUser returns the sessions of userID that have not expired by
+	// now, newest first.
+	FindByUser(ctx context.Context, userID int, now time.Time) ([]Model.SessionModel, error)
+	Create(ctx context.Context, session *Model.SessionModel) error
+	Save(ctx context.Context, session *Model.SessionModel) error
+	// Touch sets the LastSeenAt of session id to at, unless the session has
+	// been revoked. Only that column is written, so it cannot undo a
+	// revocation that raced with it.
+	Touch(ctx context.Context, id int, at time.Time) error
+	// DeleteExpired removes the sessions of userID that expired before now.
+	DeleteExpired(ctx context.Context, userID int, now time.Time) error
+	DeleteForUser(ctx context.Context, userID int) error
+}
+
+type gormSessionRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormSessionRepository) FindByTokenID(ctx context.Context, tokenID string) (Model.SessionModel, error) {
+	var session Model.SessionModel
+	err := r.db.WithContext(ctx).Where("token_id = ?", tokenID).First(&session).Error
+	return session, translateError(err)
+}
+
+func (r *gormSessionRepository) FindByUser(ctx context.Context, userID int, now time.Time) ([]Model.SessionModel, error) {
+	var sessions []Model.SessionModel
+	err := r.db.WithContext(ctx).Where("user_id = ? AND expires_at > ?", userID, now).Order("created_at DESC, id DESC").Find(&sessions).Error
+	return sessions, translateError(err)
+}
+
+func (r *gormSessionRepository) Create(ctx context.Context, session *Model.SessionModel) error {
+	return translateError(r.db.WithContext(ctx).Create(session).Error)
+}
+
+func (r *gormSessionRepository) Save(ctx context.Context, session *Model.SessionModel) error {
+	return translateError(r.db.WithContext(ctx).Save(session).Error)
+}
+
+func (r *gormSessionRepository) Touch(ctx context.Context, id int, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&Model.SessionModel{}).Where("id = ? AND revoked_at IS NULL", id).UpdateColumn("last_seen_at", at).Error
+	return translateError(err)
+}
+
+func (r *gormSessionRepository) DeleteExpired(ctx context.Context, userID int, now time.Time) error {
+	return translateError(r.db.WithContext(ctx).Where("user_id = ? AND expires_at <= ?", userID, now).Delete(&Model.SessionModel{}).Error)
+}
+
+func (r *gormSessionRepository) DeleteForUser(ctx context.Context, userID int) error {
+	return translateError(r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&Model.SessionModel{}).Error)
+}
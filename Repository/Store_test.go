@@ -0,0 +1,437 @@
+package Repository_test
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	ctx       = context.Background()
+	testStart = time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+)
+
+// forEachStore runs test against a gorm store on a fresh SQLite database and
+// against a fresh memory store, so the two keep the same contract.
+func forEachStore(t *testing.T, test func(t *testing.T, store Repository.Store)) {
+	t.Helper()
+	t.Run("gorm", func(t *testing.T) {
+		db, err := Config.OpenDatabase(filepath.Join(t.TempDir(), "library.db"))
+		if err != nil {
+			t.Fatalf("open database: %v", err)
+		}
+		t.Cleanup(func() {
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.Close()
+			}
+		})
+		test(t, Repository.NewGormStore(db))
+	})
+	t.Run("memory", func(t *testing.T) {
+		test(t, Repository.NewMemoryStore())
+	})
+}
+
+// must fails the test on err.
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectErr fails the test unless err is want.
+func expectErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Errorf("error = %v, want %v", err, want)
+	}
+}
+
+func createBook(t *testing.T, store Repository.Store, title, isbn13 string) Model.BookModel {
+	t.Helper()
+	book := Model.BookModel{Title: title, Author: "Frank Herbert", ISBN13: isbn13}
+	must(t, store.Books().Create(ctx, &book))
+	return book
+}
+
+func createUser(t *testing.T, store Repository.Store, email string) Model.UserModel {
+	t.Helper()
+	user := Model.UserModel{UserName: email, Email: email, Password: "hash", Role: Model.RoleMember}
+	must(t, store.Users().Create(ctx, &user))
+	return user
+}
+
+func TestBooks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Repository.Store) {
+		dune := createBook(t, store, "Dune", "9780441172719")
+		messiah := createBook(t, store, "Dune Messiah", "")
+		if dune.ID == 0 || dune.Slug != "dune-frank-herbert" {
+			t.Errorf("created book = %+v, want an ID and slug", dune)
+		}
+
+		found, err := store.Books().FindByISBN(ctx, "9780441172719")
+		if err != nil || found.ID != dune.ID {
+			t.Errorf("FindByISBN = %+v, %v", found, err)
+		}
+		_, err = store.Books().FindByID(ctx, 99)
+		expectErr(t, err, Repository.ErrNotFound)
+		books, err := store.Books().FindByIDs(ctx, []int{messiah.ID, 99, dune.ID})
+		if err != nil || len(books) != 2 || books[0].ID != dune.ID {
+			t.Errorf("FindByIDs = %+v, %v, want both books by ID", books, err)
+		}
+
+		twin := Model.BookModel{Title: "Dune", Author: "Frank Herbert", ISBN13: "9780441172719"}
+		expectErr(t, store.Books().Create(ctx, &twin), Repository.ErrDuplicate)
+		twin.ISBN13 = ""
+		must(t, store.Books().Create(ctx, &twin))
+		if twin.Slug != "dune-frank-herbert-2" {
+			t.Errorf("slug of a second Dune = %q", twin.Slug)
+		}
+
+		dune.Title = "Dune (40th anniversary)"
+		must(t, store.Books().Save(ctx, &dune))
+		for _, slug := range []string{dune.Slug, "dune-frank-herbert"} {
+			if found, err := store.Books().FindBySlug(ctx, slug); err != nil || found.ID != dune.ID {
+				t.Errorf("FindBySlug(%q) = %+v, %v", slug, found, err)
+			}
+		}
+
+		must(t, store.Books().SetCover(ctx, dune.ID, "c0ffee"))
+		found, err = store.Books().FindByID(ctx, dune.ID)
+		if err != nil || found.Cover != "c0ffee" || found.Title != dune.Title {
+			t.Errorf("after SetCover = %+v, %v, want the cover set and the rest kept", found, err)
+		}
+		expectErr(t, store.Books().SetCover(ctx, 99, ""), Repository.ErrNotFound)
+	})
+}
+
+func TestCopies(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Repository.Store) {
+		dune := createBook(t, store, "Dune", "")
+		messiah := createBook(t, store, "Dune Messiah", "")
+		var copies []Model.BookCopyModel
+		for i, status := range []string{Model.CopyWithdrawn, Model.CopyAvailable, Model.CopyAvailable} {
+			item := Model.BookCopyModel{BookID: dune.ID, Barcode: fmt.Sprintf("LIB-%05d", i+1), Status: status, AcquiredAt: testStart}
+			must(t, store.Copies().Create(ctx, &item))
+			copies = append(copies, item)
+		}
+
+		duplicate := Model.BookCopyModel{BookID: messiah.ID, Barcode: "LIB-00001", AcquiredAt: testStart}
+		expectErr(t, store.Copies().Create(ctx, &duplicate), Repository.ErrDuplicate)
+
+		available, err := store.Copies().FindAvailable(ctx, dune.ID)
+		if err != nil || available.ID != copies[1].ID {
+			t.Errorf("FindAvailable = %+v, %v, want the lowest available ID", available, err)
+		}
+		_, err = store.Copies().FindAvailable(ctx, messiah.ID)
+		expectErr(t, err, Repository.ErrNotFound)
+
+		copies[1].Status = Model.CopyOnLoan
+		must(t, store.Copies().Save(ctx, &copies[1]))
+		availability, err := store.Copies().Availability(ctx, []int{dune.ID, messiah.ID})
+		want := map[int]Model.BookAvailability{dune.ID: {Copies: 2, Available: 1}}
+		if err != nil || fmt.Sprint(availability) != fmt.Sprint(want) {
+			t.Errorf("Availability = %v, %v, want %v", availability, err, want)
+		}
+	})
+}
+
+func TestAuthors(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Repository.Store) {
+		book := createBook(t, store, "Good Omens", "")
+		var authors []Model.AuthorModel
+		for _, name := range []string{"Terry Pratchett", "Neil Gaiman"} {
+			author := Model.AuthorModel{Name: name}
+			must(t, store.Authors().Create(ctx, &author))
+			must(t, store.Authors().ReplaceNames(ctx, author.ID, []Model.AuthorNameModel{{Name: name, Key: Model.AuthorNameKey(name)}}))
+			authors = append(authors, author)
+		}
+
+		all, err := store.Authors().FindAll(ctx)
+		if err != nil || len(all) != 2 || all[0].Name != "Neil Gaiman" {
+			t.Errorf("FindAll = %+v, %v, want authors by name", all, err)
+		}
+		namesake := Model.AuthorModel{Name: "Pratchett, Terry"}
+		must(t, store.Authors().Create(ctx, &namesake))
+		must(t, store.Authors().ReplaceNames(ctx, namesake.ID, []Model.AuthorNameModel{{Name: namesake.Name, Key: Model.AuthorNameKey(namesake.Name)}}))
+		found, err := store.Authors().FindByNameKey(ctx, Model.AuthorNameKey("PRATCHETT, Terry"))
+		if err != nil || len(found) != 2 || found[0].ID != authors[0].ID || found[1].ID != namesake.ID {
+			t.Errorf("FindByNameKey = %+v, %v, want both Terry Pratchetts by ID", found, err)
+		}
+
+		credits := []Model.BookAuthorModel{
+			{AuthorID: authors[1].ID, Role: Model.CreditAuthor, Position: 1},
+			{AuthorID: authors[0].ID, Role: Model.CreditAuthor, Position: 0},
+		}
+		must(t, store.Authors().ReplaceCredits(ctx, book.ID, credits))
+		got, err := store.Authors().CreditsOfBook(ctx, book.ID)
+		if err != nil || len(got) != 2 || got[0].AuthorID != authors[0].ID || got[0].BookID != book.ID {
+			t.Errorf("CreditsOfBook = %+v, %v, want them by position", got, err)
+		}
+		uncredited := createBook(t, store, "Dune", "")
+		if books, err := store.Books().FindUncredited(ctx); err != nil || len(books) != 1 || books[0].ID != uncredited.ID {
+			t.Errorf("FindUncredited = %+v, %v, want only the book without credits", books, err)
+		}
+		must(t, store.Authors().ReplaceCredits(ctx, book.ID, nil))
+		if got, err := store.Authors().CreditsOfAuthor(ctx, authors[0].ID); err != nil || len(got) != 0 {
+			t.Errorf("CreditsOfAuthor = %+v, %v, want none once replaced", got, err)
+		}
+		if books, err := store.Books().FindUncredited(ctx); err != nil || len(books) != 2 {
+			t.Errorf("FindUncredited = %+v, %v, want both books once credits are cleared", books, err)
+		}
+	})
+}
+
+func TestSubjectsAndTags(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Repository.Store) {
+		dune := createBook(t, store, "Dune", "")
+		emma := createBook(t, store, "Emma", "")
+		fiction := Model.SubjectModel{Name: "Fiction"}
+		must(t, store.Subjects().Create(ctx, &fiction))
+		science := Model.SubjectModel{Name: "Science fiction", ParentID: &fiction.ID}
+		must(t, store.Subjects().Create(ctx, &science))
+
+		must(t, store.Subjects().ReplaceBookSubjects(ctx, emma.ID, []int{fiction.ID}))
+		must(t, store.Subjects().ReplaceBookSubjects(ctx, dune.ID, []int{science.ID, fiction.ID}))
+		if ids, err := store.Subjects().SubjectIDsOfBook(ctx, dune.ID); err != nil || fmt.Sprint(ids) != fmt.Sprint([]int{fiction.ID, science.ID}) {
+			t.Errorf("SubjectIDsOfBook = %v, %v", ids, err)
+		}
+		if ids, err := store.Subjects().BookIDsUnder(ctx, []int{fiction.ID, science.ID}); err != nil || fmt.Sprint(ids) != fmt.Sprint([]int{dune.ID, emma.ID}) {
+			t.Errorf("BookIDsUnder = %v, %v, want each book once", ids, err)
+		}
+
+		must(t, store.Tags().ReplaceBookTags(ctx, dune.ID, []string{"space", "classic"}))
+		must(t, store.Tags().ReplaceBookTags(ctx, emma.ID, []string{"classic"}))
+		if tags, err := store.Tags().TagsOfBook(ctx, dune.ID); err != nil || fmt.Sprint(tags) != "[classic space]" {
+			t.Errorf("TagsOfBook = %v, %v", tags, err)
+		}
+		counts, err := store.Tags().Counts(ctx)
+		if want := []Repository.TagCount{{Tag: "classic", Books: 2}, {Tag: "space", Books: 1}}; err != nil || fmt.Sprint(counts) != fmt.Sprint(want) {
+			t.Errorf("Counts = %v, %v, want %v", counts, err, want)
+		}
+		must(t, store.Tags().ReplaceBookTags(ctx, emma.ID, nil))
+		if ids, err := store.Tags().BookIDsTagged(ctx, "classic"); err != nil || fmt.Sprint(ids) != fmt.Sprint([]int{dune.ID}) {
+			t.Errorf("BookIDsTagged = %v, %v", ids, err)
+		}
+	})
+}
+
+func TestUsers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Repository.Store) {
+		ada := createUser(t, store, "ada@example.com")
+		grace := createUser(t, store, "grace@example.com")
+
+		twin := Model.UserModel{UserName: "ada", Email: "ada@example.com", Password: "hash"}
+		expectErr(t, store.Users().Create(ctx, &twin), Repository.ErrDuplicate)
+		grace.Email = ada.Email
+		expectErr(t, store.Users().Save(ctx, &grace), Repository.ErrDuplicate)
+
+		if found, err := store.Users().FindByEmail(ctx, "ada@example.com"); err != nil || found.UserId != ada.UserId {
+			t.Errorf("FindByEmail = %+v, %v", found, err)
+		}
+		for i, step := range []int64{7, 7, 6} {
+			if advanced, err := store.Users().AdvanceTOTPStep(ctx, ada.UserId, step); err != nil || advanced != (i == 0) {
+				t.Errorf("AdvanceTOTPStep #%d(%d) = %v, %v", i+1, step, advanced, err)
+			}
+		}
+		if found, err := store.Users().FindByID(ctx, ada.UserId); err != nil || found.TOTPLastStep != 7 || found.Email != ada.Email {
+			t.Errorf("after AdvanceTOTPStep = %+v, %v, want step 7 and nothing else changed", found, err)
+		}
+		must(t, store.Users().Delete(ctx, ada.UserId))
+		expectErr(t, store.Users().Delete(ctx, ada.UserId), Repository.ErrNotFound)
+		_, err := store.Users().FindByID(ctx, ada.UserId)
+		expectErr(t, err, Repository.ErrNotFound)
+	})
+}
+
+func TestLoans(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Repository.Store) {
+		user := createUser(t, store, "ada@example.com")
+		returned := testStart.Add(time.Hour)
+		loans := []Model.LoanModel{
+			{BookID: 1, CopyID: 1, UserID: user.UserId, BorrowedAt: testStart.Add(time.Minute), DueAt: testStart.Add(14 * 24 * time.Hour)},
+			{BookID: 2, CopyID: 2, UserID: user.UserId, BorrowedAt: testStart, DueAt: testStart.Add(24 * time.Hour)},
+			{BookID: 3, CopyID: 3, UserID: user.UserId, BorrowedAt: testStart, DueAt: testStart, ReturnedAt: &returned},
+		}
+		for i := range loans {
+			must(t, store.Loans().Create(ctx, &loans[i]))
+		}
+
+		open, err := store.Loans().FindOpenByUser(ctx, user.UserId)
+		if err != nil || len(open) != 2 || open[0].ID != loans[1].ID {
+			t.Errorf("FindOpenByUser = %+v, %v, want the open loans oldest first", open, err)
+		}
+		if count, err := store.Loans().CountOpen(ctx); err != nil || count != 2 {
+			t.Errorf("CountOpen = %d, %v", count, err)
+		}
+		if count, err := store.Loans().CountOverdue(ctx, testStart.Add(2*24*time.Hour)); err != nil || count != 1 {
+			t.Errorf("CountOverdue = %d, %v", count, err)
+		}
+	})
+}
+
+func TestCredentials(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Repository.Store) {
+		user := createUser(t, store, "ada@example.com")
+
+		reset := Model.PasswordResetModel{UserID: user.UserId, TokenHash: "reset", CreatedAt: testStart, ExpiresAt: testStart.Add(time.Hour)}
+		must(t, store.PasswordResets().Create(ctx, &reset))
+		must(t, store.PasswordResets().InvalidateForUser(ctx, user.UserId, testStart))
+		if found, err := store.PasswordResets().FindByTokenHash(ctx, "reset"); err != nil || found.UsedAt == nil {
+			t.Errorf("reset after InvalidateForUser = %+v, %v, want it used", found, err)
+		}
+
+		must(t, store.RecoveryCodes().ReplaceForUser(ctx, user.UserId, []Model.RecoveryCodeModel{{UserID: user.UserId, CodeHash: "a"}, {UserID: user.UserId, CodeHash: "b"}}))
+		code, err := store.RecoveryCodes().FindUnused(ctx, user.UserId, "a")
+		must(t, err)
+		for i, want := range []bool{true, false} {
+			if used, err := store.RecoveryCodes().Use(ctx, code.ID, testStart); err != nil || used != want {
+				t.Errorf("Use #%d = %v, %v, want %v", i+1, used, err, want)
+			}
+		}
+		_, err = store.RecoveryCodes().FindUnused(ctx, user.UserId, "a")
+		expectErr(t, err, Repository.ErrNotFound)
+		must(t, store.RecoveryCodes().ReplaceForUser(ctx, user.UserId, nil))
+		_, err = store.RecoveryCodes().FindUnused(ctx, user.UserId, "b")
+		expectErr(t, err, Repository.ErrNotFound)
+
+		identity := Model.ExternalIdentityModel{Issuer: "https://id.example", Subject: "42", UserID: user.UserId, CreatedAt: testStart}
+		must(t, store.ExternalIdentities().Create(ctx, &identity))
+		twin := identity
+		twin.ID = 0
+		expectErr(t, store.ExternalIdentities().Create(ctx, &twin), Repository.ErrDuplicate)
+		must(t, store.ExternalIdentities().DeleteForUser(ctx, user.UserId))
+		_, err = store.ExternalIdentities().Find(ctx, identity.Issuer, identity.Subject)
+		expectErr(t, err, Repository.ErrNotFound)
+	})
+}
+
+func TestSessions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Repository.Store) {
+		user := createUser(t, store, "ada@example.com")
+		var sessions []Model.SessionModel
+		for i, expiresAt := range []time.Time{testStart.Add(time.Hour), testStart.Add(2 * time.Hour), testStart} {
+			session := Model.SessionModel{
+				UserID: user.UserId, TokenID: fmt.Sprint("token-", i),
+				CreatedAt: testStart.Add(time.Duration(i) * time.Minute), LastSeenAt: testStart, ExpiresAt: expiresAt,
+			}
+			must(t, store.Sessions().Create(ctx, &session))
+			sessions = append(sessions, session)
+		}
+
+		live, err := store.Sessions().FindByUser(ctx, user.UserId, testStart)
+		if err != nil || len(live) != 2 || live[0].ID != sessions[1].ID {
+			t.Errorf("FindByUser = %+v, %v, want unexpired sessions newest first", live, err)
+		}
+
+		// A touch that read the session before it was revoked must not
+		// bring it back.
+		revokedAt := testStart.Add(time.Minute)
+		sessions[0].RevokedAt = &revokedAt
+		must(t, store.Sessions().Save(ctx, &sessions[0]))
+		must(t, store.Sessions().Touch(ctx, sessions[0].ID, testStart.Add(2*time.Minute)))
+		must(t, store.Sessions().Touch(ctx, sessions[1].ID, testStart.Add(2*time.Minute)))
+		revoked, err := store.Sessions().FindByTokenID(ctx, "token-0")
+		if err != nil || revoked.RevokedAt == nil || !revoked.LastSeenAt.Equal(testStart) {
+			t.Errorf("revoked session after Touch = %+v, %v", revoked, err)
+		}
+		touched, err := store.Sessions().FindByTokenID(ctx, "token-1")
+		if err != nil || !touched.LastSeenAt.Equal(testStart.Add(2*time.Minute)) {
+			t.Errorf("session after Touch = %+v, %v", touched, err)
+		}
+
+		must(t, store.Sessions().DeleteExpired(ctx, user.UserId, testStart))
+		_, err = store.Sessions().FindByTokenID(ctx, "token-2")
+		expectErr(t, err, Repository.ErrNotFound)
+		must(t, store.Sessions().DeleteForUser(ctx, user.UserId))
+		_, err = store.Sessions().FindByTokenID(ctx, "token-1")
+		expectErr(t, err, Repository.ErrNotFound)
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Repository.Store) {
+		var keys []Model.APIKeyModel
+		for _, prefix := range []string{"lib_a", "lib_b"} {
+			key := Model.APIKeyModel{Name: prefix, Prefix: prefix, KeyHash: "hash", Scopes: "books:read", CreatedByID: 1, CreatedAt: testStart}
+			must(t, store.APIKeys().Create(ctx, &key))
+			keys = append(keys, key)
+		}
+		twin := Model.APIKeyModel{Name: "twin", Prefix: "lib_a", KeyHash: "hash", CreatedAt: testStart}
+		expectErr(t, store.APIKeys().Create(ctx, &twin), Repository.ErrDuplicate)
+
+		keys[0].RevokedAt = &testStart
+		must(t, store.APIKeys().Save(ctx, &keys[0]))
+		usedAt := testStart.Add(time.Minute)
+		for _, key := range keys {
+			must(t, store.APIKeys().Touch(ctx, key.ID, usedAt))
+		}
+		revoked, err := store.APIKeys().FindByPrefix(ctx, "lib_a")
+		if err != nil || revoked.RevokedAt == nil || revoked.LastUsedAt != nil {
+			t.Errorf("revoked key after Touch = %+v, %v", revoked, err)
+		}
+		used, err := store.APIKeys().FindByID(ctx, keys[1].ID)
+		if err != nil || used.LastUsedAt == nil || !used.LastUsedAt.Equal(usedAt) {
+			t.Errorf("key after Touch = %+v, %v", used, err)
+		}
+	})
+}
+
+func TestImportJobs(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Repository.Store) {
+		var jobs []Model.ImportJobModel
+		for range 3 {
+			job := Model.ImportJobModel{Format: Model.ImportCSV, Status: Model.ImportQueued, CreatedAt: testStart}
+			must(t, store.ImportJobs().Create(ctx, &job))
+			jobs = append(jobs, job)
+		}
+		recent, err := store.ImportJobs().FindRecent(ctx, 2)
+		if err != nil || len(recent) != 2 || recent[0].ID != jobs[2].ID {
+			t.Errorf("FindRecent = %+v, %v, want the last two newest first", recent, err)
+		}
+
+		must(t, store.ImportJobs().AddErrors(ctx, []Model.ImportErrorModel{
+			{JobID: jobs[0].ID, Row: 3, Field: "title", Message: "is required"},
+			{JobID: jobs[0].ID, Row: 2, Field: "isbn", Message: "must be a valid ISBN-10 or ISBN-13"},
+			{JobID: jobs[1].ID, Row: 1, Message: "is not a JSON object"},
+		}))
+		problems, err := store.ImportJobs().Errors(ctx, jobs[0].ID)
+		if err != nil || len(problems) != 2 || problems[0].Row != 2 {
+			t.Errorf("Errors = %+v, %v, want the job's errors in row order", problems, err)
+		}
+	})
+}
+
+func TestTransaction(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Repository.Store) {
+		failure := errors.New("failure")
+		err := store.Transaction(ctx, func(tx Repository.Store) error {
+			createBook(t, tx, "Dune", "")
+			return tx.Transaction(ctx, func(tx Repository.Store) error {
+				createUser(t, tx, "ada@example.com")
+				return failure
+			})
+		})
+		expectErr(t, err, failure)
+		if books, err := store.Books().FindAll(ctx); err != nil || len(books) != 0 {
+			t.Errorf("books after rollback = %+v, %v", books, err)
+		}
+		_, err = store.Users().FindByEmail(ctx, "ada@example.com")
+		expectErr(t, err, Repository.ErrNotFound)
+
+		must(t, store.Transaction(ctx, func(tx Repository.Store) error {
+			createBook(t, tx, "Dune", "")
+			return nil
+		}))
+		if books, err := store.Books().FindAll(ctx); err != nil || len(books) != 1 {
+			t.Errorf("books after commit = %+v, %v", books, err)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"gorm.io/gorm"
+)
+
+type SubjectRepository interface {
+	// FindAll returns the whole taxonomy by name.
+	FindAll(ctx context.Context) ([]Model.SubjectModel, error)
+	FindByID(ctx context.Context, id int) (Model.SubjectModel, error)
+	Create(ctx context.Context, subject *Model.SubjectModel) error
+	Save(ctx context.Context, subject *Model.SubjectModel) error
+
+	// SubjectIDsOfBook lists the subjects bookID is filed under.
+	SubjectIDsOfBook(ctx context.Context, bookID int) ([]int, error)
+	// BookIDsUnder lists the books filed under any of subjectIDs, by ID.
+	BookIDsUnder(ctx context.Context, subjectIDs []int) ([]int, error)
+	ReplaceBookSubjects(ctx context.Context, bookID int, subjectIDs []int) error
+}
+
+type gormSubjectRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormSubjectRepository) FindAll(ctx context.Context) ([]Model.SubjectModel, error) {
+	var subjects []Model.SubjectModel
+	err := r.db.WithContext(ctx).Order("name, id").Find(&subjects).Error
+	return subjects, translateError(err)
+}
+
+func (r *gormSubjectRepository) FindByID(ctx context.Context, id int) (Model.SubjectModel, error) {
+	var subject Model.SubjectModel
+	err := r.db.WithContext(ctx).First(&subject, id).Error
+	return subject, translateError(err)
+}
+
+func (r *gormSubjectRepository) Create(ctx context.Context, subject *Model.SubjectModel) error {
+	return translateError(r.db.WithContext(ctx).Create(subject).Error)
+}
+
+func (r *gormSubjectRepository) Save(ctx context.Context, subject *Model.SubjectModel) error {
+	return translateError(r.db.WithContext(ctx).Save(subject).Error)
+}
+
+func (r *gormSubjectRepository) SubjectIDsOfBook(ctx context.Context, bookID int) ([]int, error) {
+	var ids []int
+	err := r.db.WithContext(ctx).Model(&Model.BookSubjectModel{}).Where("book_id = ?", bookID).Order("subject_id").Pluck("subject_id", &ids).Error
+	return ids, translateError(err)
+}
+
+func (r *gormSubjectRepository) BookIDsUnder(ctx context.Context, subjectIDs []int) ([]int, error) {
+	var ids []int
+	err := r.db.WithContext(ctx).Model(&Model.BookSubjectModel{}).Distinct("book_id").Where("subject_id IN ?", subjectIDs).Order("book_id").Pluck("book_id", &ids).Error
+	return ids, translateError(err)
+}
+
+func (r *gormSubjectRepository) ReplaceBookSubjects(ctx context.Context, bookID int, subjectIDs []int) error {
+	return translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("book_id = ?", bookID).Delete(&Model.BookSubjectModel{}).Error; err != nil {
+			return err
+		}
+		if len(subjectIDs) == 0 {
+			return nil
+		}
+		links := make([]Model.BookSubjectModel, 0, len(subjectIDs))
+		for _, id := range subjectIDs {
+			links = append(links, Model.BookSubjectModel{BookID: bookID, SubjectID: id})
+		}
+		return tx.Create(&links).Error
+	}))
+}
@@ -0,0 +1,66 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"gorm.io/gorm"
+)
+
+// TagCount is a tag with the number of books carrying it.
+type TagCount struct {
+	Tag   string
+	Books int
+}
+
+type TagRepository interface {
+	// TagsOfBook lists the tags of bookID alphabetically.
+	TagsOfBook(ctx context.Context, bookID int) ([]string, error)
+	// BookIDsTagged lists the books carrying tag, by ID.
+	BookIDsTagged(ctx context.Context, tag string) ([]int, error)
+	// Counts lists every tag in use alphabetically.
+	Counts(ctx context.Context) ([]TagCount, error)
+	// ReplaceBookTags expects tags normalized and free of duplicates.
+	ReplaceBookTags(ctx context.Context, bookID int, tags []string) error
+}
+
+type gormTagRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormTagRepository) TagsOfBook(ctx context.Context, bookID int) ([]string, error) {
+	var tags []string
+	err := r.db.WithContext(ctx).Model(&Model.BookTagModel{}).Where("book_id = ?", bookID).Order("tag").Pluck("tag", &tags).Error
+	return tags, translateError(err)
+}
+
+func (r *gormTagRepository) BookIDsTagged(ctx context.Context, tag string) ([]int, error) {
+	var ids []int
+	err := r.db.WithContext(ctx).Model(&Model.BookTagModel{}).Where("tag = ?", tag).Order("book_id").Pluck("book_id", &ids).Error
+	return ids, translateError(err)
+}
+
+func (r *gormTagRepository) Counts(ctx context.Context) ([]TagCount, error) {
+	var counts []TagCount
+	err := r.db.WithContext(ctx).Model(&Model.BookTagModel{}).
+		Select("tag, COUNT(*) AS books").
+		Group("tag").
+		Order("tag").
+		Scan(&counts).Error
+	return counts, translateError(err)
+}
+
+func (r *gormTagRepository) ReplaceBookTags(ctx context.Context, bookID int, tags []string) error {
+	return translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("book_id = ?", bookID).Delete(&Model.BookTagModel{}).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		rows := make([]Model.BookTagModel, 0, len(tags))
+		for _, tag := range tags {
+			rows = append(rows, Model.BookTagModel{BookID: bookID, Tag: tag})
+		}
+		return tx.Create(&rows).Error
+	}))
+}
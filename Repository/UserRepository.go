@@ -0,0 +1,56 @@
+package Repository
+
+import (
+	"awesomeProject/Model"
+	"context"
+	"gorm.io/gorm"
+)
+
+type UserRepository interface {
+	FindByID(ctx context.Context, id int) (Model.UserModel, error)
+	FindByEmail(ctx context.Context, email string) (Model.UserModel, error)
+	Create(ctx context.Context, user *Model.UserModel) error
+	Save(ctx context.Context, user *Model.UserModel) error
+	// AdvanceTOTPStep records step as the last authenticator time step user
+	// id logged in with, unless a step as late was recorded already. It
+	// reports whether it did, so concurrent logins cannot share a code.
+	AdvanceTOTPStep(ctx context.Context, id int, step int64) (bool, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormUserRepository) FindByID(ctx context.Context, id int) (Model.UserModel, error) {
+	var user Model.UserModel
+	err := r.db.WithContext(ctx).First(&user, id).Error
+	return user, translateError(err)
+}
+
+func (r *gormUserRepository) FindByEmail(ctx context.Context, email string) (Model.UserModel, error) {
+	var user Model.UserModel
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	return user, translateError(err)
+}
+
+func (r *gormUserRepository) Create(ctx context.Context, user *Model.UserModel) error {
+	return translateError(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r *gormUserRepository) Save(ctx context.Context, user *Model.UserModel) error {
+	return translateError(r.db.WithContext(ctx).Save(user).Error)
+}
+
+func (r *gormUserRepository) AdvanceTOTPStep(ctx context.Context, id int, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&Model.UserModel{}).Where("user_id = ? AND totp_last_step < ?", id, step).UpdateColumn("totp_last_step", step)
+	return result.RowsAffected > 0, translateError(result.Error)
+}
+
+func (r *gormUserRepository) Delete(ctx context.Context, id int) error {
+	result := r.db.WithContext(ctx).Delete(&Model.UserModel{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return translateError(result.Error)
+}
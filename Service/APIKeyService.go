@@ -0,0 +1,127 @@
+package Service
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// apiKeyTouchInterval limits how often LastUsedAt is written, so a busy
+// client does not turn every request into a database write.
+const apiKeyTouchInterval = time.Minute
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrAPIKeyExpiry rejects keys that would expire before they are made.
+	ErrAPIKeyExpiry = errors.New("API key expiry is in the past")
+)
+
+type APIKeyService interface {
+	// Create issues a key with scopes on behalf of the admin createdBy. The
+	// key itself is only returned here.
+	Create(ctx context.Context, createdBy int, name string, scopes []string, expiresAt *time.Time) (Model.APIKeyModel, string, error)
+	List(ctx context.Context) ([]Model.APIKeyModel, error)
+	// Revoke stops a key from working. Revoking it again is a no-op.
+	Revoke(ctx context.Context, id int) (Model.APIKeyModel, error)
+
+	// CheckAPIKey implements Config.APIKeyChecker.
+	CheckAPIKey(ctx context.Context, key string) (int, []string, bool, error)
+}
+
+type apiKeyService struct {
+	store Repository.Store
+	clock Config.Clock
+}
+
+func NewAPIKeyService(store Repository.Store, clock Config.Clock) APIKeyService {
+	return &apiKeyService{store: store, clock: clock}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, createdBy int, name string, scopes []string, expiresAt *time.Time) (Model.APIKeyModel, string, error) {
+	now := s.clock.Now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return Model.APIKeyModel{}, "", ErrAPIKeyExpiry
+	}
+
+	key, prefix, err := Config.NewAPIKey()
+	if err != nil {
+		return Model.APIKeyModel{}, "", err
+	}
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+
+	model := Model.APIKeyModel{
+		Name:        strings.TrimSpace(name),
+		Prefix:      prefix,
+		KeyHash:     Config.HashSecretToken(key),
+		Scopes:      strings.Join(slices.Compact(scopes), " "),
+		CreatedByID: createdBy,
+		CreatedAt:   now,
+		ExpiresAt:   expiresAt,
+	}
+	if err := s.store.APIKeys().Create(ctx, &model); err != nil {
+		return model, "", err
+	}
+	slog.InfoContext(ctx, "API key created", "apiKeyId", model.ID, "prefix", prefix, "scopes", model.Scopes, "createdBy", createdBy)
+	return model, key, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context) ([]Model.APIKeyModel, error) {
+	return s.store.APIKeys().FindAll(ctx)
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id int) (Model.APIKeyModel, error) {
+	var key Model.APIKeyModel
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		var err error
+		key, err = tx.APIKeys().FindByID(ctx, id)
+		if errors.Is(err, Repository.ErrNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		if err != nil || key.RevokedAt != nil {
+			return err
+		}
+
+		now := s.clock.Now()
+		key.RevokedAt = &now
+		return tx.APIKeys().Save(ctx, &key)
+	})
+	if err == nil {
+		slog.InfoContext(ctx, "API key revoked", "apiKeyId", key.ID, "prefix", key.Prefix)
+	}
+	return key, err
+}
+
+func (s *apiKeyService) CheckAPIKey(ctx context.Context, key string) (int, []string, bool, error) {
+	prefix, ok := Config.APIKeyPrefix(key)
+	if !ok {
+		return 0, nil, false, nil
+	}
+
+	model, err := s.store.APIKeys().FindByPrefix(ctx, prefix)
+	if errors.Is(err, Repository.ErrNotFound) {
+		return 0, nil, false, nil
+	}
+	if err != nil {
+		return 0, nil, false, err
+	}
+	now := s.clock.Now()
+	if subtle.ConstantTimeCompare([]byte(model.KeyHash), []byte(Config.HashSecretToken(key))) != 1 || !model.Active(now) {
+		return 0, nil, false, nil
+	}
+
+	if model.LastUsedAt == nil || now.Sub(*model.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.store.APIKeys().Touch(ctx, model.ID, now); err != nil {
+			// Losing a timestamp is no reason to turn the client away.
+			slog.WarnContext(ctx, "Failed to record API key use", "apiKeyId", model.ID, "error", err)
+		}
+	}
+	return model.ID, model.ScopeList(), true, nil
+}
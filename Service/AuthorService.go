@@ -0,0 +1,387 @@
+package Service
+
+import (
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrAuthorNotFound = errors.New("author not found")
+	// ErrInvalidAuthorName rejects names without a single letter or digit.
+	ErrInvalidAuthorName = errors.New("author name has no letters or digits")
+	ErrAuthorDates       = errors.New("death year before birth year")
+	// ErrUnknownCreditAuthor rejects credits of authors that do not exist.
+	ErrUnknownCreditAuthor = errors.New("credit refers to an unknown author")
+	ErrDuplicateCredit     = errors.New("author credited twice in the same role")
+)
+
+// Contributor is an author credited on a book in a role.
+type Contributor struct {
+	Author Model.AuthorModel
+	Role   string
+}
+
+// Work is a book an author is credited on in a role.
+type Work struct {
+	Book Model.BookModel
+	Role string
+}
+
+type AuthorService interface {
+	// List returns every author by name or, when name is set, the authors
+	// known by it in any spelling, by ID.
+	List(ctx context.Context, name string) ([]Model.AuthorModel, error)
+	// Find returns an author with their names and works.
+	Find(ctx context.Context, id int) (Model.AuthorModel, []Model.AuthorNameModel, []Work, error)
+	// Create adds an author known by author.Name and variants.
+	Create(ctx context.Context, author *Model.AuthorModel, variants []string) error
+	// Update replaces the details and names of author.ID.
+	Update(ctx context.Context, author *Model.AuthorModel, variants []string) error
+
+	// Contributors lists the credits of bookID in title page order.
+	Contributors(ctx context.Context, bookID int) ([]Contributor, error)
+	// SetCredits replaces the credits of bookID; their order is kept. The
+	// book's free-text author statement is rewritten from them, so lists and
+	// search agree with the credits.
+	SetCredits(ctx context.Context, bookID int, credits []Model.BookAuthorModel) ([]Contributor, error)
+
+	// ExtractFromBooks credits the authors named in the Author statement of
+	// every book without credits, creating the ones not known by any of
+	// their names yet. It returns how many books it credited.
+	ExtractFromBooks(ctx context.Context) (int, error)
+}
+
+type authorService struct {
+	store Repository.Store
+}
+
+func NewAuthorService(store Repository.Store) AuthorService {
+	return &authorService{store: store}
+}
+
+func (s *authorService) List(ctx context.Context, name string) ([]Model.AuthorModel, error) {
+	if strings.TrimSpace(name) == "" {
+		return s.store.Authors().FindAll(ctx)
+	}
+
+	return s.store.Authors().FindByNameKey(ctx, Model.AuthorNameKey(name))
+}
+
+func (s *authorService) Find(ctx context.Context, id int) (Model.AuthorModel, []Model.AuthorNameModel, []Work, error) {
+	author, err := findAuthor(ctx, s.store, id)
+	if err != nil {
+		return author, nil, nil, err
+	}
+	names, err := s.store.Authors().Names(ctx, id)
+	if err != nil {
+		return author, nil, nil, err
+	}
+	credits, err := s.store.Authors().CreditsOfAuthor(ctx, id)
+	if err != nil {
+		return author, nil, nil, err
+	}
+
+	bookIDs := make([]int, 0, len(credits))
+	for _, credit := range credits {
+		bookIDs = append(bookIDs, credit.BookID)
+	}
+	books, err := s.store.Books().FindByIDs(ctx, bookIDs)
+	if err != nil {
+		return author, nil, nil, err
+	}
+	byID := make(map[int]Model.BookModel, len(books))
+	for _, book := range books {
+		byID[book.ID] = book
+	}
+
+	works := make([]Work, 0, len(credits))
+	for _, credit := range credits {
+		if book, ok := byID[credit.BookID]; ok {
+			works = append(works, Work{Book: book, Role: credit.Role})
+		}
+	}
+	return author, names, works, nil
+}
+
+func (s *authorService) Create(ctx context.Context, author *Model.AuthorModel, variants []string) error {
+	names, err := prepareAuthor(author, variants)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.Transaction(ctx, func(tx Repository.Store) error {
+		if err := tx.Authors().Create(ctx, author); err != nil {
+			return err
+		}
+		return tx.Authors().ReplaceNames(ctx, author.ID, names)
+	})
+	if err == nil {
+		slog.InfoContext(ctx, "Author created", "authorId", author.ID, "name", author.Name)
+	}
+	return err
+}
+
+func (s *authorService) Update(ctx context.Context, author *Model.AuthorModel, variants []string) error {
+	names, err := prepareAuthor(author, variants)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Transaction(ctx, func(tx Repository.Store) error {
+		if _, err := findAuthor(ctx, tx, author.ID); err != nil {
+			return err
+		}
+		if err := tx.Authors().Save(ctx, author); err != nil {
+			return err
+		}
+		return tx.Authors().ReplaceNames(ctx, author.ID, names)
+	})
+}
+
+func (s *authorService) Contributors(ctx context.Context, bookID int) ([]Contributor, error) {
+	return contributors(ctx, s.store, bookID)
+}
+
+func (s *authorService) SetCredits(ctx context.Context, bookID int, credits []Model.BookAuthorModel) ([]Contributor, error) {
+	var result []Contributor
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		book, err := findBook(ctx, tx, bookID)
+		if err != nil {
+			return err
+		}
+
+		authorIDs := make([]int, 0, len(credits))
+		for i := range credits {
+			credits[i].BookID = bookID
+			credits[i].Position = i + 1
+			authorIDs = append(authorIDs, credits[i].AuthorID)
+		}
+		authors, err := tx.Authors().FindByIDs(ctx, authorIDs)
+		if err != nil {
+			return err
+		}
+		known := make(map[int]bool, len(authors))
+		for _, author := range authors {
+			known[author.ID] = true
+		}
+		for _, credit := range credits {
+			if !known[credit.AuthorID] {
+				return ErrUnknownCreditAuthor
+			}
+		}
+
+		err = tx.Authors().ReplaceCredits(ctx, bookID, credits)
+		if errors.Is(err, Repository.ErrDuplicate) {
+			return ErrDuplicateCredit
+		}
+		if err != nil {
+			return err
+		}
+		if result, err = contributors(ctx, tx, bookID); err != nil || len(result) == 0 {
+			return err
+		}
+
+		book.Author = authorStatement(result)
+		return tx.Books().Save(ctx, &book)
+	})
+	return result, err
+}
+
+func (s *authorService) ExtractFromBooks(ctx context.Context) (int, error) {
+	books, err := s.store.Books().FindUncredited(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	credited := 0
+	for batch := range slices.Chunk(books, importBatchSize) {
+		err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+			for _, book := range batch {
+				ok, err := extractCredits(ctx, tx, book)
+				if err != nil {
+					return err
+				}
+				if ok {
+					credited++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return credited, err
+		}
+	}
+	if credited > 0 {
+		slog.InfoContext(ctx, "Extracted authors from author statements", "books", credited)
+	}
+	return credited, nil
+}
+
+// extractCredits credits the authors named in the Author statement of book,
+// unless it was credited meanwhile. It reports whether it credited any.
+func extractCredits(ctx context.Context, tx Repository.Store, book Model.BookModel) (bool, error) {
+	existing, err := tx.Authors().CreditsOfBook(ctx, book.ID)
+	if err != nil || len(existing) > 0 {
+		return false, err
+	}
+
+	var credits []Model.BookAuthorModel
+	for _, named := range parseAuthorStatement(book.Author) {
+		author, err := authorNamed(ctx, tx, named.name)
+		if err != nil {
+			return false, err
+		}
+		if slices.ContainsFunc(credits, func(c Model.BookAuthorModel) bool { return c.AuthorID == author.ID && c.Role == named.role }) {
+			continue
+		}
+		credits = append(credits, Model.BookAuthorModel{BookID: book.ID, AuthorID: author.ID, Role: named.role, Position: len(credits) + 1})
+	}
+	if len(credits) == 0 {
+		return false, nil
+	}
+	return true, tx.Authors().ReplaceCredits(ctx, book.ID, credits)
+}
+
+// authorNamed finds the author known by name, creating one when there is
+// none. Of several authors sharing the name it picks the first created, so
+// imports and extraction credit a name to the same author every time.
+func authorNamed(ctx context.Context, store Repository.Store, name string) (Model.AuthorModel, error) {
+	key := Model.AuthorNameKey(name)
+	found, err := store.Authors().FindByNameKey(ctx, key)
+	if err != nil {
+		return Model.AuthorModel{}, err
+	}
+	if len(found) > 0 {
+		return found[0], nil
+	}
+
+	author := Model.AuthorModel{Name: Model.NaturalName(name)}
+	if err := store.Authors().Create(ctx, &author); err != nil {
+		return author, err
+	}
+	return author, store.Authors().ReplaceNames(ctx, author.ID, []Model.AuthorNameModel{{AuthorID: author.ID, Name: author.Name, Key: key}})
+}
+
+type namedCredit struct {
+	name, role string
+}
+
+var (
+	// authorSeparator splits an author statement into names.
+	authorSeparator = regexp.MustCompile(`\s*(?:;|&|\band\b)\s*`)
+	// roleSuffix matches a role in brackets after a name, as in
+	// "Edith Grossman (trans.)".
+	roleSuffix = regexp.MustCompile(`\s*[(\[]\s*([A-Za-z. ]+?)\s*[)\]]\s*$`)
+	roleNames  = map[string]string{
+		"ed": Model.CreditEditor, "eds": Model.CreditEditor, "editor": Model.CreditEditor,
+		"trans": Model.CreditTranslator, "tr": Model.CreditTranslator, "translator": Model.CreditTranslator,
+		"ill": Model.CreditIllustrator, "illus": Model.CreditIllustrator, "illustrator": Model.CreditIllustrator,
+	}
+)
+
+// parseAuthorStatement reads the people named in a free-text author field
+// such as "Cervantes; Edith Grossman (trans.)". Names are separated by
+// semicolons, ampersands or "and"; a comma inside a name marks it as
+// inverted. Names without a bracketed role are authors.
+func parseAuthorStatement(statement string) []namedCredit {
+	var credits []namedCredit
+	for _, part := range authorSeparator.Split(statement, -1) {
+		role := Model.CreditAuthor
+		if match := roleSuffix.FindStringSubmatch(part); match != nil {
+			if known, ok := roleNames[strings.TrimSuffix(strings.ToLower(strings.TrimSpace(match[1])), ".")]; ok {
+				role = known
+				part = part[:len(part)-len(match[0])]
+			}
+		}
+		if Model.AuthorNameKey(part) != "" {
+			credits = append(credits, namedCredit{name: strings.TrimSpace(part), role: role})
+		}
+	}
+	return credits
+}
+
+// prepareAuthor tidies author and returns its names: the preferred one
+// first, then the variants that spell something new.
+func prepareAuthor(author *Model.AuthorModel, variants []string) ([]Model.AuthorNameModel, error) {
+	author.Name = strings.TrimSpace(author.Name)
+	if Model.AuthorNameKey(author.Name) == "" {
+		return nil, ErrInvalidAuthorName
+	}
+	if author.BirthYear != nil && author.DeathYear != nil && *author.DeathYear < *author.BirthYear {
+		return nil, ErrAuthorDates
+	}
+
+	var names []Model.AuthorNameModel
+	for _, name := range append([]string{author.Name}, variants...) {
+		name = strings.TrimSpace(name)
+		key := Model.AuthorNameKey(name)
+		if key == "" || slices.ContainsFunc(names, func(n Model.AuthorNameModel) bool { return n.Key == key }) {
+			continue
+		}
+		names = append(names, Model.AuthorNameModel{Name: name, Key: key})
+	}
+	return names, nil
+}
+
+// roleAbbreviations are the suffixes authorStatement writes, ones that
+// parseAuthorStatement reads back.
+var roleAbbreviations = map[string]string{
+	Model.CreditEditor:      "ed.",
+	Model.CreditTranslator:  "trans.",
+	Model.CreditIllustrator: "ill.",
+}
+
+// authorStatement writes credits as a free-text author field in the form
+// parseAuthorStatement reads, such as "Cervantes; Edith Grossman (trans.)".
+func authorStatement(credits []Contributor) string {
+	parts := make([]string, 0, len(credits))
+	for _, credit := range credits {
+		part := credit.Author.Name
+		if abbreviation, ok := roleAbbreviations[credit.Role]; ok {
+			part += " (" + abbreviation + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func contributors(ctx context.Context, store Repository.Store, bookID int) ([]Contributor, error) {
+	credits, err := store.Authors().CreditsOfBook(ctx, bookID)
+	if err != nil || len(credits) == 0 {
+		return nil, err
+	}
+	authorIDs := make([]int, 0, len(credits))
+	for _, credit := range credits {
+		authorIDs = append(authorIDs, credit.AuthorID)
+	}
+	authors, err := store.Authors().FindByIDs(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]Model.AuthorModel, len(authors))
+	for _, author := range authors {
+		byID[author.ID] = author
+	}
+
+	result := make([]Contributor, 0, len(credits))
+	for _, credit := range credits {
+		if author, ok := byID[credit.AuthorID]; ok {
+			result = append(result, Contributor{Author: author, Role: credit.Role})
+		}
+	}
+	return result, nil
+}
+
+func findAuthor(ctx context.Context, store Repository.Store, authorID int) (Model.AuthorModel, error) {
+	author, err := store.Authors().FindByID(ctx, authorID)
+	if errors.Is(err, Repository.ErrNotFound) {
+		return author, ErrAuthorNotFound
+	}
+	return author, err
+}
@@ -0,0 +1,233 @@
+package Service
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCopyNotFound = errors.New("copy not found")
+	// ErrDuplicateBarcode rejects a barcode already stuck on another copy.
+	ErrDuplicateBarcode = errors.New("barcode already in use")
+	// ErrCopyOnLoan rejects status changes of a copy someone has borrowed;
+	// it has to be returned first.
+	ErrCopyOnLoan = errors.New("copy is on loan")
+	// ErrDuplicateISBN rejects an ISBN already given to another book; each
+	// edition is catalogued once.
+	ErrDuplicateISBN = errors.New("ISBN already in use")
+)
+
+// BookFilter narrows a book listing; zero fields do not filter.
+type BookFilter struct {
+	// SubjectID keeps books filed under the subject or any subject below
+	// it.
+	SubjectID int
+	// Tag keeps books carrying the tag, in any case or spacing.
+	Tag string
+	// ISBN keeps the book with this ISBN-10 or ISBN-13, in any hyphenation.
+	ISBN string
+}
+
+type CatalogService interface {
+	// ListBooks lists the books matching filter by ID. An unknown subject
+	// fails with ErrSubjectNotFound, a malformed ISBN with
+	// Model.ErrInvalidISBN.
+	ListBooks(ctx context.Context, filter BookFilter) ([]Model.BookModel, error)
+	// Availability counts the copies of each of bookIDs. Books without
+	// copies map to the zero BookAvailability.
+	Availability(ctx context.Context, bookIDs ...int) (map[int]Model.BookAvailability, error)
+	// FindBook resolves ref as a numeric ID, an ISBN-10 or ISBN-13 in any
+	// hyphenation or a slug the book has or used to have. An ID wins over
+	// an ISBN spelled with the same digits. It returns ErrBookNotFound when
+	// nothing matches; callers compare ref with the book's Slug to tell
+	// whether it was the canonical identifier.
+	FindBook(ctx context.Context, ref string) (Model.BookModel, error)
+	// SetISBN gives bookID the ISBN isbn, or clears it when isbn is empty.
+	// It fails with Model.ErrInvalidISBN for anything but a valid ISBN-10 or
+	// ISBN-13, and with ErrDuplicateISBN when another book has it.
+	SetISBN(ctx context.Context, bookID int, isbn string) (Model.BookModel, error)
+
+	// Copies lists every copy of bookID, failing with ErrBookNotFound for
+	// unknown books.
+	Copies(ctx context.Context, bookID int) ([]Model.BookCopyModel, error)
+	// AddCopy adds an available copy of bookID with barcode, acquired at
+	// acquiredAt or, when nil, now.
+	AddCopy(ctx context.Context, bookID int, barcode string, acquiredAt *time.Time) (Model.BookCopyModel, error)
+	// SetCopyStatus records that a copy was lost, sent to repair, withdrawn
+	// or is back on the shelf. Copies on loan fail with ErrCopyOnLoan.
+	SetCopyStatus(ctx context.Context, copyID int, status string) (Model.BookCopyModel, error)
+}
+
+type catalogService struct {
+	store Repository.Store
+	clock Config.Clock
+}
+
+func NewCatalogService(store Repository.Store, clock Config.Clock) CatalogService {
+	return &catalogService{store: store, clock: clock}
+}
+
+func (s *catalogService) ListBooks(ctx context.Context, filter BookFilter) ([]Model.BookModel, error) {
+	if filter == (BookFilter{}) {
+		return s.store.Books().FindAll(ctx)
+	}
+
+	// ids holds the books matching every filter applied so far; nil means
+	// none has been applied yet.
+	var ids []int
+	narrow := func(matching []int) {
+		if ids != nil {
+			matching = slices.DeleteFunc(matching, func(id int) bool { return !slices.Contains(ids, id) })
+		}
+		ids = append([]int{}, matching...)
+	}
+
+	if filter.ISBN != "" {
+		book, err := findByISBN(ctx, s.store, filter.ISBN)
+		switch {
+		case err == nil:
+			narrow([]int{book.ID})
+		case errors.Is(err, ErrBookNotFound):
+			narrow(nil)
+		default:
+			return nil, err
+		}
+	}
+	if filter.SubjectID != 0 {
+		filed, err := booksUnder(ctx, s.store, filter.SubjectID)
+		if err != nil {
+			return nil, err
+		}
+		narrow(filed)
+	}
+	if filter.Tag != "" {
+		tagged, err := s.store.Tags().BookIDsTagged(ctx, Model.NormalizeTag(filter.Tag))
+		if err != nil {
+			return nil, err
+		}
+		narrow(tagged)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return s.store.Books().FindByIDs(ctx, ids)
+}
+
+func (s *catalogService) Availability(ctx context.Context, bookIDs ...int) (map[int]Model.BookAvailability, error) {
+	return s.store.Copies().Availability(ctx, bookIDs)
+}
+
+func (s *catalogService) FindBook(ctx context.Context, ref string) (Model.BookModel, error) {
+	if id, err := strconv.Atoi(ref); err == nil && id > 0 && strconv.Itoa(id) == ref {
+		book, err := findBook(ctx, s.store, id)
+		if !errors.Is(err, ErrBookNotFound) || !Model.ValidISBN(ref) {
+			return book, err
+		}
+	}
+	if Model.ValidISBN(ref) {
+		return findByISBN(ctx, s.store, ref)
+	}
+
+	book, err := s.store.Books().FindBySlug(ctx, ref)
+	if errors.Is(err, Repository.ErrNotFound) {
+		return book, ErrBookNotFound
+	}
+	return book, err
+}
+
+func (s *catalogService) SetISBN(ctx context.Context, bookID int, isbn string) (Model.BookModel, error) {
+	var book Model.BookModel
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		var err error
+		if book, err = findBook(ctx, tx, bookID); err != nil {
+			return err
+		}
+		if err := book.SetISBN(isbn); err != nil {
+			return err
+		}
+
+		err = tx.Books().Save(ctx, &book)
+		if errors.Is(err, Repository.ErrDuplicate) {
+			return ErrDuplicateISBN
+		}
+		return err
+	})
+	return book, err
+}
+
+func (s *catalogService) Copies(ctx context.Context, bookID int) ([]Model.BookCopyModel, error) {
+	if _, err := findBook(ctx, s.store, bookID); err != nil {
+		return nil, err
+	}
+	return s.store.Copies().FindByBook(ctx, bookID)
+}
+
+func (s *catalogService) AddCopy(ctx context.Context, bookID int, barcode string, acquiredAt *time.Time) (Model.BookCopyModel, error) {
+	item := Model.BookCopyModel{
+		BookID:     bookID,
+		Barcode:    strings.TrimSpace(barcode),
+		Status:     Model.CopyAvailable,
+		AcquiredAt: s.clock.Now(),
+	}
+	if acquiredAt != nil {
+		item.AcquiredAt = *acquiredAt
+	}
+
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		if _, err := findBook(ctx, tx, bookID); err != nil {
+			return err
+		}
+		err := tx.Copies().Create(ctx, &item)
+		if errors.Is(err, Repository.ErrDuplicate) {
+			return ErrDuplicateBarcode
+		}
+		return err
+	})
+	if err == nil {
+		slog.InfoContext(ctx, "Copy added", "bookId", bookID, "copyId", item.ID, "barcode", item.Barcode)
+	}
+	return item, err
+}
+
+func (s *catalogService) SetCopyStatus(ctx context.Context, copyID int, status string) (Model.BookCopyModel, error) {
+	var item Model.BookCopyModel
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		var err error
+		item, err = tx.Copies().FindByID(ctx, copyID)
+		if errors.Is(err, Repository.ErrNotFound) {
+			return ErrCopyNotFound
+		}
+		if err != nil || item.Status == status {
+			return err
+		}
+		if item.Status == Model.CopyOnLoan {
+			return ErrCopyOnLoan
+		}
+
+		slog.InfoContext(ctx, "Copy status changed", "copyId", item.ID, "from", item.Status, "to", status)
+		item.Status = status
+		return tx.Copies().Save(ctx, &item)
+	})
+	return item, err
+}
+
+// findByISBN looks a book up by isbn in either form and any hyphenation.
+func findByISBN(ctx context.Context, store Repository.Store, isbn string) (Model.BookModel, error) {
+	isbn13, _, err := Model.ParseISBN(isbn)
+	if err != nil {
+		return Model.BookModel{}, err
+	}
+	book, err := store.Books().FindByISBN(ctx, isbn13)
+	if errors.Is(err, Repository.ErrNotFound) {
+		return book, ErrBookNotFound
+	}
+	return book, err
+}
@@ -0,0 +1,227 @@
+package Service
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+const (
+	// MaxCoverSize bounds uploaded cover images, in bytes.
+	MaxCoverSize = 5 << 20
+	// MaxCoverDimension bounds each side of a cover image, in pixels, so a
+	// small file cannot decompress into an enormous bitmap.
+	MaxCoverDimension = 6000
+)
+
+var (
+	ErrCoverNotFound = errors.New("cover not found")
+	// ErrCoverTooLarge rejects images over MaxCoverSize bytes.
+	ErrCoverTooLarge = errors.New("cover image too large")
+	// ErrCoverType rejects anything but JPEG and PNG images.
+	ErrCoverType = errors.New("unsupported cover image type")
+	// ErrCoverImage rejects files that do not decode, or whose sides exceed
+	// MaxCoverDimension.
+	ErrCoverImage = errors.New("invalid cover image")
+)
+
+// coverTypes are the content types accepted for upload.
+var coverTypes = []string{"image/jpeg", "image/png"}
+
+// Cover is one size of a book's cover image.
+type Cover struct {
+	Data        []byte
+	ContentType string
+	// Version is the BookModel.Cover the image belongs to.
+	Version string
+}
+
+type CoverService interface {
+	// Upload replaces the cover of bookID with image, a JPEG or PNG, and
+	// renders its thumbnails.
+	Upload(ctx context.Context, bookID int, image []byte) (Model.BookModel, error)
+	// Remove deletes the cover of bookID, failing with ErrCoverNotFound when
+	// it has none.
+	Remove(ctx context.Context, bookID int) error
+	// Find returns size of the cover of bookID, one of the Model.Cover
+	// sizes.
+	Find(ctx context.Context, bookID int, size string) (Cover, error)
+}
+
+type coverService struct {
+	store Repository.Store
+	blobs Config.BlobStore
+}
+
+func NewCoverService(store Repository.Store, blobs Config.BlobStore) CoverService {
+	return &coverService{store: store, blobs: blobs}
+}
+
+func (s *coverService) Upload(ctx context.Context, bookID int, data []byte) (Model.BookModel, error) {
+	if len(data) > MaxCoverSize {
+		return Model.BookModel{}, ErrCoverTooLarge
+	}
+	if contentType := http.DetectContentType(data); !slices.Contains(coverTypes, contentType) {
+		return Model.BookModel{}, ErrCoverType
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width > MaxCoverDimension || config.Height > MaxCoverDimension {
+		return Model.BookModel{}, ErrCoverImage
+	}
+	original, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Model.BookModel{}, ErrCoverImage
+	}
+
+	book, err := findBook(ctx, s.store, bookID)
+	if err != nil {
+		return book, err
+	}
+
+	digest := sha256.Sum256(data)
+	version := hex.EncodeToString(digest[:8])
+	if version == book.Cover {
+		return book, nil
+	}
+
+	// Medium is scaled from the original and small from medium, so the
+	// full-size image is only walked once.
+	blobs := map[string][]byte{Model.CoverOriginal: data}
+	scaled := original
+	for _, size := range []string{Model.CoverMedium, Model.CoverSmall} {
+		scaled = thumbnail(scaled, Model.CoverWidths[size])
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85}); err != nil {
+			return book, err
+		}
+		blobs[size] = buf.Bytes()
+	}
+	for size, blob := range blobs {
+		if err := s.blobs.Put(ctx, coverKey(bookID, version, size), blob); err != nil {
+			return book, err
+		}
+	}
+
+	previous := book.Cover
+	if err := setCover(ctx, s.store, bookID, version); err != nil {
+		return book, err
+	}
+	book.Cover = version
+	slog.InfoContext(ctx, "Cover uploaded", "bookId", bookID, "version", version, "width", config.Width, "height", config.Height)
+	s.deleteBlobs(ctx, bookID, previous)
+	return book, nil
+}
+
+func (s *coverService) Remove(ctx context.Context, bookID int) error {
+	book, err := findBook(ctx, s.store, bookID)
+	if err != nil {
+		return err
+	}
+	if book.Cover == "" {
+		return ErrCoverNotFound
+	}
+
+	previous := book.Cover
+	if err := setCover(ctx, s.store, bookID, ""); err != nil {
+		return err
+	}
+	s.deleteBlobs(ctx, bookID, previous)
+	return nil
+}
+
+// setCover writes only the cover column, so edits made to the book while
+// an image was being scaled are not undone.
+func setCover(ctx context.Context, store Repository.Store, bookID int, version string) error {
+	err := store.Books().SetCover(ctx, bookID, version)
+	if errors.Is(err, Repository.ErrNotFound) {
+		return ErrBookNotFound
+	}
+	return err
+}
+
+func (s *coverService) Find(ctx context.Context, bookID int, size string) (Cover, error) {
+	book, err := findBook(ctx, s.store, bookID)
+	if errors.Is(err, ErrBookNotFound) || (err == nil && book.Cover == "") {
+		return Cover{}, ErrCoverNotFound
+	}
+	if err != nil {
+		return Cover{}, err
+	}
+
+	data, err := s.blobs.Get(ctx, coverKey(bookID, book.Cover, size))
+	if errors.Is(err, Config.ErrBlobNotFound) {
+		return Cover{}, ErrCoverNotFound
+	}
+	if err != nil {
+		return Cover{}, err
+	}
+	return Cover{Data: data, ContentType: http.DetectContentType(data), Version: book.Cover}, nil
+}
+
+// deleteBlobs removes every size of version of the cover of bookID. The
+// book no longer points at them, so failures only leave garbage behind and
+// are logged rather than returned.
+func (s *coverService) deleteBlobs(ctx context.Context, bookID int, version string) {
+	if version == "" {
+		return
+	}
+	for _, size := range []string{Model.CoverOriginal, Model.CoverMedium, Model.CoverSmall} {
+		if err := s.blobs.Delete(ctx, coverKey(bookID, version, size)); err != nil {
+			slog.WarnContext(ctx, "Failed to delete cover image", "bookId", bookID, "version", version, "size", size, "error", err)
+		}
+	}
+}
+
+func coverKey(bookID int, version, size string) string {
+	return fmt.Sprintf("covers/%d/%s/%s", bookID, version, size)
+}
+
+// thumbnail scales src down to width pixels, keeping its aspect ratio, by
+// averaging the source pixels behind each target pixel. Transparent areas
+// are flattened onto white since JPEG has no alpha channel.
+func thumbnail(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	width = min(width, bounds.Dx())
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := range width {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			// Colors are premultiplied, so adding the missing coverage
+			// composites the pixel over white.
+			white := 0xffff - a/n
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r/n + white),
+				G: uint16(g/n + white),
+				B: uint16(b/n + white),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,501 @@
+package Service
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// MaxImportRows bounds the data rows of one import file.
+	MaxImportRows = 100_000
+	// RecentImports is how many jobs ImportService.List returns.
+	RecentImports = 50
+	// importBatchSize is how many rows an import, or the author extraction
+	// after it, writes per transaction.
+	// SQLite has one writer at a time, so a batch holds up other requests
+	// for as long as it takes to write.
+	importBatchSize = 500
+)
+
+var ErrImportNotFound = errors.New("import job not found")
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// ImportFileError rejects an import file as a whole, before any job is
+// started. Field names the request field at fault.
+type ImportFileError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ImportFileError) Error() string {
+	return e.Field + " " + e.Reason
+}
+
+// Import is a parsed import file, ready to run.
+type Import struct {
+	Format string
+	DryRun bool
+	Rows   []ImportRow
+}
+
+// ImportRow is one record of an import file, keyed by Model.ImportFields.
+type ImportRow struct {
+	// Line is where the record starts in the file.
+	Line int
+	// Values is nil for records that could not be read at all, whose
+	// fields are then not checked.
+	Values map[string]string
+	// Problems are found while parsing, such as a value of the wrong type.
+	Problems []Model.ImportErrorModel
+	// Credits and Subjects are only read from MARC records. When a record
+	// has any, they replace those of its book; other rows leave them be.
+	Credits []ImportCredit
+	// Subjects are paths of subject names, broadest first. Subjects not in
+	// the taxonomy yet are created.
+	Subjects [][]string
+}
+
+// ImportCredit names a contributor to an imported book, who is matched to
+// an author by name or added as one.
+type ImportCredit struct {
+	Name string
+	Role string
+}
+
+type ImportService interface {
+	// Parse reads data in format, Model.ImportCSV or Model.ImportJSONLines,
+	// renaming columns as mapping says; columns named like one of
+	// Model.ImportFields map to it unless mapping says otherwise. MARC
+	// formats, Model.ImportMARC and Model.ImportMARCXML, have one row per
+	// record and take no mapping. Problems
+	// with the file as a whole fail with *ImportFileError; problems with a
+	// row are kept with the row.
+	Parse(format string, data []byte, mapping map[string]string) (Import, error)
+	// Start records a job for file and runs it in the background.
+	Start(ctx context.Context, file Import) (Model.ImportJobModel, error)
+	Find(ctx context.Context, id int) (Model.ImportJobModel, []Model.ImportErrorModel, error)
+	// List returns the RecentImports latest jobs, newest first.
+	List(ctx context.Context) ([]Model.ImportJobModel, error)
+	// Wait blocks until every started job has finished or ctx is done.
+	Wait(ctx context.Context) error
+}
+
+type importService struct {
+	store   Repository.Store
+	clock   Config.Clock
+	authors AuthorService
+	running sync.WaitGroup
+}
+
+func NewImportService(store Repository.Store, clock Config.Clock, authors AuthorService) ImportService {
+	return &importService{store: store, clock: clock, authors: authors}
+}
+
+// ImportFormat guesses the format of a file from its name, returning "" for
+// unknown extensions.
+func ImportFormat(name string) string {
+	switch {
+	case strings.HasSuffix(strings.ToLower(name), ".csv"):
+		return Model.ImportCSV
+	case strings.HasSuffix(strings.ToLower(name), ".jsonl"), strings.HasSuffix(strings.ToLower(name), ".ndjson"):
+		return Model.ImportJSONLines
+	case strings.HasSuffix(strings.ToLower(name), ".mrc"), strings.HasSuffix(strings.ToLower(name), ".marc"):
+		return Model.ImportMARC
+	case strings.HasSuffix(strings.ToLower(name), ".xml"):
+		return Model.ImportMARCXML
+	}
+	return ""
+}
+
+func (s *importService) Parse(format string, data []byte, mapping map[string]string) (Import, error) {
+	for column, field := range mapping {
+		if field != "" && !slices.Contains(Model.ImportFields, field) {
+			return Import{}, &ImportFileError{Field: "mapping", Reason: fmt.Sprintf("maps %q to unknown field %q", column, field)}
+		}
+	}
+	isMARC := format == Model.ImportMARC || format == Model.ImportMARCXML
+	if isMARC && len(mapping) > 0 {
+		return Import{}, &ImportFileError{Field: "mapping", Reason: "cannot be used with MARC records"}
+	}
+	// MARC records are checked one by one, so a record in another
+	// encoding only rejects itself.
+	if !isMARC && !utf8.Valid(data) {
+		return Import{}, &ImportFileError{Field: "file", Reason: "must be UTF-8 text"}
+	}
+	data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+
+	file := Import{Format: format}
+	var err error
+	switch format {
+	case Model.ImportCSV:
+		file.Rows, err = parseCSV(data, mapping)
+	case Model.ImportJSONLines:
+		file.Rows, err = parseJSONLines(data, mapping)
+	case Model.ImportMARC:
+		file.Rows, err = parseMARC(data)
+	case Model.ImportMARCXML:
+		file.Rows, err = parseMARCXML(data)
+	default:
+		return Import{}, &ImportFileError{Field: "format", Reason: "must be one of csv, jsonl, marc, marcxml"}
+	}
+	if err != nil {
+		return Import{}, err
+	}
+	if len(file.Rows) == 0 {
+		return Import{}, &ImportFileError{Field: "file", Reason: "has no rows"}
+	}
+	if len(file.Rows) > MaxImportRows {
+		return Import{}, &ImportFileError{Field: "file", Reason: fmt.Sprintf("must have at most %d rows", MaxImportRows)}
+	}
+	return file, nil
+}
+
+func (s *importService) Start(ctx context.Context, file Import) (Model.ImportJobModel, error) {
+	job := Model.ImportJobModel{
+		Format:    file.Format,
+		DryRun:    file.DryRun,
+		Status:    Model.ImportQueued,
+		Rows:      len(file.Rows),
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.store.ImportJobs().Create(ctx, &job); err != nil {
+		return job, err
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		// The job outlives the request that started it.
+		s.run(context.WithoutCancel(ctx), job, file.Rows)
+	}()
+	return job, nil
+}
+
+func (s *importService) Find(ctx context.Context, id int) (Model.ImportJobModel, []Model.ImportErrorModel, error) {
+	job, err := s.store.ImportJobs().FindByID(ctx, id)
+	if errors.Is(err, Repository.ErrNotFound) {
+		return job, nil, ErrImportNotFound
+	}
+	if err != nil {
+		return job, nil, err
+	}
+	problems, err := s.store.ImportJobs().Errors(ctx, id)
+	return job, problems, err
+}
+
+func (s *importService) List(ctx context.Context) ([]Model.ImportJobModel, error) {
+	return s.store.ImportJobs().FindRecent(ctx, RecentImports)
+}
+
+func (s *importService) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run imports rows in transactions of importBatchSize rows, so other
+// requests can write between them. A failure part way leaves the batches
+// before it saved. Dry runs roll each batch back on purpose, so a row does
+// not see the books of earlier batches.
+func (s *importService) run(ctx context.Context, job Model.ImportJobModel, rows []ImportRow) {
+	logger := slog.With("importId", job.ID, "format", job.Format, "dryRun", job.DryRun)
+	started := s.clock.Now()
+	job.Status, job.StartedAt = Model.ImportRunning, &started
+	if err := s.store.ImportJobs().Save(ctx, &job); err != nil {
+		logger.ErrorContext(ctx, "Failed to start import", "error", err)
+		return
+	}
+
+	var problems []Model.ImportErrorModel
+	var err error
+	for batch := range slices.Chunk(rows, importBatchSize) {
+		var created, updated, failed int
+		var batchProblems []Model.ImportErrorModel
+		err = s.store.Transaction(ctx, func(tx Repository.Store) error {
+			for _, row := range batch {
+				rowProblems := row.Problems
+				if row.Values != nil {
+					rowProblems = slices.Concat(row.Problems, validateImportRow(row))
+				}
+				if len(rowProblems) == 0 {
+					existed, err := upsertImportRow(ctx, tx, row)
+					if err != nil {
+						return fmt.Errorf("line %d: %w", row.Line, err)
+					}
+					if existed {
+						updated++
+					} else {
+						created++
+					}
+					continue
+				}
+
+				failed++
+				for _, problem := range rowProblems {
+					problem.JobID, problem.Row = job.ID, row.Line
+					batchProblems = append(batchProblems, problem)
+				}
+			}
+			if job.DryRun {
+				return errDryRun
+			}
+			return nil
+		})
+		if errors.Is(err, errDryRun) {
+			err = nil
+		}
+		if err != nil {
+			break
+		}
+		job.Created, job.Updated, job.Failed = job.Created+created, job.Updated+updated, job.Failed+failed
+		problems = append(problems, batchProblems...)
+	}
+
+	if addErr := s.store.ImportJobs().AddErrors(ctx, problems); err == nil {
+		err = addErr
+	}
+	if !job.DryRun && job.Created > 0 {
+		// Credit the authors of new books the way books catalogued before
+		// authors were tracked are credited at startup.
+		if _, extractErr := s.authors.ExtractFromBooks(ctx); extractErr != nil {
+			logger.WarnContext(ctx, "Failed to credit imported authors", "error", extractErr)
+		}
+	}
+
+	finished := s.clock.Now()
+	job.FinishedAt = &finished
+	if err != nil {
+		logger.ErrorContext(ctx, "Import failed", "error", err, "created", job.Created, "updated", job.Updated)
+		job.Status = Model.ImportFailed
+		job.Error = "The import failed part way; the rows counted as created or updated were saved, see the server log for details"
+	} else {
+		logger.InfoContext(ctx, "Import finished", "rows", job.Rows, "created", job.Created, "updated", job.Updated, "failed", job.Failed)
+		job.Status = Model.ImportSucceeded
+	}
+	if err := s.store.ImportJobs().Save(ctx, &job); err != nil {
+		logger.ErrorContext(ctx, "Failed to record import result", "error", err)
+	}
+}
+
+func validateImportRow(row ImportRow) []Model.ImportErrorModel {
+	var problems []Model.ImportErrorModel
+	for _, field := range []string{"title", "author"} {
+		if row.Values[field] == "" {
+			problems = append(problems, Model.ImportErrorModel{Field: field, Message: "is required"})
+		}
+	}
+	for field, limit := range map[string]int{"title": 300, "author": 300, "description": 10_000} {
+		if utf8.RuneCountInString(row.Values[field]) > limit {
+			problems = append(problems, Model.ImportErrorModel{Field: field, Message: fmt.Sprintf("must be at most %d characters", limit)})
+		}
+	}
+	if isbn := row.Values["isbn"]; isbn != "" && !Model.ValidISBN(isbn) {
+		problems = append(problems, Model.ImportErrorModel{Field: "isbn", Message: "must be a valid ISBN-10 or ISBN-13"})
+	}
+	if slices.ContainsFunc(slices.Concat(row.Subjects...), func(name string) bool { return utf8.RuneCountInString(name) > 100 }) {
+		problems = append(problems, Model.ImportErrorModel{Field: "subjects", Message: "must have names of at most 100 characters"})
+	}
+	slices.SortFunc(problems, func(a, b Model.ImportErrorModel) int { return strings.Compare(a.Field, b.Field) })
+	return problems
+}
+
+// upsertImportRow updates the book with the row's ISBN, or creates one. It
+// reports whether an existing book was updated. A description column left
+// empty keeps the description a book already has; so do credits and
+// subjects.
+func upsertImportRow(ctx context.Context, tx Repository.Store, row ImportRow) (bool, error) {
+	var book Model.BookModel
+	if row.Values["isbn"] != "" {
+		found, err := findByISBN(ctx, tx, row.Values["isbn"])
+		if err != nil && !errors.Is(err, ErrBookNotFound) {
+			return false, err
+		}
+		book = found
+	}
+
+	book.Title, book.Author = row.Values["title"], row.Values["author"]
+	if description := row.Values["description"]; description != "" {
+		book.Description = description
+	}
+	updated := book.ID != 0
+	var err error
+	if updated {
+		err = tx.Books().Save(ctx, &book)
+	} else if err = book.SetISBN(row.Values["isbn"]); err == nil {
+		err = tx.Books().Create(ctx, &book)
+	}
+	if err == nil && len(row.Credits) > 0 {
+		err = importCredits(ctx, tx, book.ID, row.Credits)
+	}
+	if err == nil && len(row.Subjects) > 0 {
+		err = importSubjects(ctx, tx, book.ID, row.Subjects)
+	}
+	return updated, err
+}
+
+// importCredits replaces the credits of bookID, adding authors nobody
+// knows yet.
+func importCredits(ctx context.Context, tx Repository.Store, bookID int, credits []ImportCredit) error {
+	var links []Model.BookAuthorModel
+	for _, credit := range credits {
+		author, err := authorNamed(ctx, tx, credit.Name)
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(links, func(l Model.BookAuthorModel) bool { return l.AuthorID == author.ID && l.Role == credit.Role }) {
+			continue
+		}
+		links = append(links, Model.BookAuthorModel{BookID: bookID, AuthorID: author.ID, Role: credit.Role, Position: len(links) + 1})
+	}
+	return tx.Authors().ReplaceCredits(ctx, bookID, links)
+}
+
+// importSubjects files bookID under the subjects at paths instead of its
+// current ones, adding the subjects the taxonomy lacks.
+func importSubjects(ctx context.Context, tx Repository.Store, bookID int, paths [][]string) error {
+	subjects, err := loadTaxonomy(ctx, tx)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for _, path := range paths {
+		id, err := subjectAtPath(ctx, tx, subjects, path)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return tx.Subjects().ReplaceBookSubjects(ctx, bookID, ids)
+}
+
+// importField decides which field column feeds, or "" to ignore it.
+func importField(column string, mapping map[string]string) string {
+	for name, field := range mapping {
+		if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(column)) {
+			return field
+		}
+	}
+	key := strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(column))
+	switch key {
+	case "isbn13", "isbn10":
+		return "isbn"
+	}
+	if slices.Contains(Model.ImportFields, key) {
+		return key
+	}
+	return ""
+}
+
+func parseCSV(data []byte, mapping map[string]string) ([]ImportRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, &ImportFileError{Field: "file", Reason: "has no header row"}
+	}
+	if err != nil {
+		return nil, &ImportFileError{Field: "file", Reason: "is not valid CSV: " + err.Error()}
+	}
+	fields := make([]string, len(header))
+	for i, column := range header {
+		fields[i] = importField(column, mapping)
+	}
+	for _, required := range []string{"title", "author"} {
+		if !slices.Contains(fields, required) {
+			return nil, &ImportFileError{Field: "file", Reason: fmt.Sprintf("has no column for %s; name one %q or map it", required, required)}
+		}
+	}
+
+	var rows []ImportRow
+	for len(rows) <= MaxImportRows {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, &ImportFileError{Field: "file", Reason: "is not valid CSV: " + err.Error()}
+		}
+		line, _ := reader.FieldPos(0)
+
+		row := ImportRow{Line: line, Values: map[string]string{}}
+		if len(record) != len(header) {
+			row.Problems = append(row.Problems, Model.ImportErrorModel{
+				Message: fmt.Sprintf("has %d columns but the header has %d", len(record), len(header)),
+			})
+		}
+		for i, value := range record {
+			if i < len(fields) && fields[i] != "" {
+				row.Values[fields[i]] = strings.TrimSpace(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseJSONLines(data []byte, mapping map[string]string) ([]ImportRow, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+
+	var rows []ImportRow
+	for line := 1; scanner.Scan() && len(rows) <= MaxImportRows; line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := ImportRow{Line: line, Values: map[string]string{}}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			row.Problems = append(row.Problems, Model.ImportErrorModel{Message: "is not a JSON object"})
+			rows = append(rows, row)
+			continue
+		}
+		for column, value := range record {
+			field := importField(column, mapping)
+			if field == "" {
+				continue
+			}
+			switch v := value.(type) {
+			case nil:
+			case string:
+				row.Values[field] = strings.TrimSpace(v)
+			case float64:
+				row.Values[field] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				row.Problems = append(row.Problems, Model.ImportErrorModel{Field: field, Message: "must be a string"})
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
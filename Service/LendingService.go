@@ -0,0 +1,153 @@
+package Service
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"context"
+	"errors"
+	"time"
+)
+
+// LoanPeriod is how long a borrowed book may be kept before it is overdue.
+const LoanPeriod = 14 * 24 * time.Hour
+
+var (
+	ErrBookNotFound = errors.New("book not found")
+	// ErrBookUnavailable means no copy of the book is on the shelf.
+	ErrBookUnavailable = errors.New("book is already borrowed")
+	// ErrAlreadyBorrowed stops a user from holding two copies of one title.
+	ErrAlreadyBorrowed = errors.New("user already has a copy of the book")
+	ErrBookNotBorrowed = errors.New("book is already returned")
+)
+
+type LendingService interface {
+	// Borrow lends an available copy of bookID to userID, failing with
+	// ErrBookUnavailable when there is none, with ErrAlreadyBorrowed when
+	// the user already has one and with ErrEmailNotVerified when the user has
+	// not confirmed their address.
+	Borrow(ctx context.Context, userID, bookID int) (Model.LoanModel, Model.BookCopyModel, error)
+	// Return closes the open loan of bookID held by userID and puts the copy
+	// back on the shelf, failing with ErrBookNotBorrowed when there is none.
+	Return(ctx context.Context, userID, bookID int) error
+	ActiveLoans(ctx context.Context) (int64, error)
+	OverdueLoans(ctx context.Context) (int64, error)
+}
+
+type lendingService struct {
+	store Repository.Store
+	clock Config.Clock
+}
+
+func NewLendingService(store Repository.Store, clock Config.Clock) LendingService {
+	return &lendingService{store: store, clock: clock}
+}
+
+func (s *lendingService) Borrow(ctx context.Context, userID, bookID int) (Model.LoanModel, Model.BookCopyModel, error) {
+	var loan Model.LoanModel
+	var item Model.BookCopyModel
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		user, err := findUser(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if !user.EmailVerified() {
+			return ErrEmailNotVerified
+		}
+
+		book, err := findBook(ctx, tx, bookID)
+		if err != nil {
+			return err
+		}
+		if _, err := findOpenLoan(ctx, tx, userID, book.ID); err == nil {
+			return ErrAlreadyBorrowed
+		} else if !errors.Is(err, ErrBookNotBorrowed) {
+			return err
+		}
+
+		item, err = tx.Copies().FindAvailable(ctx, book.ID)
+		if errors.Is(err, Repository.ErrNotFound) {
+			return ErrBookUnavailable
+		}
+		if err != nil {
+			return err
+		}
+		item.Status = Model.CopyOnLoan
+		if err := tx.Copies().Save(ctx, &item); err != nil {
+			return err
+		}
+
+		now := s.clock.Now()
+		loan = Model.LoanModel{
+			BookID:     book.ID,
+			CopyID:     item.ID,
+			UserID:     userID,
+			BorrowedAt: now,
+			DueAt:      now.Add(LoanPeriod),
+		}
+		return tx.Loans().Create(ctx, &loan)
+	})
+	return loan, item, err
+}
+
+func (s *lendingService) Return(ctx context.Context, userID, bookID int) error {
+	return s.store.Transaction(ctx, func(tx Repository.Store) error {
+		book, err := findBook(ctx, tx, bookID)
+		if err != nil {
+			return err
+		}
+		loan, err := findOpenLoan(ctx, tx, userID, book.ID)
+		if err != nil {
+			return err
+		}
+
+		now := s.clock.Now()
+		loan.ReturnedAt = &now
+		if err := tx.Loans().Save(ctx, &loan); err != nil {
+			return err
+		}
+
+		item, err := tx.Copies().FindByID(ctx, loan.CopyID)
+		if errors.Is(err, Repository.ErrNotFound) {
+			// The copy may have been deleted while out; the loan is
+			// closed all the same.
+			return nil
+		}
+		if err != nil || item.Status != Model.CopyOnLoan {
+			return err
+		}
+		item.Status = Model.CopyAvailable
+		return tx.Copies().Save(ctx, &item)
+	})
+}
+
+func (s *lendingService) ActiveLoans(ctx context.Context) (int64, error) {
+	return s.store.Loans().CountOpen(ctx)
+}
+
+func (s *lendingService) OverdueLoans(ctx context.Context) (int64, error) {
+	return s.store.Loans().CountOverdue(ctx, s.clock.Now())
+}
+
+// findOpenLoan returns the loan of bookID userID has not returned yet, or
+// ErrBookNotBorrowed.
+func findOpenLoan(ctx context.Context, store Repository.Store, userID, bookID int) (Model.LoanModel, error) {
+	loans, err := store.Loans().FindOpenByUser(ctx, userID)
+	if err != nil {
+		return Model.LoanModel{}, err
+	}
+	for _, loan := range loans {
+		if loan.BookID == bookID {
+			return loan, nil
+		}
+	}
+	return Model.LoanModel{}, ErrBookNotBorrowed
+}
+
+func findBook(ctx context.Context, store Repository.Store, bookID int) (Model.BookModel, error) {
+	book, err := store.Books().FindByID(ctx, bookID)
+	if errors.Is(err, Repository.ErrNotFound) {
+		return book, ErrBookNotFound
+	}
+	return book, err
+}
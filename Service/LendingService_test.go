@@ -0,0 +1,170 @@
+package Service_test
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"awesomeProject/Service"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var (
+	ctx       = context.Background()
+	testStart = time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+)
+
+// lendingFixture is a lending service on a memory store with a fake clock.
+type lendingFixture struct {
+	t       *testing.T
+	store   Repository.Store
+	clock   *Config.FakeClock
+	lending Service.LendingService
+}
+
+func newLendingFixture(t *testing.T) *lendingFixture {
+	store := Repository.NewMemoryStore()
+	clock := Config.NewFakeClock(testStart)
+	return &lendingFixture{t: t, store: store, clock: clock, lending: Service.NewLendingService(store, clock)}
+}
+
+// member creates a user, with a confirmed address when verified is set.
+func (f *lendingFixture) member(email string, verified bool) Model.UserModel {
+	f.t.Helper()
+	user := Model.UserModel{UserName: email, Email: email, Password: "hash", Role: Model.RoleMember}
+	if verified {
+		now := f.clock.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := f.store.Users().Create(ctx, &user); err != nil {
+		f.t.Fatal(err)
+	}
+	return user
+}
+
+// book creates a book with the given number of copies on the shelf.
+func (f *lendingFixture) book(title string, copies int) Model.BookModel {
+	f.t.Helper()
+	book := Model.BookModel{Title: title, Author: "Ursula K. Le Guin"}
+	if err := f.store.Books().Create(ctx, &book); err != nil {
+		f.t.Fatal(err)
+	}
+	for i := range copies {
+		item := Model.BookCopyModel{BookID: book.ID, Barcode: fmt.Sprintf("LIB-%d-%d", book.ID, i+1), Status: Model.CopyAvailable, AcquiredAt: testStart}
+		if err := f.store.Copies().Create(ctx, &item); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+	return book
+}
+
+func expectErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Errorf("error = %v, want %v", err, want)
+	}
+}
+
+func TestBorrow(t *testing.T) {
+	t.Run("lends a copy until the end of the loan period", func(t *testing.T) {
+		f := newLendingFixture(t)
+		user := f.member("ged@example.com", true)
+		book := f.book("A Wizard of Earthsea", 1)
+
+		loan, item, err := f.lending.Borrow(ctx, user.UserId, book.ID)
+
+		if err != nil || loan.BookID != book.ID || loan.CopyID != item.ID || !loan.DueAt.Equal(testStart.Add(Service.LoanPeriod)) {
+			t.Fatalf("Borrow = %+v, %+v, %v", loan, item, err)
+		}
+		stored, err := f.store.Copies().FindByID(ctx, item.ID)
+		if err != nil || stored.Status != Model.CopyOnLoan {
+			t.Errorf("copy = %+v, %v, want it on loan", stored, err)
+		}
+	})
+
+	t.Run("refuses a second copy of a title already borrowed", func(t *testing.T) {
+		f := newLendingFixture(t)
+		user := f.member("ged@example.com", true)
+		book := f.book("A Wizard of Earthsea", 2)
+		if _, _, err := f.lending.Borrow(ctx, user.UserId, book.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err := f.lending.Borrow(ctx, user.UserId, book.ID)
+
+		expectErr(t, err, Service.ErrAlreadyBorrowed)
+		if item, err := f.store.Copies().FindAvailable(ctx, book.ID); err != nil {
+			t.Errorf("FindAvailable = %+v, %v, want the second copy still on the shelf", item, err)
+		}
+	})
+
+	t.Run("needs a verified email address", func(t *testing.T) {
+		f := newLendingFixture(t)
+		user := f.member("ged@example.com", false)
+		book := f.book("A Wizard of Earthsea", 1)
+
+		_, _, err := f.lending.Borrow(ctx, user.UserId, book.ID)
+
+		expectErr(t, err, Service.ErrEmailNotVerified)
+	})
+
+	t.Run("fails when no copy is on the shelf", func(t *testing.T) {
+		f := newLendingFixture(t)
+		first := f.member("ged@example.com", true)
+		second := f.member("tenar@example.com", true)
+		book := f.book("The Tombs of Atuan", 1)
+		if _, _, err := f.lending.Borrow(ctx, first.UserId, book.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err := f.lending.Borrow(ctx, second.UserId, book.ID)
+
+		expectErr(t, err, Service.ErrBookUnavailable)
+		_, _, err = f.lending.Borrow(ctx, second.UserId, f.book("The Farthest Shore", 0).ID)
+		expectErr(t, err, Service.ErrBookUnavailable)
+	})
+}
+
+func TestReturn(t *testing.T) {
+	t.Run("puts the copy back on the shelf", func(t *testing.T) {
+		f := newLendingFixture(t)
+		user := f.member("ged@example.com", true)
+		book := f.book("A Wizard of Earthsea", 1)
+		_, item, err := f.lending.Borrow(ctx, user.UserId, book.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.clock.Advance(24 * time.Hour)
+
+		if err := f.lending.Return(ctx, user.UserId, book.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		available, err := f.store.Copies().FindAvailable(ctx, book.ID)
+		if err != nil || available.ID != item.ID {
+			t.Errorf("FindAvailable = %+v, %v, want the returned copy", available, err)
+		}
+		expectErr(t, f.lending.Return(ctx, user.UserId, book.ID), Service.ErrBookNotBorrowed)
+	})
+
+	t.Run("refuses someone else's loan", func(t *testing.T) {
+		f := newLendingFixture(t)
+		borrower := f.member("ged@example.com", true)
+		other := f.member("tenar@example.com", true)
+		book := f.book("A Wizard of Earthsea", 1)
+		if _, _, err := f.lending.Borrow(ctx, borrower.UserId, book.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		err := f.lending.Return(ctx, other.UserId, book.ID)
+
+		expectErr(t, err, Service.ErrBookNotBorrowed)
+		loans, err := f.store.Loans().FindOpenByUser(ctx, borrower.UserId)
+		if err != nil || len(loans) != 1 {
+			t.Errorf("open loans of the borrower = %+v, %v, want the loan still open", loans, err)
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package Service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrTooManyAttempts is matched by *ThrottledError.
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// ThrottledError rejects a login attempt made before the backoff for its
+// account or client has passed.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", ErrTooManyAttempts, e.RetryAfter)
+}
+
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+// throttlePolicy describes how failures against one key are punished.
+type throttlePolicy struct {
+	// freeAttempts failures are tolerated before any delay applies.
+	freeAttempts int
+	// baseDelay is the delay after the first punished failure; it doubles
+	// with every further one.
+	baseDelay time.Duration
+	// lockoutAfter failures lock the key for lockout.
+	lockoutAfter int
+	lockout      time.Duration
+	// forgetAfter without failures resets the count.
+	forgetAfter time.Duration
+}
+
+var (
+	// accountPolicy applies per email address, whether or not an account
+	// uses it, so lockouts do not reveal which addresses are registered.
+	accountPolicy = throttlePolicy{
+		freeAttempts: 3,
+		baseDelay:    time.Second,
+		lockoutAfter: 10,
+		lockout:      15 * time.Minute,
+		forgetAfter:  time.Hour,
+	}
+	// clientPolicy applies per client IP, catching one client spraying
+	// passwords across many addresses.
+	clientPolicy = throttlePolicy{
+		freeAttempts: 20,
+		baseDelay:    time.Second,
+		lockoutAfter: 100,
+		lockout:      time.Hour,
+		forgetAfter:  time.Hour,
+	}
+)
+
+// throttleSweepInterval is how often stale entries are swept, so addresses
+// tried once do not accumulate forever, at a cost spread over the attempts
+// of the interval.
+const throttleSweepInterval = time.Minute
+
+type failureRecord struct {
+	failures    int
+	lastFailure time.Time
+	// blockedUntil is when the next attempt is allowed again.
+	blockedUntil time.Time
+}
+
+// loginThrottle tracks failed logins in memory, by account and by client.
+type loginThrottle struct {
+	mu        sync.Mutex
+	accounts  map[string]*failureRecord
+	clients   map[string]*failureRecord
+	nextSweep time.Time
+}
+
+func newLoginThrottle() *loginThrottle {
+	return &loginThrottle{
+		accounts: map[string]*failureRecord{},
+		clients:  map[string]*failureRecord{},
+	}
+}
+
+// loginAttempt is an attempt reserved by loginThrottle.reserve. It counts as
+// failed until released.
+type loginAttempt struct {
+	email, clientIP string
+	// lockedOut reports whether counting the attempt locked the account.
+	lockedOut bool
+	// The records as they were before and after the attempt was counted,
+	// so releasing it can put them back.
+	account, client           failureRecord
+	accountAfter, clientAfter failureRecord
+}
+
+// reserve returns a *ThrottledError when email or clientIP must still wait.
+// Otherwise it counts the attempt as failed straight away, so concurrent
+// attempts cannot all pass before the first failure is recorded; release
+// takes it back once the credentials turn out to be right.
+func (t *loginThrottle) reserve(email, clientIP string, now time.Time) (*loginAttempt, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var wait time.Duration
+	for _, record := range []*failureRecord{t.accounts[email], t.clients[clientIP]} {
+		if record != nil && record.blockedUntil.After(now) {
+			wait = max(wait, record.blockedUntil.Sub(now))
+		}
+	}
+	if wait > 0 {
+		return nil, &ThrottledError{RetryAfter: wait}
+	}
+
+	t.sweep(now)
+	attempt := &loginAttempt{email: email, clientIP: clientIP}
+	attempt.account, attempt.accountAfter, attempt.lockedOut = record(t.accounts, email, accountPolicy, now)
+	attempt.client, attempt.clientAfter, _ = record(t.clients, clientIP, clientPolicy, now)
+	return attempt, nil
+}
+
+// release takes back the failure counted for attempt.
+func (t *loginThrottle) release(attempt *loginAttempt) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	restore(t.accounts, attempt.email, attempt.account, attempt.accountAfter)
+	restore(t.clients, attempt.clientIP, attempt.client, attempt.clientAfter)
+}
+
+// succeeded clears the account's failures. The client's are kept, so a
+// client cannot reset its count with an account of its own.
+func (t *loginThrottle) succeeded(email string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.accounts, email)
+}
+
+func (t *loginThrottle) unlock(email string) {
+	t.succeeded(email)
+}
+
+// sweep drops the entries nobody failed against for a while, at most once
+// per throttleSweepInterval.
+func (t *loginThrottle) sweep(now time.Time) {
+	if now.Before(t.nextSweep) {
+		return
+	}
+	t.nextSweep = now.Add(throttleSweepInterval)
+	forgetStale(t.accounts, accountPolicy, now)
+	forgetStale(t.clients, clientPolicy, now)
+}
+
+func forgetStale(records map[string]*failureRecord, policy throttlePolicy, now time.Time) {
+	for key, r := range records {
+		if now.Sub(r.lastFailure) > policy.forgetAfter && !r.blockedUntil.After(now) {
+			delete(records, key)
+		}
+	}
+}
+
+// record adds a failure for key under policy. It returns the record before
+// and after, and whether the failure triggered a lockout.
+func record(records map[string]*failureRecord, key string, policy throttlePolicy, now time.Time) (before, after failureRecord, locked bool) {
+	r := records[key]
+	if r != nil {
+		before = *r
+	}
+	if r == nil || now.Sub(r.lastFailure) > policy.forgetAfter {
+		r = &failureRecord{}
+		records[key] = r
+	}
+	r.failures++
+	r.lastFailure = now
+
+	switch {
+	case r.failures >= policy.lockoutAfter:
+		r.blockedUntil = now.Add(policy.lockout)
+		locked = r.failures == policy.lockoutAfter
+	case r.failures > policy.freeAttempts:
+		r.blockedUntil = now.Add(policy.baseDelay << (r.failures - policy.freeAttempts - 1))
+	}
+	return before, *r, locked
+}
+
+// restore puts the record of key back to before, undoing the failure that
+// turned it into after. When other attempts have been counted since, only
+// the count is taken back, so their delays stay.
+func restore(records map[string]*failureRecord, key string, before, after failureRecord) {
+	r := records[key]
+	switch {
+	case r == nil:
+	case *r != after:
+		r.failures = max(r.failures-1, 0)
+	case before == failureRecord{}:
+		delete(records, key)
+	default:
+		*r = before
+	}
+}
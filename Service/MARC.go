@@ -0,0 +1,296 @@
+package Service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ISO 2709 delimiters.
+const (
+	marcSubfieldDelimiter = 0x1F
+	marcFieldTerminator   = 0x1E
+	marcRecordTerminator  = 0x1D
+)
+
+const (
+	marcLeaderLength = 24
+	// marcEntryLength is the size of a directory entry: tag, field length
+	// and starting position.
+	marcEntryLength = 12
+	// marcMaxFieldLength and marcMaxRecordLength are what the four and five
+	// digits of a directory entry and the leader can express.
+	marcMaxFieldLength  = 9999
+	marcMaxRecordLength = 99999
+)
+
+// marcRecord is a MARC 21 record as written in either format. Control
+// fields, tagged 001 to 009, have a value; data fields have indicators and
+// subfields.
+type marcRecord struct {
+	leader string
+	fields []marcField
+}
+
+type marcField struct {
+	tag        string
+	value      string
+	ind1, ind2 byte
+	subfields  []marcSubfield
+}
+
+type marcSubfield struct {
+	code  byte
+	value string
+}
+
+func isControlTag(tag string) bool {
+	return strings.HasPrefix(tag, "00")
+}
+
+// fieldsTagged returns the fields of r tagged tag, in record order.
+func (r marcRecord) fieldsTagged(tag string) []marcField {
+	var fields []marcField
+	for _, field := range r.fields {
+		if field.tag == tag {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// subfield returns the first subfield code of f, or "".
+func (f marcField) subfield(code byte) string {
+	for _, subfield := range f.subfields {
+		if subfield.code == code {
+			return subfield.value
+		}
+	}
+	return ""
+}
+
+// splitMARC cuts an ISO 2709 file into records, without their terminators.
+// Line breaks some tools put between records are dropped.
+func splitMARC(data []byte) [][]byte {
+	var records [][]byte
+	for _, raw := range bytes.Split(data, []byte{marcRecordTerminator}) {
+		if raw = bytes.TrimLeft(raw, "\r\n"); len(bytes.TrimSpace(raw)) > 0 {
+			records = append(records, raw)
+		}
+	}
+	return records
+}
+
+// decodeMARC reads one ISO 2709 record, as returned by splitMARC.
+func decodeMARC(raw []byte) (marcRecord, error) {
+	if len(raw) < marcLeaderLength+1 {
+		return marcRecord{}, errors.New("is too short for a MARC record")
+	}
+	if !utf8.Valid(raw) {
+		return marcRecord{}, errors.New("is not UTF-8; only Unicode MARC records can be imported")
+	}
+	record := marcRecord{leader: string(raw[:marcLeaderLength])}
+	base, ok := marcNumber(raw[12:17])
+	if !ok || base <= marcLeaderLength || base > len(raw) || raw[base-1] != marcFieldTerminator {
+		return record, errors.New("has an invalid base address of data in its leader")
+	}
+
+	directory := raw[marcLeaderLength : base-1]
+	if len(directory)%marcEntryLength != 0 {
+		return record, errors.New("has a malformed directory")
+	}
+	for i := 0; i < len(directory); i += marcEntryLength {
+		entry := directory[i : i+marcEntryLength]
+		tag := string(entry[:3])
+		length, lengthOK := marcNumber(entry[3:7])
+		start, startOK := marcNumber(entry[7:12])
+		if !lengthOK || !startOK || start < 0 || length < 1 || base+start+length > len(raw) {
+			return record, fmt.Errorf("has a malformed directory entry for field %s", tag)
+		}
+		data := raw[base+start : base+start+length]
+		data = bytes.TrimSuffix(data, []byte{marcFieldTerminator})
+
+		field := marcField{tag: tag}
+		if isControlTag(tag) {
+			field.value = string(data)
+		} else {
+			if len(data) < 2 {
+				return record, fmt.Errorf("has no indicators in field %s", tag)
+			}
+			field.ind1, field.ind2 = data[0], data[1]
+			for j, part := range bytes.Split(data[2:], []byte{marcSubfieldDelimiter}) {
+				if j > 0 && len(part) > 0 {
+					field.subfields = append(field.subfields, marcSubfield{code: part[0], value: string(part[1:])})
+				}
+			}
+		}
+		record.fields = append(record.fields, field)
+	}
+	return record, nil
+}
+
+// marcNumber reads the unsigned decimal number of a leader or directory
+// entry. Unlike strconv.Atoi it takes nothing but digits, so a sign cannot
+// make an offset negative.
+func marcNumber(digits []byte) (int, bool) {
+	if len(digits) == 0 {
+		return 0, false
+	}
+	n := 0
+	for _, digit := range digits {
+		if digit < '0' || digit > '9' {
+			return 0, false
+		}
+		n = n*10 + int(digit-'0')
+	}
+	return n, true
+}
+
+// encodeMARC writes record in ISO 2709, computing the record length, base
+// address and directory of its leader.
+func encodeMARC(record marcRecord) ([]byte, error) {
+	var directory, data bytes.Buffer
+	for _, field := range record.fields {
+		start := data.Len()
+		if isControlTag(field.tag) {
+			data.WriteString(field.value)
+		} else {
+			data.WriteByte(field.ind1)
+			data.WriteByte(field.ind2)
+			for _, subfield := range field.subfields {
+				data.WriteByte(marcSubfieldDelimiter)
+				data.WriteByte(subfield.code)
+				data.WriteString(subfield.value)
+			}
+		}
+		data.WriteByte(marcFieldTerminator)
+		if data.Len()-start > marcMaxFieldLength {
+			return nil, fmt.Errorf("field %s is longer than %d bytes", field.tag, marcMaxFieldLength)
+		}
+		fmt.Fprintf(&directory, "%3s%04d%05d", field.tag, data.Len()-start, start)
+	}
+	directory.WriteByte(marcFieldTerminator)
+
+	base := marcLeaderLength + directory.Len()
+	length := base + data.Len() + 1
+	if length > marcMaxRecordLength {
+		return nil, fmt.Errorf("record is longer than %d bytes", marcMaxRecordLength)
+	}
+	leader := []byte(record.leader)
+	copy(leader[0:5], fmt.Sprintf("%05d", length))
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+
+	out := make([]byte, 0, length)
+	out = append(out, leader...)
+	out = append(out, directory.Bytes()...)
+	out = append(out, data.Bytes()...)
+	return append(out, marcRecordTerminator), nil
+}
+
+type marcXMLCollection struct {
+	XMLName xml.Name        `xml:"http://www.loc.gov/MARC21/slim collection"`
+	Records []marcXMLRecord `xml:"record"`
+}
+
+type marcXMLRecord struct {
+	Leader        string                `xml:"leader"`
+	ControlFields []marcXMLControlField `xml:"controlfield"`
+	DataFields    []marcXMLDataField    `xml:"datafield"`
+}
+
+type marcXMLControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type marcXMLDataField struct {
+	Tag       string            `xml:"tag,attr"`
+	Ind1      string            `xml:"ind1,attr"`
+	Ind2      string            `xml:"ind2,attr"`
+	Subfields []marcXMLSubfield `xml:"subfield"`
+}
+
+type marcXMLSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// decodeMARCXML reads every record element of a MARCXML document, whether
+// it is a collection or a single record.
+func decodeMARCXML(data []byte) ([]marcRecord, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var records []marcRecord
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+		var element marcXMLRecord
+		if err := decoder.DecodeElement(&element, &start); err != nil {
+			return nil, err
+		}
+		records = append(records, element.record())
+	}
+}
+
+func (r marcXMLRecord) record() marcRecord {
+	record := marcRecord{leader: r.Leader}
+	for _, control := range r.ControlFields {
+		record.fields = append(record.fields, marcField{tag: control.Tag, value: control.Value})
+	}
+	for _, data := range r.DataFields {
+		field := marcField{tag: data.Tag, ind1: indicator(data.Ind1), ind2: indicator(data.Ind2)}
+		for _, subfield := range data.Subfields {
+			if subfield.Code != "" {
+				field.subfields = append(field.subfields, marcSubfield{code: subfield.Code[0], value: subfield.Value})
+			}
+		}
+		record.fields = append(record.fields, field)
+	}
+	return record
+}
+
+// indicator reads an indicator attribute, which is blank when missing.
+func indicator(value string) byte {
+	if value == "" {
+		return ' '
+	}
+	return value[0]
+}
+
+// encodeMARCXML writes records as an indented MARCXML collection.
+func encodeMARCXML(records []marcRecord) ([]byte, error) {
+	collection := marcXMLCollection{Records: make([]marcXMLRecord, 0, len(records))}
+	for _, record := range records {
+		element := marcXMLRecord{Leader: record.leader}
+		for _, field := range record.fields {
+			if isControlTag(field.tag) {
+				element.ControlFields = append(element.ControlFields, marcXMLControlField{Tag: field.tag, Value: field.value})
+				continue
+			}
+			data := marcXMLDataField{Tag: field.tag, Ind1: string(field.ind1), Ind2: string(field.ind2)}
+			for _, subfield := range field.subfields {
+				data.Subfields = append(data.Subfields, marcXMLSubfield{Code: string(subfield.code), Value: subfield.value})
+			}
+			element.DataFields = append(element.DataFields, data)
+		}
+		collection.Records = append(collection.Records, element)
+	}
+
+	out, err := xml.MarshalIndent(collection, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
@@ -0,0 +1,354 @@
+package Service
+
+import (
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// marcLeader is the leader of exported records: a new record for printed
+// language material, catalogued as a monograph in Unicode at minimal level
+// with ISBD punctuation. Encoding fills in the lengths.
+const marcLeader = "00000nam a22000007i 4500"
+
+// marcMaxParagraph bounds a 520 subfield so the field fits its directory
+// entry, leaving room for indicators, delimiter and terminator.
+const marcMaxParagraph = marcMaxFieldLength - 8
+
+var ErrUnknownMARCFormat = errors.New("unknown MARC format")
+
+var (
+	// marcRelators maps MARC relator codes ($4) to credit roles.
+	marcRelators = map[string]string{
+		"aut": Model.CreditAuthor, "edt": Model.CreditEditor,
+		"trl": Model.CreditTranslator, "ill": Model.CreditIllustrator,
+	}
+	// endsWithInitial matches text ending in an initial such as "R.", whose
+	// full stop is not ISBD punctuation.
+	endsWithInitial = regexp.MustCompile(`(?:^|[\s.])\p{L}\.$`)
+	// leadingBy matches the "by" opening many statements of responsibility.
+	leadingBy = regexp.MustCompile(`(?i)^by\s+`)
+)
+
+type MARCService interface {
+	// Export writes books as MARC 21 bibliographic records with their
+	// credits and subjects, in format Model.ImportMARC (ISO 2709) or
+	// Model.ImportMARCXML, so they can be imported again.
+	Export(ctx context.Context, format string, books []Model.BookModel) ([]byte, error)
+}
+
+type marcService struct {
+	store Repository.Store
+}
+
+func NewMARCService(store Repository.Store) MARCService {
+	return &marcService{store: store}
+}
+
+func (s *marcService) Export(ctx context.Context, format string, books []Model.BookModel) ([]byte, error) {
+	if format != Model.ImportMARC && format != Model.ImportMARCXML {
+		return nil, ErrUnknownMARCFormat
+	}
+	subjects, err := loadTaxonomy(ctx, s.store)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]marcRecord, 0, len(books))
+	for _, book := range books {
+		credits, err := contributors(ctx, s.store, book.ID)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := s.store.Subjects().SubjectIDsOfBook(ctx, book.ID)
+		if err != nil {
+			return nil, err
+		}
+		paths := make([]SubjectPath, 0, len(ids))
+		for _, id := range ids {
+			if _, ok := subjects[id]; ok {
+				paths = append(paths, subjectPath(subjects, id))
+			}
+		}
+		slices.SortFunc(paths, func(a, b SubjectPath) int { return strings.Compare(a.String(), b.String()) })
+		records = append(records, bookRecord(book, credits, paths))
+	}
+
+	if format == Model.ImportMARCXML {
+		return encodeMARCXML(records)
+	}
+	var out bytes.Buffer
+	for i, record := range records {
+		data, err := encodeMARC(record)
+		if err != nil {
+			return nil, fmt.Errorf("book %d: %w", books[i].ID, err)
+		}
+		out.Write(data)
+	}
+	return out.Bytes(), nil
+}
+
+// bookRecord describes book as a MARC record: its ID as control number
+// (001), ISBNs (020), first author as main entry (100), title and author
+// statement (245), description (520), subjects (650) and other
+// contributors as added entries (700).
+func bookRecord(book Model.BookModel, credits []Contributor, subjects []SubjectPath) marcRecord {
+	record := marcRecord{leader: marcLeader}
+	record.fields = append(record.fields, marcField{tag: "001", value: strconv.Itoa(book.ID)})
+	for _, isbn := range []string{book.ISBN13, book.ISBN10} {
+		if isbn != "" {
+			record.fields = append(record.fields, marcField{tag: "020", ind1: ' ', ind2: ' ', subfields: []marcSubfield{{'a', isbn}}})
+		}
+	}
+
+	mainEntry := slices.IndexFunc(credits, func(c Contributor) bool { return c.Role == Model.CreditAuthor })
+	title := marcField{tag: "245", ind1: '0', ind2: nonfilingCharacters(book.Title), subfields: []marcSubfield{{'a', book.Title}}}
+	if mainEntry >= 0 {
+		record.fields = append(record.fields, nameField("100", credits[mainEntry]))
+		title.ind1 = '1'
+	}
+	if book.Author != "" {
+		title.subfields[0].value += " /"
+		title.subfields = append(title.subfields, marcSubfield{'c', withFullStop(book.Author)})
+	}
+	record.fields = append(record.fields, title)
+
+	for _, paragraph := range strings.Split(book.Description, "\n\n") {
+		for _, chunk := range marcChunks(strings.TrimSpace(paragraph), marcMaxParagraph) {
+			record.fields = append(record.fields, marcField{tag: "520", ind1: ' ', ind2: ' ', subfields: []marcSubfield{{'a', chunk}}})
+		}
+	}
+	for _, path := range subjects {
+		// Second indicator 4: the headings come from our own taxonomy,
+		// not from a thesaurus such as LCSH.
+		field := marcField{tag: "650", ind1: ' ', ind2: '4'}
+		for i, subject := range path {
+			code := byte('x')
+			if i == 0 {
+				code = 'a'
+			}
+			field.subfields = append(field.subfields, marcSubfield{code, subject.Name})
+		}
+		record.fields = append(record.fields, field)
+	}
+	for i, credit := range credits {
+		if i != mainEntry {
+			record.fields = append(record.fields, nameField("700", credit))
+		}
+	}
+	return record
+}
+
+// nameField is a personal name entry, surname first, with the
+// contributor's role as relator term.
+func nameField(tag string, credit Contributor) marcField {
+	name, ind1 := credit.Author.Name, byte('0')
+	if first, last, ok := cutLastWord(name); ok && !strings.Contains(name, ",") {
+		name, ind1 = last+", "+first, '1'
+	}
+	return marcField{tag: tag, ind1: ind1, ind2: ' ', subfields: []marcSubfield{{'a', name + ","}, {'e', credit.Role + "."}}}
+}
+
+func cutLastWord(name string) (string, string, bool) {
+	i := strings.LastIndexByte(name, ' ')
+	if i < 0 {
+		return "", name, false
+	}
+	return name[:i], name[i+1:], true
+}
+
+// nonfilingCharacters counts the leading English article of title that
+// sorting skips, as the second indicator of 245.
+func nonfilingCharacters(title string) byte {
+	for _, article := range []string{"The ", "An ", "A "} {
+		if strings.HasPrefix(title, article) {
+			return byte('0' + len(article))
+		}
+	}
+	return '0'
+}
+
+func withFullStop(text string) string {
+	if strings.HasSuffix(text, ".") || strings.HasSuffix(text, "?") || strings.HasSuffix(text, "!") {
+		return text
+	}
+	return text + "."
+}
+
+// marcChunks cuts text into pieces of at most limit bytes, at spaces where
+// it can. Empty text has no pieces.
+func marcChunks(text string, limit int) []string {
+	var chunks []string
+	for len(text) > limit {
+		cut := strings.LastIndexByte(text[:limit], ' ')
+		if cut <= 0 {
+			for cut = limit; !utf8.RuneStart(text[cut]); cut-- {
+			}
+		}
+		chunks = append(chunks, text[:cut])
+		text = strings.TrimLeft(text[cut:], " ")
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+// recordRow reads the catalog fields of record, the number-th of its file,
+// the way bookRecord writes them. The title joins 245 $a and $b; the author
+// is the statement of responsibility (245 $c), or else the names of the 100
+// and 700 entries. Name entries with a relator the catalog has no role for
+// are left out.
+func recordRow(record marcRecord, number int) ImportRow {
+	if len(record.leader) > 6 && strings.ContainsRune("quvxyz", rune(record.leader[6])) {
+		return ImportRow{Line: number, Problems: []Model.ImportErrorModel{{Message: "is not a bibliographic record"}}}
+	}
+	row := ImportRow{Line: number, Values: map[string]string{}}
+
+	if titles := record.fieldsTagged("245"); len(titles) > 0 {
+		title := trimISBD(titles[0].subfield('a'))
+		if remainder := trimISBD(titles[0].subfield('b')); remainder != "" {
+			title += ": " + remainder
+		}
+		row.Values["title"] = title
+		row.Values["author"] = leadingBy.ReplaceAllString(trimISBD(titles[0].subfield('c')), "")
+	}
+
+	var names []string
+	for _, field := range record.fields {
+		if field.tag != "100" && field.tag != "700" {
+			continue
+		}
+		name := trimISBD(field.subfield('a'))
+		if field.ind1 == '1' {
+			name = Model.NaturalName(name)
+		}
+		role, ok := marcRole(field)
+		if !ok || Model.AuthorNameKey(name) == "" {
+			continue
+		}
+		row.Credits = append(row.Credits, ImportCredit{Name: name, Role: role})
+		if role != Model.CreditAuthor {
+			name += " (" + role + ")"
+		}
+		names = append(names, name)
+	}
+	if row.Values["author"] == "" {
+		row.Values["author"] = strings.Join(names, "; ")
+	}
+
+	var isbns []string
+	for _, field := range record.fieldsTagged("020") {
+		if isbn, _, _ := strings.Cut(strings.TrimSpace(field.subfield('a')), " "); isbn != "" {
+			isbns = append(isbns, isbn)
+		}
+	}
+	if i := slices.IndexFunc(isbns, Model.ValidISBN); i >= 0 {
+		row.Values["isbn"] = isbns[i]
+	} else if len(isbns) > 0 {
+		row.Values["isbn"] = isbns[0]
+	}
+
+	var paragraphs []string
+	for _, field := range record.fieldsTagged("520") {
+		if summary := strings.TrimSpace(field.subfield('a')); summary != "" {
+			paragraphs = append(paragraphs, summary)
+		}
+	}
+	row.Values["description"] = strings.Join(paragraphs, "\n\n")
+
+	for _, field := range record.fieldsTagged("650") {
+		var path []string
+		for _, subfield := range field.subfields {
+			if strings.IndexByte("avxyz", subfield.code) >= 0 {
+				if name := trimISBD(subfield.value); name != "" {
+					path = append(path, name)
+				}
+			}
+		}
+		if len(path) > 0 {
+			row.Subjects = append(row.Subjects, path)
+		}
+	}
+	return row
+}
+
+// marcRole reads the role of a name entry from its relator terms ($e) or
+// codes ($4). Entries without either are authors.
+func marcRole(field marcField) (string, bool) {
+	described := false
+	for _, subfield := range field.subfields {
+		var role string
+		switch subfield.code {
+		case 'e':
+			term := strings.ToLower(trimISBD(subfield.value))
+			role = roleNames[term]
+			if term == Model.CreditAuthor {
+				role = term
+			}
+		case '4':
+			role = marcRelators[strings.ToLower(strings.TrimSpace(subfield.value))]
+		default:
+			continue
+		}
+		if role != "" {
+			return role, true
+		}
+		described = true
+	}
+	return Model.CreditAuthor, !described
+}
+
+// trimISBD strips the punctuation ISBD puts between the elements of a
+// field, such as " /" before a statement of responsibility or the full
+// stop ending it, but not the full stop of a closing initial.
+func trimISBD(value string) string {
+	value = strings.TrimRight(strings.TrimSpace(value), " /:;,=")
+	if strings.HasSuffix(value, ".") && !endsWithInitial.MatchString(value) {
+		value = strings.TrimSuffix(value, ".")
+	}
+	return strings.TrimSpace(value)
+}
+
+// parseMARC reads an ISO 2709 file into one row per record, numbered from
+// one.
+func parseMARC(data []byte) ([]ImportRow, error) {
+	if !bytes.Contains(data, []byte{marcRecordTerminator}) {
+		return nil, &ImportFileError{Field: "file", Reason: "is not MARC 21; records must end with the record terminator 0x1D"}
+	}
+	var rows []ImportRow
+	for i, raw := range splitMARC(data) {
+		if len(rows) > MaxImportRows {
+			break
+		}
+		record, err := decodeMARC(raw)
+		if err != nil {
+			rows = append(rows, ImportRow{Line: i + 1, Problems: []Model.ImportErrorModel{{Message: err.Error()}}})
+			continue
+		}
+		rows = append(rows, recordRow(record, i+1))
+	}
+	return rows, nil
+}
+
+// parseMARCXML reads a MARCXML collection or record into one row per
+// record, numbered from one.
+func parseMARCXML(data []byte) ([]ImportRow, error) {
+	records, err := decodeMARCXML(data)
+	if err != nil {
+		return nil, &ImportFileError{Field: "file", Reason: "is not valid MARCXML: " + err.Error()}
+	}
+	rows := make([]ImportRow, 0, len(records))
+	for i, record := range records {
+		rows = append(rows, recordRow(record, i+1))
+	}
+	return rows, nil
+}
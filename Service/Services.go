@@ -0,0 +1,53 @@
+package Service
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Repository"
+)
+
+// Services bundles every service the HTTP layer depends on.
+type Services struct {
+	// Clock is shared by every service and by token issuance, so a single
+	// fake or offset clock moves the whole application.
+	Clock Config.Clock
+
+	Users     UserService
+	TwoFactor TwoFactorService
+	Catalog   CatalogService
+	Authors   AuthorService
+	Taxonomy  TaxonomyService
+	Covers    CoverService
+	// Imports runs bulk catalog imports in the background.
+	Imports ImportService
+	// MARC exports the catalog as MARC 21 records.
+	MARC    MARCService
+	Lending LendingService
+	APIKeys APIKeyService
+	// Sessions issues login tokens and checks them on every request.
+	Sessions SessionService
+	// SingleSignOn is nil unless an OpenID Connect provider is configured.
+	SingleSignOn SingleSignOnService
+}
+
+// New wires all services on top of store and clock, sending mail through
+// mail and keeping images in blobs.
+func New(store Repository.Store, clock Config.Clock, mail Config.MailSender, blobs Config.BlobStore) *Services {
+	// Both share one user service, so second-factor failures count
+	// towards the same login throttle as wrong passwords.
+	users := newUserService(store, clock, mail)
+	authors := NewAuthorService(store)
+	return &Services{
+		Clock:     clock,
+		Users:     users,
+		TwoFactor: users,
+		Catalog:   NewCatalogService(store, clock),
+		Authors:   authors,
+		Taxonomy:  NewTaxonomyService(store),
+		Covers:    NewCoverService(store, blobs),
+		Imports:   NewImportService(store, clock, authors),
+		MARC:      NewMARCService(store),
+		Lending:   NewLendingService(store, clock),
+		APIKeys:   NewAPIKeyService(store, clock),
+		Sessions:  NewSessionService(store, clock),
+	}
+}
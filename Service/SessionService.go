@@ -0,0 +1,180 @@
+package Service
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// sessionTouchInterval limits how often LastSeenAt is written, like
+// apiKeyTouchInterval for API keys.
+const sessionTouchInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionService interface {
+	// Start records a login of user from the client described by userAgent
+	// and ip, and returns the JWT bound to the new session.
+	Start(ctx context.Context, user Model.UserModel, userAgent, ip string) (string, error)
+	// List returns the sessions of userID whose tokens are still honoured,
+	// newest first.
+	List(ctx context.Context, userID int) ([]Model.SessionModel, error)
+	// Revoke signs one session of userID out. Sessions of other users are
+	// reported as ErrSessionNotFound.
+	Revoke(ctx context.Context, userID, sessionID int) error
+	// RevokeOthers signs out every session of userID except the one of
+	// currentTokenID.
+	RevokeOthers(ctx context.Context, userID int, currentTokenID string) error
+
+	// TokenCurrent implements Config.TokenChecker.
+	TokenCurrent(ctx context.Context, userID, version int, tokenID string) (bool, error)
+}
+
+type sessionService struct {
+	store Repository.Store
+	clock Config.Clock
+}
+
+func NewSessionService(store Repository.Store, clock Config.Clock) SessionService {
+	return &sessionService{store: store, clock: clock}
+}
+
+func (s *sessionService) Start(ctx context.Context, user Model.UserModel, userAgent, ip string) (string, error) {
+	tokenID, err := Config.NewSecretToken()
+	if err != nil {
+		return "", err
+	}
+	now := s.clock.Now()
+	if runes := []rune(userAgent); len(runes) > 255 {
+		userAgent = string(runes[:255])
+	}
+
+	session := Model.SessionModel{
+		UserID:       user.UserId,
+		TokenID:      tokenID,
+		TokenVersion: user.TokenVersion,
+		UserAgent:    userAgent,
+		IPAddress:    ip,
+		CreatedAt:    now,
+		LastSeenAt:   now,
+		ExpiresAt:    now.Add(Config.TokenTTL),
+	}
+	err = s.store.Transaction(ctx, func(tx Repository.Store) error {
+		// Logging in is a good moment to forget the user's dead sessions.
+		if err := tx.Sessions().DeleteExpired(ctx, user.UserId, now); err != nil {
+			return err
+		}
+		return tx.Sessions().Create(ctx, &session)
+	})
+	if err != nil {
+		return "", err
+	}
+	return Config.GenerateJWT(s.clock, user, tokenID)
+}
+
+func (s *sessionService) List(ctx context.Context, userID int) ([]Model.SessionModel, error) {
+	user, err := findUser(ctx, s.store, userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.store.Sessions().FindByUser(ctx, userID, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	active := sessions[:0]
+	for _, session := range sessions {
+		if session.Active(s.clock.Now(), user.TokenVersion) {
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+func (s *sessionService) Revoke(ctx context.Context, userID, sessionID int) error {
+	return s.store.Transaction(ctx, func(tx Repository.Store) error {
+		sessions, err := tx.Sessions().FindByUser(ctx, userID, s.clock.Now())
+		if err != nil {
+			return err
+		}
+		for _, session := range sessions {
+			if session.ID != sessionID {
+				continue
+			}
+			if session.RevokedAt != nil {
+				return nil
+			}
+			now := s.clock.Now()
+			session.RevokedAt = &now
+			slog.InfoContext(ctx, "Session revoked", "userId", userID, "sessionId", sessionID)
+			return tx.Sessions().Save(ctx, &session)
+		}
+		return ErrSessionNotFound
+	})
+}
+
+func (s *sessionService) RevokeOthers(ctx context.Context, userID int, currentTokenID string) error {
+	revoked := 0
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		revoked = 0
+		now := s.clock.Now()
+		sessions, err := tx.Sessions().FindByUser(ctx, userID, now)
+		if err != nil {
+			return err
+		}
+		for _, session := range sessions {
+			if session.TokenID == currentTokenID || session.RevokedAt != nil {
+				continue
+			}
+			session.RevokedAt = &now
+			if err := tx.Sessions().Save(ctx, &session); err != nil {
+				return err
+			}
+			revoked++
+		}
+		return nil
+	})
+	if err == nil && revoked > 0 {
+		slog.InfoContext(ctx, "Other sessions revoked", "userId", userID, "count", revoked)
+	}
+	return err
+}
+
+func (s *sessionService) TokenCurrent(ctx context.Context, userID, version int, tokenID string) (bool, error) {
+	if tokenID == "" {
+		// Tokens from before sessions were recorded have no session to
+		// revoke, so they are not honoured either.
+		return false, nil
+	}
+	user, err := s.store.Users().FindByID(ctx, userID)
+	if errors.Is(err, Repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil || user.TokenVersion != version {
+		return false, err
+	}
+
+	session, err := s.store.Sessions().FindByTokenID(ctx, tokenID)
+	if errors.Is(err, Repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	now := s.clock.Now()
+	if session.UserID != userID || !session.Active(now, version) {
+		return false, nil
+	}
+
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		if err := s.store.Sessions().Touch(ctx, session.ID, now); err != nil {
+			// As with API keys, a stale timestamp does not lock anyone out.
+			slog.WarnContext(ctx, "Failed to record session activity", "sessionId", session.ID, "error", err)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,173 @@
+package Service
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+var (
+	// ErrInvalidLoginFlow rejects a callback that does not belong to the
+	// login started in this browser, or whose ID token fails validation.
+	ErrInvalidLoginFlow = errors.New("invalid single sign-on response")
+	// ErrExternalEmailNotVerified rejects identities whose provider does not
+	// vouch for the address, which is what accounts are matched by.
+	ErrExternalEmailNotVerified = errors.New("identity provider did not verify the email address")
+	// ErrProviderUnavailable wraps failures to reach the identity provider
+	// or to make sense of its answers.
+	ErrProviderUnavailable = errors.New("identity provider unavailable")
+)
+
+type SingleSignOnService interface {
+	// Begin starts a login at the identity provider. The flow must be
+	// presented again to Complete.
+	Begin(ctx context.Context) (string, Config.OIDCFlow, error)
+	// Complete redeems the code the provider redirected back with and
+	// returns the local user, linking an existing account by verified email
+	// or provisioning a new one on first login.
+	Complete(ctx context.Context, flow Config.OIDCFlow, state, code string) (Model.UserModel, error)
+}
+
+type singleSignOnService struct {
+	store    Repository.Store
+	clock    Config.Clock
+	provider *Config.OIDCProvider
+}
+
+func NewSingleSignOnService(store Repository.Store, clock Config.Clock, provider *Config.OIDCProvider) SingleSignOnService {
+	return &singleSignOnService{store: store, clock: clock, provider: provider}
+}
+
+func (s *singleSignOnService) Begin(ctx context.Context) (string, Config.OIDCFlow, error) {
+	flow, err := Config.NewOIDCFlow()
+	if err != nil {
+		return "", flow, err
+	}
+	target, err := s.provider.AuthorizationURL(ctx, flow)
+	if err != nil {
+		return "", flow, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	return target, flow, nil
+}
+
+func (s *singleSignOnService) Complete(ctx context.Context, flow Config.OIDCFlow, state, code string) (Model.UserModel, error) {
+	if state == "" || state != flow.State || code == "" {
+		return Model.UserModel{}, ErrInvalidLoginFlow
+	}
+
+	rawIDToken, err := s.provider.Exchange(ctx, code, flow.Verifier)
+	if err != nil {
+		return Model.UserModel{}, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	identity, err := s.provider.VerifyIDToken(ctx, rawIDToken, flow.Nonce, s.clock.Now())
+	if errors.Is(err, Config.ErrInvalidIDToken) {
+		return Model.UserModel{}, fmt.Errorf("%w: %v", ErrInvalidLoginFlow, err)
+	}
+	if err != nil {
+		return Model.UserModel{}, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+
+	var user Model.UserModel
+	err = s.store.Transaction(ctx, func(tx Repository.Store) error {
+		var err error
+		if user, err = s.linkedUser(ctx, tx, identity); err != nil {
+			return err
+		}
+		return s.syncRole(ctx, tx, &user, identity)
+	})
+	return user, err
+}
+
+// linkedUser finds the user linked to identity. Unlinked identities are
+// linked to the account with the same verified address, or to a new one.
+func (s *singleSignOnService) linkedUser(ctx context.Context, tx Repository.Store, identity Config.OIDCIdentity) (Model.UserModel, error) {
+	link, err := tx.ExternalIdentities().Find(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return findUser(ctx, tx, link.UserID)
+	}
+	if !errors.Is(err, Repository.ErrNotFound) {
+		return Model.UserModel{}, err
+	}
+
+	if !identity.EmailVerified || identity.Email == "" {
+		return Model.UserModel{}, ErrExternalEmailNotVerified
+	}
+	email := normalizeEmail(identity.Email)
+	now := s.clock.Now()
+
+	user, err := tx.Users().FindByEmail(ctx, email)
+	switch {
+	case errors.Is(err, Repository.ErrNotFound):
+		// Provisioned accounts have no local password; one can be set
+		// through the password reset flow.
+		user = Model.UserModel{
+			UserName:        provisionedUserName(identity),
+			Email:           email,
+			Role:            Model.RoleMember,
+			EmailVerifiedAt: &now,
+		}
+		if err := tx.Users().Create(ctx, &user); err != nil {
+			return user, err
+		}
+		slog.InfoContext(ctx, "Provisioned user from identity provider", "userId", user.UserId, "issuer", identity.Issuer)
+	case err != nil:
+		return user, err
+	default:
+		// The provider vouches for the address, just as a followed
+		// verification link would.
+		if !user.EmailVerified() {
+			user.EmailVerifiedAt = &now
+			if err := tx.Users().Save(ctx, &user); err != nil {
+				return user, err
+			}
+		}
+		slog.InfoContext(ctx, "Linked user to identity provider", "userId", user.UserId, "issuer", identity.Issuer)
+	}
+
+	return user, tx.ExternalIdentities().Create(ctx, &Model.ExternalIdentityModel{
+		Issuer:    identity.Issuer,
+		Subject:   identity.Subject,
+		UserID:    user.UserId,
+		CreatedAt: now,
+	})
+}
+
+// syncRole applies the configured group mapping, if any. A changed role
+// revokes the user's tokens, which still carry the old one.
+func (s *singleSignOnService) syncRole(ctx context.Context, tx Repository.Store, user *Model.UserModel, identity Config.OIDCIdentity) error {
+	mapping := s.provider.Settings().RoleMapping
+	if len(mapping) == 0 {
+		return nil
+	}
+
+	role := Model.RoleMember
+	for _, group := range identity.Groups {
+		if mapping[group] == Model.RoleAdmin {
+			role = Model.RoleAdmin
+		}
+	}
+	if user.Role == role {
+		return nil
+	}
+
+	slog.InfoContext(ctx, "Role changed by identity provider groups", "userId", user.UserId, "from", user.Role, "to", role)
+	user.Role = role
+	user.TokenVersion++
+	return tx.Users().Save(ctx, user)
+}
+
+func provisionedUserName(identity Config.OIDCIdentity) string {
+	name := strings.TrimSpace(identity.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	if runes := []rune(name); len(runes) > 50 {
+		name = string(runes[:50])
+	}
+	return name
+}
@@ -0,0 +1,328 @@
+package Service
+
+import (
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrSubjectNotFound = errors.New("subject not found")
+	// ErrSubjectExists rejects a second subject of the same name under one
+	// parent.
+	ErrSubjectExists = errors.New("subject already exists")
+	// ErrSubjectCycle rejects moving a subject below itself.
+	ErrSubjectCycle = errors.New("subject cannot be moved below itself")
+	// ErrUnknownSubject rejects filing a book under a subject that does not
+	// exist.
+	ErrUnknownSubject = errors.New("unknown subject")
+)
+
+// SubjectPath is a subject preceded by its ancestors, root first.
+type SubjectPath []Model.SubjectModel
+
+// Subject returns the subject the path leads to.
+func (p SubjectPath) Subject() Model.SubjectModel {
+	return p[len(p)-1]
+}
+
+// String joins the names on the path, as in "Fantasy > High Fantasy".
+func (p SubjectPath) String() string {
+	names := make([]string, 0, len(p))
+	for _, subject := range p {
+		names = append(names, subject.Name)
+	}
+	return strings.Join(names, " > ")
+}
+
+// SubjectNode is a subject with the subjects below it, by name.
+type SubjectNode struct {
+	Subject  Model.SubjectModel
+	Children []SubjectNode
+}
+
+type TaxonomyService interface {
+	// Tree returns the root subjects with everything below them.
+	Tree(ctx context.Context) ([]SubjectNode, error)
+	Path(ctx context.Context, subjectID int) (SubjectPath, error)
+	// Create adds subject below subject.ParentID, or as a root.
+	Create(ctx context.Context, subject *Model.SubjectModel) (SubjectPath, error)
+	// Update renames subject.ID and moves it, with everything below it,
+	// below subject.ParentID.
+	Update(ctx context.Context, subject *Model.SubjectModel) (SubjectPath, error)
+	// BooksUnder lists the books filed under subjectID or any subject below
+	// it.
+	BooksUnder(ctx context.Context, subjectID int) ([]Model.BookModel, error)
+
+	SubjectsOfBook(ctx context.Context, bookID int) ([]SubjectPath, error)
+	// SetBookSubjects files bookID under exactly subjectIDs.
+	SetBookSubjects(ctx context.Context, bookID int, subjectIDs []int) ([]SubjectPath, error)
+
+	// Tags lists every tag in use with the number of books carrying it.
+	Tags(ctx context.Context) ([]Repository.TagCount, error)
+	TagsOfBook(ctx context.Context, bookID int) ([]string, error)
+	// SetBookTags replaces the tags of bookID, normalizing them first.
+	SetBookTags(ctx context.Context, bookID int, tags []string) ([]string, error)
+}
+
+type taxonomyService struct {
+	store Repository.Store
+}
+
+func NewTaxonomyService(store Repository.Store) TaxonomyService {
+	return &taxonomyService{store: store}
+}
+
+func (s *taxonomyService) Tree(ctx context.Context) ([]SubjectNode, error) {
+	subjects, err := s.store.Subjects().FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var build func(parentID *int) []SubjectNode
+	build = func(parentID *int) []SubjectNode {
+		nodes := []SubjectNode{}
+		for _, subject := range subjects {
+			if sameParent(subject.ParentID, parentID) {
+				nodes = append(nodes, SubjectNode{Subject: subject, Children: build(&subject.ID)})
+			}
+		}
+		return nodes
+	}
+	return build(nil), nil
+}
+
+func (s *taxonomyService) Path(ctx context.Context, subjectID int) (SubjectPath, error) {
+	subjects, err := loadTaxonomy(ctx, s.store)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := subjects[subjectID]; !ok {
+		return nil, ErrSubjectNotFound
+	}
+	return subjectPath(subjects, subjectID), nil
+}
+
+func (s *taxonomyService) Create(ctx context.Context, subject *Model.SubjectModel) (SubjectPath, error) {
+	var path SubjectPath
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		subjects, err := checkPlacement(ctx, tx, subject)
+		if err != nil {
+			return err
+		}
+		if err := tx.Subjects().Create(ctx, subject); err != nil {
+			return err
+		}
+		subjects[subject.ID] = *subject
+		path = subjectPath(subjects, subject.ID)
+		return nil
+	})
+	return path, err
+}
+
+func (s *taxonomyService) Update(ctx context.Context, subject *Model.SubjectModel) (SubjectPath, error) {
+	var path SubjectPath
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		subjects, err := checkPlacement(ctx, tx, subject)
+		if err != nil {
+			return err
+		}
+		if _, ok := subjects[subject.ID]; !ok {
+			return ErrSubjectNotFound
+		}
+		for parentID := subject.ParentID; parentID != nil; parentID = subjects[*parentID].ParentID {
+			if *parentID == subject.ID {
+				return ErrSubjectCycle
+			}
+		}
+
+		if err := tx.Subjects().Save(ctx, subject); err != nil {
+			return err
+		}
+		subjects[subject.ID] = *subject
+		path = subjectPath(subjects, subject.ID)
+		return nil
+	})
+	return path, err
+}
+
+func (s *taxonomyService) BooksUnder(ctx context.Context, subjectID int) ([]Model.BookModel, error) {
+	ids, err := booksUnder(ctx, s.store, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Books().FindByIDs(ctx, ids)
+}
+
+func (s *taxonomyService) SubjectsOfBook(ctx context.Context, bookID int) ([]SubjectPath, error) {
+	return subjectsOfBook(ctx, s.store, bookID)
+}
+
+func (s *taxonomyService) SetBookSubjects(ctx context.Context, bookID int, subjectIDs []int) ([]SubjectPath, error) {
+	var paths []SubjectPath
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		if _, err := findBook(ctx, tx, bookID); err != nil {
+			return err
+		}
+		subjects, err := loadTaxonomy(ctx, tx)
+		if err != nil {
+			return err
+		}
+		for _, id := range subjectIDs {
+			if _, ok := subjects[id]; !ok {
+				return ErrUnknownSubject
+			}
+		}
+
+		ids := slices.Compact(slices.Sorted(slices.Values(subjectIDs)))
+		if err := tx.Subjects().ReplaceBookSubjects(ctx, bookID, ids); err != nil {
+			return err
+		}
+		paths, err = subjectsOfBook(ctx, tx, bookID)
+		return err
+	})
+	return paths, err
+}
+
+func (s *taxonomyService) Tags(ctx context.Context) ([]Repository.TagCount, error) {
+	return s.store.Tags().Counts(ctx)
+}
+
+func (s *taxonomyService) TagsOfBook(ctx context.Context, bookID int) ([]string, error) {
+	return s.store.Tags().TagsOfBook(ctx, bookID)
+}
+
+func (s *taxonomyService) SetBookTags(ctx context.Context, bookID int, tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = Model.NormalizeTag(tag); tag != "" {
+			normalized = append(normalized, tag)
+		}
+	}
+	normalized = slices.Compact(slices.Sorted(slices.Values(normalized)))
+
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		if _, err := findBook(ctx, tx, bookID); err != nil {
+			return err
+		}
+		return tx.Tags().ReplaceBookTags(ctx, bookID, normalized)
+	})
+	return normalized, err
+}
+
+// checkPlacement loads the taxonomy and checks that subject's parent exists
+// and has no other child of the same name.
+func checkPlacement(ctx context.Context, store Repository.Store, subject *Model.SubjectModel) (map[int]Model.SubjectModel, error) {
+	subject.Name = strings.Join(strings.Fields(subject.Name), " ")
+	subjects, err := loadTaxonomy(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	if subject.ParentID != nil {
+		if _, ok := subjects[*subject.ParentID]; !ok {
+			return nil, ErrUnknownSubject
+		}
+	}
+	for _, sibling := range subjects {
+		if sibling.ID != subject.ID && sameParent(sibling.ParentID, subject.ParentID) && strings.EqualFold(sibling.Name, subject.Name) {
+			return nil, ErrSubjectExists
+		}
+	}
+	return subjects, nil
+}
+
+// booksUnder lists the IDs of the books filed under subjectID or below it.
+func booksUnder(ctx context.Context, store Repository.Store, subjectID int) ([]int, error) {
+	subjects, err := loadTaxonomy(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := subjects[subjectID]; !ok {
+		return nil, ErrSubjectNotFound
+	}
+
+	ids := []int{subjectID}
+	for i := 0; i < len(ids); i++ {
+		for _, subject := range subjects {
+			if subject.ParentID != nil && *subject.ParentID == ids[i] {
+				ids = append(ids, subject.ID)
+			}
+		}
+	}
+	return store.Subjects().BookIDsUnder(ctx, ids)
+}
+
+func subjectsOfBook(ctx context.Context, store Repository.Store, bookID int) ([]SubjectPath, error) {
+	ids, err := store.Subjects().SubjectIDsOfBook(ctx, bookID)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	subjects, err := loadTaxonomy(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]SubjectPath, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := subjects[id]; ok {
+			paths = append(paths, subjectPath(subjects, id))
+		}
+	}
+	slices.SortFunc(paths, func(a, b SubjectPath) int { return strings.Compare(a.String(), b.String()) })
+	return paths, nil
+}
+
+// subjectAtPath finds the subject path names, root first, creating the
+// part of the path that does not exist yet. Names match in any case, as
+// sibling names must differ in more than case. subjects is the loaded
+// taxonomy, to which created subjects are added.
+func subjectAtPath(ctx context.Context, store Repository.Store, subjects map[int]Model.SubjectModel, path []string) (int, error) {
+	var parentID *int
+	for _, name := range path {
+		name = strings.Join(strings.Fields(name), " ")
+		subject, found := Model.SubjectModel{}, false
+		for _, candidate := range subjects {
+			if sameParent(candidate.ParentID, parentID) && strings.EqualFold(candidate.Name, name) {
+				subject, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			subject = Model.SubjectModel{ParentID: parentID, Name: name}
+			if err := store.Subjects().Create(ctx, &subject); err != nil {
+				return 0, err
+			}
+			subjects[subject.ID] = subject
+		}
+		parentID = &subject.ID
+	}
+	return *parentID, nil
+}
+
+func loadTaxonomy(ctx context.Context, store Repository.Store) (map[int]Model.SubjectModel, error) {
+	all, err := store.Subjects().FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subjects := make(map[int]Model.SubjectModel, len(all))
+	for _, subject := range all {
+		subjects[subject.ID] = subject
+	}
+	return subjects, nil
+}
+
+func subjectPath(subjects map[int]Model.SubjectModel, subjectID int) SubjectPath {
+	var path SubjectPath
+	for id := &subjectID; id != nil && len(path) <= len(subjects); id = subjects[*id].ParentID {
+		path = append(path, subjects[*id])
+	}
+	slices.Reverse(path)
+	return path
+}
+
+func sameParent(a, b *int) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
@@ -0,0 +1,270 @@
+package Service
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+)
+
+// RecoveryCodeCount is how many recovery codes are issued at a time.
+const RecoveryCodeCount = 10
+
+var (
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication already enabled")
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication not enabled")
+	// ErrTwoFactorNotEnrolled rejects a confirmation without a pending
+	// enrollment.
+	ErrTwoFactorNotEnrolled = errors.New("two-factor enrollment not started")
+	// ErrInvalidTwoFactorCode covers wrong, expired and reused authenticator
+	// codes as well as unknown and used recovery codes.
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	// ErrInvalidLoginChallenge covers malformed, forged and expired challenge
+	// tokens as well as ones issued before a password change.
+	ErrInvalidLoginChallenge = errors.New("invalid or expired login challenge")
+)
+
+type TwoFactorService interface {
+	// Enroll stores a new authenticator secret for userID after checking
+	// auth. It is not enforced until Confirm; enrolling again before that
+	// replaces the secret.
+	Enroll(ctx context.Context, userID int, auth Reauthentication) (Model.UserModel, error)
+	// Confirm enables two-factor authentication once code proves the
+	// authenticator works, and returns the recovery codes.
+	Confirm(ctx context.Context, userID int, code string) ([]string, error)
+	// Disable turns two-factor authentication off after checking auth and
+	// a code, and drops the recovery codes. For accounts without a password
+	// the code is all it takes.
+	Disable(ctx context.Context, userID int, auth Reauthentication, code string) error
+	// RegenerateRecoveryCodes replaces every recovery code after checking
+	// auth.
+	RegenerateRecoveryCodes(ctx context.Context, userID int, auth Reauthentication) ([]string, error)
+	// CompleteLogin redeems a challenge from the password step with an
+	// authenticator or recovery code. Wrong codes count as failed logins
+	// and are throttled like wrong passwords.
+	CompleteLogin(ctx context.Context, challenge, code, clientIP string) (Model.UserModel, error)
+}
+
+func (s *userService) Enroll(ctx context.Context, userID int, auth Reauthentication) (Model.UserModel, error) {
+	var user Model.UserModel
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		var err error
+		if user, err = s.reauthenticate(ctx, tx, userID, auth); err != nil {
+			return err
+		}
+		if user.TwoFactorEnabled() {
+			return ErrTwoFactorEnabled
+		}
+
+		if user.TOTPSecret, err = Config.GenerateTOTPSecret(); err != nil {
+			return err
+		}
+		return tx.Users().Save(ctx, &user)
+	})
+	return user, err
+}
+
+func (s *userService) Confirm(ctx context.Context, userID int, code string) ([]string, error) {
+	var codes []string
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		user, err := findUser(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case user.TwoFactorEnabled():
+			return ErrTwoFactorEnabled
+		case user.TOTPSecret == "":
+			return ErrTwoFactorNotEnrolled
+		}
+
+		now := s.clock.Now()
+		step, ok := Config.ValidateTOTP(user.TOTPSecret, normalizeCode(code), now)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		user.TOTPEnabledAt = &now
+		user.TOTPLastStep = step
+		if err := tx.Users().Save(ctx, &user); err != nil {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(ctx, tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+func (s *userService) Disable(ctx context.Context, userID int, auth Reauthentication, code string) error {
+	return s.store.Transaction(ctx, func(tx Repository.Store) error {
+		auth.Code = code
+		user, err := s.reauthenticate(ctx, tx, userID, auth)
+		if err != nil {
+			return err
+		}
+		if !user.TwoFactorEnabled() {
+			return ErrTwoFactorNotEnabled
+		}
+		// Without a password, reauthenticate has already used up the code.
+		if user.Password != "" {
+			if err := s.verifySecondFactor(ctx, tx, &user, code); err != nil {
+				return err
+			}
+		}
+
+		user.TOTPSecret = ""
+		user.TOTPEnabledAt = nil
+		user.TOTPLastStep = 0
+		if err := tx.Users().Save(ctx, &user); err != nil {
+			return err
+		}
+		return tx.RecoveryCodes().ReplaceForUser(ctx, userID, nil)
+	})
+}
+
+func (s *userService) RegenerateRecoveryCodes(ctx context.Context, userID int, auth Reauthentication) ([]string, error) {
+	var codes []string
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		user, err := s.reauthenticate(ctx, tx, userID, auth)
+		if err != nil {
+			return err
+		}
+		if !user.TwoFactorEnabled() {
+			return ErrTwoFactorNotEnabled
+		}
+
+		codes, err = replaceRecoveryCodes(ctx, tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+func (s *userService) CompleteLogin(ctx context.Context, challenge, code, clientIP string) (Model.UserModel, error) {
+	userID, version, err := Config.ParseLoginChallenge(s.clock, challenge)
+	if err != nil {
+		return Model.UserModel{}, ErrInvalidLoginChallenge
+	}
+
+	user, err := challengedUser(ctx, s.store, userID, version)
+	if err != nil {
+		return Model.UserModel{}, err
+	}
+
+	// The challenge is valid for minutes; without the shared throttle it
+	// would allow guessing codes at full speed.
+	attempt, err := s.throttle.reserve(user.Email, clientIP, s.clock.Now())
+	if err != nil {
+		return Model.UserModel{}, err
+	}
+
+	err = s.store.Transaction(ctx, func(tx Repository.Store) error {
+		// Read again, so a password change or revocation made since the
+		// challenge was checked is seen.
+		if user, err = challengedUser(ctx, tx, userID, version); err != nil {
+			return err
+		}
+		return s.verifySecondFactor(ctx, tx, &user, code)
+	})
+	if !errors.Is(err, ErrInvalidTwoFactorCode) {
+		// Only wrong codes count; anything else was not the client's doing.
+		s.throttle.release(attempt)
+	}
+	if err != nil {
+		return Model.UserModel{}, err
+	}
+
+	s.throttle.succeeded(user.Email)
+	return user, nil
+}
+
+// challengedUser finds the user a login challenge was issued to, failing with
+// ErrInvalidLoginChallenge when their tokens were revoked since or two-factor
+// authentication was turned off.
+func challengedUser(ctx context.Context, store Repository.Store, userID, version int) (Model.UserModel, error) {
+	user, err := store.Users().FindByID(ctx, userID)
+	if errors.Is(err, Repository.ErrNotFound) {
+		return user, ErrInvalidLoginChallenge
+	}
+	if err != nil {
+		return user, err
+	}
+	if user.TokenVersion != version || !user.TwoFactorEnabled() {
+		return user, ErrInvalidLoginChallenge
+	}
+	return user, nil
+}
+
+// verifySecondFactor accepts either an authenticator code, at most once per
+// time step, or an unused recovery code, which it uses up. It only writes
+// the step or the code, never the rest of user.
+func (s *userService) verifySecondFactor(ctx context.Context, tx Repository.Store, user *Model.UserModel, code string) error {
+	code = normalizeCode(code)
+	now := s.clock.Now()
+
+	if len(code) == Config.TOTPDigits {
+		step, ok := Config.ValidateTOTP(user.TOTPSecret, code, now)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		advanced, err := tx.Users().AdvanceTOTPStep(ctx, user.UserId, step)
+		if err != nil {
+			return err
+		}
+		if !advanced {
+			return ErrInvalidTwoFactorCode
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	recovery, err := tx.RecoveryCodes().FindUnused(ctx, user.UserId, Config.HashSecretToken(code))
+	if errors.Is(err, Repository.ErrNotFound) {
+		return ErrInvalidTwoFactorCode
+	}
+	if err != nil {
+		return err
+	}
+	used, err := tx.RecoveryCodes().Use(ctx, recovery.ID, now)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// replaceRecoveryCodes issues a new set of recovery codes for userID,
+// voiding the old ones, and returns them in the form shown to the user.
+func replaceRecoveryCodes(ctx context.Context, tx Repository.Store, userID int) ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	models := make([]Model.RecoveryCodeModel, 0, RecoveryCodeCount)
+	for range RecoveryCodeCount {
+		// 50 random bits, written as two groups of five characters.
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw)[:10])
+
+		codes = append(codes, code[:5]+"-"+code[5:])
+		models = append(models, Model.RecoveryCodeModel{UserID: userID, CodeHash: Config.HashSecretToken(code)})
+	}
+
+	if err := tx.RecoveryCodes().ReplaceForUser(ctx, userID, models); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeCode tolerates the spaces and dashes people type into codes, and
+// either case in recovery codes.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
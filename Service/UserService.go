@@ -0,0 +1,558 @@
+package Service
+
+import (
+	"awesomeProject/Config"
+	"awesomeProject/Model"
+	"awesomeProject/Repository"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PasswordResetTTL is how long a password reset link stays valid.
+const PasswordResetTTL = 30 * time.Minute
+
+// ReauthenticationWindow is how recent a login must be to stand in for the
+// password of an account that has none.
+const ReauthenticationWindow = 10 * time.Minute
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrEmailTaken         = errors.New("email already registered")
+	ErrHasOpenLoans       = errors.New("user has books on loan")
+	ErrEmailNotVerified   = errors.New("email address not verified")
+	// ErrInvalidVerificationToken covers malformed, forged and expired
+	// verification tokens as well as ones issued for a previous address.
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	// ErrInvalidResetToken covers unknown, expired and already used password
+	// reset tokens alike.
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+	// ErrReauthenticationRequired asks the owner of an account without a
+	// password to log in again, or to send a second-factor code, before a
+	// sensitive change.
+	ErrReauthenticationRequired = errors.New("recent login required")
+)
+
+// Reauthentication is what a signed-in user offers to confirm a sensitive
+// account change. Accounts with a password must give it. Accounts without
+// one, provisioned through single sign-on, qualify with a code when they
+// have two-factor authentication or with a session started within
+// ReauthenticationWindow.
+type Reauthentication struct {
+	Password string
+	// TokenID is the jti of the token the request was made with.
+	TokenID string
+	Code    string
+}
+
+type UserService interface {
+	// Register creates an unverified account and mails it a verification
+	// link, failing with ErrEmailTaken when another account uses the email.
+	Register(ctx context.Context, user *Model.UserModel) error
+	// Authenticate returns the user owning email when password matches, and
+	// ErrInvalidCredentials otherwise, whether or not the account exists.
+	// Repeated failures from the same address or client are answered with a
+	// *ThrottledError until their backoff has passed. Accounts that have not
+	// confirmed their address get ErrEmailNotVerified.
+	Authenticate(ctx context.Context, email, password, clientIP string) (Model.UserModel, error)
+	// UnlockAccount forgets the failed logins of userID's address.
+	UnlockAccount(ctx context.Context, userID int) error
+	// VerifyEmail marks the address signed into token as verified. When it
+	// is the user's pending address, the account moves to it: reset links
+	// sent to the old address stop working and the old address is told of
+	// the change. That fails with ErrEmailTaken if another account took the
+	// address in the meantime.
+	VerifyEmail(ctx context.Context, token string) (Model.UserModel, error)
+	// ResendVerification mails a fresh link to email if it belongs to an
+	// unverified account, and silently does nothing otherwise.
+	ResendVerification(ctx context.Context, email string) error
+	// RequestPasswordReset mails a single-use reset link to email if it
+	// belongs to an account, and silently does nothing otherwise. Links sent
+	// before stop working.
+	RequestPasswordReset(ctx context.Context, email string) error
+	// ResetPassword consumes token and sets password on its account,
+	// revoking every token issued before. Following the mailed link proves
+	// control of the address, so it also verifies it.
+	ResetPassword(ctx context.Context, token, password string) error
+
+	// Profile returns userID's account together with its open loans.
+	Profile(ctx context.Context, userID int) (Model.UserModel, []Model.LoanModel, error)
+	// UpdateProfile changes the user name when userName is not nil.
+	UpdateProfile(ctx context.Context, userID int, userName *string) (Model.UserModel, error)
+	// ChangePassword replaces the password after checking auth, failing
+	// with ErrInvalidCredentials or ErrReauthenticationRequired otherwise.
+	// It revokes every token issued before; the returned user carries the
+	// new token version.
+	ChangePassword(ctx context.Context, userID int, auth Reauthentication, next string) (Model.UserModel, error)
+	// ChangeEmail records email as the pending address after checking
+	// auth, failing with ErrEmailTaken when another account uses it, and
+	// mails it a verification link. The account keeps its current address
+	// until that link is followed. Asking for the current address cancels a
+	// pending change.
+	ChangeEmail(ctx context.Context, userID int, auth Reauthentication, email string) (Model.UserModel, error)
+	// DeleteAccount removes the account after checking auth. It fails
+	// with ErrHasOpenLoans while the user still has books, so they are never
+	// left on loan to nobody; returned loans are kept for the history.
+	DeleteAccount(ctx context.Context, userID int, auth Reauthentication) error
+}
+
+type userService struct {
+	store    Repository.Store
+	clock    Config.Clock
+	mail     Config.MailSender
+	throttle *loginThrottle
+}
+
+func NewUserService(store Repository.Store, clock Config.Clock, mail Config.MailSender) UserService {
+	return newUserService(store, clock, mail)
+}
+
+func newUserService(store Repository.Store, clock Config.Clock, mail Config.MailSender) *userService {
+	return &userService{store: store, clock: clock, mail: mail, throttle: newLoginThrottle()}
+}
+
+func (s *userService) Register(ctx context.Context, user *Model.UserModel) error {
+	// Roles are granted by administrators, never chosen at sign-up.
+	user.Role = Model.RoleMember
+	user.Email = normalizeEmail(user.Email)
+	user.UserName = strings.TrimSpace(user.UserName)
+
+	hash, err := Config.HashPassword(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	user.EmailVerifiedAt = nil
+
+	err = s.store.Users().Create(ctx, user)
+	if errors.Is(err, Repository.ErrDuplicate) {
+		return ErrEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+	s.sendVerification(ctx, *user, user.Email)
+	return nil
+}
+
+func (s *userService) Authenticate(ctx context.Context, email, password, clientIP string) (Model.UserModel, error) {
+	email = normalizeEmail(email)
+	attempt, err := s.throttle.reserve(email, clientIP, s.clock.Now())
+	if err != nil {
+		return Model.UserModel{}, err
+	}
+
+	user, err := s.store.Users().FindByEmail(ctx, email)
+	if err != nil && !errors.Is(err, Repository.ErrNotFound) {
+		s.throttle.release(attempt)
+		return user, err
+	}
+
+	// Unknown addresses still pay for a bcrypt comparison, so response
+	// times do not tell them apart from wrong passwords.
+	hash := user.Password
+	if err != nil {
+		hash = dummyPasswordHash()
+	}
+	if !Config.CheckPassword(hash, password) || err != nil {
+		if attempt.lockedOut {
+			slog.WarnContext(ctx, "Login locked out after repeated failures", "email", email, "clientIp", clientIP)
+		}
+		return Model.UserModel{}, ErrInvalidCredentials
+	}
+
+	s.throttle.release(attempt)
+	// With a second factor pending, only an accepted code clears the
+	// failures; otherwise logging in again would reset the count of wrong
+	// codes.
+	if !user.TwoFactorEnabled() {
+		s.throttle.succeeded(email)
+	}
+	if !user.EmailVerified() {
+		return user, ErrEmailNotVerified
+	}
+	return user, nil
+}
+
+func (s *userService) UnlockAccount(ctx context.Context, userID int) error {
+	user, err := findUser(ctx, s.store, userID)
+	if err != nil {
+		return err
+	}
+
+	s.throttle.unlock(user.Email)
+	return nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// dummyPasswordHash is compared against when the account does not exist. It
+// is created lazily so it uses the configured PasswordHashCost.
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = Config.HashPassword("not the password of anyone")
+	})
+	return dummyHash
+}
+
+func (s *userService) VerifyEmail(ctx context.Context, token string) (Model.UserModel, error) {
+	userID, email, err := Config.ParseEmailVerificationToken(s.clock, token)
+	if err != nil {
+		return Model.UserModel{}, ErrInvalidVerificationToken
+	}
+
+	var user Model.UserModel
+	var previous string
+	err = s.store.Transaction(ctx, func(tx Repository.Store) error {
+		user, err = tx.Users().FindByID(ctx, userID)
+		if errors.Is(err, Repository.ErrNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		if err != nil {
+			return err
+		}
+
+		now := s.clock.Now()
+		switch email {
+		case user.Email:
+			if user.EmailVerified() {
+				return nil
+			}
+			user.EmailVerifiedAt = &now
+			return tx.Users().Save(ctx, &user)
+		case user.PendingEmail:
+			previous = user.Email
+			user.Email, user.PendingEmail, user.EmailVerifiedAt = email, "", &now
+			err := tx.Users().Save(ctx, &user)
+			if errors.Is(err, Repository.ErrDuplicate) {
+				return ErrEmailTaken
+			}
+			if err != nil {
+				return err
+			}
+			// Reset links went to the old address, which no longer speaks
+			// for the account.
+			return tx.PasswordResets().InvalidateForUser(ctx, user.UserId, now)
+		default:
+			return ErrInvalidVerificationToken
+		}
+	})
+	if err != nil {
+		return user, err
+	}
+
+	if previous != "" {
+		s.notifyEmailChanged(ctx, user, previous)
+	}
+	return user, nil
+}
+
+func (s *userService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.store.Users().FindByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, Repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !user.EmailVerified() {
+		s.sendVerification(ctx, user, user.Email)
+	}
+	return nil
+}
+
+func (s *userService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.store.Users().FindByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, Repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := Config.NewSecretToken()
+	if err != nil {
+		return err
+	}
+
+	now := s.clock.Now()
+	err = s.store.Transaction(ctx, func(tx Repository.Store) error {
+		if err := tx.PasswordResets().InvalidateForUser(ctx, user.UserId, now); err != nil {
+			return err
+		}
+		return tx.PasswordResets().Create(ctx, &Model.PasswordResetModel{
+			UserID:    user.UserId,
+			TokenHash: Config.HashSecretToken(token),
+			CreatedAt: now,
+			ExpiresAt: now.Add(PasswordResetTTL),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// A delivery failure is only logged: reporting it would tell the caller
+	// that the address has an account.
+	link := Config.AppURL("/password/reset", url.Values{"token": {token}})
+	err = s.mail.Send(ctx, Config.Mail{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Someone asked to reset the password of your library account. If it was you, "+
+			"choose a new password within %s using this link; it works only once:\n\n"+
+			"%s\n\n"+
+			"If you did not ask for this, ignore this message and your password stays unchanged.\n",
+			user.UserName, humanDuration(PasswordResetTTL), link),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to send password reset email", "userId", user.UserId, "error", err)
+	}
+	return nil
+}
+
+func (s *userService) ResetPassword(ctx context.Context, token, password string) error {
+	hash, err := Config.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Transaction(ctx, func(tx Repository.Store) error {
+		now := s.clock.Now()
+		reset, err := tx.PasswordResets().FindByTokenHash(ctx, Config.HashSecretToken(token))
+		if errors.Is(err, Repository.ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+		if reset.UsedAt != nil || !now.Before(reset.ExpiresAt) {
+			return ErrInvalidResetToken
+		}
+
+		user, err := tx.Users().FindByID(ctx, reset.UserID)
+		if errors.Is(err, Repository.ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+
+		user.Password = hash
+		user.TokenVersion++
+		if !user.EmailVerified() {
+			user.EmailVerifiedAt = &now
+		}
+		if err := tx.Users().Save(ctx, &user); err != nil {
+			return err
+		}
+		return tx.PasswordResets().InvalidateForUser(ctx, user.UserId, now)
+	})
+}
+
+// sendVerification mails user a link to verify email, their current or
+// pending address. Failures are only logged: the account exists either way
+// and the link can be resent.
+func (s *userService) sendVerification(ctx context.Context, user Model.UserModel, email string) {
+	token, err := Config.GenerateEmailVerificationToken(s.clock, user.UserId, email)
+	if err == nil {
+		link := Config.AppURL("/verify-email", url.Values{"token": {token}})
+		err = s.mail.Send(ctx, Config.Mail{
+			To:      email,
+			Subject: "Verify your email address",
+			Body: fmt.Sprintf("Hello %s,\n\n"+
+				"Please confirm that %s is your address by opening this link within %s:\n\n"+
+				"%s\n\n"+
+				"If you did not sign up for the library or ask to change your address, you can ignore this message.\n",
+				user.UserName, email, humanDuration(Config.EmailVerificationTTL), link),
+		})
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to send verification email", "userId", user.UserId, "error", err)
+	}
+}
+
+// notifyEmailChanged tells the previous address that the account moved away
+// from it, so a takeover does not go unnoticed. Failures are only logged.
+func (s *userService) notifyEmailChanged(ctx context.Context, user Model.UserModel, previous string) {
+	err := s.mail.Send(ctx, Config.Mail{
+		To:      previous,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"The email address of your library account was changed from %s to %s.\n\n"+
+			"If you did not make this change, contact the library right away.\n",
+			user.UserName, previous, user.Email),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to send email change notice", "userId", user.UserId, "error", err)
+	}
+}
+
+func (s *userService) Profile(ctx context.Context, userID int) (Model.UserModel, []Model.LoanModel, error) {
+	user, err := findUser(ctx, s.store, userID)
+	if err != nil {
+		return user, nil, err
+	}
+
+	loans, err := s.store.Loans().FindOpenByUser(ctx, userID)
+	return user, loans, err
+}
+
+func (s *userService) UpdateProfile(ctx context.Context, userID int, userName *string) (Model.UserModel, error) {
+	var user Model.UserModel
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		var err error
+		if user, err = findUser(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		if userName != nil {
+			user.UserName = strings.TrimSpace(*userName)
+		}
+		return tx.Users().Save(ctx, &user)
+	})
+	return user, err
+}
+
+func (s *userService) ChangePassword(ctx context.Context, userID int, auth Reauthentication, next string) (Model.UserModel, error) {
+	var user Model.UserModel
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		var err error
+		if user, err = s.reauthenticate(ctx, tx, userID, auth); err != nil {
+			return err
+		}
+
+		if user.Password, err = Config.HashPassword(next); err != nil {
+			return err
+		}
+		user.TokenVersion++
+		return tx.Users().Save(ctx, &user)
+	})
+	return user, err
+}
+
+func (s *userService) ChangeEmail(ctx context.Context, userID int, auth Reauthentication, email string) (Model.UserModel, error) {
+	var user Model.UserModel
+	err := s.store.Transaction(ctx, func(tx Repository.Store) error {
+		var err error
+		if user, err = s.reauthenticate(ctx, tx, userID, auth); err != nil {
+			return err
+		}
+
+		email = normalizeEmail(email)
+		if email == user.Email {
+			user.PendingEmail = ""
+			return tx.Users().Save(ctx, &user)
+		}
+		_, err = tx.Users().FindByEmail(ctx, email)
+		if err == nil {
+			return ErrEmailTaken
+		}
+		if !errors.Is(err, Repository.ErrNotFound) {
+			return err
+		}
+
+		user.PendingEmail = email
+		return tx.Users().Save(ctx, &user)
+	})
+	if err != nil {
+		return user, err
+	}
+
+	if user.PendingEmail != "" {
+		s.sendVerification(ctx, user, user.PendingEmail)
+	}
+	return user, nil
+}
+
+func (s *userService) DeleteAccount(ctx context.Context, userID int, auth Reauthentication) error {
+	return s.store.Transaction(ctx, func(tx Repository.Store) error {
+		if _, err := s.reauthenticate(ctx, tx, userID, auth); err != nil {
+			return err
+		}
+
+		loans, err := tx.Loans().FindOpenByUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(loans) > 0 {
+			return ErrHasOpenLoans
+		}
+		if err := tx.RecoveryCodes().ReplaceForUser(ctx, userID, nil); err != nil {
+			return err
+		}
+		if err := tx.ExternalIdentities().DeleteForUser(ctx, userID); err != nil {
+			return err
+		}
+		if err := tx.Sessions().DeleteForUser(ctx, userID); err != nil {
+			return err
+		}
+		return tx.Users().Delete(ctx, userID)
+	})
+}
+
+func findUser(ctx context.Context, store Repository.Store, userID int) (Model.UserModel, error) {
+	user, err := store.Users().FindByID(ctx, userID)
+	if errors.Is(err, Repository.ErrNotFound) {
+		return user, ErrUserNotFound
+	}
+	return user, err
+}
+
+// reauthenticate guards sensitive account changes: it finds userID and
+// checks auth as described at Reauthentication.
+func (s *userService) reauthenticate(ctx context.Context, tx Repository.Store, userID int, auth Reauthentication) (Model.UserModel, error) {
+	user, err := findUser(ctx, tx, userID)
+	if err != nil {
+		return user, err
+	}
+	if user.Password != "" {
+		if !Config.CheckPassword(user.Password, auth.Password) {
+			return user, ErrInvalidCredentials
+		}
+		return user, nil
+	}
+
+	if auth.Code != "" && user.TwoFactorEnabled() {
+		return user, s.verifySecondFactor(ctx, tx, &user, auth.Code)
+	}
+	session, err := tx.Sessions().FindByTokenID(ctx, auth.TokenID)
+	if errors.Is(err, Repository.ErrNotFound) {
+		return user, ErrReauthenticationRequired
+	}
+	if err != nil {
+		return user, err
+	}
+	if session.UserID != userID || s.clock.Now().Sub(session.CreatedAt) > ReauthenticationWindow {
+		return user, ErrReauthenticationRequired
+	}
+	return user, nil
+}
+
+// humanDuration spells out whole hours and minutes for mail texts, such as
+// "24 hours" or "30 minutes".
+func humanDuration(d time.Duration) string {
+	count, unit := int(d/time.Minute), "minute"
+	if d >= time.Hour && d%time.Hour == 0 {
+		count, unit = int(d/time.Hour), "hour"
+	}
+	if count != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", count, unit)
+}
+
+// normalizeEmail makes addresses compare case-insensitively, as mail
+// providers treat them.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
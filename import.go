@@ -0,0 +1,100 @@
+package main
+
+import (
+	"awesomeProject/Model"
+	"awesomeProject/Service"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// mappingFlag collects repeated -map column=field flags.
+type mappingFlag map[string]string
+
+func (m mappingFlag) String() string {
+	return fmt.Sprint(map[string]string(m))
+}
+
+func (m mappingFlag) Set(value string) error {
+	column, field, ok := strings.Cut(value, "=")
+	if !ok {
+		return errors.New(`want column=field, such as "Book Title=title"`)
+	}
+	m[column] = field
+	return nil
+}
+
+// runImport implements the import command: it imports one CSV, JSON Lines or
+// MARC file into the database at DATABASE_PATH, waits for the job and prints its
+// report. It returns the process exit code.
+func runImport(ctx context.Context, imports Service.ImportService, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "", "csv, jsonl, marc or marcxml; defaults to the file's extension")
+	dryRun := flags.Bool("dry-run", false, "validate and count without saving")
+	mapping := mappingFlag{}
+	flags.Var(mapping, "map", "map a column to title, author, description or isbn, as column=field; repeatable")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: awesomeProject import [-format csv|jsonl|marc|marcxml] [-dry-run] [-map column=field]... FILE")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	name := flags.Arg(0)
+	if *format == "" {
+		*format = Service.ImportFormat(name)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	file, err := imports.Parse(*format, data, mapping)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	file.DryRun = *dryRun
+
+	job, err := imports.Start(ctx, file)
+	if err == nil {
+		err = imports.Wait(ctx)
+	}
+	var problems []Model.ImportErrorModel
+	if err == nil {
+		job, problems, err = imports.Find(ctx, job.ID)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	for _, problem := range problems {
+		if problem.Field == "" {
+			fmt.Fprintf(stdout, "%s:%d: %s\n", name, problem.Row, problem.Message)
+		} else {
+			fmt.Fprintf(stdout, "%s:%d: %s %s\n", name, problem.Row, problem.Field, problem.Message)
+		}
+	}
+	verb := "imported"
+	if job.DryRun {
+		verb = "would import"
+	}
+	fmt.Fprintf(stdout, "%d rows: %s %d new and %d updated books, %d rows rejected\n", job.Rows, verb, job.Created, job.Updated, job.Failed)
+	if job.Status != Model.ImportSucceeded {
+		fmt.Fprintln(stderr, job.Error)
+		return 1
+	}
+	return 0
+}
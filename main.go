@@ -3,17 +3,98 @@ package main
 import (
 	"awesomeProject/Config"
 	"awesomeProject/Controller"
+	"awesomeProject/Repository"
+	"awesomeProject/Service"
+	"context"
+	"errors"
 	"github.com/labstack/echo/v4"
-	"log"
+	"gorm.io/gorm"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	db := Config.InitializeDatabase()
+	slog.SetDefault(Config.NewLogger())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := Config.InitTracing(ctx)
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+
+	clock := Config.NewOffsetClock(Config.SystemClock{})
+	importing := len(os.Args) > 1 && os.Args[1] == "import"
+	var db *gorm.DB
+	if importing {
+		db = Config.OpenImportDatabase()
+	} else {
+		db = Config.InitializeDatabase(clock)
+	}
 	e := echo.New()
+	e.HideBanner = true
 
-	Controller.Router(e, db)
+	mail, err := Config.NewMailSender()
+	if err != nil {
+		slog.Error("Failed to configure mail", "error", err)
+		os.Exit(1)
+	}
+
+	blobs, err := Config.NewBlobStore()
+	if err != nil {
+		slog.Error("Failed to configure blob store", "error", err)
+		os.Exit(1)
+	}
+
+	store := Repository.NewGormStore(db)
+	services := Service.New(store, clock, mail, blobs)
+	// Books catalogued before authors were tracked only name them in free
+	// text; credit those authors properly.
+	if _, err := services.Authors.ExtractFromBooks(ctx); err != nil {
+		slog.Error("Failed to extract authors", "error", err)
+		os.Exit(1)
+	}
 
-	if err := e.Start(":8080"); err != nil {
-		log.Fatal("Error starting the server: ", err)
+	if importing {
+		os.Exit(runImport(ctx, services.Imports, os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	oidc, enabled, err := Config.OIDCSettingsFromEnv()
+	if err != nil {
+		slog.Error("Failed to configure single sign-on", "error", err)
+		os.Exit(1)
+	}
+	if enabled {
+		slog.Info("Single sign-on enabled", "issuer", oidc.Issuer)
+		provider := Config.NewOIDCProvider(oidc, &http.Client{Timeout: 10 * time.Second})
+		services.SingleSignOn = Service.NewSingleSignOnService(store, clock, provider)
+	}
+	Controller.Router(e, services)
+
+	go func() {
+		if err := e.Start(":8080"); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Error starting the server", "error", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error stopping the server", "error", err)
+	}
+	if err := services.Imports.Wait(shutdownCtx); err != nil {
+		slog.Error("Imports still running at shutdown", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Error flushing traces", "error", err)
 	}
 }